@@ -4,8 +4,10 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json" // ✅ Added for health check JSON encoding
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,13 +19,16 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
-	"github.com/iyunix/go-internist/internal/ratelimit"
 	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/ratelimit"
 
 	"github.com/iyunix/go-internist/internal/config"
 	"github.com/iyunix/go-internist/internal/domain"
 	"github.com/iyunix/go-internist/internal/handlers"
-	"github.com/iyunix/go-internist/internal/services"
+	"github.com/iyunix/go-internist/internal/health"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/repository/audit"
+	"github.com/iyunix/go-internist/internal/repository/message"
 )
 
 //go:generate wire
@@ -119,73 +124,82 @@ func staticFileMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func healthCheckHandler(app *Application, sqlDB *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-		defer cancel()
-
-		health := map[string]interface{}{
-			"service":   "go_internist",
-			"timestamp": time.Now().UTC().Format(time.RFC3339),
-			"status":    "healthy",
-			"checks":    make(map[string]interface{}),
-		}
+// newHealthChecker registers dependency probes. Database, vector store and the
+// AI models are critical; translation and SMS only degrade the service.
+func newHealthChecker(app *Application, sqlDB *sql.DB, cfg *config.Config) *health.Checker {
+	probeTTL := time.Duration(cfg.HealthProbeTTLSeconds) * time.Second
+	checker := health.NewChecker("go_internist")
+
+	checker.Register(health.Check{
+		Name:     "database",
+		Critical: true,
+		TTL:      5 * time.Second,
+		Probe:    sqlDB.PingContext,
+	})
+	checker.Register(health.Check{
+		Name:     "vector_store",
+		Critical: true,
+		TTL:      15 * time.Second,
+		Probe:    app.PineconeService.HealthCheck,
+	})
+	checker.Register(health.Check{
+		Name:     "embedding_model",
+		Critical: true,
+		TTL:      probeTTL,
+		Probe:    app.AIService.CheckEmbedding,
+	})
+	checker.Register(health.Check{
+		Name:     "completion_model",
+		Critical: true,
+		TTL:      probeTTL,
+		Probe:    app.AIService.CheckCompletion,
+	})
+	checker.Register(health.Check{
+		Name:     "translation",
+		TTL:      probeTTL,
+		Disabled: !cfg.IsTranslationEnabled(),
+		Probe:    app.TranslationService.HealthCheck,
+	})
+	checker.Register(health.Check{
+		Name:  "sms_provider",
+		TTL:   probeTTL,
+		Probe: app.SMSService.HealthCheck,
+	})
 
-		allHealthy := true
+	return checker
+}
 
-		// Database Health Check
-		if err := sqlDB.PingContext(ctx); err != nil {
-			health["checks"].(map[string]interface{})["database"] = map[string]interface{}{
-				"status": "unhealthy",
-				"error":  err.Error(),
-			}
-			allHealthy = false
-		} else {
-			health["checks"].(map[string]interface{})["database"] = map[string]interface{}{
-				"status": "healthy",
-			}
-		}
+// livenessHandler only reports that the process is serving HTTP; it never
+// touches dependencies so orchestrators don't restart us for upstream outages.
+func livenessHandler(startTime time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":    "alive",
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"uptime":    time.Since(startTime).Round(time.Second).String(),
+		})
+	}
+}
 
-		aiStatus := app.AIService.GetProviderStatus()
-		if !aiStatus.IsHealthy {
-			health["checks"].(map[string]interface{})["ai_provider"] = map[string]interface{}{
-				"status":           "unhealthy",
-				"message":          aiStatus.Message,
-				"embedding_healthy": aiStatus.EmbeddingHealthy,
-				"llm_healthy":      aiStatus.LLMHealthy,
-			}
-			allHealthy = false
-		} else {
-			health["checks"].(map[string]interface{})["ai_provider"] = map[string]interface{}{
-				"status":           "healthy",
-				"embedding_healthy": aiStatus.EmbeddingHealthy,
-				"llm_healthy":      aiStatus.LLMHealthy,
-			}
-		}
+// readinessHandler runs the (cached) dependency probes. Degraded still
+// returns 200 so traffic keeps flowing when only optional services are down.
+func readinessHandler(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
 
-		// Pinecone Health Check (lightweight)
-		if err := app.PineconeService.HealthCheck(ctx); err != nil {
-			health["checks"].(map[string]interface{})["pinecone"] = map[string]interface{}{
-				"status": "unhealthy",
-				"error":  err.Error(),
-			}
-			allHealthy = false
-		} else {
-			health["checks"].(map[string]interface{})["pinecone"] = map[string]interface{}{
-				"status": "healthy",
-			}
-		}
+		report := checker.Run(ctx)
 
-		// Set overall status
-		if !allHealthy {
-			health["status"] = "unhealthy"
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status == health.StatusUnhealthy {
 			w.WriteHeader(http.StatusServiceUnavailable)
 		} else {
 			w.WriteHeader(http.StatusOK)
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(health)
+		json.NewEncoder(w).Encode(report)
 	}
 }
 
@@ -208,13 +222,18 @@ func setupStaticFiles(r *mux.Router, cfg *config.Config) {
 }
 
 // ✅ FIXED: Accept sqlDB parameter
-func setupPublicRoutes(r *mux.Router, app *Application, sqlDB *sql.DB, loginLimiter, registrationLimiter *ratelimit.MemoryRateLimiter) {
-	// Enhanced health check endpoint
-	r.HandleFunc("/health", healthCheckHandler(app, sqlDB)).Methods("GET") // ✅ sqlDB now available
+func setupPublicRoutes(r *mux.Router, app *Application, checker *health.Checker, startTime time.Time, loginLimiter, registrationLimiter *ratelimit.MemoryRateLimiter) {
+	// Liveness and readiness probes; /health is kept as an alias of /readyz
+	r.HandleFunc("/livez", livenessHandler(startTime)).Methods("GET")
+	r.HandleFunc("/readyz", readinessHandler(checker)).Methods("GET")
+	r.HandleFunc("/health", readinessHandler(checker)).Methods("GET")
 
 	// Frontend logging
 	r.HandleFunc("/api/log", handlers.LogFrontendEvent).Methods("POST")
 
+	// SMS provider delivery reports; authenticated by the token in the URL
+	r.HandleFunc("/api/sms/delivery/{provider}", app.SMSDeliveryHandler.DeliveryReport).Methods("POST")
+
 	// Public routes — PAGES
 	r.HandleFunc("/", app.PageHandler.ShowIndexPage).Methods("GET")
 	r.HandleFunc("/login", app.PageHandler.ShowLoginPage).Methods("GET")
@@ -222,6 +241,7 @@ func setupPublicRoutes(r *mux.Router, app *Application, sqlDB *sql.DB, loginLimi
 	r.HandleFunc("/verify-sms", app.PageHandler.ShowVerifySMSPage).Methods("GET")
 	r.HandleFunc("/forgot-password", app.PageHandler.ShowForgotPasswordPage).Methods("GET")
 	r.HandleFunc("/reset-password", app.PageHandler.ShowResetPasswordPage).Methods("GET")
+	r.HandleFunc("/s/{token:[A-Za-z0-9_-]+}", app.PageHandler.ShowSharedChatPage).Methods("GET")
 
 	// RATE-LIMITED AUTH ENDPOINTS — ACTIONS
 	r.Handle("/login",
@@ -276,34 +296,91 @@ func setupProtectedRoutes(r *mux.Router, app *Application, authMW mux.Middleware
 	// Protected routes
 	protected := r.PathPrefix("/").Subrouter()
 	protected.Use(authMW)
+	// Read-only "view as user" sessions started from the admin panel
+	protected.Use(middleware.Impersonation(app.AuthService, app.UserService, app.UserRepo, app.AdminService))
 	protected.HandleFunc("/chat", app.PageHandler.ShowChatPage).Methods("GET")
 
 	// API routes
 	api := protected.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/user/balance", app.AuthHandler.GetUserCreditHandler).Methods("GET")
+	api.HandleFunc("/user/preferences", app.AuthHandler.GetPreferencesHandler).Methods("GET")
+	api.HandleFunc("/user/preferences", app.AuthHandler.UpdatePreferencesHandler).Methods("PUT")
 	api.HandleFunc("/chats", app.ChatHandler.GetUserChats).Methods("GET")
 	api.HandleFunc("/chats", app.ChatHandler.CreateChat).Methods("POST")
+	api.HandleFunc("/chats/export", app.ChatHandler.ExportAllChats).Methods("GET")
+	api.HandleFunc("/chats/bulk", app.ChatHandler.BulkUpdateChats).Methods("POST")
+	api.HandleFunc("/chats/{id:[0-9]+}/pin", app.ChatHandler.PinChat).Methods("POST", "DELETE")
+	api.HandleFunc("/chats/{id:[0-9]+}/archive", app.ChatHandler.ArchiveChat).Methods("POST", "DELETE")
+	api.HandleFunc("/chats/{id:[0-9]+}/folder", app.ChatHandler.MoveChat).Methods("PUT")
+	api.HandleFunc("/chats/{id:[0-9]+}/tags", app.ChatHandler.SetChatTags).Methods("PUT")
+	api.HandleFunc("/tags", app.ChatHandler.ListTags).Methods("GET")
+	api.HandleFunc("/folders", app.ChatHandler.ListFolders).Methods("GET")
+	api.HandleFunc("/folders", app.ChatHandler.CreateFolder).Methods("POST")
+	api.HandleFunc("/folders/{folderId:[0-9]+}", app.ChatHandler.RenameFolder).Methods("PATCH")
+	api.HandleFunc("/folders/{folderId:[0-9]+}", app.ChatHandler.DeleteFolder).Methods("DELETE")
 	api.HandleFunc("/chats/{id:[0-9]+}/messages", app.ChatHandler.GetChatMessages).Methods("GET")
 	api.HandleFunc("/chats/{id:[0-9]+}/messages", app.ChatHandler.SendMessage).Methods("POST")
+	api.HandleFunc("/chats/{id:[0-9]+}/export", app.ChatHandler.ExportChat).Methods("GET")
+	api.HandleFunc("/chats/{id:[0-9]+}/shares", app.ChatHandler.ListShares).Methods("GET")
+	api.HandleFunc("/chats/{id:[0-9]+}/shares", app.ChatHandler.CreateShare).Methods("POST")
+	api.HandleFunc("/chats/{id:[0-9]+}/shares/{shareId:[0-9]+}", app.ChatHandler.RevokeShare).Methods("DELETE")
+	api.HandleFunc("/chats/{id:[0-9]+}/messages/{messageId:[0-9]+}", app.ChatHandler.EditMessage).Methods("PUT")
+	api.HandleFunc("/chats/{id:[0-9]+}/messages/{messageId:[0-9]+}/select", app.ChatHandler.SelectMessageVersion).Methods("POST")
+	api.HandleFunc("/chats/{id:[0-9]+}/messages/{messageId:[0-9]+}/regenerate", app.ChatHandler.RegenerateMessage).Methods("POST")
+	api.HandleFunc("/chats/{id:[0-9]+}/messages/{messageId:[0-9]+}/feedback", app.ChatHandler.RateAnswer).Methods("PUT")
+	api.HandleFunc("/chats/{id:[0-9]+}/messages/{messageId:[0-9]+}/feedback", app.ChatHandler.RemoveAnswerRating).Methods("DELETE")
+	api.HandleFunc("/chats/{id:[0-9]+}/feedback", app.ChatHandler.GetChatFeedback).Methods("GET")
+	api.HandleFunc("/models", app.ChatHandler.GetRegenerateModels).Methods("GET")
+	api.HandleFunc("/search", app.ChatHandler.SearchConversations).Methods("GET")
 	api.HandleFunc("/chats/{id:[0-9]+}", app.ChatHandler.DeleteChat).Methods("DELETE")
+	api.HandleFunc("/chats/{id:[0-9]+}", app.ChatHandler.RenameChat).Methods("PATCH")
+	api.HandleFunc("/chats/{id:[0-9]+}/ask", app.ChatHandler.AskQuestion).Methods("POST")
+	api.HandleFunc("/chats/{id:[0-9]+}/jobs/{jobId:[0-9]+}", app.ChatHandler.GetGenerationJob).Methods("GET")
+	api.HandleFunc("/chats/{id:[0-9]+}/jobs/{jobId:[0-9]+}/cancel", app.ChatHandler.CancelGenerationJob).Methods("POST")
 	api.HandleFunc("/chats/{id:[0-9]+}/stream", app.ChatHandler.StreamChatSSE).Methods("GET")
+	api.HandleFunc("/chats/{id:[0-9]+}/ws", app.ChatHandler.ChatWebSocket).Methods("GET")
 }
 
-func setupAdminRoutes(r *mux.Router, app *Application, authMW, adminMW mux.MiddlewareFunc) {
+func setupAdminRoutes(r *mux.Router, app *Application, authMW mux.MiddlewareFunc) {
+	// Each admin route checks one permission of the caller's role (see domain.RolePermissions)
+	requires := func(permission string, h http.HandlerFunc) http.Handler {
+		return middleware.RequirePermission(app.UserRepo, permission)(h)
+	}
+
 	// Admin routes
 	adminPage := r.PathPrefix("/admin").Subrouter()
 	adminPage.Use(authMW)
-	adminPage.Use(adminMW)
-	adminPage.HandleFunc("", app.PageHandler.ShowAdminPage).Methods("GET")
+	adminPage.Handle("", requires(domain.PermUsersRead, app.PageHandler.ShowAdminPage)).Methods("GET")
+	adminPage.Handle("/impersonation/stop", middleware.RequireAdmin(app.UserRepo)(http.HandlerFunc(app.AdminHandler.StopImpersonationHandler))).Methods("POST")
 
 	adminAPI := r.PathPrefix("/api/admin").Subrouter()
 	adminAPI.Use(authMW)
-	adminAPI.Use(adminMW)
-	adminAPI.HandleFunc("/users", app.AdminHandler.GetAllUsersHandler).Methods("GET")
-	adminAPI.HandleFunc("/users/export", app.AdminHandler.ExportUsersCSVHandler).Methods("GET")
-	adminAPI.HandleFunc("/users/plan", app.AdminHandler.ChangePlanHandler).Methods("POST")
-	adminAPI.HandleFunc("/users/renew", app.AdminHandler.RenewSubscriptionHandler).Methods("POST")
-	adminAPI.HandleFunc("/users/topup", app.AdminHandler.TopUpBalanceHandler).Methods("POST")
+	adminAPI.Handle("/roles", middleware.RequireAdmin(app.UserRepo)(http.HandlerFunc(app.AdminHandler.GetRolesHandler))).Methods("GET")
+	adminAPI.Handle("/users", requires(domain.PermUsersRead, app.AdminHandler.GetAllUsersHandler)).Methods("GET")
+	adminAPI.Handle("/users/export", requires(domain.PermUsersExport, app.AdminHandler.ExportUsersHandler)).Methods("GET")
+	adminAPI.Handle("/users/export/usage", requires(domain.PermUsersExport, app.AdminHandler.ExportChatUsageHandler)).Methods("GET")
+	adminAPI.Handle("/credits/export", requires(domain.PermUsersExport, app.AdminHandler.ExportCreditTransactionsHandler)).Methods("GET")
+	adminAPI.Handle("/users/import", requires(domain.PermUsersImport, app.AdminHandler.ImportUsersHandler)).Methods("POST")
+	adminAPI.Handle("/users/plan", requires(domain.PermPlanChange, app.AdminHandler.ChangePlanHandler)).Methods("POST")
+	adminAPI.Handle("/users/renew", requires(domain.PermPlanChange, app.AdminHandler.RenewSubscriptionHandler)).Methods("POST")
+	adminAPI.Handle("/users/topup", requires(domain.PermBalanceTopUp, app.AdminHandler.TopUpBalanceHandler)).Methods("POST")
+	adminAPI.Handle("/users/{id:[0-9]+}/role", requires(domain.PermRolesManage, app.AdminHandler.AssignRoleHandler)).Methods("PUT")
+	adminAPI.Handle("/users/{id:[0-9]+}/suspend", requires(domain.PermUsersManage, app.AdminHandler.SuspendUserHandler)).Methods("POST")
+	adminAPI.Handle("/users/{id:[0-9]+}/reactivate", requires(domain.PermUsersManage, app.AdminHandler.ReactivateUserHandler)).Methods("POST")
+	adminAPI.Handle("/users/{id:[0-9]+}/unlock", requires(domain.PermUsersManage, app.AdminHandler.UnlockUserHandler)).Methods("POST")
+	adminAPI.Handle("/users/{id:[0-9]+}/force-password-reset", requires(domain.PermUsersManage, app.AdminHandler.ForcePasswordResetHandler)).Methods("POST")
+	adminAPI.Handle("/users/{id:[0-9]+}", requires(domain.PermUsersDelete, app.AdminHandler.DeleteUserHandler)).Methods("DELETE")
+	adminAPI.Handle("/users/{id:[0-9]+}/restore", requires(domain.PermUsersDelete, app.AdminHandler.RestoreUserHandler)).Methods("POST")
+	adminAPI.Handle("/users/{id:[0-9]+}/impersonate", requires(domain.PermUsersImpersonate, app.AdminHandler.ImpersonateUserHandler)).Methods("POST")
+	adminAPI.Handle("/feedback", requires(domain.PermFeedbackRead, app.AdminHandler.GetFeedbackReviewQueueHandler)).Methods("GET")
+	adminAPI.Handle("/feedback/{id:[0-9]+}", requires(domain.PermFeedbackReview, app.AdminHandler.ReviewFeedbackHandler)).Methods("PATCH")
+	adminAPI.Handle("/sms", requires(domain.PermSMSRead, app.AdminHandler.GetSMSOutboxHandler)).Methods("GET")
+	adminAPI.Handle("/sms/phones", requires(domain.PermSMSRead, app.AdminHandler.GetSMSPhoneSummaryHandler)).Methods("GET")
+	adminAPI.Handle("/audit", requires(domain.PermAuditRead, app.AdminHandler.GetAuditEventsHandler)).Methods("GET")
+	adminAPI.Handle("/audit/export", requires(domain.PermAuditRead, app.AdminHandler.ExportAuditCSVHandler)).Methods("GET")
+	adminAPI.Handle("/analytics", requires(domain.PermAnalyticsRead, app.AdminHandler.GetAnalyticsHandler)).Methods("GET")
+	adminAPI.Handle("/analytics/summary", requires(domain.PermAnalyticsRead, app.AdminHandler.GetAnalyticsSummaryHandler)).Methods("GET")
+	adminAPI.Handle("/analytics/{series}", requires(domain.PermAnalyticsRead, app.AdminHandler.GetAnalyticsSeriesHandler)).Methods("GET")
 }
 
 func setupErrorHandlers(r *mux.Router, pageHandler *handlers.PageHandler) {
@@ -345,18 +422,35 @@ func configureDatabaseConnection(db *gorm.DB) (*sql.DB, error) {
 	return sqlDB, nil
 }
 
-func runDatabaseMigrations(db *gorm.DB, logger services.Logger) error {
+func runDatabaseMigrations(db *gorm.DB, logger logging.Logger) error {
 	logger.Info("running database migrations")
-	if err := db.AutoMigrate(&domain.User{}, &domain.Chat{}, &domain.Message{}, &domain.VerificationCode{}); err != nil {
+	// Messages predating branching need their parent links filled in, exactly once
+	backfillParents := !db.Migrator().HasColumn(&domain.Message{}, "ParentID")
+	if err := db.AutoMigrate(&domain.User{}, &domain.Chat{}, &domain.Message{}, &domain.VerificationCode{}, &domain.GenerationJob{}, &domain.ChatSummary{}, &domain.ChatShare{}, &domain.ChatFolder{}, &domain.MessageFeedback{}, &domain.SMSMessage{}, &domain.VerificationSend{}, &domain.AuditEvent{}); err != nil {
 		logger.Error("database migration failed", "error", err,
-			"tables", []string{"users", "chats", "messages", "verification_codes"})
+			"tables", []string{"users", "chats", "messages", "verification_codes", "generation_jobs", "chat_summaries", "chat_shares", "chat_folders", "message_feedback", "sms_messages", "verification_sends", "audit_events"})
+		return err
+	}
+	if backfillParents {
+		if err := message.BackfillParents(context.Background(), db); err != nil {
+			logger.Error("message parent backfill failed", "error", err)
+			return err
+		}
+		logger.Info("linked existing messages into conversation branches")
+	}
+	if err := message.EnsureSearchIndexes(context.Background(), db); err != nil {
+		logger.Error("search index migration failed", "error", err)
+		return err
+	}
+	if err := audit.EnsureAppendOnly(context.Background(), db); err != nil {
+		logger.Error("audit log trigger migration failed", "error", err)
 		return err
 	}
 	logger.Info("database migrations completed successfully")
 	return nil
 }
 
-func startServer(srv *http.Server, logger services.Logger) {
+func startServer(srv *http.Server, logger logging.Logger) {
 	go func() {
 		logger.Info("HTTP server starting", "address", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -367,7 +461,9 @@ func startServer(srv *http.Server, logger services.Logger) {
 	}()
 }
 
-func gracefulShutdown(srv *http.Server, sqlDB *sql.DB, logger services.Logger, startTime time.Time) {
+// gracefulShutdown drains generation jobs before stopping HTTP so answers that
+// are mid-stream get persisted, then releases open SSE connections.
+func gracefulShutdown(srv *http.Server, app *Application, releaseConns context.CancelFunc, sqlDB *sql.DB, logger logging.Logger, startTime time.Time) {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	receivedSignal := <-stop
@@ -380,10 +476,37 @@ func gracefulShutdown(srv *http.Server, sqlDB *sql.DB, logger services.Logger, s
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
+	// Stop taking new jobs and let queued/running ones finish
+	drainCtx, drainCancel := context.WithTimeout(ctx, 10*time.Second)
+	if err := app.GenerationService.Shutdown(drainCtx); err != nil {
+		logger.Warn("generation jobs did not drain in time", "error", err)
+	} else {
+		logger.Info("generation jobs drained")
+	}
+	drainCancel()
+
+	// Stop sending SMS; anything unsent stays in the outbox for the next start
+	smsCtx, smsCancel := context.WithTimeout(ctx, 3*time.Second)
+	if err := app.SMSService.Shutdown(smsCtx); err != nil {
+		logger.Warn("SMS dispatcher did not stop in time", "error", err)
+	}
+	smsCancel()
+
+	verificationCtx, verificationCancel := context.WithTimeout(ctx, 2*time.Second)
+	if err := app.VerificationService.Shutdown(verificationCtx); err != nil {
+		logger.Warn("verification cleanup did not stop in time", "error", err)
+	}
+	verificationCancel()
+
+	// SSE handlers block until the client goes away; cancel their contexts
+	// so srv.Shutdown doesn't wait on them
+	releaseConns()
+
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Error("server forced shutdown", "error", err, "timeout", "15s")
 		os.Exit(1)
 	}
+	app.StreamHub.Close()
 
 	// Close database connections
 	if sqlDB != nil {
@@ -407,109 +530,137 @@ func setupRateLimiters() (*ratelimit.MemoryRateLimiter, *ratelimit.MemoryRateLim
 }
 
 func main() {
-    startTime := time.Now()
-
-    // Initialize logger first (still manual since it's used everywhere)
-    logger := services.NewLogger("go_internist")
-    logger.Info("🤖 Internist AI - Medical Chat Assistant starting")
-
-    // Load and validate config in one clean step
-    cfg, err := config.New()
-    if err != nil {
-        logger.Error("FATAL: Configuration error", "error", err)
-        os.Exit(1)
-    }
-    logger.Info("configuration loaded successfully", "environment", cfg.Environment)
-
-    // Database Connection
-    logger.Info("initializing PostgreSQL database connection")
-    db, err := gorm.Open(postgres.Open(cfg.GetDatabaseDSN()), &gorm.Config{
-        NowFunc: func() time.Time {
-            return time.Now().UTC()
-        },
-    })
-    if err != nil {
-        logger.Error("PostgreSQL connection failed", "error", err,
-            "host", cfg.DBHost, "port", cfg.DBPort, "database", cfg.DBName)
-        os.Exit(1)
-    }
-
-    // Configure connection pool
-    sqlDB, err := configureDatabaseConnection(db)
-    if err != nil {
-        logger.Error("failed to configure database connection", "error", err)
-        os.Exit(1)
-    }
-
-    logger.Info("PostgreSQL connected successfully",
-        "host", cfg.DBHost, "port", cfg.DBPort, "database", cfg.DBName,
-        "max_idle_conns", 10, "max_open_conns", 100)
-
-    // ---- Run GORM migrations on every startup ----
-    if err := runDatabaseMigrations(db, logger); err != nil {
-        logger.Error("database migration failed", "error", err)
-        os.Exit(1)
-    }
-    logger.Info("database migrations completed successfully")
-    // ---- End migration block ----
-
-    // 🎯 WIRE MAGIC - Replace 50+ lines of manual DI with this single call!
-    logger.Info("initializing application with Wire dependency injection")
-    app, err := InitializeApplication(cfg, logger, db)
-    if err != nil {
-        logger.Error("application initialization failed", "error", err)
-        os.Exit(1)
-    }
-    logger.Info("🚀 application initialized successfully via Wire DI")
-
-    // 🛡️ SETUP RATE LIMITERS — for login, register, SMS, reset
-    loginLimiter, registrationLimiter := setupRateLimiters()
-    logger.Info("🛡️ rate limiters initialized for auth endpoints")
-
-    // Router setup
-    logger.Info("configuring HTTP router and middleware")
-    r := mux.NewRouter()
-
-    // Create middleware instances
-    authMW := middleware.NewJWTMiddleware(app.AuthService, app.UserService, cfg.AdminPhoneNumber)
-    adminMW := middleware.RequireAdmin(app.UserRepo)
-
-    // ✅ CORRECTED: Pass all required parameters
-    setupGlobalMiddleware(r, cfg)
-    setupStaticFiles(r, cfg)
-    setupPublicRoutes(r, app, sqlDB, loginLimiter, registrationLimiter)
-    setupProtectedRoutes(r, app, authMW)
-    setupAdminRoutes(r, app, authMW, adminMW)
-    setupErrorHandlers(r, app.PageHandler)
-
-    logger.Info("HTTP routes configured successfully")
-
-    // Server configuration
-    port := getServerPort(cfg)
-    srv := &http.Server{
-        Addr:           port,
-        Handler:        r,
-        ReadTimeout:    60 * time.Second,
-        WriteTimeout:   120 * time.Second, // Increased for chat streaming
-        IdleTimeout:    120 * time.Second,
-        MaxHeaderBytes: 1 << 20, // 1 MB
-    }
-
-    initTime := time.Since(startTime)
-    logger.Info("🚀 server initialization completed",
-        "initialization_time", initTime.String(),
-        "port", port)
-
-    logger.Info("==================================================")
-    logger.Info("🤖 Internist AI - Medical Chat Assistant", "status", "ready")
-    logger.Info("🚀 server starting", "port", port)
-    logger.Info("🌐 local access", "url", fmt.Sprintf("http://localhost%s", port))
-    logger.Info("💬 chat interface", "url", fmt.Sprintf("http://localhost%s/chat", port))
-    logger.Info("🔒 admin panel", "url", fmt.Sprintf("http://localhost%s/admin", port))
-    logger.Info("🔄 server ready to accept connections")
-    logger.Info("==================================================")
-
-    // Start server and handle graceful shutdown
-    startServer(srv, logger)
-    gracefulShutdown(srv, sqlDB, logger, startTime)
+	startTime := time.Now()
+
+	// Load and validate config in one clean step
+	cfg, err := config.New()
+	if err != nil {
+		slog.Error("FATAL: Configuration error", "error", err)
+		os.Exit(1)
+	}
+
+	// Install the process-wide slog logger; every service logger derives from it
+	policy := logging.DefaultPolicy()
+	policy.Content = logging.ParseContentMode(cfg.LogContent)
+	logging.Setup(logging.Options{
+		Level:     cfg.LogLevel,
+		Format:    cfg.LogFormat,
+		Redaction: policy,
+	})
+	logger := logging.NewLogger("go_internist")
+	logger.Info("🤖 Internist AI - Medical Chat Assistant starting")
+	logger.Info("configuration loaded successfully",
+		"environment", cfg.Environment, "log_level", cfg.LogLevel, "log_content", string(policy.Content))
+
+	// Client IPs feed rate limits, SMS pumping checks and the audit log; forwarding
+	// headers are only believed from these proxies
+	if err := ratelimit.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Error("invalid TRUSTED_PROXIES", "error", err)
+		os.Exit(1)
+	}
+
+	// Database Connection
+	logger.Info("initializing PostgreSQL database connection")
+	db, err := gorm.Open(postgres.Open(cfg.GetDatabaseDSN()), &gorm.Config{
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	})
+	if err != nil {
+		logger.Error("PostgreSQL connection failed", "error", err,
+			"host", cfg.DBHost, "port", cfg.DBPort, "database", cfg.DBName)
+		os.Exit(1)
+	}
+
+	// Configure connection pool
+	sqlDB, err := configureDatabaseConnection(db)
+	if err != nil {
+		logger.Error("failed to configure database connection", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Info("PostgreSQL connected successfully",
+		"host", cfg.DBHost, "port", cfg.DBPort, "database", cfg.DBName,
+		"max_idle_conns", 10, "max_open_conns", 100)
+
+	// ---- Run GORM migrations on every startup ----
+	if err := runDatabaseMigrations(db, logger); err != nil {
+		logger.Error("database migration failed", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("database migrations completed successfully")
+	// ---- End migration block ----
+
+	// 🎯 WIRE MAGIC - Replace 50+ lines of manual DI with this single call!
+	logger.Info("initializing application with Wire dependency injection")
+	app, err := InitializeApplication(cfg, logger, db)
+	if err != nil {
+		logger.Error("application initialization failed", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("🚀 application initialized successfully via Wire DI")
+
+	// Jobs left running by a previous process cannot resume; mark them failed
+	if err := app.GenerationService.RecoverInterrupted(context.Background()); err != nil {
+		logger.Warn("failed to recover interrupted generation jobs", "error", err)
+	}
+
+	// Send SMS queued in the outbox, including any left over from a previous process
+	app.SMSService.StartDispatcher()
+
+	// Delete expired verification codes and prune the verification send log
+	app.VerificationService.StartCleanup()
+
+	// 🛡️ SETUP RATE LIMITERS — for login, register, SMS, reset
+	loginLimiter, registrationLimiter := setupRateLimiters()
+	logger.Info("🛡️ rate limiters initialized for auth endpoints")
+
+	// Router setup
+	logger.Info("configuring HTTP router and middleware")
+	r := mux.NewRouter()
+
+	// Create middleware instances
+	authMW := middleware.NewJWTMiddleware(app.AuthService, app.UserService, cfg.AdminPhoneNumber)
+
+	// ✅ CORRECTED: Pass all required parameters
+	setupGlobalMiddleware(r, cfg)
+	setupStaticFiles(r, cfg)
+	checker := newHealthChecker(app, sqlDB, cfg)
+	setupPublicRoutes(r, app, checker, startTime, loginLimiter, registrationLimiter)
+	setupProtectedRoutes(r, app, authMW)
+	setupAdminRoutes(r, app, authMW)
+	setupErrorHandlers(r, app.PageHandler)
+
+	logger.Info("HTTP routes configured successfully")
+
+	// Server configuration
+	port := getServerPort(cfg)
+	connCtx, releaseConns := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Addr:           port,
+		Handler:        r,
+		BaseContext:    func(net.Listener) context.Context { return connCtx },
+		ReadTimeout:    60 * time.Second,
+		WriteTimeout:   120 * time.Second, // Increased for chat streaming
+		IdleTimeout:    120 * time.Second,
+		MaxHeaderBytes: 1 << 20, // 1 MB
+	}
+
+	initTime := time.Since(startTime)
+	logger.Info("🚀 server initialization completed",
+		"initialization_time", initTime.String(),
+		"port", port)
+
+	logger.Info("==================================================")
+	logger.Info("🤖 Internist AI - Medical Chat Assistant", "status", "ready")
+	logger.Info("🚀 server starting", "port", port)
+	logger.Info("🌐 local access", "url", fmt.Sprintf("http://localhost%s", port))
+	logger.Info("💬 chat interface", "url", fmt.Sprintf("http://localhost%s/chat", port))
+	logger.Info("🔒 admin panel", "url", fmt.Sprintf("http://localhost%s/admin", port))
+	logger.Info("🔄 server ready to accept connections")
+	logger.Info("==================================================")
+
+	// Start server and handle graceful shutdown
+	startServer(srv, logger)
+	gracefulShutdown(srv, app, releaseConns, sqlDB, logger, startTime)
 }
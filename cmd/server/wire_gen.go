@@ -7,37 +7,54 @@
 package main
 
 import (
+	"fmt"
 	"github.com/iyunix/go-internist/internal/config"
 	"github.com/iyunix/go-internist/internal/handlers"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/repository/analytics"
+	"github.com/iyunix/go-internist/internal/repository/audit"
 	"github.com/iyunix/go-internist/internal/repository/chat"
+	"github.com/iyunix/go-internist/internal/repository/job"
 	"github.com/iyunix/go-internist/internal/repository/message"
+	"github.com/iyunix/go-internist/internal/repository/outbox"
+	"github.com/iyunix/go-internist/internal/repository/reports"
+	"github.com/iyunix/go-internist/internal/repository/transaction"
 	"github.com/iyunix/go-internist/internal/repository/user"
 	"github.com/iyunix/go-internist/internal/repository/verification"
 	"github.com/iyunix/go-internist/internal/services"
 	"github.com/iyunix/go-internist/internal/services/admin_services"
 	"github.com/iyunix/go-internist/internal/services/ai"
+	chatservice "github.com/iyunix/go-internist/internal/services/chat"
 	"github.com/iyunix/go-internist/internal/services/sms"
 	"github.com/iyunix/go-internist/internal/services/user_services"
+	"github.com/iyunix/go-internist/internal/streams"
 	"gorm.io/gorm"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Injectors from wire.go:
 
-func InitializeApplication(cfg *config.Config, logger services.Logger, db *gorm.DB) (*Application, error) {
+func InitializeApplication(cfg *config.Config, logger logging.Logger, db *gorm.DB) (*Application, error) {
 	userRepository := user.NewGormUserRepository(db)
 	jwtSecret := ProvideJWTSecret(cfg)
 	adminPhone := ProvideAdminPhone(cfg)
-	userService := NewUserServiceWrapped(userRepository, jwtSecret, adminPhone, logger)
+	smsConfig := ProvideSMSConfig(cfg)
+	provider, err := ProvideSMSProvider(cfg, smsConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+	outboxRepository := outbox.NewGormOutboxRepository(db)
+	smsService := services.NewSMSService(provider, outboxRepository, logger)
+	userService := NewUserServiceWrapped(userRepository, jwtSecret, adminPhone, smsService, logger)
 	authService := NewAuthServiceWrapped(userRepository, jwtSecret, adminPhone, logger)
 	verificationRepository := verification.NewGormVerificationRepository(db)
-	smsConfig := ProvideSMSConfig(cfg)
-	provider := ProvideSMSProvider(smsConfig)
-	smsService := services.NewSMSService(provider, logger)
-	verificationService := user_services.NewVerificationService(userRepository, verificationRepository, smsService, authService, logger)
-	user_servicesLogger := ProvideUserServicesLogger(logger)
-	balanceService := user_services.NewBalanceService(userRepository, user_servicesLogger)
+	verificationService, err := ProvideVerificationService(cfg, userRepository, verificationRepository, smsService, authService, logger)
+	if err != nil {
+		return nil, err
+	}
+	balanceService := user_services.NewBalanceService(userRepository, smsService, logger)
 	authHandler := handlers.NewAuthHandler(userService, authService, verificationService, smsService, balanceService)
 	chatRepository := chat.NewChatRepository(db)
 	messageRepository := message.NewMessageRepository(db)
@@ -50,18 +67,28 @@ func InitializeApplication(cfg *config.Config, logger services.Logger, db *gorm.
 	}
 	int2 := ProvideRetrievalTopK(cfg)
 	translationService := ProvideTranslationService(cfg, logger)
-	chatService, err := services.NewChatService(chatRepository, messageRepository, aiService, pineconeService, int2, cfg, translationService)
+	chatService, err := services.NewChatService(chatRepository, messageRepository, aiService, pineconeService, int2, cfg, translationService, userRepository)
 	if err != nil {
 		return nil, err
 	}
-	chatHandler, err := handlers.NewChatHandler(userService, chatService)
+	jobRepository := job.NewGormJobRepository(db)
+	hub := ProvideStreamHub(cfg)
+	generationService, err := ProvideGenerationService(cfg, chatService, jobRepository, hub, balanceService, logger)
 	if err != nil {
 		return nil, err
 	}
-	admin_servicesLogger := ProvideAdminServicesLogger(logger)
-	adminService := admin_services.NewAdminService(userRepository, admin_servicesLogger)
+	chatHandler, err := handlers.NewChatHandler(userService, chatService, generationService, hub)
+	if err != nil {
+		return nil, err
+	}
+	auditRepository := audit.NewGormAuditRepository(db)
+	analyticsRepository := analytics.NewGormAnalyticsRepository(db)
+	reportsRepository := reports.NewGormReportsRepository(db)
+	transactor := transaction.NewGormTransactor(db)
+	adminService := admin_services.NewAdminService(userRepository, messageRepository, chatRepository, outboxRepository, auditRepository, analyticsRepository, reportsRepository, transactor, smsService, logger)
 	pageHandler := handlers.NewPageHandler(userService, chatService, adminService)
-	adminHandler := handlers.NewAdminHandler(adminService)
+	adminHandler := handlers.NewAdminHandler(adminService, authService)
+	smsDeliveryHandler := ProvideSMSDeliveryHandler(cfg, smsService)
 	application := &Application{
 		Config:              cfg,
 		Logger:              logger,
@@ -69,10 +96,14 @@ func InitializeApplication(cfg *config.Config, logger services.Logger, db *gorm.
 		ChatHandler:         chatHandler,
 		PageHandler:         pageHandler,
 		AdminHandler:        adminHandler,
+		SMSDeliveryHandler:  smsDeliveryHandler,
 		ChatService:         chatService,
 		AIService:           aiService,
 		PineconeService:     pineconeService,
 		SMSService:          smsService,
+		TranslationService:  translationService,
+		StreamHub:           hub,
+		GenerationService:   generationService,
 		UserService:         userService,
 		AuthService:         authService,
 		VerificationService: verificationService,
@@ -88,15 +119,19 @@ func InitializeApplication(cfg *config.Config, logger services.Logger, db *gorm.
 // Application aggregates all services and handlers
 type Application struct {
 	Config              *config.Config
-	Logger              services.Logger
+	Logger              logging.Logger
 	AuthHandler         *handlers.AuthHandler
 	ChatHandler         *handlers.ChatHandler
 	PageHandler         *handlers.PageHandler
 	AdminHandler        *handlers.AdminHandler
+	SMSDeliveryHandler  *handlers.SMSDeliveryHandler
 	ChatService         *services.ChatService
 	AIService           *services.AIService
 	PineconeService     *services.PineconeService
 	SMSService          *services.SMSService
+	TranslationService  *services.TranslationService
+	StreamHub           *streams.Hub
+	GenerationService   *services.GenerationService
 	UserService         *user_services.UserService
 	AuthService         *user_services.AuthService
 	VerificationService *user_services.VerificationService
@@ -115,8 +150,8 @@ func ProvideConfig() (*config.Config, error) {
 	return config.New()
 }
 
-func ProvideLogger() services.Logger {
-	return services.NewLogger("go_internist")
+func ProvideLogger() logging.Logger {
+	return logging.NewLogger("go_internist")
 }
 
 func ProvideJWTSecret(cfg *config.Config) JWTSecret {
@@ -132,20 +167,12 @@ func ProvideRetrievalTopK(cfg *config.Config) int {
 	return cfg.RetrievalTopK
 }
 
-func ProvideUserServicesLogger(logger services.Logger) user_services.Logger {
-	return logger
-}
-
-func ProvideAdminServicesLogger(logger services.Logger) admin_services.Logger {
-	return logger
-}
-
 // Wrapped constructors for user services
-func NewUserServiceWrapped(repo user.UserRepository, jwtSecret JWTSecret, adminPhone AdminPhone, logger services.Logger) *user_services.UserService {
-	return user_services.NewUserService(repo, string(jwtSecret), string(adminPhone), logger)
+func NewUserServiceWrapped(repo user.UserRepository, jwtSecret JWTSecret, adminPhone AdminPhone, notifier user_services.Notifier, logger logging.Logger) *user_services.UserService {
+	return user_services.NewUserService(repo, string(jwtSecret), string(adminPhone), notifier, logger)
 }
 
-func NewAuthServiceWrapped(repo user.UserRepository, jwtSecret JWTSecret, adminPhone AdminPhone, logger services.Logger) *user_services.AuthService {
+func NewAuthServiceWrapped(repo user.UserRepository, jwtSecret JWTSecret, adminPhone AdminPhone, logger logging.Logger) *user_services.AuthService {
 	return user_services.NewAuthService(repo, string(jwtSecret), string(adminPhone), logger)
 }
 
@@ -156,6 +183,7 @@ func ProvideAIConfig(cfg *config.Config) *ai.Config {
 	aiConfig.EmbeddingBaseURL = "https://api.avalai.ir/v1"
 	aiConfig.LLMBaseURL = "https://openai.jabirproject.org/v1"
 	aiConfig.EmbeddingModel = cfg.EmbeddingModelName
+	aiConfig.CompletionModel = chatservice.DefaultConfig().ChatModel
 	return aiConfig
 }
 
@@ -173,11 +201,115 @@ func ProvideAIProvider(aiConfig *ai.Config) ai.AIProvider {
 	return ai.NewOpenAIProvider(aiConfig)
 }
 
-func ProvideSMSProvider(smsConfig *sms.Config) sms.Provider {
-	return sms.NewSMSIRProvider(smsConfig)
+// ProvideSMSProvider chains the providers named in SMS_PROVIDERS, in order, behind
+// per-provider retries and circuit breakers
+func ProvideSMSProvider(cfg *config.Config, smsConfig *sms.Config, logger logging.Logger) (sms.Provider, error) {
+	var chain []sms.NamedProvider
+	for _, name := range cfg.SMSProviders {
+		var provider sms.Provider
+		switch name {
+		case "smsir":
+			templates, err := sms.ParseTemplates(cfg.SMSTemplates)
+			if err != nil {
+				return nil, fmt.Errorf("SMS_TEMPLATES: %w", err)
+			}
+			smsConfig.Templates = templates
+			provider = sms.NewSMSIRProvider(smsConfig)
+		case "kavenegar":
+			templates, err := sms.ParseTemplates(cfg.KavenegarTemplates)
+			if err != nil {
+				return nil, fmt.Errorf("KAVENEGAR_TEMPLATES: %w", err)
+			}
+			provider = sms.NewKavenegarProvider(&sms.KavenegarConfig{
+				APIKey:    cfg.KavenegarAPIKey,
+				Template:  cfg.KavenegarTemplate,
+				Templates: templates,
+				APIURL:    cfg.KavenegarAPIURL,
+				Timeout:   smsConfig.Timeout,
+			})
+		case "http":
+			templates, err := sms.ParseTemplates(cfg.SMSHTTPTemplates)
+			if err != nil {
+				return nil, fmt.Errorf("SMS_HTTP_TEMPLATES: %w", err)
+			}
+			headers := make(map[string]string)
+			for _, pair := range cfg.SMSHTTPHeaders {
+				if key, value, ok := strings.Cut(pair, "="); ok {
+					headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+				}
+			}
+			httpConfig := &sms.HTTPConfig{
+				URL:         cfg.SMSHTTPURL,
+				Method:      cfg.SMSHTTPMethod,
+				Body:        cfg.SMSHTTPBody,
+				ContentType: cfg.SMSHTTPContentType,
+				Headers:     headers,
+				Templates:   templates,
+				HealthURL:   cfg.SMSHTTPHealthURL,
+				Timeout:     smsConfig.Timeout,
+			}
+			if err := httpConfig.Validate(); err != nil {
+				return nil, err
+			}
+			provider = sms.NewHTTPTemplateProvider(httpConfig)
+		case "console":
+			logger.Warn("SMS console provider enabled: verification codes are logged, not sent", "file", cfg.SMSConsoleFile)
+			provider = sms.NewConsoleProvider(cfg.SMSConsoleFile)
+		default:
+			return nil, fmt.Errorf("unknown SMS provider %q", name)
+		}
+		chain = append(chain, sms.NamedProvider{Name: name, Provider: provider})
+	}
+
+	failoverConfig := sms.DefaultFailoverConfig()
+	if cfg.SMSBreakerThreshold > 0 {
+		failoverConfig.BreakerThreshold = cfg.SMSBreakerThreshold
+	}
+	if cfg.SMSBreakerCooldownSeconds > 0 {
+		failoverConfig.BreakerCooldown = time.Duration(cfg.SMSBreakerCooldownSeconds) * time.Second
+	}
+	return sms.NewFailoverProvider(chain, failoverConfig, logger)
+}
+
+func ProvideSMSDeliveryHandler(cfg *config.Config, smsService *services.SMSService) *handlers.SMSDeliveryHandler {
+	return handlers.NewSMSDeliveryHandler(smsService, cfg.SMSCallbackToken)
+}
+
+func ProvideStreamHub(cfg *config.Config) *streams.Hub {
+	hubConfig := streams.DefaultConfig()
+	if cfg.SSEReplayRetentionSeconds > 0 {
+		hubConfig.Retention = time.Duration(cfg.SSEReplayRetentionSeconds) * time.Second
+	}
+	return streams.NewHub(hubConfig)
+}
+
+func ProvideGenerationService(cfg *config.Config, chatService *services.ChatService, jobRepo job.JobRepository, hub *streams.Hub, balanceService *user_services.BalanceService, logger logging.Logger) (*services.GenerationService, error) {
+	genConfig := services.DefaultGenerationConfig()
+	if cfg.GenerationWorkers > 0 {
+		genConfig.Workers = cfg.GenerationWorkers
+	}
+	if cfg.GenerationQueueSize > 0 {
+		genConfig.QueueSize = cfg.GenerationQueueSize
+	}
+	return services.NewGenerationService(genConfig, chatService, jobRepo, hub, balanceService, logger)
+}
+
+func ProvideVerificationService(cfg *config.Config, userRepo user.UserRepository, verificationRepo verification.VerificationRepository, smsService *services.SMSService, authService *user_services.AuthService, logger logging.Logger) (*user_services.VerificationService, error) {
+	verificationConfig := user_services.DefaultVerificationConfig()
+	verificationConfig.CodeSecret = []byte(cfg.VerificationCodeSecret)
+	if len(verificationConfig.CodeSecret) == 0 {
+		verificationConfig.CodeSecret = []byte(cfg.JWTSecretKey)
+	}
+	if cfg.SMSResendCooldownSeconds > 0 {
+		verificationConfig.ResendCooldown = time.Duration(cfg.SMSResendCooldownSeconds) * time.Second
+	}
+	verificationConfig.DailyCap = cfg.SMSDailyCapPerPhone
+	verificationConfig.MaxPhonesPerIP = cfg.SMSMaxPhonesPerIP
+	verificationConfig.MaxPhonesPerPrefix = cfg.SMSMaxPhonesPerPrefix
+	return user_services.NewVerificationService(verificationConfig, userRepo, verificationRepo, smsService, authService, logger)
 }
 
-func ProvidePineconeService(cfg *config.Config, logger services.Logger) (*services.PineconeService, error) {
+func ProvidePineconeService(cfg *config.Config, logger logging.Logger) (*services.PineconeService, error) {
 	return services.NewPineconeService(
 		cfg.PineconeAPIKey,
 		cfg.PineconeIndexHost,
@@ -186,6 +318,6 @@ func ProvidePineconeService(cfg *config.Config, logger services.Logger) (*servic
 	)
 }
 
-func ProvideTranslationService(cfg *config.Config, logger services.Logger) *services.TranslationService {
+func ProvideTranslationService(cfg *config.Config, logger logging.Logger) *services.TranslationService {
 	return services.NewTranslationService(cfg.AvalaiAPIKeyTranslation, "", logger)
 }
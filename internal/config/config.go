@@ -17,11 +17,14 @@ type Config struct {
     ServerPort  string
     Environment string
     LogLevel    string
+    LogFormat   string // "json" or "text"
+    LogContent  string // message content in logs: redact, truncate or full
     Port        string    // ✅ Added missing Port field
 
     // Security
     JWTSecretKey   string
     AllowedOrigins []string  // ✅ Single definition (removed duplicate)
+    TrustedProxies []string  // CIDRs or addresses of reverse proxies whose X-Forwarded-For is believed
     StaticDir      string
 
     // Database Configuration
@@ -43,16 +46,58 @@ type Config struct {
     PineconeIndexHost string  // Now stores Qdrant URL
     PineconeNamespace string  // Now stores Qdrant Collection Name
     // SMS Configuration - ✅ Clean field definitions
+    SMSProviders  []string // failover order: smsir, kavenegar, http, console
     SMSAccessKey  string
     SMSTemplateID string  // Keep as string, convert to int in wire.go
+    SMSTemplates  string  // other templates as key=id pairs separated by ";"
     SMSAPIURL     string
 
+    // Second SMS provider (Kavenegar verify lookup)
+    KavenegarAPIKey   string
+    KavenegarTemplate  string
+    KavenegarTemplates string // other templates as key=name pairs separated by ";"
+    KavenegarAPIURL    string
+
+    // Generic HTTP gateway; {phone} and {code} are substituted in the URL and body
+    SMSHTTPURL         string
+    SMSHTTPMethod      string
+    SMSHTTPBody        string
+    SMSHTTPContentType string
+    SMSHTTPHeaders     []string // Name=value pairs
+    SMSHTTPHealthURL   string
+    SMSHTTPTemplates   string // message texts as key=text pairs separated by ";"
+
+    SMSConsoleFile            string // console provider appends codes here; empty logs them
+    SMSBreakerThreshold       int    // consecutive failures before a provider is skipped
+    SMSBreakerCooldownSeconds int    // how long a failing provider is skipped
+    SMSCallbackToken          string // shared secret in delivery report callback URLs; empty disables them
+
+    // Verification code limits, enforced per phone number and against SMS pumping
+    VerificationCodeSecret   string // HMAC key for stored codes; empty uses JWT_SECRET_KEY
+    SMSResendCooldownSeconds int
+    SMSDailyCapPerPhone      int
+    SMSMaxPhonesPerIP        int // other numbers one client IP may request codes for per hour
+    SMSMaxPhonesPerPrefix    int // other numbers in one number range that may get codes per hour
+
     // Application Settings
     AdminPhoneNumber   string
     TranslationEnabled bool
 
     RetrievalTopK int
 
+    // Health probes
+    HealthProbeTTLSeconds int // how long upstream probe results are cached
+
+    // Streaming
+    SSEReplayRetentionSeconds int // how long finished SSE streams stay replayable
+
+    // Generation worker pool
+    GenerationWorkers   int // concurrent RAG pipeline runs
+    GenerationQueueSize int // jobs waiting for a worker before /ask returns 503
+
+    // Models besides the default that users may regenerate answers with
+    AlternateModels []string
+
 }
 
 func New() (*Config, error) {
@@ -68,11 +113,14 @@ func New() (*Config, error) {
         ServerPort:  getEnv("SERVER_PORT", "8080"),
         Environment: env,
         LogLevel:    getEnv("LOG_LEVEL", "INFO"),
+        LogFormat:   getEnv("LOG_FORMAT", defaultLogFormat(env)),
+        LogContent:  getEnv("LOG_CONTENT_POLICY", "redact"),
         Port:        getEnv("PORT", ""), // ✅ Added Port field
 
         // Security
         JWTSecretKey:   os.Getenv("JWT_SECRET_KEY"), // No default - must be provided
         AllowedOrigins: getEnvAsSlice("ALLOWED_ORIGINS", []string{}), // ✅ Single definition
+        TrustedProxies: getEnvAsSlice("TRUSTED_PROXIES", nil),
         StaticDir:      getEnv("STATIC_DIR", "web/static"),
 
         // Database Configuration
@@ -94,12 +142,43 @@ func New() (*Config, error) {
         PineconeIndexHost: os.Getenv("QDRANT_URL"),         // Now reads Qdrant URL
         PineconeNamespace: getEnv("QDRANT_COLLECTION", "UpToDate"), // Now reads Qdrant Collection
         RetrievalTopK:     getEnvAsInt("RAG_TOPK", 15),
+        HealthProbeTTLSeconds: getEnvAsInt("HEALTH_PROBE_TTL_SECONDS", 60),
+        SSEReplayRetentionSeconds: getEnvAsInt("SSE_REPLAY_RETENTION_SECONDS", 300),
+        GenerationWorkers:   getEnvAsInt("GENERATION_WORKERS", 4),
+        GenerationQueueSize: getEnvAsInt("GENERATION_QUEUE_SIZE", 64),
+        AlternateModels:     getEnvAsSlice("ALTERNATE_MODELS", nil),
 
         // SMS Service - ✅ Clean field population
+        SMSProviders:  getEnvAsSlice("SMS_PROVIDERS", []string{"smsir"}),
         SMSAccessKey:  os.Getenv("SMS_ACCESS_KEY"), // No default
         SMSTemplateID: os.Getenv("SMS_TEMPLATE_ID"), // Keep as string
+        SMSTemplates:  os.Getenv("SMS_TEMPLATES"),
         SMSAPIURL:     os.Getenv("SMS_API_URL"), // No default
 
+        KavenegarAPIKey:   os.Getenv("KAVENEGAR_API_KEY"),
+        KavenegarTemplate:  os.Getenv("KAVENEGAR_TEMPLATE"),
+        KavenegarTemplates: os.Getenv("KAVENEGAR_TEMPLATES"),
+        KavenegarAPIURL:    getEnv("KAVENEGAR_API_URL", "https://api.kavenegar.com/v1"),
+
+        SMSHTTPURL:         os.Getenv("SMS_HTTP_URL"),
+        SMSHTTPMethod:      getEnv("SMS_HTTP_METHOD", "POST"),
+        SMSHTTPBody:        os.Getenv("SMS_HTTP_BODY"),
+        SMSHTTPContentType: getEnv("SMS_HTTP_CONTENT_TYPE", "application/json"),
+        SMSHTTPHeaders:     getEnvAsSlice("SMS_HTTP_HEADERS", nil),
+        SMSHTTPTemplates:   os.Getenv("SMS_HTTP_TEMPLATES"),
+        SMSHTTPHealthURL:   os.Getenv("SMS_HTTP_HEALTH_URL"),
+
+        SMSConsoleFile:            os.Getenv("SMS_CONSOLE_FILE"),
+        SMSBreakerThreshold:       getEnvAsInt("SMS_BREAKER_THRESHOLD", 3),
+        SMSBreakerCooldownSeconds: getEnvAsInt("SMS_BREAKER_COOLDOWN_SECONDS", 60),
+        SMSCallbackToken:          os.Getenv("SMS_CALLBACK_TOKEN"),
+
+        VerificationCodeSecret:   os.Getenv("VERIFICATION_CODE_SECRET"),
+        SMSResendCooldownSeconds: getEnvAsInt("SMS_RESEND_COOLDOWN_SECONDS", 60),
+        SMSDailyCapPerPhone:      getEnvAsInt("SMS_DAILY_CAP_PER_PHONE", 10),
+        SMSMaxPhonesPerIP:        getEnvAsInt("SMS_MAX_PHONES_PER_IP", 5),
+        SMSMaxPhonesPerPrefix:    getEnvAsInt("SMS_MAX_PHONES_PER_PREFIX", 20),
+
         // Application Settings
         AdminPhoneNumber:   os.Getenv("ADMIN_PHONE_NUMBER"), // No default
         TranslationEnabled: getEnvAsBool("TRANSLATION_ENABLED", true),
@@ -139,8 +218,6 @@ func (c *Config) Validate() error {
         "JABIR_API_KEY":            c.JabirAPIKey,
         "QDRANT_API_KEY":           c.PineconeAPIKey,    // Now validates Qdrant API Key
         "QDRANT_URL":               c.PineconeIndexHost, // Now validates Qdrant URL
-        "SMS_ACCESS_KEY":           c.SMSAccessKey,
-        "SMS_API_URL":              c.SMSAPIURL,
         "ADMIN_PHONE_NUMBER":       c.AdminPhoneNumber,
     }
 
@@ -160,8 +237,8 @@ func (c *Config) Validate() error {
         return errors.New("JWT_SECRET_KEY must be at least 32 characters long for security")
     }
 
-    if c.SMSTemplateID == "" {
-        return errors.New("SMS_TEMPLATE_ID is required")
+    if err := c.validateSMSProviders(); err != nil {
+        return err
     }
 
     // Production-specific security checks
@@ -170,6 +247,10 @@ func (c *Config) Validate() error {
             return errors.New("DB_SSL_MODE cannot be 'disable' in production - use 'require' or 'verify-full'")
         }
         
+        if strings.EqualFold(c.LogContent, "full") {
+            return errors.New("LOG_CONTENT_POLICY=full is not allowed in production")
+        }
+
         if len(c.AllowedOrigins) == 0 {
             return errors.New("ALLOWED_ORIGINS must be set in production")
         }
@@ -185,6 +266,49 @@ func (c *Config) Validate() error {
     return nil
 }
 
+// validateSMSProviders checks that every provider in SMS_PROVIDERS is configured
+func (c *Config) validateSMSProviders() error {
+    if len(c.SMSProviders) == 0 {
+        return errors.New("SMS_PROVIDERS must name at least one provider")
+    }
+    for _, name := range c.SMSProviders {
+        var missing []string
+        switch name {
+        case "smsir":
+            if c.SMSAccessKey == "" {
+                missing = append(missing, "SMS_ACCESS_KEY")
+            }
+            if c.SMSAPIURL == "" {
+                missing = append(missing, "SMS_API_URL")
+            }
+            if c.SMSTemplateID == "" {
+                missing = append(missing, "SMS_TEMPLATE_ID")
+            }
+        case "kavenegar":
+            if c.KavenegarAPIKey == "" {
+                missing = append(missing, "KAVENEGAR_API_KEY")
+            }
+            if c.KavenegarTemplate == "" {
+                missing = append(missing, "KAVENEGAR_TEMPLATE")
+            }
+        case "http":
+            if c.SMSHTTPURL == "" {
+                missing = append(missing, "SMS_HTTP_URL")
+            }
+        case "console":
+            if c.IsProduction() {
+                return errors.New("the console SMS provider is not allowed in production")
+            }
+        default:
+            return fmt.Errorf("unknown SMS provider %q in SMS_PROVIDERS (use smsir, kavenegar, http or console)", name)
+        }
+        if len(missing) > 0 {
+            return fmt.Errorf("SMS provider %s needs: %s", name, strings.Join(missing, ", "))
+        }
+    }
+    return nil
+}
+
 // GetDatabaseDSN builds PostgreSQL connection string.
 func (c *Config) GetDatabaseDSN() string {
     return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=UTC",
@@ -272,3 +396,11 @@ func getDBSSLMode(env string) string {
     
     return mode
 }
+
+// defaultLogFormat emits JSON in production and readable text elsewhere.
+func defaultLogFormat(env string) string {
+    if env == "production" {
+        return "json"
+    }
+    return "text"
+}
@@ -0,0 +1,46 @@
+// File: internal/domain/analytics.go
+package domain
+
+import (
+    "time"
+)
+
+// SeriesPoint is one bucket of an analytics time series. Group splits a series, for
+// example by plan or pipeline stage; Samples is how many rows the value summarises.
+type SeriesPoint struct {
+    Bucket  time.Time `json:"bucket"`
+    Group   string    `json:"group,omitempty"`
+    Value   float64   `json:"value"`
+    Samples int64     `json:"samples"`
+}
+
+// Time series granularities, named after the Postgres date_trunc fields they map to
+const (
+    GranularityDay   = "day"
+    GranularityWeek  = "week"
+    GranularityMonth = "month"
+)
+
+// IsValidGranularity reports whether g is a supported bucket size
+func IsValidGranularity(g string) bool {
+    return g == GranularityDay || g == GranularityWeek || g == GranularityMonth
+}
+
+// Analytics series served by the admin API
+const (
+    SeriesActiveUsers            = "active_users"
+    SeriesQuestions              = "questions"
+    SeriesChargesByPlan          = "charges_by_plan"
+    SeriesRegistrations          = "registrations"
+    SeriesVerificationConversion = "verification_conversion"
+    SeriesRetrievalScore         = "retrieval_score"
+    SeriesStageLatency           = "stage_latency"
+)
+
+// AnalyticsSeries lists every series in display order
+func AnalyticsSeries() []string {
+    return []string{
+        SeriesActiveUsers, SeriesQuestions, SeriesChargesByPlan, SeriesRegistrations,
+        SeriesVerificationConversion, SeriesRetrievalScore, SeriesStageLatency,
+    }
+}
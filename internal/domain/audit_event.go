@@ -0,0 +1,47 @@
+// File: internal/domain/audit_event.go
+package domain
+
+import (
+    "time"
+)
+
+// AuditEvent records one privileged action: which admin did what to whom, from where,
+// and the values before and after. Rows are only ever inserted; the table rejects
+// updates and deletes.
+type AuditEvent struct {
+    ID           uint                   `gorm:"primaryKey" json:"id"`
+    ActorID      uint                   `gorm:"not null;index" json:"actor_id"`
+    ActorName    string                 `gorm:"size:50" json:"actor_name"`
+    Action       string                 `gorm:"size:50;not null;index" json:"action"` // AuditAction*
+    TargetUserID *uint                  `gorm:"index" json:"target_user_id,omitempty"`
+    Before       map[string]interface{} `gorm:"serializer:json;type:text" json:"before,omitempty"`
+    After        map[string]interface{} `gorm:"serializer:json;type:text" json:"after,omitempty"`
+    RequestID    string                 `gorm:"size:64;index" json:"request_id,omitempty"`
+    IP           string                 `gorm:"size:45" json:"ip,omitempty"`
+    CreatedAt    time.Time              `gorm:"index" json:"created_at"`
+}
+
+// Audited actions
+const (
+    AuditActionPlanChange     = "plan.change"
+    AuditActionRenew          = "subscription.renew"
+    AuditActionBalanceTopUp   = "balance.topup"
+    AuditActionFeedbackReview = "feedback.review"
+    AuditActionUsersExport    = "users.export"
+    AuditActionAuditExport    = "audit.export"
+    AuditActionRoleAssign     = "role.assign"
+    AuditActionUsageExport    = "usage.export"
+    AuditActionCreditsExport  = "credits.export"
+    AuditActionUserCreate     = "user.create"
+    AuditActionBulkImport     = "users.bulk_import"
+
+    AuditActionUserSuspend         = "user.suspend"
+    AuditActionUserReactivate      = "user.reactivate"
+    AuditActionUserUnlock          = "user.unlock"
+    AuditActionForcePasswordReset  = "user.force_password_reset"
+    AuditActionUserDelete          = "user.delete"
+    AuditActionUserRestore         = "user.restore"
+    AuditActionImpersonationStart  = "impersonation.start"
+    AuditActionImpersonationStop   = "impersonation.stop"
+    AuditActionImpersonatedRequest = "impersonation.request" // one per request made while viewing as a user
+)
@@ -8,9 +8,16 @@ import (
 
 // Chat represents a single conversation thread.
 type Chat struct {
-    ID        uint      `gorm:"primarykey"`
-    UserID    uint      `gorm:"not null;index"` // Added index for user's chat queries
-    Title     string    `gorm:"size:200"`       // Reasonable title length limit
+    ID          uint   `gorm:"primarykey"`
+    UserID      uint   `gorm:"not null;index"`                 // Added index for user's chat queries
+    Title       string `gorm:"size:200"`                       // Reasonable title length limit
+    TitleSource string `gorm:"size:10;not null;default:'user'"` // default, auto or user
+
+    // Organization
+    FolderID *uint    `gorm:"index"`                                                    // nil when the chat is in no folder
+    Tags     []string `gorm:"serializer:json;type:jsonb;index:idx_chats_tags,type:gin"` // normalized, lower case
+    Pinned   bool     `gorm:"not null;default:false"`
+    Archived bool     `gorm:"not null;default:false;index"`
     
     // Timestamps
     CreatedAt time.Time
@@ -21,6 +28,22 @@ type Chat struct {
     Messages  []Message `gorm:"foreignKey:ChatID" json:"-"` // Preload relationship
 }
 
+// DefaultChatTitle is shown until a title is generated from the first exchange
+const DefaultChatTitle = "New chat"
+
+// Where a chat's title came from. Only placeholder titles are replaced automatically,
+// so a user's rename always sticks.
+const (
+    ChatTitleDefault = "default"
+    ChatTitleAuto    = "auto"
+    ChatTitleUser    = "user"
+)
+
+// HasPlaceholderTitle reports whether the title is still waiting to be generated
+func (c *Chat) HasPlaceholderTitle() bool {
+    return c.TitleSource == ChatTitleDefault
+}
+
 // GetDisplayTitle returns a truncated title for display
 func (c *Chat) GetDisplayTitle() string {
     if len(c.Title) > 50 {
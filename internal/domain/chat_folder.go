@@ -0,0 +1,18 @@
+// File: internal/domain/chat_folder.go
+package domain
+
+import (
+    "time"
+)
+
+// ChatFolder groups a user's chats. A chat is in at most one folder; deleting the
+// folder leaves its chats unfiled.
+type ChatFolder struct {
+    ID     uint   `gorm:"primaryKey" json:"id"`
+    UserID uint   `gorm:"not null;uniqueIndex:idx_chat_folders_user_name" json:"-"`
+    Name   string `gorm:"size:60;not null;uniqueIndex:idx_chat_folders_user_name" json:"name"`
+
+    // Timestamps
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
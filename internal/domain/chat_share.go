@@ -0,0 +1,32 @@
+// File: internal/domain/chat_share.go
+package domain
+
+import (
+    "time"
+)
+
+// ChatShare is a read-only public link to a chat. Anyone holding the token can view
+// the conversation until the owner revokes the link or it expires.
+type ChatShare struct {
+    ID     uint   `gorm:"primaryKey" json:"id"`
+    ChatID uint   `gorm:"not null;index" json:"chat_id"`
+    UserID uint   `gorm:"not null;index" json:"-"` // owner
+    Token  string `gorm:"size:64;not null;uniqueIndex" json:"token"`
+
+    ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+    RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+    ViewCount    int64      `gorm:"not null;default:0" json:"view_count"`
+    LastViewedAt *time.Time `json:"last_viewed_at,omitempty"`
+
+    // Timestamps
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsActive reports whether the link can still be opened
+func (s *ChatShare) IsActive(now time.Time) bool {
+    if s.RevokedAt != nil {
+        return false
+    }
+    return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
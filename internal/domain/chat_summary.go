@@ -0,0 +1,18 @@
+// File: internal/domain/chat_summary.go
+package domain
+
+import (
+    "time"
+)
+
+// ChatSummary is the running summary of a chat's active branch: patient facts,
+// differentials and decisions that would otherwise fall out of the short context window.
+type ChatSummary struct {
+    ChatID           uint   `gorm:"primaryKey;autoIncrement:false" json:"chat_id"`
+    ThroughMessageID uint   `gorm:"not null" json:"through_message_id"` // last answer folded in
+    Summary          string `gorm:"type:text;not null" json:"summary"`
+
+    // Timestamps
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,81 @@
+// File: internal/domain/generation_job.go
+package domain
+
+import (
+    "time"
+)
+
+// GenerationJob tracks one assistant answer being produced by the worker pool.
+// It outlives the HTTP request that created it, so a client that closes the tab
+// can come back and pick up the result.
+type GenerationJob struct {
+    ID       uint   `gorm:"primaryKey" json:"id"`
+    UserID   uint   `gorm:"not null;index" json:"user_id"`
+    ChatID   uint   `gorm:"not null;index" json:"chat_id"`
+    StreamID string `gorm:"size:32;uniqueIndex" json:"stream_id"` // SSE stream in the hub
+
+    Kind      string `gorm:"size:20;not null;default:'ask'" json:"kind"`
+    MessageID uint   `gorm:"default:0" json:"message_id,omitempty"` // edit: question replaced; regenerate: answer or question re-run
+    Prompt    string `gorm:"type:text;not null" json:"-"`
+    Status    string `gorm:"size:20;index;not null;default:'queued'" json:"status"`
+    Charge    int    `gorm:"not null;default:0" json:"charge"` // final amount; reduced on cancel
+
+    Options GenerationOptions `gorm:"serializer:json;type:text" json:"options"`
+
+    // Progress: partial answer flushed periodically while streaming
+    Content string `gorm:"type:text" json:"content"`
+    Error   string `gorm:"size:500" json:"error,omitempty"`
+
+    // Pipeline timings and retrieval quality, recorded when the answer completes
+    Metrics GenerationMetrics `gorm:"embedded;embeddedPrefix:metric_" json:"metrics"`
+
+    // Timestamps
+    CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+    UpdatedAt  time.Time  `json:"updated_at"`
+    StartedAt  *time.Time `json:"started_at,omitempty"`
+    FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// GenerationOptions overrides the default pipeline settings when regenerating an answer.
+// Zero values keep the defaults.
+type GenerationOptions struct {
+    Model              string `json:"model,omitempty"`
+    TopK               int    `json:"top_k,omitempty"`
+    DisableTranslation bool   `json:"disable_translation,omitempty"`
+}
+
+// GenerationMetrics times each pipeline stage of one answer and records how closely the
+// retrieved passages matched. Stages that did not run stay zero; scores stay nil when
+// nothing was retrieved.
+type GenerationMetrics struct {
+    TranslationMs int64 `gorm:"not null;default:0" json:"translation_ms"`
+    EmbeddingMs   int64 `gorm:"not null;default:0" json:"embedding_ms"`
+    RetrievalMs   int64 `gorm:"not null;default:0" json:"retrieval_ms"`
+    LLMMs         int64 `gorm:"column:llm_ms;not null;default:0" json:"llm_ms"`
+    TotalMs       int64 `gorm:"not null;default:0" json:"total_ms"`
+
+    RetrievalMatches   int      `gorm:"not null;default:0" json:"retrieval_matches"`
+    RetrievalTopScore  *float64 `json:"retrieval_top_score,omitempty"`
+    RetrievalMeanScore *float64 `json:"retrieval_mean_score,omitempty"`
+}
+
+// Generation job statuses
+const (
+    JobStatusQueued    = "queued"
+    JobStatusRunning   = "running"
+    JobStatusCompleted = "completed"
+    JobStatusFailed    = "failed"
+    JobStatusCancelled = "cancelled"
+)
+
+// Generation job kinds
+const (
+    JobKindAsk        = "ask"        // answer a new question
+    JobKindRegenerate = "regenerate" // replace the last answer in the chat
+    JobKindEdit       = "edit"       // answer an edited question on a new branch
+)
+
+// IsFinished reports whether the job reached a terminal state
+func (j *GenerationJob) IsFinished() bool {
+    return j.Status == JobStatusCompleted || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
@@ -15,6 +15,15 @@ type Message struct {
     // Message classification
     MessageType string    `gorm:"size:50;index;default:'user'" json:"messageType"`
     
+    // Conversation tree: a question's parent is the answer it follows, an answer's
+    // parent is its question. Editing a question adds a sibling under the same parent;
+    // Active marks the sibling on the branch currently shown.
+    ParentID    *uint     `gorm:"index" json:"parent_id,omitempty"`
+    Active      bool      `gorm:"default:true;not null" json:"active"`
+    
+    // Documents the answer was grounded on, as shown to the user when it was generated
+    Sources     []string  `gorm:"serializer:json;type:text" json:"sources,omitempty"`
+    
     // Data management for large tables
     Archived    bool      `gorm:"default:false;index" json:"archived"` // Added index for archival queries
     
@@ -22,7 +31,7 @@ type Message struct {
     TokenCount  *int      `gorm:"default:null" json:"token_count,omitempty"` // For billing/analytics
     
     // Timestamps
-    CreatedAt   time.Time `json:"created_at"`
+    CreatedAt   time.Time `gorm:"index" json:"created_at"`
     UpdatedAt   time.Time `json:"updated_at"`
     DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"` // ADDED: Soft delete protection
     
@@ -59,6 +68,12 @@ func (m *Message) IsValidMessageType() bool {
     return false
 }
 
+// IsConversationTurn reports whether the message is a question or answer on a branch,
+// as opposed to bookkeeping such as internal_context
+func (m *Message) IsConversationTurn() bool {
+    return m.MessageType == MessageTypeUser || m.MessageType == MessageTypeAssistant
+}
+
 // GetContentPreview returns a truncated version for display
 func (m *Message) GetContentPreview(maxLength int) string {
     if len(m.Content) <= maxLength {
@@ -0,0 +1,85 @@
+// File: internal/domain/message_feedback.go
+package domain
+
+import (
+    "time"
+)
+
+// MessageFeedback is a user's rating of one assistant answer. Each user rates an answer
+// at most once; rating again replaces the earlier feedback.
+type MessageFeedback struct {
+    ID        uint   `gorm:"primaryKey" json:"id"`
+    MessageID uint   `gorm:"not null;uniqueIndex:idx_message_feedback_message_user" json:"message_id"`
+    UserID    uint   `gorm:"not null;uniqueIndex:idx_message_feedback_message_user" json:"-"`
+    ChatID    uint   `gorm:"not null;index" json:"chat_id"`
+    Rating    int    `gorm:"not null;index" json:"rating"` // FeedbackUp or FeedbackDown
+    Category  string `gorm:"size:20" json:"category,omitempty"`
+    Comment   string `gorm:"type:text" json:"comment,omitempty"`
+
+    // Triage by medical reviewers
+    ReviewStatus string     `gorm:"size:20;not null;default:'open';index" json:"review_status"`
+    ReviewedBy   *uint      `json:"reviewed_by,omitempty"`
+    ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+    ReviewNote   string     `gorm:"type:text" json:"review_note,omitempty"`
+
+    // Timestamps
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName keeps the table name singular, as feedback is uncountable
+func (MessageFeedback) TableName() string {
+    return "message_feedback"
+}
+
+// Ratings
+const (
+    FeedbackUp   = 1
+    FeedbackDown = -1
+)
+
+// Why an answer was rated down
+const (
+    FeedbackInaccurate    = "inaccurate"
+    FeedbackOutdated      = "outdated"
+    FeedbackMissingSource = "missing_source"
+    FeedbackUnsafe        = "unsafe"
+)
+
+// Review states of a rated-down answer
+const (
+    FeedbackReviewOpen      = "open"
+    FeedbackReviewConfirmed = "confirmed" // the answer was wrong
+    FeedbackReviewDismissed = "dismissed" // the answer was fine
+)
+
+// IsValidFeedbackCategory reports whether c is empty or one of the known categories
+func IsValidFeedbackCategory(c string) bool {
+    switch c {
+    case "", FeedbackInaccurate, FeedbackOutdated, FeedbackMissingSource, FeedbackUnsafe:
+        return true
+    }
+    return false
+}
+
+// IsValidFeedbackReviewStatus reports whether s is a known review state
+func IsValidFeedbackReviewStatus(s string) bool {
+    switch s {
+    case FeedbackReviewOpen, FeedbackReviewConfirmed, FeedbackReviewDismissed:
+        return true
+    }
+    return false
+}
+
+// FeedbackReview is a rated answer as shown to reviewers: the feedback with the question
+// the user asked, the rewrite the answer was generated from and the sources it cited
+type FeedbackReview struct {
+    MessageFeedback
+    UserID          uint      `json:"user_id"` // the embedded one is hidden from users
+    ChatTitle       string    `json:"chat_title"`
+    Question        string    `json:"question"`
+    InternalContext string    `json:"internal_context"`
+    Answer          string    `json:"answer"`
+    Sources         []string  `json:"sources"`
+    AnsweredAt      time.Time `json:"answered_at"`
+}
@@ -0,0 +1,40 @@
+// File: internal/domain/report.go
+package domain
+
+import (
+    "time"
+)
+
+// UserChatUsage is one row of the chats-per-user export: how much a user used the
+// assistant within the export window
+type UserChatUsage struct {
+    UserID         uint             `json:"user_id"`
+    Username       string           `json:"username"`
+    PhoneNumber    string           `json:"phone_number"`
+    Plan           SubscriptionPlan `json:"plan"`
+    Chats          int64            `json:"chats"`
+    Questions      int64            `json:"questions"`
+    Charged        int64            `json:"charged"` // characters charged for answers
+    LastQuestionAt *time.Time       `json:"last_question_at,omitempty"`
+}
+
+// Kinds of credit transaction. Admin changes reuse the audit action they were logged as.
+const (
+    CreditKindCharge  = "charge"                 // an answer was charged
+    CreditKindTopUp   = AuditActionBalanceTopUp  // an admin added credits
+    CreditKindRenewal = AuditActionRenew         // an admin reset the balance to the plan amount
+)
+
+// CreditTransaction is one change to a user's character balance. There is no ledger
+// table: charges come from generation jobs and admin changes from the audit log.
+type CreditTransaction struct {
+    OccurredAt  time.Time `json:"occurred_at"`
+    UserID      uint      `json:"user_id"`
+    Username    string    `json:"username"`
+    PhoneNumber string    `json:"phone_number"`
+    Kind        string    `json:"kind"`      // CreditKind*
+    Amount      int64     `json:"amount"`    // negative for charges
+    Reference   string    `json:"reference"` // "job:<id>" or "audit:<id>"
+    ActorID     uint      `json:"actor_id,omitempty"`
+    ActorName   string    `json:"actor_name,omitempty"`
+}
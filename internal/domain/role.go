@@ -0,0 +1,74 @@
+// File: internal/domain/role.go
+package domain
+
+import (
+    "sort"
+)
+
+// Admin roles. A user without a role has no admin access, except legacy IsAdmin users,
+// who count as superadmins until they are given a role.
+const (
+    RoleViewer     = "viewer"
+    RoleSupport    = "support"
+    RoleBilling    = "billing"
+    RoleSuperadmin = "superadmin"
+)
+
+// Admin permissions, checked per route by middleware.RequirePermission
+const (
+    PermUsersRead        = "users.read"
+    PermUsersExport      = "users.export"
+    PermUsersManage      = "users.manage"      // suspend, reactivate, unlock, force password reset
+    PermUsersDelete      = "users.delete"      // soft-delete and restore
+    PermUsersImpersonate = "users.impersonate" // read-only "view as user"
+    PermUsersImport      = "users.import"      // bulk create and top up from CSV
+    PermBalanceTopUp     = "balance.topup"
+    PermPlanChange       = "plan.change"
+    PermFeedbackRead     = "feedback.read"
+    PermFeedbackReview   = "feedback.review"
+    PermSMSRead          = "sms.read"
+    PermAuditRead        = "audit.read"
+    PermAnalyticsRead    = "analytics.read"
+    PermRolesManage      = "roles.manage"
+)
+
+// RolePermissions lists what each role may do
+var RolePermissions = map[string][]string{
+    RoleViewer:  {PermUsersRead, PermFeedbackRead, PermSMSRead, PermAnalyticsRead},
+    RoleSupport: {
+        PermUsersRead, PermUsersExport, PermUsersManage, PermUsersImpersonate,
+        PermFeedbackRead, PermFeedbackReview, PermSMSRead,
+    },
+    RoleBilling: {PermUsersRead, PermUsersExport, PermUsersImport, PermBalanceTopUp, PermPlanChange, PermAnalyticsRead},
+    RoleSuperadmin: {
+        PermUsersRead, PermUsersExport, PermUsersManage, PermUsersDelete, PermUsersImpersonate,
+        PermUsersImport, PermBalanceTopUp, PermPlanChange, PermFeedbackRead, PermFeedbackReview, PermSMSRead,
+        PermAuditRead, PermAnalyticsRead, PermRolesManage,
+    },
+}
+
+// IsValidRole reports whether role names an admin role
+func IsValidRole(role string) bool {
+    _, ok := RolePermissions[role]
+    return ok
+}
+
+// RoleHasPermission reports whether the role grants the permission
+func RoleHasPermission(role, permission string) bool {
+    for _, p := range RolePermissions[role] {
+        if p == permission {
+            return true
+        }
+    }
+    return false
+}
+
+// Roles returns the admin role names, sorted
+func Roles() []string {
+    roles := make([]string, 0, len(RolePermissions))
+    for role := range RolePermissions {
+        roles = append(roles, role)
+    }
+    sort.Strings(roles)
+    return roles
+}
@@ -0,0 +1,18 @@
+// File: internal/domain/search.go
+package domain
+
+import (
+    "time"
+)
+
+// SearchHit is one match of a full-text search across a user's conversations.
+// MessageID is 0 when the chat title matched rather than a message.
+type SearchHit struct {
+    ChatID      uint      `json:"chat_id"`
+    ChatTitle   string    `json:"chat_title"`
+    MessageID   uint      `json:"message_id,omitempty"`
+    MessageType string    `json:"message_type,omitempty"`
+    Snippet     string    `json:"snippet"` // HTML-escaped, matches wrapped in <mark>
+    Rank        float64   `json:"rank"`
+    CreatedAt   time.Time `json:"created_at"`
+}
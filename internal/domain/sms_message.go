@@ -0,0 +1,56 @@
+// File: internal/domain/sms_message.go
+package domain
+
+import (
+    "time"
+)
+
+// SMSMessage is one text message in the outbox. It is written before anything is sent,
+// so a provider outage or a restart delays the message instead of losing it.
+type SMSMessage struct {
+    ID       uint              `gorm:"primaryKey" json:"id"`
+    Phone    string            `gorm:"size:20;not null;index" json:"phone"`
+    Template string            `gorm:"size:40;not null" json:"template"` // sms.Template* key
+    Params   map[string]string `gorm:"serializer:json;type:text" json:"-"` // cleared once the message is final; may hold codes
+
+    Status            string `gorm:"size:20;not null;default:'queued';index" json:"status"`
+    Provider          string `gorm:"size:40" json:"provider,omitempty"`
+    ProviderMessageID string `gorm:"size:100;index" json:"provider_message_id,omitempty"`
+    Attempts          int    `gorm:"not null;default:0" json:"attempts"`
+    LastError         string `gorm:"size:500" json:"last_error,omitempty"`
+
+    NextAttemptAt time.Time  `gorm:"not null;index" json:"next_attempt_at"`
+    ClaimedAt     *time.Time `json:"claimed_at,omitempty"` // when a dispatcher last took it; only that claim may record the outcome
+    ExpiresAt     *time.Time `json:"expires_at,omitempty"` // not sent after this; a stale code is useless
+    SentAt        *time.Time `json:"sent_at,omitempty"`
+    DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+
+    CreatedAt time.Time `gorm:"index" json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName pins the table name; GORM's naming would split the SMS initialism
+func (SMSMessage) TableName() string {
+    return "sms_messages"
+}
+
+// SMS outbox statuses
+const (
+    SMSStatusQueued    = "queued"    // waiting for its next attempt
+    SMSStatusSending   = "sending"   // claimed by the dispatcher
+    SMSStatusSent      = "sent"      // accepted by a provider
+    SMSStatusDelivered = "delivered" // the provider reported delivery to the handset
+    SMSStatusFailed    = "failed"    // given up on, or reported undelivered
+)
+
+// SMSPhoneSummary counts recent sends to one phone number for the admin view
+type SMSPhoneSummary struct {
+    Phone     string    `json:"phone"`
+    Total     int64     `json:"total"`
+    Sent      int64     `json:"sent"`
+    Delivered int64     `json:"delivered"`
+    Failed    int64     `json:"failed"`
+    Pending   int64     `json:"pending"`
+    LastError string    `json:"last_error,omitempty"`
+    LastAt    time.Time `json:"last_at"`
+}
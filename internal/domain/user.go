@@ -11,8 +11,9 @@ import (
 type UserStatus string
 
 const (
-    UserStatusPending UserStatus = "pending"
-    UserStatusActive  UserStatus = "active"
+    UserStatusPending   UserStatus = "pending"
+    UserStatusActive    UserStatus = "active"
+    UserStatusSuspended UserStatus = "suspended" // blocked by an admin until reactivated
 )
 
 // SubscriptionPlan defines the type for user subscription tiers.
@@ -40,6 +41,18 @@ const (
     DefaultRegistrationChars  = 2500  // NEW: Initial chars for new users
 )
 
+// Response languages a user can choose for assistant answers
+const (
+    LanguageAuto    = "auto" // answer in the language the question was asked in
+    LanguageEnglish = "en"
+    LanguagePersian = "fa"
+)
+
+// IsSupportedLanguage reports whether lang is a valid response language preference
+func IsSupportedLanguage(lang string) bool {
+    return lang == LanguageAuto || lang == LanguageEnglish || lang == LanguagePersian
+}
+
 // User represents a user in the system - UPDATED WITH NEW PLANS
 type User struct {
     ID          uint      `gorm:"primaryKey" json:"id"`
@@ -56,15 +69,26 @@ type User struct {
     FailedLoginAttempts int        `gorm:"default:0" json:"-"`
     LastFailedLoginAt   *time.Time `gorm:"default:null" json:"-"`
     LockedUntil         *time.Time `gorm:"default:null" json:"-"`
-    IsAdmin             bool       `gorm:"default:false;not null" json:"-"`
+    IsAdmin             bool       `gorm:"default:false;not null" json:"-"` // legacy; see AdminRole
+    Role                string     `gorm:"default:'';not null;size:20" json:"role,omitempty"` // admin role, empty for regular users
+
+    // Admin account controls
+    SuspendedAt           *time.Time `gorm:"default:null" json:"suspended_at,omitempty"`
+    SuspendReason         string     `gorm:"size:255" json:"suspend_reason,omitempty"`
+    PasswordResetRequired bool       `gorm:"default:false;not null" json:"password_reset_required"`
+    SessionsRevokedAt     *time.Time `gorm:"default:null" json:"-"` // tokens issued before this are rejected
 
     // Subscription and billing - UPDATED DEFAULTS
     SubscriptionPlan      SubscriptionPlan `gorm:"default:'basic';not null;size:15" json:"subscription_plan"`
     CharacterBalance      int              `gorm:"default:2500;not null" json:"character_balance"`      // Updated default
     TotalCharacterBalance int              `gorm:"default:2500;not null" json:"total_character_balance"` // Updated default
 
+    // Preferences
+    PreferredLanguage string `gorm:"default:'auto';not null;size:8" json:"preferred_language"`
+    SMSNotifications  bool   `gorm:"default:true;not null" json:"sms_notifications"` // low balance and plan notices; codes are always sent
+
     // Timestamps
-    CreatedAt time.Time       `json:"created_at"`
+    CreatedAt time.Time       `gorm:"index" json:"created_at"`
     UpdatedAt time.Time       `json:"updated_at"`
     DeletedAt gorm.DeletedAt  `gorm:"index" json:"-"` // Soft delete protection
 }
@@ -84,6 +108,23 @@ func NewUser(username, phoneNumber, hashedPassword string) *User {
     }
 }
 
+// AdminRole returns the user's admin role, or "" for regular users. IsAdmin users
+// without a role are superadmins, so accounts from before roles existed keep access.
+func (u *User) AdminRole() string {
+    if u.Role != "" {
+        return u.Role
+    }
+    if u.IsAdmin {
+        return RoleSuperadmin
+    }
+    return ""
+}
+
+// HasPermission reports whether the user's admin role grants the permission
+func (u *User) HasPermission(permission string) bool {
+    return RoleHasPermission(u.AdminRole(), permission)
+}
+
 // Business logic methods
 func (u *User) IsValid() error {
     if len(u.Username) < 3 {
@@ -217,6 +258,24 @@ func (u *User) UnlockAccount() {
     u.FailedLoginAttempts = 0
 }
 
+// IsSuspended reports whether an admin has suspended the account
+func (u *User) IsSuspended() bool {
+    return u.Status == UserStatusSuspended
+}
+
+// RevokeSessions invalidates every token issued so far, signing the user out everywhere
+func (u *User) RevokeSessions() {
+    now := time.Now()
+    u.SessionsRevokedAt = &now
+}
+
+// IsTokenRevoked reports whether a token issued at issuedAt predates the last
+// revocation. Token times have one-second resolution, so the revocation time is
+// truncated to match.
+func (u *User) IsTokenRevoked(issuedAt time.Time) bool {
+    return u.SessionsRevokedAt != nil && issuedAt.Before(u.SessionsRevokedAt.Truncate(time.Second))
+}
+
 // IncrementFailedLogin increments failed login attempts
 func (u *User) IncrementFailedLogin() {
     u.FailedLoginAttempts++
@@ -228,3 +287,9 @@ type BalanceUpdate struct {
     UserID uint
     Amount int
 }
+
+// PlanUpdate moves one user to another subscription plan
+type PlanUpdate struct {
+    UserID uint
+    Plan   SubscriptionPlan
+}
@@ -18,7 +18,8 @@ const (
 type VerificationCode struct {
     ID          uint                 `gorm:"primaryKey"`
     PhoneNumber string               `gorm:"index;not null;size:15"`
-    Code        string               `gorm:"not null;size:10"`
+    CodeHash    string               `gorm:"column:code;not null;size:64"` // hex HMAC-SHA256 of the code, never the code itself
+    CodeSalt    string               `gorm:"not null;size:32;default:''"`
     Type        VerificationCodeType `gorm:"not null;size:20;index"` // SMS, password_reset, etc.
     
     // Security and rate limiting
@@ -0,0 +1,27 @@
+// File: internal/domain/verification_send.go
+package domain
+
+import (
+    "time"
+)
+
+// VerificationSend logs one request to text a verification code, whether it was sent or
+// refused. Resend cooldowns, daily caps and SMS-pumping checks count these rows, so the
+// limits hold across restarts and server instances.
+type VerificationSend struct {
+    ID          uint                 `gorm:"primaryKey"`
+    PhoneNumber string               `gorm:"index;not null;size:15"`
+    Type        VerificationCodeType `gorm:"not null;size:20"`
+    ClientIP    string               `gorm:"index;size:45"`
+    Prefix      string               `gorm:"index;size:15"` // the number without its last digits; pumping walks number ranges
+    Blocked     string               `gorm:"size:20"`       // VerificationBlock* reason, empty when the code was sent
+    CreatedAt   time.Time            `gorm:"index"`
+}
+
+// Reasons a verification code was not sent
+const (
+    VerificationBlockCooldown  = "cooldown"   // asked again before the resend cooldown passed
+    VerificationBlockDailyCap  = "daily_cap"  // the number hit its daily limit
+    VerificationBlockIPPumping = "ip_pumping" // the client IP asked for too many different numbers
+    VerificationBlockPrefix    = "prefix"     // too many different numbers in one number range
+)
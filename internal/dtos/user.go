@@ -108,8 +108,11 @@ type AdminUserResponseDTO struct {
     PlanAllowance         int     `json:"plan_allowance"`
     MonthlyPrice          string  `json:"monthly_price"`
     IsAdmin               bool    `json:"is_admin"`
+    Role                  string  `json:"role,omitempty"`
     FailedLoginAttempts   int     `json:"failed_login_attempts"`
     IsLocked              bool    `json:"is_locked"`
+    SuspendReason         string  `json:"suspend_reason,omitempty"`
+    PasswordResetRequired bool    `json:"password_reset_required"`
     CreatedAt             string  `json:"created_at"`
     UpdatedAt             string  `json:"updated_at"`
     LastFailedLoginAt     *string `json:"last_failed_login_at,omitempty"`
@@ -202,8 +205,11 @@ func ToAdminDomain(user domain.User) AdminUserResponseDTO {
         PlanAllowance:         user.GetPlanAllowance(),
         MonthlyPrice:          FormatPriceInToman(monthlyPrice),
         IsAdmin:               user.IsAdmin,
+        Role:                  user.AdminRole(),
         FailedLoginAttempts:   user.FailedLoginAttempts,
         IsLocked:              user.IsAccountLocked(),
+        SuspendReason:         user.SuspendReason,
+        PasswordResetRequired: user.PasswordResetRequired,
         CreatedAt:             user.CreatedAt.Format(time.RFC3339),
         UpdatedAt:             user.UpdatedAt.Format(time.RFC3339),
     }
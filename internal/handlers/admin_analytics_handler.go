@@ -0,0 +1,152 @@
+// File: internal/handlers/admin_analytics_handler.go
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/repository/analytics"
+	"github.com/iyunix/go-internist/internal/services/admin_services"
+)
+
+const (
+	defaultAnalyticsDays = 30
+	maxAnalyticsBuckets  = 400 // e.g. a bit over a year of daily points
+)
+
+// parseAnalyticsRange reads from, to (RFC 3339 or YYYY-MM-DD; a bare "to" date includes
+// that whole day) and granularity (day, week or month). It defaults to the last 30 days
+// by day and rejects ranges that would produce too many buckets.
+func parseAnalyticsRange(query url.Values) (analytics.Range, error) {
+	rng := analytics.Range{Granularity: query.Get("granularity")}
+	if rng.Granularity == "" {
+		rng.Granularity = domain.GranularityDay
+	}
+	if !domain.IsValidGranularity(rng.Granularity) {
+		return rng, errors.New("invalid granularity: use day, week or month")
+	}
+
+	var err error
+	if rng.From, err = parseTimeParam(query.Get("from"), false); err != nil {
+		return rng, errors.New("invalid from: use RFC 3339 or YYYY-MM-DD")
+	}
+	if rng.To, err = parseTimeParam(query.Get("to"), true); err != nil {
+		return rng, errors.New("invalid to: use RFC 3339 or YYYY-MM-DD")
+	}
+	if rng.To.IsZero() {
+		rng.To = time.Now().UTC()
+	}
+	if rng.From.IsZero() {
+		rng.From = rng.To.AddDate(0, 0, -defaultAnalyticsDays)
+	}
+	if !rng.From.Before(rng.To) {
+		return rng, errors.New("from must be before to")
+	}
+
+	days := rng.To.Sub(rng.From).Hours() / 24
+	buckets := days
+	switch rng.Granularity {
+	case domain.GranularityWeek:
+		buckets = days / 7
+	case domain.GranularityMonth:
+		buckets = days / 30
+	}
+	if buckets > maxAnalyticsBuckets {
+		return rng, errors.New("range too large for this granularity: narrow it or use a coarser granularity")
+	}
+	return rng, nil
+}
+
+// GetAnalyticsHandler returns several time series at once, all of them unless series
+// names a comma-separated subset.
+// 🚀 Route: GET /api/admin/analytics?series=questions,active_users&from=2025-01-01&to=2025-03-31&granularity=week
+func (h *AdminHandler) GetAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	rng, err := parseAnalyticsRange(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	names := domain.AnalyticsSeries()
+	if v := r.URL.Query().Get("series"); v != "" {
+		names = strings.Split(v, ",")
+	}
+
+	result := make(map[string][]domain.SeriesPoint, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		points, err := h.adminService.GetAnalyticsSeries(r.Context(), name, rng)
+		if errors.Is(err, admin_services.ErrUnknownSeries) {
+			writeJSONError(w, "Unknown series: "+name, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to compute analytics series", "series", name, "error", err)
+			writeJSONError(w, "Failed to compute analytics", http.StatusInternalServerError)
+			return
+		}
+		result[name] = points
+	}
+
+	writeJSONSuccess(w, map[string]interface{}{
+		"from":        rng.From,
+		"to":          rng.To,
+		"granularity": rng.Granularity,
+		"series":      result,
+	})
+}
+
+// GetAnalyticsSeriesHandler returns one time series.
+// 🚀 Route: GET /api/admin/analytics/stage_latency?from=2025-01-01&granularity=day
+func (h *AdminHandler) GetAnalyticsSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	rng, err := parseAnalyticsRange(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["series"]
+	points, err := h.adminService.GetAnalyticsSeries(r.Context(), name, rng)
+	if errors.Is(err, admin_services.ErrUnknownSeries) {
+		writeJSONError(w, "Unknown series: "+name, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to compute analytics series", "series", name, "error", err)
+		writeJSONError(w, "Failed to compute analytics", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONSuccess(w, map[string]interface{}{
+		"series":      name,
+		"from":        rng.From,
+		"to":          rng.To,
+		"granularity": rng.Granularity,
+		"points":      points,
+	})
+}
+
+// GetAnalyticsSummaryHandler returns headline message totals and active chats.
+// 🚀 Route: GET /api/admin/analytics/summary?from=2025-01-01
+func (h *AdminHandler) GetAnalyticsSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	rng, err := parseAnalyticsRange(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summary, err := h.adminService.GetAnalyticsSummary(r.Context(), rng)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to compute analytics summary", "error", err)
+		writeJSONError(w, "Failed to compute analytics summary", http.StatusInternalServerError)
+		return
+	}
+	writeJSONSuccess(w, summary)
+}
@@ -0,0 +1,94 @@
+// File: internal/handlers/admin_feedback_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/repository/message"
+)
+
+// GetFeedbackReviewQueueHandler lists rated answers with their question, internal_context
+// rewrite and sources. Thumbs-down answers are listed unless rating=up is given.
+// 🚀 Route: GET /api/admin/feedback?status=open&category=unsafe&rating=down&page=1&limit=20
+func (h *AdminHandler) GetFeedbackReviewQueueHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := message.FeedbackReviewFilter{
+		Category:     query.Get("category"),
+		ReviewStatus: query.Get("status"),
+	}
+	switch query.Get("rating") {
+	case "", "down":
+		filter.Rating = domain.FeedbackDown
+	case "up":
+		filter.Rating = domain.FeedbackUp
+	default:
+		writeJSONError(w, "rating must be up or down", http.StatusBadRequest)
+		return
+	}
+
+	reviews, total, err := h.adminService.GetFeedbackForReview(r.Context(), filter, page, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get feedback review queue", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSONSuccess(w, map[string]interface{}{
+		"feedback": reviews,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+type feedbackReviewRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+// ReviewFeedbackHandler records a reviewer's verdict: confirmed, dismissed, or open to reopen.
+// 🚀 Route: PATCH /api/admin/feedback/{id}
+func (h *AdminHandler) ReviewFeedbackHandler(w http.ResponseWriter, r *http.Request) {
+	feedbackID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil || feedbackID == 0 {
+		writeJSONError(w, "Invalid feedback id", http.StatusBadRequest)
+		return
+	}
+	var req feedbackReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !domain.IsValidFeedbackReviewStatus(req.Status) {
+		writeJSONError(w, "status must be open, confirmed or dismissed", http.StatusBadRequest)
+		return
+	}
+
+	feedback, err := h.adminService.ReviewFeedback(r.Context(), adminActor(r), uint(feedbackID), req.Status, req.Note)
+	if err == message.ErrFeedbackNotFound {
+		writeJSONError(w, "Feedback not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to review feedback", "feedback_id", feedbackID, "error", err)
+		writeJSONError(w, "Failed to review feedback", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONSuccess(w, feedback)
+}
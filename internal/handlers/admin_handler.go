@@ -2,26 +2,27 @@
 package handlers
 
 import (
-	"context"
-	"encoding/csv"
 	"encoding/json"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/ratelimit"
 	"github.com/iyunix/go-internist/internal/services/admin_services"
+	"github.com/iyunix/go-internist/internal/services/user_services"
 )
 
 type AdminHandler struct {
 	adminService *admin_services.AdminService
+	authService  *user_services.AuthService
 }
 
-func NewAdminHandler(adminService *admin_services.AdminService) *AdminHandler {
+func NewAdminHandler(adminService *admin_services.AdminService, authService *user_services.AuthService) *AdminHandler {
 	return &AdminHandler{
 		adminService: adminService,
+		authService:  authService,
 	}
 }
 
@@ -38,6 +39,19 @@ func writeJSONSuccess(w http.ResponseWriter, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// adminActor identifies the admin making the request, for the audit log. The IP is the
+// peer address unless it is a trusted proxy (TRUSTED_PROXIES), so a forged
+// X-Forwarded-For cannot put another address on the record.
+func adminActor(r *http.Request) admin_services.Actor {
+	userID, username, _ := middleware.GetAdminFromContext(r)
+	return admin_services.Actor{
+		UserID:    userID,
+		Username:  username,
+		IP:        ratelimit.GetClientIP(r),
+		RequestID: middleware.GetRequestID(r),
+	}
+}
+
 // GetAllUsersHandler handles the API request to fetch all users with pagination and search.
 // 🚀 Route: GET /api/v1/admin/users?page=1&limit=10&search=john
 func (h *AdminHandler) GetAllUsersHandler(w http.ResponseWriter, r *http.Request) {
@@ -57,7 +71,7 @@ func (h *AdminHandler) GetAllUsersHandler(w http.ResponseWriter, r *http.Request
 
 	users, total, err := h.adminService.GetAllUsers(r.Context(), page, limit, search)
 	if err != nil {
-		log.Printf("[AdminHandler] Error getting all users: %v", err)
+		slog.ErrorContext(r.Context(), "failed to get users", "error", err)
 		writeJSONError(w, "Failed to retrieve users", http.StatusInternalServerError)
 		return
 	}
@@ -87,8 +101,8 @@ func (h *AdminHandler) RenewSubscriptionHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	if err := h.adminService.RenewSubscription(r.Context(), req.UserID); err != nil {
-		log.Printf("[AdminHandler] Error renewing subscription for user %d: %v", req.UserID, err)
+	if err := h.adminService.RenewSubscription(r.Context(), adminActor(r), req.UserID); err != nil {
+		slog.ErrorContext(r.Context(), "failed to renew subscription", "user_id", req.UserID, "error", err)
 		writeJSONError(w, "Failed to renew subscription", http.StatusInternalServerError)
 		return
 	}
@@ -112,8 +126,8 @@ func (h *AdminHandler) ChangePlanHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.adminService.ChangeUserPlan(r.Context(), req.UserID, req.NewPlan); err != nil {
-		log.Printf("[AdminHandler] Error changing plan for user %d: %v", req.UserID, err)
+	if err := h.adminService.ChangeUserPlan(r.Context(), adminActor(r), req.UserID, req.NewPlan); err != nil {
+		slog.ErrorContext(r.Context(), "failed to change plan", "user_id", req.UserID, "error", err)
 		writeJSONError(w, "Failed to change plan", http.StatusInternalServerError)
 		return
 	}
@@ -141,71 +155,11 @@ func (h *AdminHandler) TopUpBalanceHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := h.adminService.TopUpBalance(r.Context(), req.UserID, req.Amount); err != nil {
-		log.Printf("[AdminHandler] Error topping up balance for user %d: %v", req.UserID, err)
+	if err := h.adminService.TopUpBalance(r.Context(), adminActor(r), req.UserID, req.Amount); err != nil {
+		slog.ErrorContext(r.Context(), "failed to top up balance", "user_id", req.UserID, "error", err)
 		writeJSONError(w, "Failed to top up balance", http.StatusInternalServerError)
 		return
 	}
 
 	writeJSONSuccess(w, map[string]string{"message": "Balance topped up successfully"})
 }
-
-// ————————————————————————————————————————————————————————————————————————————————
-
-// ExportUsersCSVHandler exports all users as CSV (with optional pagination for large datasets).
-// 🚀 Route: GET /api/v1/admin/users/export?chunk_size=5000&page=1
-func (h *AdminHandler) ExportUsersCSVHandler(w http.ResponseWriter, r *http.Request) {
-	// Optional: Add timeout for large exports
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
-
-	query := r.URL.Query()
-
-	// Optional: Support chunked export for huge datasets
-	page, _ := strconv.Atoi(query.Get("page"))
-	limit, _ := strconv.Atoi(query.Get("chunk_size"))
-
-	// If no pagination, export all (service should handle 0,0 as "all")
-	users, _, err := h.adminService.GetAllUsers(ctx, page, limit, "")
-	if err != nil {
-		log.Printf("[AdminHandler] Error exporting users: %v", err)
-		http.Error(w, "Failed to export users", http.StatusInternalServerError)
-		return
-	}
-
-	filename := fmt.Sprintf("users_export_%s.csv", time.Now().Format("2006-01-02"))
-	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-
-	csvWriter := csv.NewWriter(w)
-	defer csvWriter.Flush()
-
-	// ✅ FIELD WHITELISTING — Explicitly define columns to export
-	// Safe against schema changes or accidental PII exposure
-	header := []string{"ID", "Username", "PhoneNumber", "Status", "IsAdmin", "CurrentBalance", "TotalBalance", "Plan"}
-	if err := csvWriter.Write(header); err != nil {
-		log.Printf("[AdminHandler] Error writing CSV header: %v", err)
-		return
-	}
-
-	for _, user := range users {
-		record := []string{
-			strconv.FormatUint(uint64(user.ID), 10), // adjust if ID is int64 → use FormatInt
-			user.Username,
-			user.PhoneNumber,
-			string(user.Status),
-			strconv.FormatBool(user.IsAdmin),
-			strconv.Itoa(user.CharacterBalance),
-			strconv.Itoa(user.TotalCharacterBalance),
-			string(user.SubscriptionPlan),
-		}
-		if err := csvWriter.Write(record); err != nil {
-			log.Printf("[AdminHandler] Error writing CSV record for user %d: %v", user.ID, err)
-			return
-		}
-		// Flush after each row for streaming safety — prevents memory buildup
-		csvWriter.Flush()
-	}
-
-	log.Printf("[AdminHandler] Successfully exported %d users to CSV.", len(users))
-}
\ No newline at end of file
@@ -0,0 +1,90 @@
+// File: internal/handlers/admin_import_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/services/admin_services"
+)
+
+// maxImportBytes caps the size of an uploaded import file
+const maxImportBytes = 1 << 20
+
+// ImportUsersHandler creates or tops up accounts from a CSV with phone, plan, credits
+// and optional username columns. By default it is a dry run that only reports what
+// would change; dry_run=false applies every row in one transaction, or none if any
+// row is invalid. The file is sent as multipart field "file" or as a text/csv body.
+// 🚀 Route: POST /api/admin/users/import?dry_run=false
+func (h *AdminHandler) ImportUsersHandler(w http.ResponseWriter, r *http.Request) {
+	dryRun := true
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSONError(w, "Invalid dry_run", http.StatusBadRequest)
+			return
+		}
+		dryRun = parsed
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var file io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		upload, _, err := r.FormFile("file")
+		if err != nil {
+			writeJSONError(w, "Upload the CSV as form field \"file\" (max 1 MB)", http.StatusBadRequest)
+			return
+		}
+		defer upload.Close()
+		file = upload
+	}
+
+	rows, err := admin_services.ParseBulkCSV(file)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, "CSV is larger than 1 MB", http.StatusRequestEntityTooLarge)
+			return
+		}
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if dryRun {
+		report, err := h.adminService.PreviewBulkImport(r.Context(), rows)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to preview user import", "error", err)
+			writeJSONError(w, "Failed to check import", http.StatusInternalServerError)
+			return
+		}
+		writeJSONSuccess(w, report)
+		return
+	}
+
+	report, err := h.adminService.ApplyBulkImport(r.Context(), adminActor(r), rows)
+	if errors.Is(err, admin_services.ErrBulkInvalid) {
+		// Nothing was saved; send the report so the admin can fix the listed rows
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "report": report})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to apply user import", "error", err)
+		writeJSONError(w, "Failed to apply import; no changes were saved", http.StatusInternalServerError)
+		return
+	}
+
+	for _, row := range report.Rows {
+		if row.Action == admin_services.BulkActionUpdate {
+			middleware.ClearUserCache(row.UserID)
+		}
+	}
+	writeJSONSuccess(w, report)
+}
@@ -0,0 +1,66 @@
+// File: internal/handlers/admin_roles_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/services/admin_services"
+)
+
+// GetRolesHandler lists the admin roles with their permissions, and the caller's own
+// role so the admin panel can hide what it cannot do.
+// 🚀 Route: GET /api/admin/roles
+func (h *AdminHandler) GetRolesHandler(w http.ResponseWriter, r *http.Request) {
+	role := middleware.GetAdminRoleFromContext(r)
+	writeJSONSuccess(w, map[string]interface{}{
+		"roles":       domain.RolePermissions,
+		"role":        role,
+		"permissions": domain.RolePermissions[role],
+	})
+}
+
+type assignRoleRequest struct {
+	Role string `json:"role"` // empty removes admin access
+}
+
+// AssignRoleHandler sets a user's admin role.
+// 🚀 Route: PUT /api/admin/users/{id}/role
+func (h *AdminHandler) AssignRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil || userID == 0 {
+		writeJSONError(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	var req assignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.adminService.AssignRole(r.Context(), adminActor(r), uint(userID), req.Role)
+	switch {
+	case err == admin_services.ErrInvalidRole, err == admin_services.ErrOwnRoleChange:
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "failed to assign role", "user_id", userID, "error", err)
+		writeJSONError(w, "Failed to assign role", http.StatusInternalServerError)
+		return
+	}
+
+	// Cached roles would keep the old access for up to the cache lifetime
+	middleware.ClearAdminCache(user.ID)
+	middleware.ClearUserCache(user.ID)
+
+	writeJSONSuccess(w, map[string]interface{}{
+		"userID":      user.ID,
+		"role":        user.AdminRole(),
+		"permissions": domain.RolePermissions[user.AdminRole()],
+	})
+}
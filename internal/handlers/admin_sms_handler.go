@@ -0,0 +1,68 @@
+// File: internal/handlers/admin_sms_handler.go
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iyunix/go-internist/internal/repository/outbox"
+)
+
+// GetSMSOutboxHandler lists recent SMS sends with their status, provider and last error.
+// 🚀 Route: GET /api/admin/sms?phone=+989121234567&status=failed&page=1&limit=50
+func (h *AdminHandler) GetSMSOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	filter := outbox.Filter{Phone: query.Get("phone"), Status: query.Get("status")}
+	messages, total, err := h.adminService.GetRecentSMS(r.Context(), filter, page, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get SMS outbox", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSONSuccess(w, map[string]interface{}{
+		"messages": messages,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+// GetSMSPhoneSummaryHandler counts sends and failures per phone number.
+// 🚀 Route: GET /api/admin/sms/phones?since_hours=24&limit=100
+func (h *AdminHandler) GetSMSPhoneSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	hours, err := strconv.Atoi(query.Get("since_hours"))
+	if err != nil || hours < 1 || hours > 24*30 {
+		hours = 24
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	summaries, err := h.adminService.GetSMSPhoneSummary(r.Context(), time.Duration(hours)*time.Hour, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to summarize SMS outbox", "error", err)
+		writeJSONError(w, "Failed to summarize SMS sends", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONSuccess(w, map[string]interface{}{
+		"phones":      summaries,
+		"since_hours": hours,
+	})
+}
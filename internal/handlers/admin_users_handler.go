@@ -0,0 +1,233 @@
+// File: internal/handlers/admin_users_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/repository/user"
+	"github.com/iyunix/go-internist/internal/services/admin_services"
+)
+
+// pathUserID reads the {id} route variable
+func pathUserID(r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// writeUserActionError maps account management errors to responses
+func writeUserActionError(w http.ResponseWriter, r *http.Request, action string, userID uint, err error) {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		writeJSONError(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, admin_services.ErrOwnAccount), errors.Is(err, admin_services.ErrTargetIsAdmin):
+		writeJSONError(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, admin_services.ErrAlreadySuspended), errors.Is(err, admin_services.ErrNotSuspended):
+		writeJSONError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, admin_services.ErrSuspendReasonLimit):
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+	default:
+		slog.ErrorContext(r.Context(), "user action failed", "action", action, "user_id", userID, "error", err)
+		writeJSONError(w, "Failed to "+action+" user", http.StatusInternalServerError)
+	}
+}
+
+// userStatusResponse summarises the account state after a change
+func userStatusResponse(u *domain.User) map[string]interface{} {
+	return map[string]interface{}{
+		"userID":                  u.ID,
+		"status":                  u.Status,
+		"suspend_reason":          u.SuspendReason,
+		"is_locked":               u.IsAccountLocked(),
+		"password_reset_required": u.PasswordResetRequired,
+	}
+}
+
+type suspendRequest struct {
+	Reason string `json:"reason"`
+}
+
+// SuspendUserHandler blocks a user and signs them out.
+// 🚀 Route: POST /api/admin/users/{id}/suspend {"reason": "..."}
+func (h *AdminHandler) SuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(r)
+	if !ok {
+		writeJSONError(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	var req suspendRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	u, err := h.adminService.SuspendUser(r.Context(), adminActor(r), userID, req.Reason)
+	if err != nil {
+		writeUserActionError(w, r, "suspend", userID, err)
+		return
+	}
+	middleware.ClearUserCache(userID)
+	middleware.ClearAdminCache(userID)
+	writeJSONSuccess(w, userStatusResponse(u))
+}
+
+// ReactivateUserHandler lifts a suspension.
+// 🚀 Route: POST /api/admin/users/{id}/reactivate
+func (h *AdminHandler) ReactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(r)
+	if !ok {
+		writeJSONError(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	u, err := h.adminService.ReactivateUser(r.Context(), adminActor(r), userID)
+	if err != nil {
+		writeUserActionError(w, r, "reactivate", userID, err)
+		return
+	}
+	middleware.ClearUserCache(userID)
+	middleware.ClearAdminCache(userID)
+	writeJSONSuccess(w, userStatusResponse(u))
+}
+
+// UnlockUserHandler clears a failed-login lockout.
+// 🚀 Route: POST /api/admin/users/{id}/unlock
+func (h *AdminHandler) UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(r)
+	if !ok {
+		writeJSONError(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	u, err := h.adminService.UnlockUser(r.Context(), adminActor(r), userID)
+	if err != nil {
+		writeUserActionError(w, r, "unlock", userID, err)
+		return
+	}
+	middleware.ClearUserCache(userID)
+	middleware.ClearAdminCache(userID)
+	writeJSONSuccess(w, userStatusResponse(u))
+}
+
+// ForcePasswordResetHandler signs a user out and requires a new password at next login.
+// 🚀 Route: POST /api/admin/users/{id}/force-password-reset
+func (h *AdminHandler) ForcePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(r)
+	if !ok {
+		writeJSONError(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	u, err := h.adminService.ForcePasswordReset(r.Context(), adminActor(r), userID)
+	if err != nil {
+		writeUserActionError(w, r, "force password reset for", userID, err)
+		return
+	}
+	middleware.ClearUserCache(userID)
+	middleware.ClearAdminCache(userID)
+	writeJSONSuccess(w, userStatusResponse(u))
+}
+
+// DeleteUserHandler soft-deletes a user, keeping their data for a later restore.
+// 🚀 Route: DELETE /api/admin/users/{id}
+func (h *AdminHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(r)
+	if !ok {
+		writeJSONError(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	if err := h.adminService.DeleteUser(r.Context(), adminActor(r), userID); err != nil {
+		writeUserActionError(w, r, "delete", userID, err)
+		return
+	}
+	middleware.ClearUserCache(userID)
+	middleware.ClearAdminCache(userID)
+	writeJSONSuccess(w, map[string]interface{}{"userID": userID, "deleted": true})
+}
+
+// RestoreUserHandler brings back a soft-deleted user.
+// 🚀 Route: POST /api/admin/users/{id}/restore
+func (h *AdminHandler) RestoreUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(r)
+	if !ok {
+		writeJSONError(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	u, err := h.adminService.RestoreUser(r.Context(), adminActor(r), userID)
+	if err != nil {
+		writeUserActionError(w, r, "restore", userID, err)
+		return
+	}
+	middleware.ClearUserCache(userID)
+	middleware.ClearAdminCache(userID)
+	writeJSONSuccess(w, userStatusResponse(u))
+}
+
+// ImpersonateUserHandler starts a read-only "view as user" session in the admin's
+// browser. Every request made in it is audited until it expires or is stopped.
+// 🚀 Route: POST /api/admin/users/{id}/impersonate
+func (h *AdminHandler) ImpersonateUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(r)
+	if !ok {
+		writeJSONError(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	actor := adminActor(r)
+	u, err := h.adminService.StartImpersonation(r.Context(), actor, userID)
+	if err != nil {
+		writeUserActionError(w, r, "impersonate", userID, err)
+		return
+	}
+
+	token, err := h.authService.GenerateImpersonationToken(actor.UserID, u.ID, middleware.ImpersonationTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to issue impersonation token", "user_id", userID, "error", err)
+		writeJSONError(w, "Failed to start impersonation", http.StatusInternalServerError)
+		return
+	}
+	expires := time.Now().Add(middleware.ImpersonationTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.ImpersonationCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	// Drop any cached state from before the session so the view is current
+	middleware.ClearUserCache(u.ID)
+
+	writeJSONSuccess(w, map[string]interface{}{
+		"userID":     u.ID,
+		"username":   u.Username,
+		"expires_at": expires.UTC().Format(time.RFC3339),
+		"redirect":   "/chat",
+	})
+}
+
+// StopImpersonationHandler ends a "view as user" session and returns to the admin panel.
+// 🚀 Route: POST /admin/impersonation/stop
+func (h *AdminHandler) StopImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(middleware.ImpersonationCookie); err == nil && cookie.Value != "" {
+		actor := adminActor(r)
+		if adminID, userID, err := h.authService.ValidateImpersonationToken(cookie.Value); err == nil && adminID == actor.UserID {
+			if err := h.adminService.StopImpersonation(r.Context(), actor, userID); err != nil {
+				// Keep the session so the admin can retry; it must not end unrecorded
+				http.Error(w, "Failed to record the end of the session; try again", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	middleware.ClearImpersonationCookie(w)
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
@@ -3,9 +3,8 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
-	"log"
-	"math/rand"
+	"errors"
+	"log/slog"
 	"net/http"
 	"regexp"
 	"strings"
@@ -13,6 +12,10 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/ratelimit"
 	"github.com/iyunix/go-internist/internal/services"
 	"github.com/iyunix/go-internist/internal/services/user_services"
 )
@@ -63,9 +66,18 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	_, token, err := h.AuthService.Login(r.Context(), identifier, password)
 	if err != nil {
-		log.Printf("Login error: %v", err)
+		slog.ErrorContext(r.Context(), "login failed", "error", err)
+		message := "Invalid credentials."
+		switch {
+		case errors.Is(err, user_services.ErrAccountSuspended):
+			message = "This account has been suspended. Please contact support."
+		case errors.Is(err, user_services.ErrAccountLocked):
+			message = "This account is temporarily locked. Please try again later."
+		case errors.Is(err, user_services.ErrPasswordResetRequired):
+			message = "You need to reset your password before signing in. Use \"Forgot password\" to set a new one."
+		}
 		data := map[string]interface{}{
-			"Error":      "Invalid credentials.",
+			"Error":      message,
 			"Identifier": identifier,
 		}
 		RenderTemplate(w, "login.html", data)
@@ -88,7 +100,6 @@ type PendingRegistration struct {
 	Username string
 	Phone    string
     Password string // store as plain text just for verification duration
-	Code     string
 	Expires  time.Time
 }
 
@@ -126,20 +137,32 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-    code := generate6DigitCode()
+    if err := h.VerificationService.SendRegistrationCode(r.Context(), validatedPhone, ratelimit.GetClientIP(r)); err != nil {
+        data := map[string]interface{}{"Error": sendCodeErrorMessage(r, err), "Username": username, "PhoneNumber": validatedPhone}
+        RenderTemplate(w, "register.html", data)
+        return
+    }
     pendingRegistrations[validatedPhone] = &PendingRegistration{
         Username: username,
         Phone:    validatedPhone,
         Password: validPassword, // <-- store plaintext password
-        Code:     code,
         Expires:  time.Now().Add(15 * time.Minute),
     }
-    h.SMSService.SendVerificationCode(r.Context(), validatedPhone, code)
     http.Redirect(w, r, "/verify-sms?phone="+validatedPhone, http.StatusSeeOther)
 }
 
-func generate6DigitCode() string {
-	return fmt.Sprintf("%06d", rand.Intn(1000000))
+// sendCodeErrorMessage turns a failure to send a verification code into a fixed message
+// for the page. Limit errors are worded for users already; anything else is logged and
+// shown generically so provider and database errors never reach the browser.
+func sendCodeErrorMessage(r *http.Request, err error) string {
+	switch {
+	case errors.Is(err, user_services.ErrCodeCooldown),
+		errors.Is(err, user_services.ErrCodeDailyLimit),
+		errors.Is(err, user_services.ErrCodeSendBlocked):
+		return err.Error()
+	}
+	slog.ErrorContext(r.Context(), "sending verification code failed", "error", err)
+	return "We could not send a verification code right now. Please try again later."
 }
 
 // Forgot password: always redirect to verification
@@ -154,7 +177,9 @@ func (h *AuthHandler) HandleForgotPassword(w http.ResponseWriter, r *http.Reques
 		RenderTemplate(w, "forgot_password.html", data)
 		return
 	}
-	_ = h.VerificationService.SendPasswordResetCode(r.Context(), phone)
+	// Errors (limits included) are not shown; only existing users get that far, so
+	// showing them would reveal which numbers have accounts
+	_ = h.VerificationService.SendPasswordResetCode(r.Context(), phone, ratelimit.GetClientIP(r))
 	http.Redirect(w, r, "/verify-sms?phone="+phone+"&action=reset", http.StatusSeeOther)
 }
 
@@ -193,7 +218,7 @@ func (h *AuthHandler) VerifySMS(w http.ResponseWriter, r *http.Request) {
         RenderTemplate(w, "verify_sms.html", data)
         return
     }
-    if pend.Code != code {
+    if err := h.VerificationService.VerifyRegistrationCode(r.Context(), phone, code); err != nil {
         data := map[string]interface{}{"Error": "Invalid verification code.", "PhoneNumber": phone}
         RenderTemplate(w, "verify_sms.html", data)
         return
@@ -252,7 +277,7 @@ func (h *AuthHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request
 		return
 	}
 	if err := h.VerificationService.VerifyAndResetPassword(r.Context(), phone, code, password); err != nil {
-		log.Printf("Error resetting password for phone %s: %v", phone, err)
+		slog.WarnContext(r.Context(), "password reset failed", "phone", logging.MaskPhone(phone), "error", err)
 		http.Redirect(w, r, "/reset-password?error=Invalid code or user. Please try again.&phone="+phone, http.StatusSeeOther)
 		return
 	}
@@ -266,14 +291,21 @@ func (h *AuthHandler) ResendSMS(w http.ResponseWriter, r *http.Request) {
 		RenderTemplate(w, "verify_sms.html", data)
 		return
 	}
-	user, err := h.UserService.GetUserByPhone(r.Context(), phone)
-	if err != nil {
-		data := map[string]interface{}{"Error": "User not found.", "PhoneNumber": phone}
-		RenderTemplate(w, "verify_sms.html", data)
-		return
+	clientIP := ratelimit.GetClientIP(r)
+	var err error
+	if pend, ok := pendingRegistrations[phone]; ok && time.Now().Before(pend.Expires) {
+		err = h.VerificationService.SendRegistrationCode(r.Context(), phone, clientIP)
+	} else {
+		user, findErr := h.UserService.GetUserByPhone(r.Context(), phone)
+		if findErr != nil {
+			data := map[string]interface{}{"Error": "User not found.", "PhoneNumber": phone}
+			RenderTemplate(w, "verify_sms.html", data)
+			return
+		}
+		err = h.VerificationService.ResendVerificationCode(r.Context(), user.ID, clientIP)
 	}
-	if err := h.VerificationService.ResendVerificationCode(r.Context(), user.ID); err != nil {
-		data := map[string]interface{}{"Error": err.Error(), "PhoneNumber": phone}
+	if err != nil {
+		data := map[string]interface{}{"Error": sendCodeErrorMessage(r, err), "PhoneNumber": phone}
 		RenderTemplate(w, "verify_sms.html", data)
 		return
 	}
@@ -316,6 +348,72 @@ func (h *AuthHandler) GetUserCreditHandler(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(response)
 }
 
+// userPreferences is the body of GET/PUT /api/user/preferences. PUT changes only the
+// fields it is given.
+type userPreferences struct {
+	ResponseLanguage string `json:"response_language,omitempty"` // "auto", "en" or "fa"
+	SMSNotifications *bool  `json:"sms_notifications,omitempty"` // low balance and plan notices
+}
+
+// GetPreferencesHandler returns the user's chat preferences
+func (h *AuthHandler) GetPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Authentication error", http.StatusUnauthorized)
+		return
+	}
+	lang, err := h.UserService.GetPreferredLanguage(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve preferences", http.StatusInternalServerError)
+		return
+	}
+	smsNotifications, err := h.UserService.GetSMSNotifications(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve preferences", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userPreferences{ResponseLanguage: lang, SMSNotifications: &smsNotifications})
+}
+
+// UpdatePreferencesHandler changes the language assistant answers are written in and
+// whether account notifications are sent by SMS
+func (h *AuthHandler) UpdatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Authentication error", http.StatusUnauthorized)
+		return
+	}
+	var req userPreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.ResponseLanguage = strings.ToLower(strings.TrimSpace(req.ResponseLanguage))
+	if req.ResponseLanguage == "" && req.SMSNotifications == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+	if req.ResponseLanguage != "" {
+		if !domain.IsSupportedLanguage(req.ResponseLanguage) {
+			http.Error(w, "response_language must be one of auto, en, fa", http.StatusBadRequest)
+			return
+		}
+		if err := h.UserService.SetPreferredLanguage(r.Context(), userID, req.ResponseLanguage); err != nil {
+			http.Error(w, "Failed to update preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.SMSNotifications != nil {
+		if err := h.UserService.SetSMSNotifications(r.Context(), userID, *req.SMSNotifications); err != nil {
+			http.Error(w, "Failed to update preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
 // simple helper for registration input validation
 func validateInput(username, phone, password string) (string, string, string, string) {
 	username = strings.TrimSpace(username)
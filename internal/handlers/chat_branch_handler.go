@@ -0,0 +1,159 @@
+// File: internal/handlers/chat_branch_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/middleware"
+)
+
+// EditMessage re-asks an earlier question with new text. The edit becomes a sibling of
+// the original question and its answer is generated as a job, like AskQuestion; the
+// original branch stays available through SelectMessageVersion.
+func (h *ChatHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in EditMessage")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+	messageID, ok := parseMessageID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.validateMedicalPrompt(req.Prompt); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Reject bad targets before charging
+	if _, err := h.ChatService.GetEditableQuestion(r.Context(), userID, chatID, messageID); err != nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	genJob, _, status, err := h.chargeAndEnqueue(r.Context(), userID, chatID, jobRequest{kind: domain.JobKindEdit, messageID: messageID, prompt: req.Prompt})
+	if err != nil {
+		writeEnqueueError(w, status, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "message edit queued", "message_id", messageID, "job_id", genJob.ID)
+	writeJobAccepted(w, r, chatID, genJob)
+}
+
+// RegenerateMessage asks for another answer to a question. messageId may be the answer to
+// replace or its question. The body optionally picks another model, a larger top-K, or
+// turns translation off. Previous answers stay available as versions, and the job is
+// billed like a new question.
+func (h *ChatHandler) RegenerateMessage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in RegenerateMessage")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+	messageID, ok := parseMessageID(w, r)
+	if !ok {
+		return
+	}
+
+	var opts domain.GenerationOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.ChatService.ValidateGenerationOptions(opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	question, err := h.ChatService.FindRegenerateQuestion(r.Context(), userID, chatID, messageID)
+	if err != nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	genJob, _, status, err := h.chargeAndEnqueue(r.Context(), userID, chatID, jobRequest{
+		kind:      domain.JobKindRegenerate,
+		messageID: messageID,
+		prompt:    question.Content,
+		options:   opts,
+	})
+	if err != nil {
+		writeEnqueueError(w, status, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "regeneration queued", "message_id", messageID, "job_id", genJob.ID,
+		"model", opts.Model, "top_k", opts.TopK, "translation_disabled", opts.DisableTranslation)
+	writeJobAccepted(w, r, chatID, genJob)
+}
+
+// GetRegenerateModels lists the models RegenerateMessage accepts, the default first
+func (h *ChatHandler) GetRegenerateModels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{"models": h.ChatService.AvailableModels()})
+}
+
+// SelectMessageVersion switches the chat to the branch through the given version of a
+// question or answer. GetChatMessages lists the versions available at each position.
+func (h *ChatHandler) SelectMessageVersion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+	messageID, ok := parseMessageID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.ChatService.SelectBranch(r.Context(), userID, chatID, messageID); err != nil {
+		slog.WarnContext(r.Context(), "failed to select message version", "message_id", messageID, "error", err)
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseMessageID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["messageId"], 10, 64)
+	if err != nil || id == 0 {
+		http.Error(w, "Invalid message id", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,86 @@
+// File: internal/handlers/chat_export_handler.go
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/services/export"
+)
+
+// ExportChat is GET /api/chats/{id}/export?format=pdf|md|json. It downloads the whole
+// conversation on the active branch, rendered on the server; PDF is the default.
+func (h *ChatHandler) ExportChat(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in ExportChat")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conv, err := h.ChatService.ExportChat(r.Context(), userID, chatID)
+	if err != nil {
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+
+	// Render fully before writing so a failure can still be reported as an error status
+	var buf bytes.Buffer
+	if err := export.Write(&buf, format, conv); err != nil {
+		slog.ErrorContext(r.Context(), "failed to render chat export", "format", string(format), "error", err)
+		http.Error(w, "Failed to export chat", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", export.FileName(conv, format)))
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		slog.WarnContext(r.Context(), "failed to write chat export", "error", err)
+	}
+	slog.InfoContext(r.Context(), "chat exported", "format", string(format), "messages", len(conv.Messages))
+}
+
+// ExportAllChats is GET /api/chats/export?format=pdf|md|json. It streams a zip with one
+// file per chat.
+func (h *ChatHandler) ExportAllChats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in ExportAllChats")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("internist-chats-%s.zip", time.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	// The archive streams, so once it has started an error can only cut it short
+	if err := h.ChatService.ExportAllChats(r.Context(), userID, format, w); err != nil {
+		slog.ErrorContext(r.Context(), "bulk chat export failed", "format", string(format), "error", err)
+	}
+}
@@ -0,0 +1,128 @@
+// File: internal/handlers/chat_feedback_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/repository/message"
+	"github.com/iyunix/go-internist/internal/services"
+)
+
+// feedbackRatings maps the rating values clients send to stored ratings
+var feedbackRatings = map[string]int{"up": domain.FeedbackUp, "down": domain.FeedbackDown}
+
+// RateAnswer is PUT /api/chats/{id}/messages/{messageId}/feedback with
+// {"rating": "up"|"down", "category": "inaccurate|outdated|missing_source|unsafe", "comment": "..."}.
+// Category and comment are optional.
+func (h *ChatHandler) RateAnswer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in RateAnswer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+	messageID, ok := parseMessageID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Rating   string `json:"rating"`
+		Category string `json:"category"`
+		Comment  string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rating, ok := feedbackRatings[req.Rating]
+	if !ok {
+		http.Error(w, services.ErrInvalidFeedback.Error(), http.StatusBadRequest)
+		return
+	}
+
+	feedback, err := h.ChatService.RateAnswer(r.Context(), userID, chatID, messageID, rating, req.Category, req.Comment)
+	if errors.Is(err, services.ErrInvalidFeedback) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed to rate answer", "message_id", messageID, "error", err)
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(feedback)
+}
+
+// RemoveAnswerRating is DELETE /api/chats/{id}/messages/{messageId}/feedback
+func (h *ChatHandler) RemoveAnswerRating(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+	messageID, ok := parseMessageID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.ChatService.RemoveAnswerRating(r.Context(), userID, chatID, messageID); err != nil {
+		if !errors.Is(err, message.ErrFeedbackNotFound) {
+			slog.WarnContext(r.Context(), "failed to remove answer rating", "message_id", messageID, "error", err)
+		}
+		http.Error(w, "Feedback not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetChatFeedback is GET /api/chats/{id}/feedback: the user's ratings of the chat's answers
+func (h *ChatHandler) GetChatFeedback(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	feedback, err := h.ChatService.GetChatFeedback(r.Context(), userID, chatID)
+	if err != nil {
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+	if feedback == nil {
+		feedback = []domain.MessageFeedback{}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"feedback": feedback})
+}
@@ -2,639 +2,956 @@
 package handlers
 
 import (
-    "context"
-    "encoding/json"
-    "fmt"
-    "log"
-    "net/http"
-    "strconv"
-    "strings"
-    "sync"
-    "time"
-
-    "github.com/gorilla/mux"
-    "github.com/iyunix/go-internist/internal/domain"
-    "github.com/iyunix/go-internist/internal/middleware"
-    "github.com/iyunix/go-internist/internal/services"
-    "github.com/iyunix/go-internist/internal/services/user_services"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/repository/chat"
+	"github.com/iyunix/go-internist/internal/services"
+	"github.com/iyunix/go-internist/internal/services/user_services"
+	"github.com/iyunix/go-internist/internal/streams"
 )
 
 // ChatHandler handles HTTP requests for chat operations with production-ready features
 type ChatHandler struct {
-    UserService *user_services.UserService
-    ChatService *services.ChatService
+	UserService *user_services.UserService
+	ChatService *services.ChatService
+	Generation  *services.GenerationService
+	Streams     *streams.Hub
 }
 
 // NewChatHandler creates a new ChatHandler with validation and error handling
-func NewChatHandler(userService *user_services.UserService, chatService *services.ChatService) (*ChatHandler, error) {
-    // Production-ready validation
-    if userService == nil {
-        return nil, fmt.Errorf("user service is required for chat handler")
-    }
-    if chatService == nil {
-        return nil, fmt.Errorf("chat service is required for chat handler")
-    }
-
-    return &ChatHandler{
-        UserService: userService,
-        ChatService: chatService,
-    }, nil
+func NewChatHandler(userService *user_services.UserService, chatService *services.ChatService, generationService *services.GenerationService, streamHub *streams.Hub) (*ChatHandler, error) {
+	// Production-ready validation
+	if userService == nil {
+		return nil, fmt.Errorf("user service is required for chat handler")
+	}
+	if chatService == nil {
+		return nil, fmt.Errorf("chat service is required for chat handler")
+	}
+	if generationService == nil {
+		return nil, fmt.Errorf("generation service is required for chat handler")
+	}
+	if streamHub == nil {
+		return nil, fmt.Errorf("stream hub is required for chat handler")
+	}
+
+	return &ChatHandler{
+		UserService: userService,
+		ChatService: chatService,
+		Generation:  generationService,
+		Streams:     streamHub,
+	}, nil
 }
 
 // TotalCreditsProvider interface for medical AI credit management
 type TotalCreditsProvider interface {
-    GetTotalCredits(ctx context.Context, userID uint) (int, error)
+	GetTotalCredits(ctx context.Context, userID uint) (int, error)
 }
 
 // Production constants for medical AI application
 const (
-    defaultTotalCredits = 2500
-    maxChatTitleLength  = 200
-    defaultPageSize     = 20
-    maxPageSize         = 100
-    defaultMessageLimit = 50
+	defaultTotalCredits = 2500
+	maxChatTitleLength  = 200
+	defaultPageSize     = 20
+	maxPageSize         = 100
+	defaultMessageLimit = 50
+	sseRetryMillis      = 2000
 )
 
 // GetUserBalance retrieves user's character balance with enhanced error handling
 func (h *ChatHandler) GetUserBalance(w http.ResponseWriter, r *http.Request) {
-    // Enhanced user ID extraction with validation
-    userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
-    if !ok || userID == 0 {
-        log.Printf("[ChatHandler] Invalid or missing user ID in context")
-        http.Error(w, "Unauthorized", http.StatusUnauthorized)
-        return
-    }
-
-    // Production-ready balance retrieval with timeout
-    ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-    defer cancel()
-
-    balance, err := h.UserService.GetCharacterBalance(ctx, userID)
-    if err != nil {
-        log.Printf("[ChatHandler] Error getting user balance for user %d: %v", userID, err)
-        http.Error(w, "Failed to get balance", http.StatusInternalServerError)
-        return
-    }
-
-    // Enhanced total credits calculation
-    totalCredits := defaultTotalCredits
-    if provider, ok := interface{}(h.UserService).(TotalCreditsProvider); ok {
-        if total, err := provider.GetTotalCredits(ctx, userID); err == nil && total > 0 {
-            totalCredits = total
-        }
-    }
-
-    // Production-ready response with proper headers
-    w.Header().Set("Content-Type", "application/json; charset=utf-8")
-    w.Header().Set("Cache-Control", "no-store")
-    
-    response := map[string]interface{}{
-        "balance":      balance,
-        "totalCredits": totalCredits,
-        "timestamp":    time.Now().Unix(),
-        "userId":       userID,
-    }
-    
-    if err := json.NewEncoder(w).Encode(response); err != nil {
-        log.Printf("[ChatHandler] Error encoding balance response: %v", err)
-    }
+	// Enhanced user ID extraction with validation
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid or missing user ID in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Production-ready balance retrieval with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	balance, err := h.UserService.GetCharacterBalance(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting user balance", "error", err)
+		http.Error(w, "Failed to get balance", http.StatusInternalServerError)
+		return
+	}
+
+	// Enhanced total credits calculation
+	totalCredits := defaultTotalCredits
+	if provider, ok := interface{}(h.UserService).(TotalCreditsProvider); ok {
+		if total, err := provider.GetTotalCredits(ctx, userID); err == nil && total > 0 {
+			totalCredits = total
+		}
+	}
+
+	// Production-ready response with proper headers
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	response := map[string]interface{}{
+		"balance":      balance,
+		"totalCredits": totalCredits,
+		"timestamp":    time.Now().Unix(),
+		"userId":       userID,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding balance response", "error", err)
+	}
 }
 
 // CreateChat creates a new medical AI chat with enhanced validation
 func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
-    // Enhanced user validation
-    userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
-    if !ok || userID == 0 {
-        log.Printf("[ChatHandler] Invalid user ID in CreateChat")
-        http.Error(w, "Unauthorized", http.StatusUnauthorized)
-        return
-    }
-
-    // Production-ready request parsing with validation
-    var req struct {
-        Title string `json:"title"`
-        Type  string `json:"type,omitempty"` // Medical AI chat type
-    }
-    
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("[ChatHandler] Invalid request body in CreateChat: %v", err)
-        http.Error(w, "Invalid request body", http.StatusBadRequest)
-        return
-    }
-
-    // Enhanced title validation for medical AI
-    if err := h.validateChatTitle(req.Title); err != nil {
-        log.Printf("[ChatHandler] Chat title validation failed: %v", err)
-        http.Error(w, err.Error(), http.StatusBadRequest)
-        return
-    }
-
-    // Set default chat type for medical AI
-    if req.Type == "" {
-        req.Type = "medical_consultation"
-    }
-
-    // Production-ready chat creation with timeout
-    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-    defer cancel()
-
-    chat, err := h.ChatService.CreateChat(ctx, userID, req.Title)
-    if err != nil {
-        log.Printf("[ChatHandler] Error creating chat for user %d: %v", userID, err)
-        http.Error(w, "Failed to create chat", http.StatusInternalServerError)
-        return
-    }
-
-    // Enhanced response with medical AI metadata
-    w.Header().Set("Content-Type", "application/json; charset=utf-8")
-    w.Header().Set("Cache-Control", "no-store")
-    w.WriteHeader(http.StatusCreated)
-    
-    response := map[string]interface{}{
-        "id":        chat.ID,
-        "title":     chat.Title,
-        "userId":    chat.UserID,
-        "type":      req.Type,
-        "createdAt": chat.CreatedAt,
-        "updatedAt": chat.UpdatedAt,
-    }
-    
-    if err := json.NewEncoder(w).Encode(response); err != nil {
-        log.Printf("[ChatHandler] Error encoding chat creation response: %v", err)
-    }
-
-    log.Printf("[ChatHandler] Successfully created chat %d for user %d", chat.ID, userID)
+	// Enhanced user validation
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in CreateChat")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Production-ready request parsing with validation
+	var req struct {
+		Title string `json:"title"`
+		Type  string `json:"type,omitempty"` // Medical AI chat type
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid request body in CreateChat", "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Enhanced title validation for medical AI; an empty title is generated after the first answer
+	if strings.TrimSpace(req.Title) != "" {
+		if err := h.validateChatTitle(req.Title); err != nil {
+			slog.WarnContext(r.Context(), "chat title validation failed", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Set default chat type for medical AI
+	if req.Type == "" {
+		req.Type = "medical_consultation"
+	}
+
+	// Production-ready chat creation with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	chat, err := h.ChatService.CreateChat(ctx, userID, req.Title)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating chat", "error", err)
+		http.Error(w, "Failed to create chat", http.StatusInternalServerError)
+		return
+	}
+
+	// Enhanced response with medical AI metadata
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+
+	response := map[string]interface{}{
+		"id":        chat.ID,
+		"title":     chat.Title,
+		"userId":    chat.UserID,
+		"type":      req.Type,
+		"createdAt": chat.CreatedAt,
+		"updatedAt": chat.UpdatedAt,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding chat creation response", "error", err)
+	}
+
+	slog.InfoContext(r.Context(), "chat created", "chat_id", chat.ID)
 }
 
-// StreamChatSSE handles the full RAG and streaming pipeline, including sending status updates.
-func (h *ChatHandler) StreamChatSSE(w http.ResponseWriter, r *http.Request) {
-	// --- 1. Initial Validation & Setup ---
+// RenameChat sets a chat title chosen by the user. Renamed chats are never retitled
+// automatically.
+func (h *ChatHandler) RenameChat(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
 	if !ok || userID == 0 {
-		log.Printf("[ChatHandler] Invalid user ID in StreamChatSSE")
+		slog.WarnContext(r.Context(), "invalid user ID in RenameChat")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	vars := mux.Vars(r)
-	idStr, ok := vars["id"]
+	chatID, ok := parseChatID(w, r)
 	if !ok {
-		http.Error(w, "Missing chat id in URL", http.StatusBadRequest)
 		return
 	}
-	id64, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil || id64 == 0 {
-		http.Error(w, "Invalid chat id", http.StatusBadRequest)
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	var req struct {
+		Title string `json:"title"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	chatID := uint(id64)
-
-	prompt := r.URL.Query().Get("q")
-	if err := h.validateMedicalPrompt(prompt); err != nil {
+	if err := h.validateChatTitle(req.Title); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// --- 2. Balance Check & Deduction ---
-    originalPromptLength := len(prompt)
+	chat, err := h.ChatService.RenameChat(r.Context(), userID, chatID, req.Title)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error renaming chat", "error", err)
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	response := map[string]interface{}{
+		"id":        chat.ID,
+		"title":     chat.Title,
+		"updatedAt": chat.UpdatedAt,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding rename response", "error", err)
+	}
+	slog.InfoContext(r.Context(), "chat renamed")
+}
 
-    // Balance check and deduction should use ONLY the original user query length
-    canAsk, _, err := h.UserService.CanUserAskQuestion(r.Context(), userID, originalPromptLength)
-    if err != nil {
-        http.Error(w, "Error checking balance", http.StatusInternalServerError)
-        return
-    }
-    if !canAsk {
-        http.Error(w, "Insufficient character balance.", http.StatusPaymentRequired)
-        return
-    }
-    actualCharge, err := h.UserService.DeductCharactersForQuestion(r.Context(), userID, originalPromptLength)
+// AskQuestion charges the user and queues a generation job. The answer is produced by the
+// worker pool whether or not a client is connected; clients follow it via StreamChatSSE
+// with ?job=<id> and can poll GetGenerationJob for persisted progress.
+func (h *ChatHandler) AskQuestion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in AskQuestion")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	var req struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.validateMedicalPrompt(req.Prompt); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	genJob, _, status, err := h.chargeAndEnqueue(r.Context(), userID, chatID, jobRequest{kind: domain.JobKindAsk, prompt: req.Prompt})
 	if err != nil {
-		http.Error(w, "Error processing payment", http.StatusInternalServerError)
+		writeEnqueueError(w, status, err)
 		return
 	}
-	log.Printf("[ChatHandler] User %d charged %d characters", userID, actualCharge)
+	writeJobAccepted(w, r, chatID, genJob)
+}
+
+// writeJobAccepted tells the client where to follow a queued job
+func writeJobAccepted(w http.ResponseWriter, r *http.Request, chatID uint, genJob *domain.GenerationJob) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusAccepted)
+	response := map[string]interface{}{
+		"jobId":        genJob.ID,
+		"streamId":     genJob.StreamID,
+		"status":       genJob.Status,
+		"chargeAmount": genJob.Charge,
+		"streamUrl":    fmt.Sprintf("/api/chats/%d/stream?job=%d", chatID, genJob.ID),
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding job response", "error", err)
+	}
+}
+
+// GetGenerationJob reports the status and persisted progress of a generation job
+func (h *ChatHandler) GetGenerationJob(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	jobID64, err := strconv.ParseUint(mux.Vars(r)["jobId"], 10, 64)
+	if err != nil || jobID64 == 0 {
+		http.Error(w, "Invalid job id", http.StatusBadRequest)
+		return
+	}
+
+	genJob, err := h.Generation.GetJob(r.Context(), userID, uint(jobID64))
+	if err != nil || genJob.ChatID != chatID {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(genJob); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding job response", "error", err)
+	}
+}
+
+// CancelGenerationJob stops a queued or running job; the unused part of the charge is refunded
+func (h *ChatHandler) CancelGenerationJob(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	jobID64, err := strconv.ParseUint(mux.Vars(r)["jobId"], 10, 64)
+	if err != nil || jobID64 == 0 {
+		http.Error(w, "Invalid job id", http.StatusBadRequest)
+		return
+	}
+
+	genJob, err := h.Generation.GetJob(r.Context(), userID, uint(jobID64))
+	if err != nil || genJob.ChatID != chatID {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err := h.Generation.Cancel(userID, genJob.ID); err != nil {
+		if errors.Is(err, services.ErrGenerationJobFinished) {
+			http.Error(w, "Job already finished", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "generation job cancel requested", "job_id", genJob.ID)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// StreamChatSSE relays a generation job's frames as Server-Sent Events. Clients either
+// subscribe to a job created by AskQuestion (?job=<id>) or, for older clients, pass the
+// prompt in ?q= and have the job created here. A client that reconnects with
+// Last-Event-ID gets the missed frames and the live tail without being charged again.
+func (h *ChatHandler) StreamChatSSE(w http.ResponseWriter, r *http.Request) {
+	// --- 1. Initial Validation & Setup ---
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in StreamChatSSE")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
 
-	// --- 3. SSE Headers and Flusher Setup ---
-	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
-	
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
 		return
 	}
 
-	// --- 4. Define Callbacks for the Service ---
-	var sourcesMutex sync.Mutex
-	var documentSources []string
-	
-	// This callback handles the new status updates
-	onStatus := func(status, message string) {
-		payload := map[string]string{
-			"status":  status,
-			"message": message,
-		}
-		if b, err := json.Marshal(payload); err == nil {
-			fmt.Fprintf(w, "event: status\ndata: %s\n\n", b)
-			flusher.Flush()
+	// --- 2. Resume an existing stream (no charge) ---
+	if lastEventID := getLastEventID(r); lastEventID != "" {
+		h.resumeStream(w, r, flusher, userID, chatID, lastEventID)
+		return
+	}
+
+	// --- 3. Subscribe to a queued job ---
+	if jobParam := r.URL.Query().Get("job"); jobParam != "" {
+		h.subscribeToJob(w, r, flusher, userID, chatID, jobParam)
+		return
+	}
+
+	// --- 4. Legacy: queue a job for the prompt in ?q= ---
+	prompt := r.URL.Query().Get("q")
+	if err := h.validateMedicalPrompt(prompt); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, stream, status, err := h.chargeAndEnqueue(r.Context(), userID, chatID, jobRequest{kind: domain.JobKindAsk, prompt: prompt})
+	if err != nil {
+		writeEnqueueError(w, status, err)
+		return
+	}
+
+	writeSSEHeaders(w)
+	h.relayStream(w, r, flusher, stream, 0)
+}
+
+// jobRequest describes the generation job to queue
+type jobRequest struct {
+	kind      string
+	messageID uint   // edit and regenerate target
+	prompt    string // the question; its length sets the charge
+	options   domain.GenerationOptions
+}
+
+// chargeAndEnqueue checks the balance, charges for the original prompt length and queues
+// the job. Every kind is billed like a new question. If the job cannot be queued the charge
+// is refunded. On failure it returns the HTTP status to report.
+func (h *ChatHandler) chargeAndEnqueue(ctx context.Context, userID, chatID uint, req jobRequest) (*domain.GenerationJob, *streams.Stream, int, error) {
+	kind, prompt := req.kind, req.prompt
+
+	// Refuse before charging when the pool cannot take the job
+	if err := h.Generation.CanAccept(); err != nil {
+		return nil, nil, http.StatusServiceUnavailable, err
+	}
+
+	// Only charge for chats the user owns
+	if err := h.ChatService.CheckChatOwner(ctx, userID, chatID); err != nil {
+		if errors.Is(err, chat.ErrChatNotFound) {
+			return nil, nil, http.StatusNotFound, errors.New("Chat not found")
 		}
+		return nil, nil, http.StatusInternalServerError, errors.New("Error checking chat")
 	}
 
-	onSources := func(sources []string) {
-		sourcesMutex.Lock()
-		defer sourcesMutex.Unlock()
-		documentSources = sources
-		// You could optionally send an initial 'sources found' event here
+	// Balance check and deduction should use ONLY the original user query length
+	originalPromptLength := len(prompt)
+	canAsk, _, err := h.UserService.CanUserAskQuestion(ctx, userID, originalPromptLength)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.New("Error checking balance")
 	}
-	
-	onDelta := func(token string) error {
-		select {
-		case <-r.Context().Done():
-			return fmt.Errorf("client disconnected")
-		default:
-			payload := map[string]string{"content": token}
-			jsonPayload, err := json.Marshal(payload)
-			if err != nil {
-				return nil // Don't kill the stream for a single bad token
-			}
-			fmt.Fprintf(w, "data: %s\n\n", jsonPayload)
-			flusher.Flush()
-			return nil
+	if !canAsk {
+		return nil, nil, http.StatusPaymentRequired, errors.New("Insufficient character balance.")
+	}
+	actualCharge, err := h.UserService.DeductCharactersForQuestion(ctx, userID, originalPromptLength)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.New("Error processing payment")
+	}
+	slog.InfoContext(ctx, "user charged for question", "characters", actualCharge, "kind", kind)
+
+	var genJob *domain.GenerationJob
+	var stream *streams.Stream
+	switch kind {
+	case domain.JobKindRegenerate:
+		genJob, stream, err = h.Generation.EnqueueRegenerate(ctx, userID, chatID, req.messageID, prompt, actualCharge, req.options)
+	case domain.JobKindEdit:
+		genJob, stream, err = h.Generation.EnqueueEdit(ctx, userID, chatID, req.messageID, prompt, actualCharge)
+	default:
+		genJob, stream, err = h.Generation.Enqueue(ctx, userID, chatID, prompt, actualCharge)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to enqueue generation job", "error", err, "charged", actualCharge)
+		if refundErr := h.Generation.RefundCharge(context.WithoutCancel(ctx), userID, actualCharge); refundErr != nil {
+			slog.ErrorContext(ctx, "failed to refund unqueued generation", "error", refundErr, "refund", actualCharge)
 		}
+		if errors.Is(err, services.ErrGenerationQueueFull) || errors.Is(err, services.ErrGenerationShuttingDown) {
+			return nil, nil, http.StatusServiceUnavailable, err
+		}
+		return nil, nil, http.StatusInternalServerError, errors.New("Failed to start generation")
 	}
+	return genJob, stream, http.StatusAccepted, nil
+}
 
-	// --- 5. Call the Streaming Service ---
-	streamCtx, streamCancel := context.WithTimeout(r.Context(), 2*time.Minute)
-	defer streamCancel()
+func writeEnqueueError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "Server is busy, please retry shortly", status)
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
 
-	startTime := time.Now()
-	err = h.ChatService.StreamChatMessageWithSources(streamCtx, userID, chatID, prompt, onDelta, onSources, onStatus)
-	
-	// --- 6. Handle Stream Completion ---
+// subscribeToJob relays a job's stream, or its persisted result once the buffer has expired
+func (h *ChatHandler) subscribeToJob(w http.ResponseWriter, r *http.Request, flusher http.Flusher, userID, chatID uint, jobParam string) {
+	jobID64, err := strconv.ParseUint(jobParam, 10, 64)
+	if err != nil || jobID64 == 0 {
+		http.Error(w, "Invalid job id", http.StatusBadRequest)
+		return
+	}
+	genJob, err := h.Generation.GetJob(r.Context(), userID, uint(jobID64))
+	if err != nil || genJob.ChatID != chatID {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if stream, ok := h.Streams.Get(genJob.StreamID); ok {
+		writeSSEHeaders(w)
+		h.relayStream(w, r, flusher, stream, 0)
+		return
+	}
+	h.sendJobResult(w, r, flusher, genJob)
+}
+
+// resumeStream replays frames after lastEventID. If the buffer has expired it falls back
+// to the job's persisted result, or the saved assistant message, so the client can still
+// show the full answer.
+func (h *ChatHandler) resumeStream(w http.ResponseWriter, r *http.Request, flusher http.Flusher, userID, chatID uint, lastEventID string) {
+	streamID, seq, err := streams.ParseEventID(lastEventID)
 	if err != nil {
-		log.Printf("[ChatHandler] Streaming error for user %d: %v", userID, err)
-		errorPayload, _ := json.Marshal(map[string]string{"error": "An error occurred during the stream."})
-		fmt.Fprintf(w, "event: error\ndata: %s\n\n", errorPayload)
-		flusher.Flush()
+		http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
 		return
 	}
 
-	// Send final metadata event with sources
-	sourcesMutex.Lock()
-	if len(documentSources) > 0 {
-		finalSourcesPayload, _ := json.Marshal(map[string]interface{}{"type": "final_sources", "sources": documentSources})
-		fmt.Fprintf(w, "event: metadata\ndata: %s\n\n", finalSourcesPayload)
+	if stream, ok := h.Streams.Get(streamID); ok {
+		if stream.UserID != userID || stream.ChatID != chatID {
+			http.Error(w, "Stream not found", http.StatusNotFound)
+			return
+		}
+		slog.InfoContext(r.Context(), "resuming stream", "stream_id", streamID, "after_seq", seq)
+		writeSSEHeaders(w)
+		h.relayStream(w, r, flusher, stream, seq)
+		return
 	}
-	sourcesMutex.Unlock()
 
-	// Send a completion event
-	completionPayload, _ := json.Marshal(map[string]interface{}{
-		"type":         "complete",
-		"responseTime": time.Since(startTime).Milliseconds(),
-		"chargeAmount": actualCharge,
-	})
-	fmt.Fprintf(w, "event: complete\ndata: %s\n\n", completionPayload)
-	flusher.Flush()
+	if genJob, err := h.Generation.GetJobByStreamID(r.Context(), userID, streamID); err == nil && genJob.ChatID == chatID {
+		slog.InfoContext(r.Context(), "stream expired, sending job result", "stream_id", streamID, "job_id", genJob.ID)
+		h.sendJobResult(w, r, flusher, genJob)
+		return
+	}
+
+	// Without the stream or its job there is no answer that belongs to this stream ID
+	http.Error(w, "Stream not found", http.StatusNotFound)
+}
+
+// sendJobResult writes a finished job's persisted answer as a single final frame
+func (h *ChatHandler) sendJobResult(w http.ResponseWriter, r *http.Request, flusher http.Flusher, genJob *domain.GenerationJob) {
+	if !genJob.IsFinished() {
+		// Stream is gone but the job never finished (e.g. restart); nothing to follow
+		http.Error(w, "Stream expired", http.StatusGone)
+		return
+	}
 
-	// Signal the absolute end of the stream to the client
+	writeSSEHeaders(w)
+	if genJob.Status == domain.JobStatusFailed {
+		errorPayload, _ := json.Marshal(map[string]string{"error": "An error occurred during the stream."})
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", errorPayload)
+	} else {
+		finalPayload, _ := json.Marshal(map[string]interface{}{"job_id": genJob.ID, "content": genJob.Content})
+		fmt.Fprintf(w, "event: final\ndata: %s\n\n", finalPayload)
+	}
 	fmt.Fprintf(w, "event: done\ndata: {\"message\": \"Stream complete\"}\n\n")
 	flusher.Flush()
-	
-	log.Printf("[ChatHandler] Stream for user %d completed in %v", userID, time.Since(startTime))
+	<-r.Context().Done()
+}
+
+// relayStream writes buffered frames after the given sequence and then follows the live stream.
+func (h *ChatHandler) relayStream(w http.ResponseWriter, r *http.Request, flusher http.Flusher, stream *streams.Stream, after uint64) {
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis)
+	flusher.Flush()
+
+	for {
+		events, done, wait := stream.Since(after)
+		for _, ev := range events {
+			fmt.Fprintf(w, "id: %s\n", stream.EventID(ev.Seq))
+			if ev.Name != "" {
+				fmt.Fprintf(w, "event: %s\n", ev.Name)
+			}
+			fmt.Fprintf(w, "data: %s\n\n", ev.Data)
+			after = ev.Seq
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+		if done {
+			break
+		}
+		select {
+		case <-wait:
+		case <-r.Context().Done():
+			slog.DebugContext(r.Context(), "stream client disconnected before completion", "stream_id", stream.ID)
+			return
+		}
+	}
 
 	// Block until the client disconnects to ensure all messages are sent
 	<-r.Context().Done()
-	log.Printf("[ChatHandler] Client for user %d has disconnected.", userID)
+	slog.DebugContext(r.Context(), "stream client disconnected")
 }
 
+func writeSSEHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+}
+
+// getLastEventID reads the resume point from the standard header, or from a query
+// parameter for clients that open a fresh EventSource after a page reload.
+func getLastEventID(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get("Last-Event-ID")); id != "" {
+		return id
+	}
+	return strings.TrimSpace(r.URL.Query().Get("lastEventId"))
+}
+
+// parseChatID reads the {id} route variable, writing a 400 on failure
+func parseChatID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	idStr, ok := mux.Vars(r)["id"]
+	if !ok {
+		http.Error(w, "Missing chat id in URL", http.StatusBadRequest)
+		return 0, false
+	}
+	id64, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil || id64 == 0 {
+		http.Error(w, "Invalid chat id", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id64), true
+}
 
 // GetUserChats retrieves user chats with production-ready pagination
 func (h *ChatHandler) GetUserChats(w http.ResponseWriter, r *http.Request) {
-    // Enhanced user validation
-    userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
-    if !ok || userID == 0 {
-        log.Printf("[ChatHandler] Invalid user ID in GetUserChats")
-        http.Error(w, "Unauthorized", http.StatusUnauthorized)
-        return
-    }
-
-    // ✅ FIXED: Parse pagination parameters (backward compatible)
-    page := h.getPageFromQuery(r)
-    limit := h.getLimitFromQuery(r)
-    
-    // For backward compatibility, use high limit to get most chats at once
-    if limit == defaultPageSize {
-        limit = 100 // Higher default to load most chats at once
-    }
-    
-    offset := (page - 1) * limit
-    
-    // Enhanced chat retrieval with timeout and pagination
-    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-    defer cancel()
-
-    // ✅ CALL NEW SERVICE METHOD (eliminates the warning)
-    chats, total, err := h.ChatService.GetUserChatsWithPagination(ctx, userID, limit, offset)
-    if err != nil {
-        log.Printf("[ChatHandler] Error getting chats for user %d: %v", userID, err)
-        http.Error(w, "Failed to get user chats", http.StatusInternalServerError)
-        return
-    }
-
-    // ✅ BACKWARD COMPATIBLE: Return just chats array (same as before)
-    w.Header().Set("Content-Type", "application/json; charset=utf-8")
-    w.Header().Set("Cache-Control", "no-store")
-    response := map[string]interface{}{
-    "chats": chats,
-    "total": total,
-    "page":  page,
-    "limit": limit,
-    "has_more": total > int64(offset + len(chats)),
-    }
-    if err := json.NewEncoder(w).Encode(response); err != nil {
-        log.Printf("[ChatHandler] Error encoding chats response: %v", err)
-    }
-
-    log.Printf("[ChatHandler] Retrieved %d chats for user %d (total: %d)", len(chats), userID, total)
-}
+	// Enhanced user validation
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in GetUserChats")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// ✅ FIXED: Parse pagination parameters (backward compatible)
+	page := h.getPageFromQuery(r)
+	limit := h.getLimitFromQuery(r)
 
+	// For backward compatibility, use high limit to get most chats at once
+	if limit == defaultPageSize {
+		limit = 100 // Higher default to load most chats at once
+	}
+
+	offset := (page - 1) * limit
+
+	// Optional folder_id, tag, archived and pinned filters; archived chats are hidden by default
+	filter, err := parseChatFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Enhanced chat retrieval with timeout and pagination
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	chats, total, err := h.ChatService.ListChats(ctx, userID, filter, limit, offset)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting user chats", "error", err)
+		http.Error(w, "Failed to get user chats", http.StatusInternalServerError)
+		return
+	}
+
+	// ✅ BACKWARD COMPATIBLE: Return just chats array (same as before)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	response := map[string]interface{}{
+		"chats":    chats,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+		"has_more": total > int64(offset+len(chats)),
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding chats response", "error", err)
+	}
+
+	slog.DebugContext(r.Context(), "retrieved chats", "count", len(chats), "total", total)
+}
 
 // GetChatMessages retrieves chat messages with production-ready pagination and filtering
 func (h *ChatHandler) GetChatMessages(w http.ResponseWriter, r *http.Request) {
-    // Enhanced user validation
-    userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
-    if !ok || userID == 0 {
-        log.Printf("[ChatHandler] Invalid user ID in GetChatMessages")
-        http.Error(w, "Unauthorized", http.StatusUnauthorized)
-        return
-    }
-
-    // Production-ready chat ID validation
-    vars := mux.Vars(r)
-    idStr, ok := vars["id"]
-    if !ok {
-        http.Error(w, "Missing chat id in URL", http.StatusBadRequest)
-        return
-    }
-
-    chatIDU64, err := strconv.ParseUint(idStr, 10, 64)
-    if err != nil || chatIDU64 == 0 {
-        log.Printf("[ChatHandler] Invalid chat ID format: %s", idStr)
-        http.Error(w, "Invalid chat id", http.StatusBadRequest)
-        return
-    }
-    chatID := uint(chatIDU64)
-
-    // Enhanced pagination and filtering parameters
-    page := h.getPageFromQuery(r)
-    limit := h.getLimitFromQuery(r)
-    messageType := r.URL.Query().Get("type") // Filter by message type
-
-    // Production-ready message retrieval with timeout
-    ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
-    defer cancel()
-
-    offset := (page - 1) * limit
-
-    messages, total, err := h.ChatService.GetChatMessagesWithPagination(ctx, userID, chatID, limit, offset)
-    if err != nil {
-        log.Printf("[ChatHandler] Error getting messages for user %d chat %d: %v", userID, chatID, err)
-        http.Error(w, "Failed to get messages", http.StatusInternalServerError)
-        return
-    }
-
-    // Enhanced filtering for medical AI message types
-    if messageType != "" {
-        messages = h.filterMessagesByType(messages, messageType)
-    }
-
-    // Enhanced response with medical AI metadata
-    w.Header().Set("Content-Type", "application/json; charset=utf-8")
-    w.Header().Set("Cache-Control", "no-store")
-    
-    response := map[string]interface{}{
-        "messages":     messages,
-        "total":        total,
-        "chatId":       chatID,
-        "page":         page,
-        "limit":        limit,
-        "messageType":  messageType,
-        "timestamp":    time.Now().Unix(),
-        "userId":       userID,
-        "has_more":     total > int64(offset+len(messages)), // Optional
-    }
-
-    
-    if err := json.NewEncoder(w).Encode(response); err != nil {
-        log.Printf("[ChatHandler] Error encoding messages response: %v", err)
-    }
-
-    log.Printf("[ChatHandler] Retrieved %d messages for user %d chat %d", len(messages), userID, chatID)
+	// Enhanced user validation
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in GetChatMessages")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Production-ready chat ID validation
+	vars := mux.Vars(r)
+	idStr, ok := vars["id"]
+	if !ok {
+		http.Error(w, "Missing chat id in URL", http.StatusBadRequest)
+		return
+	}
+
+	chatIDU64, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil || chatIDU64 == 0 {
+		slog.WarnContext(r.Context(), "invalid chat ID format", "chat_id_param", idStr)
+		http.Error(w, "Invalid chat id", http.StatusBadRequest)
+		return
+	}
+	chatID := uint(chatIDU64)
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	// Enhanced pagination and filtering parameters
+	page := h.getPageFromQuery(r)
+	limit := h.getLimitFromQuery(r)
+	messageType := r.URL.Query().Get("type") // Filter by message type
+
+	// Production-ready message retrieval with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * limit
+
+	// Only the active branch is returned; versions lists the alternatives at each edited position
+	branch, err := h.ChatService.GetActiveBranch(ctx, userID, chatID, limit, offset)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting chat messages", "error", err)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+
+	messages, total := branch.Messages, branch.Total
+
+	// Enhanced filtering for medical AI message types
+	if messageType != "" {
+		messages = h.filterMessagesByType(messages, messageType)
+	}
+
+	// Enhanced response with medical AI metadata
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	response := map[string]interface{}{
+		"messages":    messages,
+		"total":       total,
+		"chatId":      chatID,
+		"page":        page,
+		"limit":       limit,
+		"messageType": messageType,
+		"timestamp":   time.Now().Unix(),
+		"userId":      userID,
+		"has_more":    total > int64(offset+len(messages)), // Optional
+		"versions":    branch.Versions,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding messages response", "error", err)
+	}
+
+	slog.DebugContext(r.Context(), "retrieved chat messages", "count", len(messages))
 }
 
 // DeleteChat deletes a chat with enhanced validation and logging
 func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
-    // Enhanced user validation
-    userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
-    if !ok || userID == 0 {
-        log.Printf("[ChatHandler] Invalid user ID in DeleteChat")
-        http.Error(w, "Unauthorized", http.StatusUnauthorized)
-        return
-    }
-
-    // Production-ready chat ID validation
-    vars := mux.Vars(r)
-    chatIDU64, err := strconv.ParseUint(vars["id"], 10, 64)
-    if err != nil || chatIDU64 == 0 {
-        log.Printf("[ChatHandler] Invalid chat ID for deletion: %s", vars["id"])
-        http.Error(w, "Invalid chat id", http.StatusBadRequest)
-        return
-    }
-    chatID := uint(chatIDU64)
-
-    // Production-ready deletion with timeout and logging
-    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-    defer cancel()
-
-    if err := h.ChatService.DeleteChat(ctx, userID, chatID); err != nil {
-        log.Printf("[ChatHandler] Error deleting chat %d for user %d: %v", chatID, userID, err)
-        http.Error(w, "Failed to delete chat", http.StatusInternalServerError)
-        return
-    }
-
-    // Enhanced response headers
-    w.Header().Set("Cache-Control", "no-store")
-    w.WriteHeader(http.StatusNoContent)
-    
-    log.Printf("[ChatHandler] Successfully deleted chat %d for user %d", chatID, userID)
+	// Enhanced user validation
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in DeleteChat")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Production-ready chat ID validation
+	vars := mux.Vars(r)
+	chatIDU64, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil || chatIDU64 == 0 {
+		slog.WarnContext(r.Context(), "invalid chat ID for deletion", "chat_id_param", vars["id"])
+		http.Error(w, "Invalid chat id", http.StatusBadRequest)
+		return
+	}
+	chatID := uint(chatIDU64)
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	// Production-ready deletion with timeout and logging
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.ChatService.DeleteChat(ctx, userID, chatID); err != nil {
+		slog.ErrorContext(r.Context(), "error deleting chat", "error", err)
+		http.Error(w, "Failed to delete chat", http.StatusInternalServerError)
+		return
+	}
+
+	// Enhanced response headers
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNoContent)
+
+	slog.InfoContext(r.Context(), "chat deleted")
 }
 
 // ===== PRODUCTION-READY HELPER METHODS =====
 
 // validateChatTitle validates chat title for medical AI application
 func (h *ChatHandler) validateChatTitle(title string) error {
-    title = strings.TrimSpace(title)
-    if title == "" {
-        return fmt.Errorf("chat title cannot be empty")
-    }
-    if len(title) > maxChatTitleLength {
-        return fmt.Errorf("chat title too long (max %d characters)", maxChatTitleLength)
-    }
-    
-    // Basic XSS protection
-    if strings.Contains(title, "<script") || strings.Contains(title, "javascript:") {
-        return fmt.Errorf("invalid characters in chat title")
-    }
-    
-    return nil
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return fmt.Errorf("chat title cannot be empty")
+	}
+	if len(title) > maxChatTitleLength {
+		return fmt.Errorf("chat title too long (max %d characters)", maxChatTitleLength)
+	}
+
+	// Basic XSS protection
+	if strings.Contains(title, "<script") || strings.Contains(title, "javascript:") {
+		return fmt.Errorf("invalid characters in chat title")
+	}
+
+	return nil
 }
 
 // validateMedicalPrompt validates medical AI prompts
 func (h *ChatHandler) validateMedicalPrompt(prompt string) error {
-    if prompt == "" {
-        return fmt.Errorf("missing query parameter: q")
-    }
-    if len(prompt) > domain.MaxQuestionLength {
-        return fmt.Errorf("question too long. Maximum %d characters allowed", domain.MaxQuestionLength)
-    }
-    if len(strings.TrimSpace(prompt)) == 0 {
-        return fmt.Errorf("prompt cannot be empty")
-    }
-    
-    // Enhanced medical content validation
-    if strings.Contains(prompt, "<script") || strings.Contains(prompt, "javascript:") {
-        return fmt.Errorf("invalid characters in medical prompt")
-    }
-    
-    return nil
+	if prompt == "" {
+		return fmt.Errorf("missing query parameter: q")
+	}
+	if len(prompt) > domain.MaxQuestionLength {
+		return fmt.Errorf("question too long. Maximum %d characters allowed", domain.MaxQuestionLength)
+	}
+	if len(strings.TrimSpace(prompt)) == 0 {
+		return fmt.Errorf("prompt cannot be empty")
+	}
+
+	// Enhanced medical content validation
+	if strings.Contains(prompt, "<script") || strings.Contains(prompt, "javascript:") {
+		return fmt.Errorf("invalid characters in medical prompt")
+	}
+
+	return nil
 }
 
 // getPageFromQuery extracts page number from query parameters
 func (h *ChatHandler) getPageFromQuery(r *http.Request) int {
-    pageStr := r.URL.Query().Get("page")
-    if pageStr == "" {
-        return 1
-    }
-    
-    page, err := strconv.Atoi(pageStr)
-    if err != nil || page < 1 {
-        return 1
-    }
-    
-    return page
+	pageStr := r.URL.Query().Get("page")
+	if pageStr == "" {
+		return 1
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		return 1
+	}
+
+	return page
 }
 
 // getLimitFromQuery extracts limit from query parameters
 func (h *ChatHandler) getLimitFromQuery(r *http.Request) int {
-    limitStr := r.URL.Query().Get("limit")
-    if limitStr == "" {
-        return defaultPageSize
-    }
-    
-    limit, err := strconv.Atoi(limitStr)
-    if err != nil || limit < 1 {
-        return defaultPageSize
-    }
-    
-    if limit > maxPageSize {
-        return maxPageSize
-    }
-    
-    return limit
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return defaultPageSize
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return defaultPageSize
+	}
+
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+
+	return limit
 }
 
 // filterMessagesByType filters messages by medical AI message type
 func (h *ChatHandler) filterMessagesByType(messages []domain.Message, messageType string) []domain.Message {
-    if messageType == "" {
-        return messages
-    }
-    
-    var filtered []domain.Message
-    for _, message := range messages {
-        if message.MessageType == messageType {
-            filtered = append(filtered, message)
-        }
-    }
-    
-    return filtered
+	if messageType == "" {
+		return messages
+	}
+
+	var filtered []domain.Message
+	for _, message := range messages {
+		if message.MessageType == messageType {
+			filtered = append(filtered, message)
+		}
+	}
+
+	return filtered
 }
 
 // SendMessage sends a new message to a chat
 func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
-    // Enhanced user validation
-    userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
-    if !ok || userID == 0 {
-        log.Printf("[ChatHandler] Invalid user ID in SendMessage")
-        http.Error(w, "Unauthorized", http.StatusUnauthorized)
-        return
-    }
-
-    // Production-ready chat ID extraction and validation
-    vars := mux.Vars(r)
-    idStr, ok := vars["id"]
-    if !ok {
-        http.Error(w, "Missing chat id in URL", http.StatusBadRequest)
-        return
-    }
-    
-    id64, err := strconv.ParseUint(idStr, 10, 64)
-    if err != nil || id64 == 0 {
-        log.Printf("[ChatHandler] Invalid chat ID format: %s", idStr)
-        http.Error(w, "Invalid chat id", http.StatusBadRequest)
-        return
-    }
-    chatID := uint(id64)
-
-    // Production-ready request parsing
-    var req struct {
-        Content     string `json:"content"`
-        MessageType string `json:"messageType,omitempty"`
-    }
-    
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        log.Printf("[ChatHandler] Invalid request body in SendMessage: %v", err)
-        http.Error(w, "Invalid request body", http.StatusBadRequest)
-        return
-    }
-
-    // Set default message type
-    if req.MessageType == "" {
-        req.MessageType = "user"
-    }
-
-    // Production-ready message creation with timeout
-    ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-    defer cancel()
-
-    message, err := h.ChatService.SaveMessage(ctx, userID, chatID, req.Content, req.MessageType)
-    if err != nil {
-        log.Printf("[ChatHandler] Error saving message for user %d chat %d: %v", userID, chatID, err)
-        http.Error(w, "Failed to save message", http.StatusInternalServerError)
-        return
-    }
-
-    // Enhanced response
-    w.Header().Set("Content-Type", "application/json; charset=utf-8")
-    w.Header().Set("Cache-Control", "no-store")
-    w.WriteHeader(http.StatusCreated)
-    
-    response := map[string]interface{}{
-        "id":          message.ID,
-        "content":     message.Content,
-        "messageType": message.MessageType,
-        "chatId":      message.ChatID,
-        "createdAt":   message.CreatedAt,
-        "updatedAt":   message.UpdatedAt,
-        "archived":    message.Archived,        // ✅ Available field
-
-    }
-    
-    if err := json.NewEncoder(w).Encode(response); err != nil {
-        log.Printf("[ChatHandler] Error encoding message response: %v", err)
-    }
-
-    log.Printf("[ChatHandler] Successfully saved message %d for user %d in chat %d", message.ID, userID, chatID)
+	// Enhanced user validation
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in SendMessage")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Production-ready chat ID extraction and validation
+	vars := mux.Vars(r)
+	idStr, ok := vars["id"]
+	if !ok {
+		http.Error(w, "Missing chat id in URL", http.StatusBadRequest)
+		return
+	}
+
+	id64, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil || id64 == 0 {
+		slog.WarnContext(r.Context(), "invalid chat ID format", "chat_id_param", idStr)
+		http.Error(w, "Invalid chat id", http.StatusBadRequest)
+		return
+	}
+	chatID := uint(id64)
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	// Production-ready request parsing
+	var req struct {
+		Content     string `json:"content"`
+		MessageType string `json:"messageType,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid request body in SendMessage", "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Set default message type
+	if req.MessageType == "" {
+		req.MessageType = "user"
+	}
+
+	// Production-ready message creation with timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	message, err := h.ChatService.SaveMessage(ctx, userID, chatID, req.Content, req.MessageType)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving message", "error", err)
+		http.Error(w, "Failed to save message", http.StatusInternalServerError)
+		return
+	}
+
+	// Enhanced response
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+
+	response := map[string]interface{}{
+		"id":          message.ID,
+		"content":     message.Content,
+		"messageType": message.MessageType,
+		"chatId":      message.ChatID,
+		"createdAt":   message.CreatedAt,
+		"updatedAt":   message.UpdatedAt,
+		"archived":    message.Archived, // ✅ Available field
+
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding message response", "error", err)
+	}
+
+	slog.InfoContext(r.Context(), "message saved", "message_id", message.ID)
 }
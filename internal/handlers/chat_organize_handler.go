@@ -0,0 +1,323 @@
+// File: internal/handlers/chat_organize_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/repository/chat"
+	"github.com/iyunix/go-internist/internal/services"
+)
+
+// parseChatFilter reads the folder_id, tag, archived and pinned query parameters of
+// GET /api/chats. folder_id=none lists chats that are in no folder.
+func parseChatFilter(r *http.Request) (chat.ChatFilter, error) {
+	var filter chat.ChatFilter
+	q := r.URL.Query()
+
+	if v := q.Get("folder_id"); v != "" {
+		var folderID uint
+		if v != "none" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil || id == 0 {
+				return filter, errors.New("invalid folder_id")
+			}
+			folderID = uint(id)
+		}
+		filter.FolderID = &folderID
+	}
+	filter.Tag = q.Get("tag")
+	if v := q.Get("archived"); v != "" {
+		archived, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("invalid archived value")
+		}
+		filter.Archived = archived
+	}
+	if v := q.Get("pinned"); v != "" {
+		pinned, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("invalid pinned value")
+		}
+		filter.Pinned = &pinned
+	}
+	return filter, nil
+}
+
+// organizeErrorStatus maps folder and ownership errors to a status code
+func organizeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, chat.ErrFolderNotFound), errors.Is(err, chat.ErrUnauthorizedAccess):
+		return http.StatusNotFound
+	case errors.Is(err, chat.ErrFolderExists):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// BulkUpdateChats is POST /api/chats/bulk with
+// {"action": "delete|archive|unarchive|pin|unpin|move|tag|untag", "chat_ids": [...],
+// "folder_id": n, "tags": [...]}. Chats the user does not own are skipped.
+func (h *ChatHandler) BulkUpdateChats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in BulkUpdateChats")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Action   string   `json:"action"`
+		ChatIDs  []uint   `json:"chat_ids"`
+		FolderID *uint    `json:"folder_id"`
+		Tags     []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	affected, err := h.ChatService.BulkUpdateChats(r.Context(), userID, services.BulkChatAction{
+		Action:   req.Action,
+		ChatIDs:  req.ChatIDs,
+		FolderID: req.FolderID,
+		Tags:     req.Tags,
+	})
+	if err != nil {
+		slog.WarnContext(r.Context(), "bulk chat update failed", "action", req.Action, "error", err)
+		http.Error(w, err.Error(), organizeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"action": req.Action, "affected": affected})
+}
+
+// PinChat is POST (pin) or DELETE (unpin) /api/chats/{id}/pin
+func (h *ChatHandler) PinChat(w http.ResponseWriter, r *http.Request) {
+	action := services.BulkPin
+	if r.Method == http.MethodDelete {
+		action = services.BulkUnpin
+	}
+	h.updateOneChat(w, r, services.BulkChatAction{Action: action})
+}
+
+// ArchiveChat is POST (archive) or DELETE (unarchive) /api/chats/{id}/archive
+func (h *ChatHandler) ArchiveChat(w http.ResponseWriter, r *http.Request) {
+	action := services.BulkArchive
+	if r.Method == http.MethodDelete {
+		action = services.BulkUnarchive
+	}
+	h.updateOneChat(w, r, services.BulkChatAction{Action: action})
+}
+
+// MoveChat is PUT /api/chats/{id}/folder with {"folder_id": n}, or null to take the
+// chat out of its folder
+func (h *ChatHandler) MoveChat(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FolderID *uint `json:"folder_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	h.updateOneChat(w, r, services.BulkChatAction{Action: services.BulkMove, FolderID: req.FolderID})
+}
+
+// updateOneChat applies a bulk action to the chat in the URL
+func (h *ChatHandler) updateOneChat(w http.ResponseWriter, r *http.Request, action services.BulkChatAction) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	action.ChatIDs = []uint{chatID}
+	affected, err := h.ChatService.BulkUpdateChats(r.Context(), userID, action)
+	if err != nil {
+		slog.WarnContext(r.Context(), "chat update failed", "action", action.Action, "error", err)
+		http.Error(w, err.Error(), organizeErrorStatus(err))
+		return
+	}
+	if affected == 0 {
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetChatTags is PUT /api/chats/{id}/tags with {"tags": [...]}, replacing the chat's tags
+func (h *ChatHandler) SetChatTags(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := h.ChatService.SetChatTags(r.Context(), userID, chatID, req.Tags)
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed to set chat tags", "error", err)
+		http.Error(w, err.Error(), organizeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": chatID, "tags": tags})
+}
+
+// ListTags is GET /api/tags: every tag on the user's chats
+func (h *ChatHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tags, err := h.ChatService.ListTags(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list tags", "error", err)
+		http.Error(w, "Failed to list tags", http.StatusInternalServerError)
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tags": tags})
+}
+
+// ListFolders is GET /api/folders
+func (h *ChatHandler) ListFolders(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	folders, err := h.ChatService.ListFolders(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to list folders", "error", err)
+		http.Error(w, "Failed to list folders", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"folders": folders})
+}
+
+// CreateFolder is POST /api/folders with {"name": "..."}
+func (h *ChatHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	folder, err := h.ChatService.CreateFolder(r.Context(), userID, req.Name)
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed to create folder", "error", err)
+		http.Error(w, err.Error(), organizeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(folder)
+}
+
+// RenameFolder is PATCH /api/folders/{folderId} with {"name": "..."}
+func (h *ChatHandler) RenameFolder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	folderID, err := strconv.ParseUint(mux.Vars(r)["folderId"], 10, 64)
+	if err != nil || folderID == 0 {
+		http.Error(w, "Invalid folder id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	folder, err := h.ChatService.RenameFolder(r.Context(), userID, uint(folderID), req.Name)
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed to rename folder", "folder_id", folderID, "error", err)
+		http.Error(w, err.Error(), organizeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(folder)
+}
+
+// DeleteFolder is DELETE /api/folders/{folderId}. The folder's chats are kept.
+func (h *ChatHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	folderID, err := strconv.ParseUint(mux.Vars(r)["folderId"], 10, 64)
+	if err != nil || folderID == 0 {
+		http.Error(w, "Invalid folder id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ChatService.DeleteFolder(r.Context(), userID, uint(folderID)); err != nil {
+		slog.WarnContext(r.Context(), "failed to delete folder", "folder_id", folderID, "error", err)
+		http.Error(w, err.Error(), organizeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,191 @@
+// File: internal/handlers/chat_share_handler.go
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/repository/chat"
+	"github.com/iyunix/go-internist/internal/services"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// shareResponse is a share link as shown to its owner
+type shareResponse struct {
+	ID           uint       `json:"id"`
+	URL          string     `json:"url"`
+	Active       bool       `json:"active"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ViewCount    int64      `json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func newShareResponse(s *domain.ChatShare, now time.Time) shareResponse {
+	return shareResponse{
+		ID:           s.ID,
+		URL:          "/s/" + s.Token,
+		Active:       s.IsActive(now),
+		ExpiresAt:    s.ExpiresAt,
+		RevokedAt:    s.RevokedAt,
+		ViewCount:    s.ViewCount,
+		LastViewedAt: s.LastViewedAt,
+		CreatedAt:    s.CreatedAt,
+	}
+}
+
+// CreateShare is POST /api/chats/{id}/shares with an optional {"expires_in_hours": n}.
+// Without an expiry the link works until it is revoked.
+func (h *ChatHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in CreateShare")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	var req struct {
+		ExpiresInHours int `json:"expires_in_hours"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInHours < 0 {
+		http.Error(w, "expires_in_hours must be positive", http.StatusBadRequest)
+		return
+	}
+
+	share, err := h.ChatService.CreateShare(r.Context(), userID, chatID, time.Duration(req.ExpiresInHours)*time.Hour)
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed to create share link", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newShareResponse(share, time.Now()))
+}
+
+// ListShares is GET /api/chats/{id}/shares: every link to the chat with its view count
+func (h *ChatHandler) ListShares(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	shares, err := h.ChatService.ListShares(r.Context(), userID, chatID)
+	if err != nil {
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+	now := time.Now()
+	response := make([]shareResponse, 0, len(shares))
+	for i := range shares {
+		response = append(response, newShareResponse(&shares[i], now))
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"shares": response})
+}
+
+// RevokeShare is DELETE /api/chats/{id}/shares/{shareId}
+func (h *ChatHandler) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+	shareID, err := strconv.ParseUint(mux.Vars(r)["shareId"], 10, 64)
+	if err != nil || shareID == 0 {
+		http.Error(w, "Invalid share id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ChatService.RevokeShare(r.Context(), userID, chatID, uint(shareID)); err != nil {
+		if errors.Is(err, chat.ErrShareNotFound) {
+			http.Error(w, "Share link not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "failed to revoke share link", "share_id", shareID, "error", err)
+		http.Error(w, "Failed to revoke share link", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ShowSharedChatPage is the public page behind a share link. It shows the questions and
+// answers only: no owner details, no internal context, and no link back into the app.
+func (h *PageHandler) ShowSharedChatPage(w http.ResponseWriter, r *http.Request) {
+	// The token is the credential: keep it out of referrers and search engines
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	w.Header().Set("Cache-Control", "no-store")
+
+	shared, err := h.ChatService.ViewSharedChat(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		if !errors.Is(err, services.ErrShareUnavailable) {
+			slog.ErrorContext(r.Context(), "failed to load shared chat", "error", err)
+		}
+		h.ShowErrorPage(w, "404", "Link not available", "This conversation link does not exist, has expired or was revoked by its owner.")
+		return
+	}
+
+	mdParser := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(html.WithHardWraps()),
+	)
+	rendered := make([]RenderedMessage, 0, len(shared.Messages))
+	for _, msg := range shared.Messages {
+		if msg.MessageType == domain.MessageTypeAssistant {
+			var buf bytes.Buffer
+			if err := mdParser.Convert([]byte(msg.Content), &buf); err != nil {
+				continue
+			}
+			rendered = append(rendered, RenderedMessage{Message: msg, RenderedContent: template.HTML(buf.String())})
+		} else if msg.MessageType == domain.MessageTypeUser {
+			rendered = append(rendered, RenderedMessage{Message: msg, RenderedContent: template.HTML(template.HTMLEscapeString(msg.Content))})
+		}
+	}
+
+	RenderTemplate(w, "shared_chat.html", map[string]interface{}{
+		"Title":     shared.Title,
+		"CreatedAt": shared.CreatedAt,
+		"Messages":  rendered,
+	})
+}
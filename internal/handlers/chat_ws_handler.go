@@ -0,0 +1,297 @@
+// File: internal/handlers/chat_ws_handler.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/services"
+	"github.com/iyunix/go-internist/internal/streams"
+)
+
+// WebSocket connection settings
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = (wsPongWait * 9) / 10
+	wsMaxMessageSize = 16 * 1024 // prompts are capped at MaxQuestionLength characters
+)
+
+// The default CheckOrigin rejects cross-origin upgrades, which is what we want
+// for a cookie-authenticated endpoint.
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// wsClientMessage is a request from the client: ask, edit, cancel or regenerate
+type wsClientMessage struct {
+	Type      string `json:"type"`
+	Prompt    string `json:"prompt,omitempty"`
+	JobID     uint   `json:"job_id,omitempty"`     // cancel: defaults to the job in flight
+	MessageID uint   `json:"message_id,omitempty"` // edit: the question being replaced
+}
+
+// wsServerMessage carries one frame of a job's stream, or a protocol reply.
+// Stream frames use the SSE event names, with the unnamed token event sent as
+// "delta" and "metadata" sent as "sources".
+type wsServerMessage struct {
+	Type  string          `json:"type"`
+	JobID uint            `json:"job_id,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// wsSession is one chat WebSocket connection. It runs at most one job at a time.
+type wsSession struct {
+	h      *ChatHandler
+	conn   *websocket.Conn
+	ctx    context.Context
+	userID uint
+	chatID uint
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	current uint // job in flight, 0 when idle
+}
+
+// ChatWebSocket is a WebSocket alternative to StreamChatSSE. The prompt travels in a
+// message body rather than the URL, and the client can cancel or regenerate answers.
+// Generation still runs on the worker pool, so a dropped socket does not stop it.
+func (h *ChatHandler) ChatWebSocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in ChatWebSocket")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID, ok := parseChatID(w, r)
+	if !ok {
+		return
+	}
+	r = r.WithContext(logging.WithChatID(r.Context(), chatID))
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response
+		slog.WarnContext(r.Context(), "websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sess := &wsSession{h: h, conn: conn, ctx: ctx, userID: userID, chatID: chatID}
+	slog.InfoContext(ctx, "websocket connected")
+
+	go sess.pingLoop()
+	sess.readLoop()
+
+	slog.InfoContext(ctx, "websocket disconnected")
+}
+
+func (s *wsSession) readLoop() {
+	s.conn.SetReadLimit(wsMaxMessageSize)
+	s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var msg wsClientMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				slog.DebugContext(s.ctx, "websocket read error", "error", err)
+			}
+			return
+		}
+
+		switch msg.Type {
+		case "ask":
+			s.handleAsk(msg.Prompt)
+		case "edit":
+			s.handleEdit(msg.MessageID, msg.Prompt)
+		case "regenerate":
+			s.handleRegenerate()
+		case "cancel":
+			s.handleCancel(msg.JobID)
+		default:
+			s.sendError(0, "unknown message type")
+		}
+	}
+}
+
+func (s *wsSession) pingLoop() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *wsSession) handleAsk(prompt string) {
+	if s.busy() {
+		s.sendError(0, "a generation is already in progress")
+		return
+	}
+	if err := s.h.validateMedicalPrompt(prompt); err != nil {
+		s.sendError(0, err.Error())
+		return
+	}
+	s.start(jobRequest{kind: domain.JobKindAsk, prompt: prompt})
+}
+
+func (s *wsSession) handleEdit(messageID uint, prompt string) {
+	if s.busy() {
+		s.sendError(0, "a generation is already in progress")
+		return
+	}
+	if err := s.h.validateMedicalPrompt(prompt); err != nil {
+		s.sendError(0, err.Error())
+		return
+	}
+	if _, err := s.h.ChatService.GetEditableQuestion(s.ctx, s.userID, s.chatID, messageID); err != nil {
+		s.sendError(0, "message not found")
+		return
+	}
+	s.start(jobRequest{kind: domain.JobKindEdit, messageID: messageID, prompt: prompt})
+}
+
+func (s *wsSession) handleRegenerate() {
+	if s.busy() {
+		s.sendError(0, "a generation is already in progress")
+		return
+	}
+	prompt, err := s.h.ChatService.GetLastQuestion(s.ctx, s.userID, s.chatID)
+	if err != nil {
+		s.sendError(0, "nothing to regenerate")
+		return
+	}
+	s.start(jobRequest{kind: domain.JobKindRegenerate, prompt: prompt})
+}
+
+func (s *wsSession) handleCancel(jobID uint) {
+	if jobID == 0 {
+		s.mu.Lock()
+		jobID = s.current
+		s.mu.Unlock()
+	}
+	if jobID == 0 {
+		s.sendError(0, "no generation in progress")
+		return
+	}
+
+	if err := s.h.Generation.Cancel(s.userID, jobID); err != nil {
+		if errors.Is(err, services.ErrGenerationJobFinished) {
+			s.sendError(jobID, "generation already finished")
+			return
+		}
+		s.sendError(jobID, "job not found")
+		return
+	}
+	slog.InfoContext(s.ctx, "generation job cancel requested", "job_id", jobID)
+}
+
+// start charges, queues the job and relays its stream to the socket
+func (s *wsSession) start(req jobRequest) {
+	genJob, stream, status, err := s.h.chargeAndEnqueue(s.ctx, s.userID, s.chatID, req)
+	if err != nil {
+		if status == http.StatusServiceUnavailable {
+			s.sendError(0, "Server is busy, please retry shortly")
+			return
+		}
+		s.sendError(0, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.current = genJob.ID
+	s.mu.Unlock()
+
+	queued, _ := json.Marshal(map[string]interface{}{
+		"jobId":        genJob.ID,
+		"streamId":     genJob.StreamID,
+		"kind":         genJob.Kind,
+		"chargeAmount": genJob.Charge,
+	})
+	s.send(wsServerMessage{Type: "queued", JobID: genJob.ID, Data: queued})
+
+	go s.relay(genJob.ID, stream)
+}
+
+// relay forwards a job's buffered and live frames until the job or the socket ends
+func (s *wsSession) relay(jobID uint, stream *streams.Stream) {
+	defer func() {
+		s.mu.Lock()
+		if s.current == jobID {
+			s.current = 0
+		}
+		s.mu.Unlock()
+	}()
+
+	var after uint64
+	for {
+		events, done, wait := stream.Since(after)
+		for _, ev := range events {
+			if err := s.send(wsServerMessage{Type: wsEventType(ev.Name), JobID: jobID, Data: ev.Data}); err != nil {
+				return
+			}
+			after = ev.Seq
+		}
+		if done {
+			return
+		}
+		select {
+		case <-wait:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *wsSession) busy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current != 0
+}
+
+func (s *wsSession) send(msg wsServerMessage) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return s.conn.WriteJSON(msg)
+}
+
+func (s *wsSession) sendError(jobID uint, message string) {
+	s.send(wsServerMessage{Type: "error", JobID: jobID, Error: message})
+}
+
+// wsEventType maps stream event names onto the WebSocket message types
+func wsEventType(name string) string {
+	switch name {
+	case "":
+		return "delta"
+	case "metadata":
+		return "sources"
+	default:
+		return name
+	}
+}
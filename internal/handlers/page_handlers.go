@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"html/template"
 	"log"
+	"log/slog"
 	"os"
 	"net/http"
     "path/filepath"
@@ -59,7 +60,7 @@ func loadTemplates() {
 
     partials, err := filepath.Glob(filepath.Join(partialsDir, "*.html"))
     if err != nil {
-        log.Printf("Warning: could not find partial templates: %v", err)
+        slog.Warn("could not find partial templates", "error", err)
     }
 
     pages, err := filepath.Glob(filepath.Join(templateDir, "*.html"))
@@ -86,7 +87,7 @@ func loadTemplates() {
 		}
 		templates[filename] = ts
 	}
-	log.Printf("Successfully loaded and cached %d page templates with %d partials.", len(templates), len(partials))
+	slog.Info("page templates loaded", "templates", len(templates), "partials", len(partials))
 }
 
 
@@ -99,7 +100,7 @@ func RenderTemplate(w http.ResponseWriter, tmplName string, data map[string]inte
 	}
 	err := t.ExecuteTemplate(w, "layout.html", data)
 	if err != nil {
-		log.Printf("Error executing template %s: %v", tmplName, err)
+		slog.Error("failed to execute template", "template", tmplName, "error", err)
 		http.Error(w, "Error rendering template", http.StatusInternalServerError)
 	}
 }
@@ -163,7 +164,7 @@ func (h *PageHandler) ShowResetPasswordPage(w http.ResponseWriter, r *http.Reque
 func (h *PageHandler) ShowAdminPage(w http.ResponseWriter, r *http.Request) {
 	users, _, err := h.AdminService.GetAllUsers(r.Context(), 1, 10, "")
 	if err != nil {
-		log.Printf("Error fetching users for admin page: %v", err)
+		slog.ErrorContext(r.Context(), "failed to fetch users for admin page", "error", err)
 		users = []domain.User{}
 	}
 	data := map[string]interface{}{
@@ -185,7 +186,7 @@ func (h *PageHandler) ShowChatPage(w http.ResponseWriter, r *http.Request) {
 	}
 	chats, err := h.ChatService.GetUserChats(r.Context(), userID)
 	if err != nil {
-		log.Printf("Error fetching chats for user %d: %v", userID, err)
+		slog.ErrorContext(r.Context(), "failed to fetch chats", "user_id", userID, "error", err)
 		chats = []domain.Chat{}
 	}
 	activeChatIDStr := r.URL.Query().Get("id")
@@ -195,7 +196,7 @@ func (h *PageHandler) ShowChatPage(w http.ResponseWriter, r *http.Request) {
 		activeChatID, _ = strconv.ParseUint(activeChatIDStr, 10, 64)
 		messages, _, err := h.ChatService.GetChatMessagesWithPagination(r.Context(), userID, uint(activeChatID), 50, 0)
 		if err != nil {
-			log.Printf("Error fetching messages for chat %d: %v", activeChatID, err)
+			slog.ErrorContext(r.Context(), "failed to fetch messages", "chat_id", activeChatID, "error", err)
 		} else {
 			mdParser := goldmark.New(
 				goldmark.WithExtensions(extension.GFM),
@@ -225,6 +226,9 @@ func (h *PageHandler) ShowChatPage(w http.ResponseWriter, r *http.Request) {
 		"Messages":     renderedMessages,
 		"ActiveChatID": uint(activeChatID),
 	}
+	if _, impersonating := middleware.GetImpersonatorID(r); impersonating {
+		data["Impersonating"] = true
+	}
 	RenderTemplate(w, "chat.html", data)
 }
 
@@ -249,12 +253,12 @@ func findTemplateDir() string {
     
     for _, path := range possiblePaths {
         if _, err := os.Stat(path); err == nil {
-            log.Printf("Found templates directory at: %s", path)
+            slog.Info("found templates directory", "path", path)
             return path
         }
     }
     
     // Fallback to default
-    log.Printf("Warning: using fallback template path - templates may not load")
+    slog.Warn("using fallback template path, templates may not load")
     return "web/templates"
 }
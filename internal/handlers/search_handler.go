@@ -0,0 +1,52 @@
+// File: internal/handlers/search_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/iyunix/go-internist/internal/middleware"
+	"github.com/iyunix/go-internist/internal/services"
+)
+
+// SearchConversations is GET /api/search?q=&page=&limit=. It searches message content and
+// chat titles across all of the user's chats; each hit carries the chat and the message to
+// scroll to (message_id is omitted for title matches).
+func (h *ChatHandler) SearchConversations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(uint)
+	if !ok || userID == 0 {
+		slog.WarnContext(r.Context(), "invalid user ID in SearchConversations")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page := h.getPageFromQuery(r)
+	limit := h.getLimitFromQuery(r)
+	offset := (page - 1) * limit
+
+	hits, total, err := h.ChatService.SearchConversations(r.Context(), userID, r.URL.Query().Get("q"), limit, offset)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSearchQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.ErrorContext(r.Context(), "conversation search failed", "error", err)
+		http.Error(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	response := map[string]interface{}{
+		"results":  hits,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+		"has_more": total > int64(offset+len(hits)),
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding search response", "error", err)
+	}
+}
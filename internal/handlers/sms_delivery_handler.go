@@ -0,0 +1,60 @@
+// File: internal/handlers/sms_delivery_handler.go
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/iyunix/go-internist/internal/services"
+	"github.com/iyunix/go-internist/internal/services/sms"
+)
+
+// SMSDeliveryHandler receives delivery reports pushed by SMS providers
+type SMSDeliveryHandler struct {
+	SMSService *services.SMSService
+	token      string
+}
+
+// NewSMSDeliveryHandler creates the callback handler. An empty token disables the
+// callback, since the URL would otherwise let anyone mark messages delivered.
+func NewSMSDeliveryHandler(smsService *services.SMSService, token string) *SMSDeliveryHandler {
+	return &SMSDeliveryHandler{SMSService: smsService, token: token}
+}
+
+// DeliveryReport is POST /api/sms/delivery/{provider}?token=... Configure the provider's
+// delivery callback with this URL, e.g. /api/sms/delivery/kavenegar?token=SMS_CALLBACK_TOKEN.
+func (h *SMSDeliveryHandler) DeliveryReport(w http.ResponseWriter, r *http.Request) {
+	if h.token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(h.token)) != 1 {
+		slog.WarnContext(r.Context(), "SMS delivery report with bad token", "remote_addr", r.RemoteAddr)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	provider := mux.Vars(r)["provider"]
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	updated, err := h.SMSService.HandleDeliveryReport(r.Context(), provider, r)
+	if err != nil {
+		slog.WarnContext(r.Context(), "SMS delivery report rejected", "provider", provider, "error", err)
+		status := http.StatusInternalServerError
+		if smsErr, ok := err.(*sms.SMSError); ok {
+			switch smsErr.Type {
+			case sms.ErrTypeValidation:
+				status = http.StatusBadRequest
+			case sms.ErrTypeConfig:
+				status = http.StatusNotFound
+			}
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{"updated": updated})
+}
@@ -0,0 +1,154 @@
+// File: internal/health/health.go
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Status values reported per check and overall.
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+	StatusDisabled  = "disabled"
+)
+
+// Check describes one dependency probe.
+type Check struct {
+	Name     string
+	Critical bool          // a failing critical check makes the service unhealthy
+	TTL      time.Duration // how long a result is reused before probing again
+	Timeout  time.Duration
+	Disabled bool // reported but never probed (e.g. translation turned off)
+	Probe    func(ctx context.Context) error
+}
+
+// Result is the outcome of a single check as returned to clients. Probe errors
+// are logged, never returned, since the endpoint is unauthenticated.
+type Result struct {
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	LatencyMS int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	Cached    bool      `json:"cached"`
+}
+
+// Report aggregates all check results.
+type Report struct {
+	Status    string            `json:"status"`
+	Service   string            `json:"service"`
+	Timestamp time.Time         `json:"timestamp"`
+	Checks    map[string]Result `json:"checks"`
+}
+
+type cachedResult struct {
+	result    Result
+	expiresAt time.Time
+}
+
+// Checker runs registered probes concurrently and caches their results so
+// that frequent readiness polling does not hit paid upstream APIs.
+type Checker struct {
+	service string
+	checks  []Check
+
+	mu    sync.Mutex
+	cache map[string]cachedResult
+}
+
+// NewChecker creates an empty checker for the named service.
+func NewChecker(service string) *Checker {
+	return &Checker{
+		service: service,
+		cache:   make(map[string]cachedResult),
+	}
+}
+
+// Register adds a check. Missing timeouts default to 3s.
+func (c *Checker) Register(check Check) {
+	if check.Timeout <= 0 {
+		check.Timeout = 3 * time.Second
+	}
+	c.checks = append(c.checks, check)
+}
+
+// Run executes all checks (honouring the cache) and builds a report.
+// Overall status is unhealthy if any critical check fails, degraded if only
+// optional checks fail, healthy otherwise.
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{
+		Status:    StatusHealthy,
+		Service:   c.service,
+		Timestamp: time.Now().UTC(),
+		Checks:    make(map[string]Result, len(c.checks)),
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := c.runCheck(ctx, check)
+			mu.Lock()
+			report.Checks[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusUnhealthy {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusUnhealthy
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+func (c *Checker) runCheck(ctx context.Context, check Check) Result {
+	if check.Disabled {
+		return Result{Status: StatusDisabled, Critical: check.Critical, CheckedAt: time.Now().UTC()}
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	cached, ok := c.cache[check.Name]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		result := cached.result
+		result.Cached = true
+		return result
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Probe(probeCtx)
+	result := Result{
+		Status:    StatusHealthy,
+		Critical:  check.Critical,
+		LatencyMS: time.Since(start).Milliseconds(),
+		CheckedAt: start.UTC(),
+	}
+	if err != nil {
+		result.Status = StatusUnhealthy
+		slog.WarnContext(ctx, "health check failed", "check", check.Name, "critical", check.Critical, "error", err)
+	}
+
+	// A request cancelled by the caller says nothing about the dependency
+	if ctx.Err() == nil && check.TTL > 0 {
+		c.mu.Lock()
+		c.cache[check.Name] = cachedResult{result: result, expiresAt: start.Add(check.TTL)}
+		c.mu.Unlock()
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+// File: internal/logging/context.go
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	userIDKey
+	chatIDKey
+)
+
+// WithRequestID stores the request ID so every log record emitted with this
+// context carries it.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// WithUserID stores the authenticated user ID for log enrichment.
+func WithUserID(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// WithChatID stores the chat being operated on for log enrichment.
+func WithChatID(ctx context.Context, chatID uint) context.Context {
+	return context.WithValue(ctx, chatIDKey, chatID)
+}
+
+// RequestIDFromContext returns the request ID, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// UserIDFromContext returns the user ID, if any.
+func UserIDFromContext(ctx context.Context) uint {
+	if ctx == nil {
+		return 0
+	}
+	id, _ := ctx.Value(userIDKey).(uint)
+	return id
+}
+
+// ChatIDFromContext returns the chat ID, if any.
+func ChatIDFromContext(ctx context.Context) uint {
+	if ctx == nil {
+		return 0
+	}
+	id, _ := ctx.Value(chatIDKey).(uint)
+	return id
+}
+
+// contextHandler appends request_id, user_id and chat_id from the record's
+// context, so callers never have to pass them explicitly.
+type contextHandler struct {
+	next slog.Handler
+}
+
+func newContextHandler(next slog.Handler) *contextHandler {
+	return &contextHandler{next: next}
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	present := make(map[string]bool, 3)
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "request_id", "user_id", "chat_id":
+			present[a.Key] = true
+		}
+		return true
+	})
+
+	if id := RequestIDFromContext(ctx); id != "" && !present["request_id"] {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if id := UserIDFromContext(ctx); id != 0 && !present["user_id"] {
+		r.AddAttrs(slog.Uint64("user_id", uint64(id)))
+	}
+	if id := ChatIDFromContext(ctx); id != 0 && !present["chat_id"] {
+		r.AddAttrs(slog.Uint64("chat_id", uint64(id)))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
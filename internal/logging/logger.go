@@ -0,0 +1,90 @@
+// File: internal/logging/logger.go
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger is the logging interface services, repositories and providers take. It is
+// the one interface for the whole app; NewLogger backs it with the slog default.
+//
+// A context.Context may be passed anywhere in keysAndValues; it is not logged
+// as a field but used to attach request_id, user_id and chat_id to the record.
+type Logger interface {
+	Info(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+	Debug(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+}
+
+// SlogLogger adapts a *slog.Logger to the Logger interface used by services.
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger wraps an slog logger. A nil logger falls back to slog.Default().
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{logger: logger}
+}
+
+// Slog exposes the underlying slog logger for code that logs with context directly.
+func (s *SlogLogger) Slog() *slog.Logger {
+	return s.logger
+}
+
+// Info logs informational messages
+func (s *SlogLogger) Info(msg string, keysAndValues ...interface{}) {
+	s.log(slog.LevelInfo, msg, keysAndValues...)
+}
+
+// Error logs error messages
+func (s *SlogLogger) Error(msg string, keysAndValues ...interface{}) {
+	s.log(slog.LevelError, msg, keysAndValues...)
+}
+
+// Debug logs debug messages
+func (s *SlogLogger) Debug(msg string, keysAndValues ...interface{}) {
+	s.log(slog.LevelDebug, msg, keysAndValues...)
+}
+
+// Warn logs warning messages
+func (s *SlogLogger) Warn(msg string, keysAndValues ...interface{}) {
+	s.log(slog.LevelWarn, msg, keysAndValues...)
+}
+
+// log pulls an optional context out of keysAndValues and forwards the rest to slog.
+func (s *SlogLogger) log(level slog.Level, msg string, keysAndValues ...interface{}) {
+	ctx := context.Background()
+	args := keysAndValues
+	for i, kv := range keysAndValues {
+		if c, ok := kv.(context.Context); ok {
+			ctx = c
+			args = make([]interface{}, 0, len(keysAndValues)-1)
+			args = append(args, keysAndValues[:i]...)
+			args = append(args, keysAndValues[i+1:]...)
+			break
+		}
+	}
+	if !s.logger.Enabled(ctx, level) {
+		return
+	}
+	s.logger.Log(ctx, level, msg, args...)
+}
+
+// NoOpLogger is a logger that does nothing (for testing)
+type NoOpLogger struct{}
+
+func (n *NoOpLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (n *NoOpLogger) Error(msg string, keysAndValues ...interface{}) {}
+func (n *NoOpLogger) Debug(msg string, keysAndValues ...interface{}) {}
+func (n *NoOpLogger) Warn(msg string, keysAndValues ...interface{})  {}
+
+// NewLogger returns a service-scoped logger derived from the process-wide
+// slog default installed by Setup.
+func NewLogger(service string) Logger {
+	return NewSlogLogger(slog.Default().With("service", service))
+}
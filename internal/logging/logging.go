@@ -0,0 +1,62 @@
+// File: internal/logging/logging.go
+package logging
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Options configures the process-wide logger.
+type Options struct {
+	Level     string // DEBUG, INFO, WARN, ERROR (case-insensitive)
+	Format    string // "json" or "text"
+	Redaction Policy
+	Output    io.Writer
+}
+
+// ParseLevel converts a LOG_LEVEL string to a slog level, defaulting to INFO.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToUpper(strings.TrimSpace(level)) {
+	case "DEBUG":
+		return slog.LevelDebug
+	case "WARN", "WARNING":
+		return slog.LevelWarn
+	case "ERROR":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// New builds a slog logger that enriches records with request-scoped fields
+// from the context and redacts sensitive values before they are written.
+func New(opts Options) *slog.Logger {
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: ParseLevel(opts.Level)}
+
+	var base slog.Handler
+	if strings.EqualFold(opts.Format, "json") {
+		base = slog.NewJSONHandler(out, handlerOpts)
+	} else {
+		base = slog.NewTextHandler(out, handlerOpts)
+	}
+
+	return slog.New(newContextHandler(newRedactHandler(base, opts.Redaction)))
+}
+
+// Setup builds the logger and installs it as the slog default. Because the
+// standard library routes log.Printf through the default slog handler once
+// one is installed, legacy log.Printf calls get the same redaction.
+func Setup(opts Options) *slog.Logger {
+	logger := New(opts)
+	slog.SetDefault(logger)
+	log.SetFlags(0)
+	return logger
+}
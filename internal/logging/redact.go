@@ -0,0 +1,189 @@
+// File: internal/logging/redact.go
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// ContentMode controls how user/assistant message content appears in logs.
+type ContentMode string
+
+const (
+	ContentRedact   ContentMode = "redact"   // replace with a length marker
+	ContentTruncate ContentMode = "truncate" // keep a short prefix
+	ContentFull     ContentMode = "full"     // log as-is (development only)
+)
+
+// Policy describes which values are masked before a record is written.
+type Policy struct {
+	MaskPhones       bool
+	MaskTokens       bool
+	Content          ContentMode
+	MaxContentLength int // prefix length kept in truncate mode
+}
+
+// DefaultPolicy masks everything sensitive; suitable for production.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaskPhones:       true,
+		MaskTokens:       true,
+		Content:          ContentRedact,
+		MaxContentLength: 40,
+	}
+}
+
+// ParseContentMode converts a LOG_CONTENT_POLICY value, defaulting to redact.
+func ParseContentMode(mode string) ContentMode {
+	switch ContentMode(strings.ToLower(strings.TrimSpace(mode))) {
+	case ContentTruncate:
+		return ContentTruncate
+	case ContentFull:
+		return ContentFull
+	default:
+		return ContentRedact
+	}
+}
+
+var (
+	phonePattern = regexp.MustCompile(`(?:\+98|0098|98|0)9\d{9}`)
+	tokenPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+|(?i:bearer\s+)[A-Za-z0-9._~+/=-]+`)
+
+	phoneKeys = map[string]bool{
+		"phone": true, "phone_number": true, "phonenumber": true,
+		"admin_phone": true, "mobile": true, "recipient": true,
+	}
+	secretKeys = map[string]bool{
+		"token": true, "auth_token": true, "jwt": true, "password": true,
+		"secret": true, "api_key": true, "apikey": true, "authorization": true,
+		"code": true, "sms_code": true, "otp": true, "verification_code": true,
+	}
+	contentKeys = map[string]bool{
+		"content": true, "text": true, "prompt": true, "query": true,
+		"question": true, "current_query": true, "original": true,
+		"translated": true, "embedding_query": true, "llm_query": true,
+		"message_content": true, "answer": true, "response": true,
+	}
+)
+
+// MaskPhone keeps the operator prefix and hides the subscriber number.
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return "****"
+	}
+	return phone[:4] + "****"
+}
+
+// Redactor applies a Policy to individual values. It is exported so code that
+// builds log lines by hand can share the same rules.
+type Redactor struct {
+	policy Policy
+}
+
+// NewRedactor returns a redactor for the given policy.
+func NewRedactor(policy Policy) *Redactor {
+	return &Redactor{policy: policy}
+}
+
+// String scrubs phone numbers and bearer/JWT tokens embedded in free text.
+func (rd *Redactor) String(s string) string {
+	if rd.policy.MaskPhones {
+		s = phonePattern.ReplaceAllStringFunc(s, MaskPhone)
+	}
+	if rd.policy.MaskTokens {
+		s = tokenPattern.ReplaceAllString(s, "[REDACTED_TOKEN]")
+	}
+	return s
+}
+
+// Content applies the content mode to a message body.
+func (rd *Redactor) Content(s string) string {
+	switch rd.policy.Content {
+	case ContentFull:
+		return rd.String(s)
+	case ContentTruncate:
+		limit := rd.policy.MaxContentLength
+		if limit <= 0 {
+			limit = 40
+		}
+		runes := []rune(s)
+		if len(runes) > limit {
+			s = string(runes[:limit]) + "…"
+		}
+		return rd.String(s)
+	default:
+		return fmt.Sprintf("[REDACTED len=%d]", len([]rune(s)))
+	}
+}
+
+// Attr redacts a single attribute according to its key and value.
+func (rd *Redactor) Attr(a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	v := a.Value.Resolve()
+
+	if v.Kind() == slog.KindGroup {
+		attrs := v.Group()
+		out := make([]any, 0, len(attrs))
+		for _, ga := range attrs {
+			out = append(out, rd.Attr(ga))
+		}
+		return slog.Group(a.Key, out...)
+	}
+
+	switch {
+	case secretKeys[key] && rd.policy.MaskTokens:
+		return slog.String(a.Key, "[REDACTED]")
+	case phoneKeys[key] && rd.policy.MaskPhones:
+		return slog.String(a.Key, MaskPhone(v.String()))
+	case contentKeys[key]:
+		return slog.String(a.Key, rd.Content(v.String()))
+	}
+
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, rd.String(v.String()))
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok && err != nil {
+			return slog.String(a.Key, rd.String(err.Error()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// redactHandler rewrites every record through a Redactor before delegating.
+type redactHandler struct {
+	next slog.Handler
+	rd   *Redactor
+}
+
+func newRedactHandler(next slog.Handler, policy Policy) *redactHandler {
+	return &redactHandler{next: next, rd: NewRedactor(policy)}
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	clean := slog.NewRecord(r.Time, r.Level, h.rd.String(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(h.rd.Attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, clean)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = h.rd.Attr(a)
+	}
+	return &redactHandler{next: h.next.WithAttrs(clean), rd: h.rd}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), rd: h.rd}
+}
@@ -3,22 +3,24 @@ package middleware
 
 import (
     "context"
-    "log"
+    "fmt"
+    "log/slog"
     "net/http"
     "sync"
     "time"
 
+    "github.com/iyunix/go-internist/internal/domain"
     "github.com/iyunix/go-internist/internal/repository/user"
 )
 
-// AdminCache stores admin status to avoid database hits
+// AdminCache stores admin roles to avoid database hits
 type AdminCache struct {
     mu    sync.RWMutex
     cache map[uint]AdminCacheEntry
 }
 
 type AdminCacheEntry struct {
-    IsAdmin   bool
+    Role      string // domain.Role*, empty for regular users
     Username  string
     ExpiresAt time.Time
 }
@@ -27,36 +29,36 @@ var (
     adminCache = &AdminCache{
         cache: make(map[uint]AdminCacheEntry),
     }
-    cacheExpiry = 10 * time.Minute // Admin status cache for 10 minutes
+    cacheExpiry = 10 * time.Minute // Admin role cache for 10 minutes
 )
 
-// isAdminCached checks admin status with caching
-func (ac *AdminCache) isAdminCached(userID uint, userRepo user.UserRepository, ctx context.Context) (bool, string, error) {
+// roleCached looks up the user's admin role with caching
+func (ac *AdminCache) roleCached(userID uint, userRepo user.UserRepository, ctx context.Context) (string, string, error) {
     ac.mu.RLock()
     entry, exists := ac.cache[userID]
     ac.mu.RUnlock()
 
     // Return cached result if valid
     if exists && time.Now().Before(entry.ExpiresAt) {
-        return entry.IsAdmin, entry.Username, nil
+        return entry.Role, entry.Username, nil
     }
 
     // Cache miss or expired - fetch from database
     user, err := userRepo.FindByID(ctx, userID)
     if err != nil {
-        return false, "", err
+        return "", "", err
     }
 
     // Update cache
     ac.mu.Lock()
     ac.cache[userID] = AdminCacheEntry{
-        IsAdmin:   user.IsAdmin,
+        Role:      user.AdminRole(),
         Username:  user.Username,
         ExpiresAt: time.Now().Add(cacheExpiry),
     }
     ac.mu.Unlock()
 
-    return user.IsAdmin, user.Username, nil
+    return user.AdminRole(), user.Username, nil
 }
 
 // ClearAdminCache clears the admin cache for a specific user
@@ -73,9 +75,21 @@ func ClearAllAdminCache() {
     adminCache.mu.Unlock()
 }
 
-// RequireAdmin is a middleware that checks if the authenticated user has admin privileges.
-// It MUST be used AFTER the standard JWT authentication middleware.
+// RequireAdmin is a middleware that checks the authenticated user has any admin role.
+// It MUST be used AFTER the standard JWT authentication middleware. Routes that change
+// or reveal data should use RequirePermission instead.
 func RequireAdmin(userRepo user.UserRepository) func(http.Handler) http.Handler {
+    return requireRole(userRepo, "", func(role string) bool { return role != "" })
+}
+
+// RequirePermission is a middleware that checks the authenticated user's admin role
+// grants the permission (domain.Perm*). It MUST be used AFTER the standard JWT
+// authentication middleware.
+func RequirePermission(userRepo user.UserRepository, permission string) func(http.Handler) http.Handler {
+    return requireRole(userRepo, permission, func(role string) bool { return domain.RoleHasPermission(role, permission) })
+}
+
+func requireRole(userRepo user.UserRepository, permission string, allowed func(role string) bool) func(http.Handler) http.Handler {
     return func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             startTime := time.Now()
@@ -84,8 +98,8 @@ func RequireAdmin(userRepo user.UserRepository) func(http.Handler) http.Handler
             userID, ok := r.Context().Value(UserIDKey).(uint)
             if !ok || userID == 0 {
                 // This indicates a problem with the auth setup or the token is missing claims.
-                log.Printf("[AdminMiddleware] SECURITY_ALERT: Invalid authentication context for admin route %s from %s", 
-                    r.URL.Path, r.RemoteAddr)
+                slog.WarnContext(r.Context(), "invalid authentication context for admin route",
+                    "path", r.URL.Path, "remote_addr", r.RemoteAddr)
                 
                 w.Header().Set("Content-Type", "application/json")
                 w.WriteHeader(http.StatusForbidden)
@@ -93,12 +107,12 @@ func RequireAdmin(userRepo user.UserRepository) func(http.Handler) http.Handler
                 return
             }
 
-            // 2. Check admin status with caching
-            isAdmin, username, err := adminCache.isAdminCached(userID, userRepo, r.Context())
+            // 2. Look up the admin role with caching
+            role, username, err := adminCache.roleCached(userID, userRepo, r.Context())
             if err != nil {
                 // This could happen if the user was deleted after their token was issued.
-                log.Printf("[AdminMiddleware] SECURITY_ALERT: Could not verify user ID %d for admin access to %s. Error: %v", 
-                    userID, r.URL.Path, err)
+                slog.WarnContext(r.Context(), "could not verify user for admin access",
+                    "user_id", userID, "path", r.URL.Path, "error", err)
                 
                 w.Header().Set("Content-Type", "application/json")
                 w.WriteHeader(http.StatusForbidden)
@@ -106,33 +120,39 @@ func RequireAdmin(userRepo user.UserRepository) func(http.Handler) http.Handler
                 return
             }
 
-            // 3. The core logic: check the IsAdmin flag.
-            if !isAdmin {
-                // The user is logged in, but they are NOT an admin.
-                log.Printf("[AdminMiddleware] SECURITY_ALERT: UNAUTHORIZED_ADMIN_ACCESS - Non-admin user '%s' (ID: %d) from %s attempted to access admin route: %s", 
-                    username, userID, r.RemoteAddr, r.URL.Path)
+            // 3. The core logic: check the role allows this route.
+            if !allowed(role) {
+                slog.WarnContext(r.Context(), "unauthorized admin access",
+                    "user_id", userID, "username", username, "role", role,
+                    "remote_addr", r.RemoteAddr, "path", r.URL.Path, "permission", permission)
                 
                 // Log security event for monitoring
-                logSecurityEvent("unauthorized_admin_access", userID, username, r.URL.Path, r.RemoteAddr)
+                logSecurityEvent(r.Context(), "unauthorized_admin_access", userID, username, r.URL.Path, r.RemoteAddr)
                 
                 w.Header().Set("Content-Type", "application/json")
                 w.WriteHeader(http.StatusForbidden)
-                w.Write([]byte(`{"error":"Insufficient privileges","message":"You do not have permission to access this resource","code":"INSUFFICIENT_PRIVILEGES"}`))
+                if permission == "" {
+                    w.Write([]byte(`{"error":"Insufficient privileges","message":"You do not have permission to access this resource","code":"INSUFFICIENT_PRIVILEGES"}`))
+                } else {
+                    fmt.Fprintf(w, `{"error":"Insufficient privileges","message":"This action requires the %s permission","code":"MISSING_PERMISSION","permission":%q}`, permission, permission)
+                }
                 return
             }
 
             // 4. Add admin user info to context for downstream handlers
             ctx := context.WithValue(r.Context(), IsAdminKey, true)
             ctx = context.WithValue(ctx, UsernameKey, username)
+            ctx = context.WithValue(ctx, AdminRoleKey, role)
             r = r.WithContext(ctx)
 
-            // 5. If we reach here, the user is a verified admin. Allow the request to proceed.
+            // 5. If we reach here, the user's role allows the route. Allow the request to proceed.
             duration := time.Since(startTime)
-            log.Printf("[AdminMiddleware] SUCCESS: Admin user '%s' (ID: %d) accessed admin route: %s (auth_time: %v)", 
-                username, userID, r.URL.Path, duration)
+            slog.InfoContext(r.Context(), "admin route accessed",
+                "user_id", userID, "username", username, "role", role,
+                "path", r.URL.Path, "auth_time", duration.String())
             
             // Log successful admin access for security monitoring
-            logSecurityEvent("admin_access_granted", userID, username, r.URL.Path, r.RemoteAddr)
+            logSecurityEvent(r.Context(), "admin_access_granted", userID, username, r.URL.Path, r.RemoteAddr)
             
             next.ServeHTTP(w, r)
         })
@@ -140,11 +160,15 @@ func RequireAdmin(userRepo user.UserRepository) func(http.Handler) http.Handler
 }
 
 // logSecurityEvent logs security-related events for monitoring and alerting
-func logSecurityEvent(eventType string, userID uint, username, path, remoteAddr string) {
+func logSecurityEvent(ctx context.Context, eventType string, userID uint, username, path, remoteAddr string) {
     // This can be enhanced to send to security monitoring systems
     // For now, we log with a specific format that monitoring tools can parse
-    log.Printf("[SECURITY_EVENT] type=%s user_id=%d username=%s path=%s remote_addr=%s timestamp=%s", 
-        eventType, userID, username, path, remoteAddr, time.Now().UTC().Format(time.RFC3339))
+    slog.InfoContext(ctx, "security event",
+        "event", eventType,
+        "user_id", userID,
+        "username", username,
+        "path", path,
+        "remote_addr", remoteAddr)
 }
 
 // AdminMiddlewareConfig allows configuration of the admin middleware
@@ -163,6 +187,12 @@ func RequireAdminWithConfig(userRepo user.UserRepository, config AdminMiddleware
     return RequireAdmin(userRepo)
 }
 
+// GetAdminRoleFromContext returns the admin role RequireAdmin or RequirePermission found
+func GetAdminRoleFromContext(r *http.Request) string {
+    role, _ := r.Context().Value(AdminRoleKey).(string)
+    return role
+}
+
 // GetAdminFromContext retrieves admin user information from request context
 func GetAdminFromContext(r *http.Request) (userID uint, username string, isAdmin bool) {
     if id, ok := r.Context().Value(UserIDKey).(uint); ok {
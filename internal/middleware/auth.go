@@ -4,12 +4,13 @@ package middleware
 import (
     "context"
     "encoding/json"
-    "log"
+    "log/slog"
     "net/http"
     "strings"
     "sync"
     "time"
 
+    "github.com/iyunix/go-internist/internal/logging"
     "github.com/iyunix/go-internist/internal/services/user_services"
 )
 
@@ -20,11 +21,19 @@ type UserCache struct {
 }
 
 type UserCacheEntry struct {
-    ID          uint
-    Username    string
-    PhoneNumber string
-    IsAdmin     bool
-    ExpiresAt   time.Time
+    ID                uint
+    Username          string
+    PhoneNumber       string
+    IsAdmin           bool
+    Suspended         bool
+    SessionsRevokedAt *time.Time
+    ExpiresAt         time.Time
+}
+
+// tokenRevoked reports whether a token issued at issuedAt was revoked by an admin
+// action (suspension, forced password reset)
+func (e *UserCacheEntry) tokenRevoked(issuedAt time.Time) bool {
+    return e.SessionsRevokedAt != nil && issuedAt.Before(e.SessionsRevokedAt.Truncate(time.Second))
 }
 
 var (
@@ -53,11 +62,13 @@ func (uc *UserCache) getUserCached(userID uint, userService *user_services.UserS
 
     // Create cache entry
     cacheEntry := UserCacheEntry{
-        ID:          user.ID,
-        Username:    user.Username,
-        PhoneNumber: user.PhoneNumber,
-        IsAdmin:     user.IsAdmin, // Use database field, not phone comparison
-        ExpiresAt:   time.Now().Add(userCacheExpiry),
+        ID:                user.ID,
+        Username:          user.Username,
+        PhoneNumber:       user.PhoneNumber,
+        IsAdmin:           user.IsAdmin, // Use database field, not phone comparison
+        Suspended:         user.IsSuspended(),
+        SessionsRevokedAt: user.SessionsRevokedAt,
+        ExpiresAt:         time.Now().Add(userCacheExpiry),
     }
 
     // Update cache
@@ -137,11 +148,28 @@ type AuthMetrics struct {
 
 var authMetrics = &AuthMetrics{}
 
+// AuthMetricsSnapshot is a lock-free copy of AuthMetrics
+type AuthMetricsSnapshot struct {
+    AuthAttempts     int64
+    AuthSuccesses    int64
+    AuthFailures     int64
+    TokenValidations int64
+    CacheHits        int64
+    CacheMisses      int64
+}
+
 // GetAuthMetrics returns current authentication metrics
-func GetAuthMetrics() AuthMetrics {
+func GetAuthMetrics() AuthMetricsSnapshot {
     authMetrics.mu.RLock()
     defer authMetrics.mu.RUnlock()
-    return *authMetrics
+    return AuthMetricsSnapshot{
+        AuthAttempts:     authMetrics.AuthAttempts,
+        AuthSuccesses:    authMetrics.AuthSuccesses,
+        AuthFailures:     authMetrics.AuthFailures,
+        TokenValidations: authMetrics.TokenValidations,
+        CacheHits:        authMetrics.CacheHits,
+        CacheMisses:      authMetrics.CacheMisses,
+    }
 }
 
 // NewJWTMiddleware creates middleware to validate JWT and check admin status
@@ -162,14 +190,13 @@ func NewJWTMiddleware(
             // Get auth token from cookie
             cookie, err := r.Cookie("auth_token")
             if err != nil {
-                log.Printf("[AuthMiddleware] Missing auth_token cookie from %s for path %s: %v", 
-                    r.RemoteAddr, r.URL.Path, err)
+                slog.DebugContext(r.Context(), "missing auth_token cookie", "path", r.URL.Path, "error", err)
                 
                 authMetrics.mu.Lock()
                 authMetrics.AuthFailures++
                 authMetrics.mu.Unlock()
                 
-                logAuthEvent("auth_cookie_missing", 0, "", r.URL.Path, r.RemoteAddr)
+                logAuthEvent(r.Context(), "auth_cookie_missing", 0, r.URL.Path, r.RemoteAddr)
                 
                 if isAPIRequest(r) {
                     sendAPIError(w, http.StatusUnauthorized, "authentication_required", "Authentication token required")
@@ -185,16 +212,15 @@ func NewJWTMiddleware(
             authMetrics.TokenValidations++
             authMetrics.mu.Unlock()
             
-            userID, err := authService.ValidateJWTToken(cookie.Value)
+            userID, issuedAt, err := authService.ParseJWTToken(cookie.Value)
             if err != nil {
-                log.Printf("[AuthMiddleware] Invalid token from %s for path %s: %v", 
-                    r.RemoteAddr, r.URL.Path, err)
+                slog.DebugContext(r.Context(), "invalid auth token", "path", r.URL.Path, "error", err)
                 
                 authMetrics.mu.Lock()
                 authMetrics.AuthFailures++
                 authMetrics.mu.Unlock()
                 
-                logAuthEvent("token_invalid", 0, "", r.URL.Path, r.RemoteAddr)
+                logAuthEvent(r.Context(), "token_invalid", 0, r.URL.Path, r.RemoteAddr)
                 clearAuthCookie(w)
                 
                 if isAPIRequest(r) {
@@ -209,14 +235,13 @@ func NewJWTMiddleware(
             // Get user information with caching
             user, err := userCache.getUserCached(userID, userService, r.Context())
             if err != nil {
-                log.Printf("[AuthMiddleware] User %d not found from %s for path %s: %v", 
-                    userID, r.RemoteAddr, r.URL.Path, err)
+                slog.DebugContext(r.Context(), "authenticated user not found", "user_id", userID, "path", r.URL.Path, "error", err)
                 
                 authMetrics.mu.Lock()
                 authMetrics.AuthFailures++
                 authMetrics.mu.Unlock()
                 
-                logAuthEvent("user_not_found", userID, "", r.URL.Path, r.RemoteAddr)
+                logAuthEvent(r.Context(), "user_not_found", userID, r.URL.Path, r.RemoteAddr)
                 clearAuthCookie(w)
                 
                 if isAPIRequest(r) {
@@ -228,6 +253,40 @@ func NewJWTMiddleware(
                 return
             }
 
+            // Admin actions take effect on the next request once the caches are cleared
+            if user.Suspended {
+                authMetrics.mu.Lock()
+                authMetrics.AuthFailures++
+                authMetrics.mu.Unlock()
+                
+                logAuthEvent(r.Context(), "account_suspended", userID, r.URL.Path, r.RemoteAddr)
+                clearAuthCookie(w)
+                
+                if isAPIRequest(r) {
+                    sendAPIError(w, http.StatusForbidden, "account_suspended", "This account has been suspended")
+                    return
+                }
+                
+                http.Redirect(w, r, "/login?error=account_suspended", http.StatusSeeOther)
+                return
+            }
+            if user.tokenRevoked(issuedAt) {
+                authMetrics.mu.Lock()
+                authMetrics.AuthFailures++
+                authMetrics.mu.Unlock()
+                
+                logAuthEvent(r.Context(), "token_revoked", userID, r.URL.Path, r.RemoteAddr)
+                clearAuthCookie(w)
+                
+                if isAPIRequest(r) {
+                    sendAPIError(w, http.StatusUnauthorized, "invalid_token", "Authentication token is invalid or expired")
+                    return
+                }
+                
+                http.Redirect(w, r, "/login?error=session_expired", http.StatusSeeOther)
+                return
+            }
+
             // Update cache hit metrics
             authMetrics.mu.Lock()
             authMetrics.CacheHits++
@@ -239,13 +298,14 @@ func NewJWTMiddleware(
             authMetrics.mu.Unlock()
 
             duration := time.Since(startTime)
-            log.Printf("[AuthMiddleware] User authenticated: ID=%d, Username=%s, Phone=%s, Admin=%t (auth_time: %v)", 
-                user.ID, user.Username, user.PhoneNumber, user.IsAdmin, duration)
+            // Add comprehensive user info to context
+            ctx := logging.WithUserID(r.Context(), user.ID)
+            slog.DebugContext(ctx, "user authenticated",
+                "is_admin", user.IsAdmin, "auth_time", duration.String())
 
-            logAuthEvent("auth_success", user.ID, user.Username, r.URL.Path, r.RemoteAddr)
+            logAuthEvent(ctx, "auth_success", user.ID, r.URL.Path, r.RemoteAddr)
 
-            // Add comprehensive user info to context
-            ctx := context.WithValue(r.Context(), UserIDKey, user.ID)
+            ctx = context.WithValue(ctx, UserIDKey, user.ID)
             ctx = context.WithValue(ctx, UsernameKey, user.Username)
             ctx = context.WithValue(ctx, PhoneKey, user.PhoneNumber)
             ctx = context.WithValue(ctx, IsAdminKey, user.IsAdmin)
@@ -257,9 +317,16 @@ func NewJWTMiddleware(
 }
 
 // logAuthEvent logs authentication events for security monitoring
-func logAuthEvent(eventType string, userID uint, username, path, remoteAddr string) {
-    log.Printf("[AUTH_EVENT] type=%s user_id=%d username=%s path=%s remote_addr=%s timestamp=%s", 
-        eventType, userID, username, path, remoteAddr, time.Now().UTC().Format(time.RFC3339))
+func logAuthEvent(ctx context.Context, eventType string, userID uint, path, remoteAddr string) {
+    level := slog.LevelWarn
+    if eventType == "auth_success" {
+        level = slog.LevelDebug
+    }
+    slog.Log(ctx, level, "auth event",
+        "event", eventType,
+        "user_id", userID,
+        "path", path,
+        "remote_addr", remoteAddr)
 }
 
 // Enhanced cookie clearing with proper security settings
@@ -5,9 +5,11 @@ package middleware
 type contextKey string
 
 const (
-    UserIDKey   contextKey = "user_id"
-    UserKey     contextKey = "user"
-    IsAdminKey  contextKey = "is_admin"
-    UsernameKey contextKey = "username"
-    PhoneKey    contextKey = "phone"
+    UserIDKey         contextKey = "user_id"
+    UserKey           contextKey = "user"
+    IsAdminKey        contextKey = "is_admin"
+    UsernameKey       contextKey = "username"
+    PhoneKey          contextKey = "phone"
+    AdminRoleKey      contextKey = "admin_role"
+    ImpersonatorIDKey contextKey = "impersonator_id" // admin viewing the app as the user
 )
@@ -0,0 +1,138 @@
+// File: internal/middleware/impersonation.go
+package middleware
+
+import (
+    "context"
+    "log/slog"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/logging"
+    "github.com/iyunix/go-internist/internal/ratelimit"
+    "github.com/iyunix/go-internist/internal/repository/user"
+    "github.com/iyunix/go-internist/internal/services/user_services"
+)
+
+const (
+    // ImpersonationCookie holds the token of a "view as user" session. The admin's own
+    // auth_token stays in place, so admin routes keep working as the admin.
+    ImpersonationCookie = "impersonation_token"
+    ImpersonationTTL    = 30 * time.Minute
+)
+
+// ImpersonationRecorder writes the audit trail of "view as user" sessions
+// (admin_services.AdminService)
+type ImpersonationRecorder interface {
+    RecordImpersonatedRequest(ctx context.Context, adminID uint, adminName string, userID uint, method, path, ip, requestID string) error
+}
+
+// Impersonation lets an admin holding an impersonation token see the user-facing app
+// as that user, read-only. It MUST run after the JWT middleware, which authenticates
+// the admin. Every request is audited, and not served if the audit cannot be written;
+// requests that could change anything are refused. An invalid or stale token is dropped and the admin continues as themselves.
+func Impersonation(
+    authService *user_services.AuthService,
+    userService *user_services.UserService,
+    userRepo user.UserRepository,
+    recorder ImpersonationRecorder,
+) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            cookie, err := r.Cookie(ImpersonationCookie)
+            if err != nil || cookie.Value == "" {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            adminID, _ := r.Context().Value(UserIDKey).(uint)
+            tokenAdminID, targetID, err := authService.ValidateImpersonationToken(cookie.Value)
+            if err != nil || tokenAdminID != adminID {
+                slog.WarnContext(r.Context(), "dropping invalid impersonation token", "admin_id", adminID, "error", err)
+                ClearImpersonationCookie(w)
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            // The admin may have lost the permission since the session started
+            role, adminName, err := adminCache.roleCached(adminID, userRepo, r.Context())
+            if err != nil || !domain.RoleHasPermission(role, domain.PermUsersImpersonate) {
+                slog.WarnContext(r.Context(), "dropping impersonation token without permission", "admin_id", adminID, "role", role)
+                ClearImpersonationCookie(w)
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            target, err := userCache.getUserCached(targetID, userService, r.Context())
+            if err != nil {
+                slog.WarnContext(r.Context(), "impersonated user not found", "admin_id", adminID, "user_id", targetID, "error", err)
+                ClearImpersonationCookie(w)
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            if !isReadOnlyRequest(r) {
+                slog.WarnContext(r.Context(), "blocked write while impersonating",
+                    "admin_id", adminID, "user_id", targetID, "method", r.Method, "path", r.URL.Path)
+                if isAPIRequest(r) {
+                    sendAPIError(w, http.StatusForbidden, "impersonation_read_only", "Viewing as a user is read-only")
+                    return
+                }
+                http.Error(w, "Viewing as a user is read-only", http.StatusForbidden)
+                return
+            }
+
+            if err := recorder.RecordImpersonatedRequest(r.Context(), adminID, adminName, targetID,
+                r.Method, r.URL.RequestURI(), ratelimit.GetClientIP(r), GetRequestID(r)); err != nil {
+                slog.ErrorContext(r.Context(), "refusing impersonated request that could not be audited",
+                    "admin_id", adminID, "user_id", targetID, "error", err)
+                if isAPIRequest(r) {
+                    sendAPIError(w, http.StatusServiceUnavailable, "audit_unavailable", "Viewing as a user is unavailable right now")
+                    return
+                }
+                http.Error(w, "Viewing as a user is unavailable right now", http.StatusServiceUnavailable)
+                return
+            }
+
+            ctx := logging.WithUserID(r.Context(), target.ID)
+            ctx = context.WithValue(ctx, UserIDKey, target.ID)
+            ctx = context.WithValue(ctx, UsernameKey, target.Username)
+            ctx = context.WithValue(ctx, PhoneKey, target.PhoneNumber)
+            ctx = context.WithValue(ctx, IsAdminKey, false)
+            ctx = context.WithValue(ctx, UserKey, target)
+            ctx = context.WithValue(ctx, ImpersonatorIDKey, adminID)
+
+            next.ServeHTTP(w, r.WithContext(ctx))
+        })
+    }
+}
+
+// isReadOnlyRequest allows safe methods only. WebSocket upgrades are GETs that can ask
+// questions, so they are refused too.
+func isReadOnlyRequest(r *http.Request) bool {
+    switch r.Method {
+    case http.MethodGet, http.MethodHead, http.MethodOptions:
+        return !strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+    }
+    return false
+}
+
+// ClearImpersonationCookie ends a "view as user" session in the browser
+func ClearImpersonationCookie(w http.ResponseWriter) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     ImpersonationCookie,
+        Value:    "",
+        Path:     "/",
+        Expires:  time.Unix(0, 0),
+        MaxAge:   -1,
+        HttpOnly: true,
+        SameSite: http.SameSiteStrictMode,
+    })
+}
+
+// GetImpersonatorID returns the admin viewing the app as the current user, if any
+func GetImpersonatorID(r *http.Request) (uint, bool) {
+    id, ok := r.Context().Value(ImpersonatorIDKey).(uint)
+    return id, ok && id != 0
+}
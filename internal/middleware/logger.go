@@ -2,16 +2,19 @@
 package middleware
 
 import (
+    "bufio"
     "context"
     "crypto/rand"
     "encoding/hex"
-    "encoding/json"
-    "log"
+    "fmt"
+    "log/slog"
+    "net"
     "net/http"
-    "os"
     "strings"
     "sync/atomic"
     "time"
+
+    "github.com/iyunix/go-internist/internal/logging"
 )
 
 // RequestIDKey is the context key for request IDs
@@ -67,6 +70,7 @@ var (
         "code":        true,
         "otp":         true,
         "verification_code": true,
+        "q":           true, // search text can contain patient details
     }
 )
 
@@ -182,6 +186,26 @@ func (lrw *LoggingResponseWriter) Flush() {
     }
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController, so long downloads
+// can extend their write deadline
+func (lrw *LoggingResponseWriter) Unwrap() http.ResponseWriter {
+    return lrw.ResponseWriter
+}
+
+// Hijack implements http.Hijacker so WebSocket upgrades work through the logger.
+// The upgrade is logged as 101; traffic after the hijack is not counted.
+func (lrw *LoggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    hijacker, ok := lrw.ResponseWriter.(http.Hijacker)
+    if !ok {
+        return nil, nil, fmt.Errorf("response writer does not support hijacking")
+    }
+    if !lrw.wroteHeader {
+        lrw.statusCode = http.StatusSwitchingProtocols
+        lrw.wroteHeader = true
+    }
+    return hijacker.Hijack()
+}
+
 // LoggingMiddleware creates HTTP request logging middleware with default config
 func LoggingMiddleware(next http.Handler) http.Handler {
     return LoggingMiddlewareWithConfig(next, DefaultLoggingConfig())
@@ -198,6 +222,7 @@ func LoggingMiddlewareWithConfig(next http.Handler, config LoggingConfig) http.H
         
         // Add request ID to context and response headers
         ctx := context.WithValue(r.Context(), RequestIDKey, requestID)
+        ctx = logging.WithRequestID(ctx, requestID)
         r = r.WithContext(ctx)
         w.Header().Set("X-Request-ID", requestID)
         
@@ -264,73 +289,78 @@ func LoggingMiddlewareWithConfig(next http.Handler, config LoggingConfig) http.H
         }
         
         // Log the request
-        logHTTPRequest(entry)
+        logHTTPRequest(r.Context(), entry)
         
         // Log slow requests if enabled
         if config.LogSlowRequests && duration > config.SlowThreshold {
-            logSlowRequest(requestID, r.URL.Path, duration, userID, username)
+            logSlowRequest(r.Context(), r.URL.Path, duration)
         }
         
-        // Log security events - FIXED FUNCTION CALL
-        logHTTPSecurityEvent(lrw.statusCode, requestID, r.URL.Path, getClientIP(r), userID, username)
+        // Log security events
+        logHTTPSecurityEvent(r.Context(), lrw.statusCode, r.URL.Path, getClientIP(r))
     })
 }
 
 
 // logHTTPRequest outputs the HTTP request log entry
-func logHTTPRequest(entry HTTPLogEntry) {
-    // Convert to JSON
-    jsonData, err := json.Marshal(entry)
-    if err != nil {
-        log.Printf("[LOG_ERROR] Failed to marshal HTTP log entry: %v (request_id=%s, path=%s)", 
-            err, entry.RequestID, entry.Path)
-        return
-    }
-    
+func logHTTPRequest(ctx context.Context, entry HTTPLogEntry) {
     // Determine log level based on status code
-    prefix := "[INFO]"
+    level := slog.LevelInfo
     if entry.StatusCode >= 500 {
-        prefix = "[ERROR]"
+        level = slog.LevelError
     } else if entry.StatusCode >= 400 {
-        prefix = "[WARN]"
+        level = slog.LevelWarn
     }
-    
-    // Log to stdout (container/production friendly)
-    if shouldLogToStdout() {
-        log.Printf("%s %s", prefix, string(jsonData))
-    } else {
-        // Development logging (more readable)
-        log.Printf("%s [%s] %s %s - %d (%dms) [User: %d] Size: %dB", 
-            prefix, entry.RequestID[:8], entry.Method, entry.Path, 
-            entry.StatusCode, entry.DurationMS, entry.UserID, entry.ResponseSize)
+
+    attrs := []slog.Attr{
+        slog.String("request_id", entry.RequestID),
+        slog.String("method", entry.Method),
+        slog.String("path", entry.Path),
+        slog.String("remote_addr", entry.RemoteAddr),
+        slog.Int("status_code", entry.StatusCode),
+        slog.Int64("duration_ms", entry.DurationMS),
+        slog.Int64("response_size", entry.ResponseSize),
+    }
+    if entry.Query != "" {
+        attrs = append(attrs, slog.String("http_query", entry.Query))
+    }
+    if entry.UserAgent != "" {
+        attrs = append(attrs, slog.String("user_agent", entry.UserAgent))
+    }
+    if entry.UserID > 0 {
+        attrs = append(attrs, slog.Uint64("user_id", uint64(entry.UserID)), slog.Bool("is_admin", entry.IsAdmin))
     }
+    if entry.Error != "" {
+        attrs = append(attrs, slog.String("error", entry.Error))
+    }
+
+    slog.LogAttrs(ctx, level, "http_request", attrs...)
 }
 
 // logSlowRequest logs performance warnings for slow requests
-func logSlowRequest(requestID, path string, duration time.Duration, userID uint, username string) {
-    log.Printf("[PERFORMANCE_ALERT] Slow request detected - ID: %s, Path: %s, Duration: %s, User: %d (%s)", 
-        requestID, path, duration.String(), userID, username)
+func logSlowRequest(ctx context.Context, path string, duration time.Duration) {
+    slog.WarnContext(ctx, "slow request detected",
+        "path", path,
+        "duration", duration.String())
 }
 
-// logSecurityEvent logs security-related HTTP events
-func logHTTPSecurityEvent(statusCode int, requestID, path, remoteAddr string, userID uint, username string) {
+// logHTTPSecurityEvent logs security-related HTTP events
+func logHTTPSecurityEvent(ctx context.Context, statusCode int, path, remoteAddr string) {
+    var event string
     switch statusCode {
     case 401:
-        log.Printf("[SECURITY_EVENT] Unauthorized access - ID: %s, Path: %s, IP: %s, User: %d (%s)", 
-            requestID, path, remoteAddr, userID, username)
+        event = "unauthorized_access"
     case 403:
-        log.Printf("[SECURITY_EVENT] Forbidden access - ID: %s, Path: %s, IP: %s, User: %d (%s)", 
-            requestID, path, remoteAddr, userID, username)
+        event = "forbidden_access"
     case 429:
-        log.Printf("[SECURITY_EVENT] Rate limit exceeded - ID: %s, Path: %s, IP: %s, User: %d (%s)", 
-            requestID, path, remoteAddr, userID, username)
+        event = "rate_limit_exceeded"
+    default:
+        return
     }
-}
-
-// shouldLogToStdout determines output format based on environment
-func shouldLogToStdout() bool {
-    env := os.Getenv("GO_ENV")
-    return env == "production" || os.Getenv("LOG_FORMAT") == "json"
+    slog.WarnContext(ctx, "security event",
+        "event", event,
+        "path", path,
+        "remote_addr", remoteAddr)
 }
 
 // GetRequestID retrieves the request ID from the request context
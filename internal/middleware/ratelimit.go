@@ -3,7 +3,7 @@ package middleware
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"fmt"
 
@@ -38,8 +38,8 @@ func RateLimitMiddleware(limiter *ratelimit.MemoryRateLimiter, name string) func
 				if info.Banned {
 					statusMsg = "BANNED"
 				}
-				log.Printf("[RateLimit] Blocked %s request from %s - %s",
-					name, clientIP, statusMsg)
+				slog.WarnContext(r.Context(), "request blocked by rate limit",
+					"limiter", name, "client_ip", clientIP, "status", statusMsg)
 
 				// Set retry-after header
 				if info.RetryAfter > 0 {
@@ -85,7 +85,7 @@ func AuthSuccessMiddleware(limiter *ratelimit.MemoryRateLimiter, name string) fu
 				clientIP := ratelimit.GetClientIP(r)
 				identifier := clientIP // or fmt.Sprintf("%s:%s", name, clientIP)
 				limiter.RecordSuccess(identifier)
-				log.Printf("[RateLimit] Reset attempts for %s from %s (successful auth)", name, clientIP)
+				slog.InfoContext(r.Context(), "rate limit attempts reset after successful auth", "limiter", name, "client_ip", clientIP)
 			}
 		})
 	}
@@ -4,7 +4,7 @@ package middleware
 import (
     "encoding/json"
     "fmt"
-    "log"
+    "log/slog"
     "net/http"
     "os"
     "runtime/debug"
@@ -48,18 +48,19 @@ func RecoverPanic(next http.Handler) http.Handler {
                 }
                 
                 // Log panic with full context
-                log.Printf("[PANIC_RECOVERY] request_id=%s path=%s method=%s user_id=%d username=%s remote_addr=%s error=%v timestamp=%s", 
-                    requestID, r.URL.Path, r.Method, userID, username, clientIP, err, time.Now().UTC().Format(time.RFC3339))
+                slog.ErrorContext(r.Context(), "panic recovered",
+                    "request_id", requestID, "path", r.URL.Path, "method", r.Method, "user_id", userID,
+                    "username", username, "remote_addr", clientIP, "error", err)
                 
                 // Log stack trace in development or if configured
                 if shouldLogStackTrace() {
-                    log.Printf("[STACK_TRACE] request_id=%s\n%s", requestID, string(debug.Stack()))
+                    slog.ErrorContext(r.Context(), "panic stack trace", "request_id", requestID, "stack", string(debug.Stack()))
                 }
                 
                 // Security alert for potential attacks
                 if isPotentialAttack(r, err) {
-                    log.Printf("[SECURITY_ALERT] Potential attack detected - request_id=%s path=%s user_id=%d error=%v", 
-                        requestID, r.URL.Path, userID, err)
+                    slog.WarnContext(r.Context(), "potential attack detected",
+                        "request_id", requestID, "path", r.URL.Path, "user_id", userID, "error", err)
                 }
                 
                 // Set security headers
@@ -2,6 +2,7 @@
 package ratelimit
 
 import (
+    "fmt"
     "net"
     "net/http"
     "sync"
@@ -200,41 +201,92 @@ func (rl *MemoryRateLimiter) Close() {
     close(rl.stopCh)
 }
 
-// GetClientIP extracts the real client IP from request
-func GetClientIP(r *http.Request) string {
-    // Check for forwarded IP (behind proxy/load balancer)
-    forwarded := r.Header.Get("X-Forwarded-For")
-    if forwarded != "" {
-        // Take the first IP in case of multiple
-        if ip := parseFirstIP(forwarded); ip != "" {
-            return ip
+// trustedProxies holds the networks set by SetTrustedProxies
+var (
+    trustedMu      sync.RWMutex
+    trustedProxies []*net.IPNet
+)
+
+// SetTrustedProxies sets the reverse proxies whose X-Forwarded-For and X-Real-IP
+// headers GetClientIP believes. Entries are CIDRs or single addresses. With none set,
+// forwarding headers are ignored, since any client can send them.
+func SetTrustedProxies(entries []string) error {
+    var nets []*net.IPNet
+    for _, entry := range entries {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+        if !strings.Contains(entry, "/") {
+            ip := net.ParseIP(entry)
+            if ip == nil {
+                return fmt.Errorf("invalid trusted proxy %q", entry)
+            }
+            bits := 128
+            if ip.To4() != nil {
+                ip, bits = ip.To4(), 32
+            }
+            nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+            continue
+        }
+        _, network, err := net.ParseCIDR(entry)
+        if err != nil {
+            return fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
         }
+        nets = append(nets, network)
     }
-    
-    // Check for real IP header
-    realIP := r.Header.Get("X-Real-IP")
-    if realIP != "" {
-        return realIP
+
+    trustedMu.Lock()
+    trustedProxies = nets
+    trustedMu.Unlock()
+    return nil
+}
+
+// isTrustedProxy reports whether ip belongs to a configured proxy
+func isTrustedProxy(ip net.IP) bool {
+    trustedMu.RLock()
+    defer trustedMu.RUnlock()
+    for _, network := range trustedProxies {
+        if network.Contains(ip) {
+            return true
+        }
     }
-    
-    // Fall back to remote address
-    ip, _, err := net.SplitHostPort(r.RemoteAddr)
+    return false
+}
+
+// GetClientIP extracts the real client IP from request. Forwarding headers are only
+// read when the connection comes from a trusted proxy; X-Forwarded-For is then walked
+// from the nearest hop back, and the first address not belonging to a trusted proxy
+// is the client. Earlier entries could have been written by the client and are ignored.
+func GetClientIP(r *http.Request) string {
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
     if err != nil {
-        return r.RemoteAddr
+        host = r.RemoteAddr
+    }
+    peer := net.ParseIP(host)
+    if peer == nil || !isTrustedProxy(peer) {
+        return host
     }
-    return ip
-}
 
-// parseFirstIP extracts the first valid IP from a comma-separated list
-func parseFirstIP(forwarded string) string {
-    if forwarded == "" {
-        return ""
+    if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+        hops := strings.Split(strings.Join(values, ","), ",")
+        client := peer
+        for i := len(hops) - 1; i >= 0; i-- {
+            ip := net.ParseIP(strings.TrimSpace(hops[i]))
+            if ip == nil {
+                break // a malformed hop; trust nothing beyond it
+            }
+            client = ip
+            if !isTrustedProxy(ip) {
+                break
+            }
+        }
+        return client.String()
     }
-    
-    // Split by comma and take first
-    ips := strings.Split(forwarded, ",")
-    if len(ips) > 0 {
-        return strings.TrimSpace(ips[0])
+
+    // Proxies that send X-Real-IP instead
+    if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+        return ip.String()
     }
-    return ""
+    return peer.String()
 }
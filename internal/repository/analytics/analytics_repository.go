@@ -0,0 +1,177 @@
+// File: internal/repository/analytics/analytics_repository.go
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/iyunix/go-internist/internal/domain"
+)
+
+// Range is the window and bucket size of a time series query
+type Range struct {
+	From        time.Time // inclusive
+	To          time.Time // exclusive
+	Granularity string    // domain.Granularity*
+}
+
+func (r Range) validate() error {
+	if !domain.IsValidGranularity(r.Granularity) {
+		return fmt.Errorf("invalid granularity %q", r.Granularity)
+	}
+	if r.From.IsZero() || r.To.IsZero() || !r.From.Before(r.To) {
+		return errors.New("invalid range: from must be before to")
+	}
+	return nil
+}
+
+// AnalyticsRepository computes admin time series. Every query aggregates in Postgres
+// and returns one row per bucket (and group), never the underlying rows. Buckets with
+// no data are omitted.
+type AnalyticsRepository interface {
+	ActiveUsers(ctx context.Context, rng Range) ([]domain.SeriesPoint, error)
+	Questions(ctx context.Context, rng Range) ([]domain.SeriesPoint, error)
+	ChargesByPlan(ctx context.Context, rng Range) ([]domain.SeriesPoint, error)
+	Registrations(ctx context.Context, rng Range) ([]domain.SeriesPoint, error)
+	VerificationConversion(ctx context.Context, rng Range) ([]domain.SeriesPoint, error)
+	RetrievalScore(ctx context.Context, rng Range) ([]domain.SeriesPoint, error)
+	StageLatency(ctx context.Context, rng Range) ([]domain.SeriesPoint, error)
+}
+
+// GormAnalyticsRepository implements AnalyticsRepository using GORM raw SQL
+type GormAnalyticsRepository struct {
+	db *gorm.DB
+}
+
+// NewGormAnalyticsRepository creates a new analytics repository
+func NewGormAnalyticsRepository(db *gorm.DB) AnalyticsRepository {
+	return &GormAnalyticsRepository{db: db}
+}
+
+// ActiveUsers counts distinct users who asked at least one question per bucket
+func (r *GormAnalyticsRepository) ActiveUsers(ctx context.Context, rng Range) ([]domain.SeriesPoint, error) {
+	return r.series(ctx, rng, `
+		SELECT date_trunc(?, m.created_at) AS bucket,
+			COUNT(DISTINCT c.user_id)::float8 AS value,
+			COUNT(*) AS samples
+		FROM messages m
+		JOIN chats c ON c.id = m.chat_id
+		WHERE m.message_type = ? AND m.created_at >= ? AND m.created_at < ?
+		GROUP BY 1 ORDER BY 1`,
+		rng.Granularity, domain.MessageTypeUser, rng.From, rng.To)
+}
+
+// Questions counts questions asked per bucket, including edited ones
+func (r *GormAnalyticsRepository) Questions(ctx context.Context, rng Range) ([]domain.SeriesPoint, error) {
+	return r.series(ctx, rng, `
+		SELECT date_trunc(?, created_at) AS bucket,
+			COUNT(*)::float8 AS value,
+			COUNT(*) AS samples
+		FROM messages
+		WHERE message_type = ? AND created_at >= ? AND created_at < ?
+		GROUP BY 1 ORDER BY 1`,
+		rng.Granularity, domain.MessageTypeUser, rng.From, rng.To)
+}
+
+// ChargesByPlan sums the characters charged for answers per bucket, grouped by the
+// user's current plan. Failed jobs are left out; cancelled ones count what was kept.
+func (r *GormAnalyticsRepository) ChargesByPlan(ctx context.Context, rng Range) ([]domain.SeriesPoint, error) {
+	return r.series(ctx, rng, `
+		SELECT date_trunc(?, j.created_at) AS bucket,
+			u.subscription_plan AS "group",
+			SUM(j.charge)::float8 AS value,
+			COUNT(*) AS samples
+		FROM generation_jobs j
+		JOIN users u ON u.id = j.user_id
+		WHERE j.status IN (?, ?) AND j.created_at >= ? AND j.created_at < ?
+		GROUP BY 1, 2 ORDER BY 1, 2`,
+		rng.Granularity, domain.JobStatusCompleted, domain.JobStatusCancelled, rng.From, rng.To)
+}
+
+// Registrations counts accounts created per bucket, including ones deleted since
+func (r *GormAnalyticsRepository) Registrations(ctx context.Context, rng Range) ([]domain.SeriesPoint, error) {
+	return r.series(ctx, rng, `
+		SELECT date_trunc(?, created_at) AS bucket,
+			COUNT(*)::float8 AS value,
+			COUNT(*) AS samples
+		FROM users
+		WHERE created_at >= ? AND created_at < ?
+		GROUP BY 1 ORDER BY 1`,
+		rng.Granularity, rng.From, rng.To)
+}
+
+// VerificationConversion is, per bucket, the share of phone numbers sent a
+// verification code that belong to an account verified after the code went out.
+// Samples is the number of phone numbers sent a code.
+func (r *GormAnalyticsRepository) VerificationConversion(ctx context.Context, rng Range) ([]domain.SeriesPoint, error) {
+	return r.series(ctx, rng, `
+		SELECT date_trunc(?, s.created_at) AS bucket,
+			COUNT(DISTINCT u.phone_number)::float8 / COUNT(DISTINCT s.phone) AS value,
+			COUNT(DISTINCT s.phone) AS samples
+		FROM sms_messages s
+		LEFT JOIN users u ON u.phone_number = s.phone
+			AND u.is_verified AND u.verified_at >= s.created_at
+		WHERE s.template = ? AND s.created_at >= ? AND s.created_at < ?
+		GROUP BY 1 ORDER BY 1`,
+		rng.Granularity, "verification", rng.From, rng.To) // sms.TemplateVerification
+}
+
+// RetrievalScore averages the mean and best similarity of retrieved passages per
+// bucket, as groups "mean" and "top"
+func (r *GormAnalyticsRepository) RetrievalScore(ctx context.Context, rng Range) ([]domain.SeriesPoint, error) {
+	return r.series(ctx, rng, `
+		SELECT bucket, score.name AS "group", AVG(score.value)::float8 AS value, COUNT(score.value) AS samples
+		FROM (
+			SELECT date_trunc(?, created_at) AS bucket,
+				metric_retrieval_mean_score AS mean_score,
+				metric_retrieval_top_score AS top_score
+			FROM generation_jobs
+			WHERE status = ? AND metric_retrieval_matches > 0
+				AND created_at >= ? AND created_at < ?
+		) j
+		CROSS JOIN LATERAL (VALUES ('mean', j.mean_score), ('top', j.top_score)) AS score(name, value)
+		GROUP BY 1, 2 ORDER BY 1, 2`,
+		rng.Granularity, domain.JobStatusCompleted, rng.From, rng.To)
+}
+
+// StageLatency averages each pipeline stage's duration in milliseconds per bucket,
+// grouped by stage. Translation only averages answers where it ran.
+func (r *GormAnalyticsRepository) StageLatency(ctx context.Context, rng Range) ([]domain.SeriesPoint, error) {
+	return r.series(ctx, rng, `
+		SELECT bucket, stage.name AS "group", AVG(stage.ms)::float8 AS value, COUNT(stage.ms) AS samples
+		FROM (
+			SELECT date_trunc(?, created_at) AS bucket,
+				metric_translation_ms, metric_embedding_ms, metric_retrieval_ms,
+				metric_llm_ms, metric_total_ms
+			FROM generation_jobs
+			WHERE status = ? AND metric_total_ms > 0
+				AND created_at >= ? AND created_at < ?
+		) j
+		CROSS JOIN LATERAL (VALUES
+			('translation', NULLIF(j.metric_translation_ms, 0)),
+			('embedding', j.metric_embedding_ms),
+			('retrieval', j.metric_retrieval_ms),
+			('llm', j.metric_llm_ms),
+			('total', j.metric_total_ms)
+		) AS stage(name, ms)
+		GROUP BY 1, 2 ORDER BY 1, 2`,
+		rng.Granularity, domain.JobStatusCompleted, rng.From, rng.To)
+}
+
+// series runs one aggregate query. The query groups by column position because the
+// date_trunc field is a bind parameter, which Postgres would otherwise treat as a
+// different expression in SELECT and GROUP BY.
+func (r *GormAnalyticsRepository) series(ctx context.Context, rng Range, query string, args ...interface{}) ([]domain.SeriesPoint, error) {
+	if err := rng.validate(); err != nil {
+		return nil, err
+	}
+	points := []domain.SeriesPoint{}
+	if err := r.db.WithContext(ctx).Raw(query, args...).Scan(&points).Error; err != nil {
+		return nil, err
+	}
+	return points, nil
+}
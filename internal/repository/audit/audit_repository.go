@@ -0,0 +1,137 @@
+// File: internal/repository/audit/audit_repository.go
+package audit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/repository/transaction"
+)
+
+// Filter selects audit events. Zero values match anything.
+type Filter struct {
+	ActorID      uint
+	TargetUserID uint
+	Action       string
+	From         time.Time // inclusive
+	To           time.Time // exclusive
+}
+
+// AuditRepository appends and reads the admin audit log. There is deliberately no way
+// to change or remove an event.
+type AuditRepository interface {
+	Record(ctx context.Context, event *domain.AuditEvent) error
+	Find(ctx context.Context, filter Filter, limit, offset int) ([]domain.AuditEvent, int64, error)
+	Each(ctx context.Context, filter Filter, fn func(*domain.AuditEvent) error) error
+}
+
+// GormAuditRepository implements AuditRepository using GORM
+type GormAuditRepository struct {
+	db *gorm.DB
+}
+
+// NewGormAuditRepository creates a new audit repository
+func NewGormAuditRepository(db *gorm.DB) AuditRepository {
+	return &GormAuditRepository{db: db}
+}
+
+// EnsureAppendOnly installs a trigger that rejects updates and deletes on audit_events,
+// so not even a bug or a stray query can rewrite history
+func EnsureAppendOnly(ctx context.Context, db *gorm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger
+			LANGUAGE plpgsql
+			AS $$ BEGIN RAISE EXCEPTION 'audit_events is append-only'; END $$`,
+		`DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events`,
+		`CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+			FOR EACH ROW EXECUTE FUNCTION audit_events_append_only()`,
+	}
+	for _, stmt := range statements {
+		if err := db.WithContext(ctx).Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record appends an event
+func (r *GormAuditRepository) Record(ctx context.Context, event *domain.AuditEvent) error {
+	if event == nil {
+		return errors.New("audit event is nil")
+	}
+	if event.ActorID == 0 || event.Action == "" {
+		return errors.New("audit event requires actor and action")
+	}
+	return transaction.DB(ctx, r.db).Create(event).Error
+}
+
+// Find lists events newest first
+func (r *GormAuditRepository) Find(ctx context.Context, filter Filter, limit, offset int) ([]domain.AuditEvent, int64, error) {
+	if limit <= 0 || limit > 1000 {
+		return nil, 0, errors.New("invalid limit: must be between 1 and 1000")
+	}
+	if offset < 0 {
+		return nil, 0, errors.New("invalid offset: must be >= 0")
+	}
+
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&domain.AuditEvent{}).Scopes(filter.scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var events []domain.AuditEvent
+	err := r.db.WithContext(ctx).
+		Scopes(filter.scope).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&events).Error
+	return events, total, err
+}
+
+// Each streams every matching event, oldest first, through a database cursor so an
+// export of the whole log never holds it in memory. It stops at fn's first error.
+func (r *GormAuditRepository) Each(ctx context.Context, filter Filter, fn func(*domain.AuditEvent) error) error {
+	db := r.db.WithContext(ctx)
+	rows, err := db.Model(&domain.AuditEvent{}).
+		Scopes(filter.scope).
+		Order("created_at, id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event domain.AuditEvent
+		if err := db.ScanRows(rows, &event); err != nil {
+			return err
+		}
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (f Filter) scope(db *gorm.DB) *gorm.DB {
+	if f.ActorID != 0 {
+		db = db.Where("actor_id = ?", f.ActorID)
+	}
+	if f.TargetUserID != 0 {
+		db = db.Where("target_user_id = ?", f.TargetUserID)
+	}
+	if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	if !f.From.IsZero() {
+		db = db.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		db = db.Where("created_at < ?", f.To)
+	}
+	return db
+}
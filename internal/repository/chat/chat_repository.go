@@ -6,15 +6,17 @@ import (
     "context"
     "errors"
     "fmt"
-    "log"
+    "log/slog"
     "strings"
     "time"
     "github.com/iyunix/go-internist/internal/domain"
     "gorm.io/gorm"
+    "gorm.io/gorm/clause"
 )
 
 var ErrChatNotFound = errors.New("chat not found")
 var ErrUnauthorizedAccess = errors.New("unauthorized access to chat")
+var ErrSummaryNotFound = errors.New("chat summary not found")
 
 type gormChatRepository struct {
     db *gorm.DB
@@ -30,18 +32,18 @@ func NewChatRepository(db *gorm.DB) ChatRepository {
 func (r *gormChatRepository) Create(ctx context.Context, chat *domain.Chat) (*domain.Chat, error) {
     // Input validation
     if err := r.validateChatInput(chat); err != nil {
-        log.Printf("[ChatRepository] Validation failed: %v", err)
+        slog.ErrorContext(ctx, "chat validation failed", "error", err)
         return nil, fmt.Errorf("validation failed: %w", err)
     }
     
     err := r.db.WithContext(ctx).Create(chat).Error
     if err != nil {
         // Secure logging - no sensitive data exposed
-        log.Printf("[ChatRepository] Database error during chat creation for user ID %d: %v", chat.UserID, err)
+        slog.ErrorContext(ctx, "database error during chat creation", "user_id", chat.UserID, "error", err)
         return nil, errors.New("database error creating chat")
     }
     
-    log.Printf("[ChatRepository] Chat created successfully with ID: %d for user: %d", chat.ID, chat.UserID)
+    slog.InfoContext(ctx, "chat created", "chat_id", chat.ID, "user_id", chat.UserID)
     return chat, nil
 }
 
@@ -53,12 +55,12 @@ func (r *gormChatRepository) FindByID(ctx context.Context, chatID uint) (*domain
     
     var chat domain.Chat
     err := r.db.WithContext(ctx).First(&chat, chatID).Error
-    return r.handleFindError(err, &chat, "FindByID")
+    return r.handleFindError(ctx, err, &chat, "FindByID")
 }
 
 // FindByUserID - Enhanced with memory safety warning (deprecated)
 func (r *gormChatRepository) FindByUserID(ctx context.Context, userID uint) ([]domain.Chat, error) {
-    log.Printf("[ChatRepository] WARNING: FindByUserID() loads all chats into memory. Use FindByUserIDWithPagination() for production.")
+    slog.WarnContext(ctx, "FindByUserID loads all chats into memory, use FindByUserIDWithPagination in production")
     
     if userID == 0 {
         return nil, errors.New("invalid user ID")
@@ -71,7 +73,7 @@ func (r *gormChatRepository) FindByUserID(ctx context.Context, userID uint) ([]d
         Find(&chats).Error
     
     if err != nil {
-        log.Printf("[ChatRepository] Database error finding chats for user ID %d: %v", userID, err)
+        slog.ErrorContext(ctx, "database error finding chats", "user_id", userID, "error", err)
         return nil, errors.New("database error fetching chats")
     }
     
@@ -89,7 +91,7 @@ func (r *gormChatRepository) Delete(ctx context.Context, chatID, userID uint) er
         Delete(&domain.Chat{})
     
     if result.Error != nil {
-        log.Printf("[ChatRepository] Database error deleting chat ID %d for user ID %d: %v", chatID, userID, result.Error)
+        slog.ErrorContext(ctx, "database error deleting chat", "chat_id", chatID, "user_id", userID, "error", result.Error)
         return errors.New("database error deleting chat")
     }
     
@@ -97,7 +99,7 @@ func (r *gormChatRepository) Delete(ctx context.Context, chatID, userID uint) er
         return ErrUnauthorizedAccess
     }
     
-    log.Printf("[ChatRepository] Chat deleted successfully: ID %d for user %d", chatID, userID)
+    slog.InfoContext(ctx, "chat deleted", "chat_id", chatID, "user_id", userID)
     return nil
 }
 
@@ -113,7 +115,7 @@ func (r *gormChatRepository) TouchUpdatedAt(ctx context.Context, chatID uint) er
         Update("updated_at", gorm.Expr("CURRENT_TIMESTAMP"))
     
     if result.Error != nil {
-        log.Printf("[ChatRepository] Database error updating timestamp for chat ID %d: %v", chatID, result.Error)
+        slog.ErrorContext(ctx, "database error updating timestamp", "chat_id", chatID, "error", result.Error)
         return errors.New("database error updating chat timestamp")
     }
     
@@ -124,6 +126,97 @@ func (r *gormChatRepository) TouchUpdatedAt(ctx context.Context, chatID uint) er
     return nil
 }
 
+// UpdateTitle - Rename a chat owned by the user, recording where the title came from
+func (r *gormChatRepository) UpdateTitle(ctx context.Context, chatID, userID uint, title, source string) error {
+    if chatID == 0 || userID == 0 {
+        return errors.New("invalid chat ID or user ID")
+    }
+    if err := r.validateChatTitle(title); err != nil {
+        return fmt.Errorf("title validation: %w", err)
+    }
+
+    result := r.db.WithContext(ctx).
+        Model(&domain.Chat{}).
+        Where("id = ? AND user_id = ?", chatID, userID).
+        Updates(map[string]interface{}{"title": title, "title_source": source})
+
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error updating title", "chat_id", chatID, "error", result.Error)
+        return errors.New("database error updating chat title")
+    }
+
+    if result.RowsAffected == 0 {
+        return ErrUnauthorizedAccess
+    }
+
+    return nil
+}
+
+// ReplacePlaceholderTitle - Set a generated title unless the chat was titled meanwhile
+func (r *gormChatRepository) ReplacePlaceholderTitle(ctx context.Context, chatID uint, title string) (bool, error) {
+    if chatID == 0 {
+        return false, errors.New("invalid chat ID")
+    }
+    if err := r.validateChatTitle(title); err != nil {
+        return false, fmt.Errorf("title validation: %w", err)
+    }
+
+    result := r.db.WithContext(ctx).
+        Model(&domain.Chat{}).
+        Where("id = ? AND title_source = ?", chatID, domain.ChatTitleDefault).
+        Updates(map[string]interface{}{"title": title, "title_source": domain.ChatTitleAuto})
+
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error setting generated title", "chat_id", chatID, "error", result.Error)
+        return false, errors.New("database error updating chat title")
+    }
+
+    return result.RowsAffected > 0, nil
+}
+
+// FindSummary - Running summary of the chat, ErrSummaryNotFound before the first one
+func (r *gormChatRepository) FindSummary(ctx context.Context, chatID uint) (*domain.ChatSummary, error) {
+    if chatID == 0 {
+        return nil, errors.New("invalid chat ID")
+    }
+
+    var summary domain.ChatSummary
+    err := r.db.WithContext(ctx).Where("chat_id = ?", chatID).First(&summary).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, ErrSummaryNotFound
+    }
+    if err != nil {
+        slog.ErrorContext(ctx, "database error finding summary", "chat_id", chatID, "error", err)
+        return nil, errors.New("database error finding chat summary")
+    }
+    return &summary, nil
+}
+
+// SaveSummary - Insert or replace the chat's running summary
+func (r *gormChatRepository) SaveSummary(ctx context.Context, summary *domain.ChatSummary) error {
+    if summary == nil || summary.ChatID == 0 {
+        return errors.New("invalid chat summary")
+    }
+
+    err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+        Columns:   []clause.Column{{Name: "chat_id"}},
+        DoUpdates: clause.AssignmentColumns([]string{"through_message_id", "summary", "updated_at"}),
+    }).Create(summary).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error saving summary", "chat_id", summary.ChatID, "error", err)
+        return errors.New("database error saving chat summary")
+    }
+    return nil
+}
+
+// DeleteSummary - Drop the running summary when the chat goes away
+func (r *gormChatRepository) DeleteSummary(ctx context.Context, chatID uint) error {
+    if chatID == 0 {
+        return errors.New("invalid chat ID")
+    }
+    return r.db.WithContext(ctx).Where("chat_id = ?", chatID).Delete(&domain.ChatSummary{}).Error
+}
+
 // ===== NEW PRODUCTION-READY METHODS =====
 
 // FindByUserIDWithPagination - Memory safety: prevents OOM with large chat histories
@@ -145,7 +238,7 @@ func (r *gormChatRepository) FindByUserIDWithPagination(ctx context.Context, use
     
     // Efficient counting without loading data
     if err := r.db.WithContext(ctx).Model(&domain.Chat{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
-        log.Printf("[ChatRepository] Database error counting chats for user ID %d: %v", userID, err)
+        slog.ErrorContext(ctx, "database error counting chats", "user_id", userID, "error", err)
         return nil, 0, errors.New("database error counting chats")
     }
     
@@ -158,7 +251,7 @@ func (r *gormChatRepository) FindByUserIDWithPagination(ctx context.Context, use
         Find(&chats).Error
     
     if err != nil {
-        log.Printf("[ChatRepository] Database error in paginated query for user ID %d: %v", userID, err)
+        slog.ErrorContext(ctx, "database error in paginated query", "user_id", userID, "error", err)
         return nil, 0, errors.New("database error retrieving paginated chats")
     }
     
@@ -192,12 +285,12 @@ func (r *gormChatRepository) CreateInBatch(ctx context.Context, chats []*domain.
         
         batch := chats[i:end]
         if err := r.db.WithContext(ctx).CreateInBatches(batch, batchSize).Error; err != nil {
-            log.Printf("[ChatRepository] Batch creation failed for batch %d-%d: %v", i, end, err)
+            slog.ErrorContext(ctx, "batch chat creation failed", "from", i, "to", end, "error", err)
             return fmt.Errorf("database error creating batch %d-%d: %w", i, end, err)
         }
     }
     
-    log.Printf("[ChatRepository] Successfully created %d chats in batches", len(chats))
+    slog.InfoContext(ctx, "created chats in batches", "count", len(chats))
     return nil
 }
 
@@ -223,11 +316,11 @@ func (r *gormChatRepository) DeleteMultipleByUserID(ctx context.Context, chatIDs
         Delete(&domain.Chat{})
     
     if result.Error != nil {
-        log.Printf("[ChatRepository] Database error in bulk delete for user ID %d: %v", userID, result.Error)
+        slog.ErrorContext(ctx, "database error in bulk delete", "user_id", userID, "error", result.Error)
         return errors.New("database error in bulk chat deletion")
     }
     
-    log.Printf("[ChatRepository] Bulk deleted %d chats for user %d", result.RowsAffected, userID)
+    slog.InfoContext(ctx, "bulk deleted chats", "count", result.RowsAffected, "user_id", userID)
     return nil
 }
 
@@ -240,7 +333,7 @@ func (r *gormChatRepository) ExistsByID(ctx context.Context, chatID uint) (bool,
     var count int64
     err := r.db.WithContext(ctx).Model(&domain.Chat{}).Where("id = ?", chatID).Count(&count).Error
     if err != nil {
-        log.Printf("[ChatRepository] Database error checking chat existence for ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error checking chat existence", "chat_id", chatID, "error", err)
         return false, errors.New("database error checking chat existence")
     }
     
@@ -256,7 +349,7 @@ func (r *gormChatRepository) ExistsByIDAndUserID(ctx context.Context, chatID, us
     var count int64
     err := r.db.WithContext(ctx).Model(&domain.Chat{}).Where("id = ? AND user_id = ?", chatID, userID).Count(&count).Error
     if err != nil {
-        log.Printf("[ChatRepository] Database error checking chat ownership for chat ID %d, user ID %d: %v", chatID, userID, err)
+        slog.ErrorContext(ctx, "database error checking chat ownership", "chat_id", chatID, "user_id", userID, "error", err)
         return false, errors.New("database error checking chat ownership")
     }
     
@@ -277,7 +370,7 @@ func (r *gormChatRepository) CountByUserID(ctx context.Context, userID uint) (in
     var count int64
     err := r.db.WithContext(ctx).Model(&domain.Chat{}).Where("user_id = ?", userID).Count(&count).Error
     if err != nil {
-        log.Printf("[ChatRepository] Database error counting chats for user ID %d: %v", userID, err)
+        slog.ErrorContext(ctx, "database error counting chats", "user_id", userID, "error", err)
         return 0, errors.New("database error counting user chats")
     }
     
@@ -289,7 +382,7 @@ func (r *gormChatRepository) CountTotalChats(ctx context.Context) (int64, error)
     var count int64
     err := r.db.WithContext(ctx).Model(&domain.Chat{}).Count(&count).Error
     if err != nil {
-        log.Printf("[ChatRepository] Database error counting total chats: %v", err)
+        slog.ErrorContext(ctx, "database error counting total chats", "error", err)
         return 0, errors.New("database error counting total chats")
     }
     
@@ -301,7 +394,7 @@ func (r *gormChatRepository) CountActiveChats(ctx context.Context, since time.Ti
     var count int64
     err := r.db.WithContext(ctx).Model(&domain.Chat{}).Where("updated_at >= ?", since).Count(&count).Error
     if err != nil {
-        log.Printf("[ChatRepository] Database error counting active chats since %v: %v", since, err)
+        slog.ErrorContext(ctx, "database error counting active chats", "since", since, "error", err)
         return 0, errors.New("database error counting active chats")
     }
     
@@ -326,7 +419,7 @@ func (r *gormChatRepository) FindRecentChats(ctx context.Context, userID uint, l
         Find(&chats).Error
     
     if err != nil {
-        log.Printf("[ChatRepository] Database error finding recent chats for user ID %d: %v", userID, err)
+        slog.ErrorContext(ctx, "database error finding recent chats", "user_id", userID, "error", err)
         return nil, errors.New("database error finding recent chats")
     }
     
@@ -350,7 +443,7 @@ func (r *gormChatRepository) FindChatsByDateRange(ctx context.Context, userID ui
         Find(&chats).Error
     
     if err != nil {
-        log.Printf("[ChatRepository] Database error finding chats by date range for user ID %d: %v", userID, err)
+        slog.ErrorContext(ctx, "database error finding chats by date range", "user_id", userID, "error", err)
         return nil, errors.New("database error finding chats by date range")
     }
     
@@ -375,7 +468,7 @@ func (r *gormChatRepository) FindOldestChats(ctx context.Context, userID uint, l
         Find(&chats).Error
     
     if err != nil {
-        log.Printf("[ChatRepository] Database error finding oldest chats for user ID %d: %v", userID, err)
+        slog.ErrorContext(ctx, "database error finding oldest chats", "user_id", userID, "error", err)
         return nil, errors.New("database error finding oldest chats")
     }
     
@@ -393,11 +486,11 @@ func (r *gormChatRepository) DeleteOldChats(ctx context.Context, userID uint, ol
         Delete(&domain.Chat{})
     
     if result.Error != nil {
-        log.Printf("[ChatRepository] Database error deleting old chats for user ID %d: %v", userID, result.Error)
+        slog.ErrorContext(ctx, "database error deleting old chats", "user_id", userID, "error", result.Error)
         return 0, errors.New("database error deleting old chats")
     }
     
-    log.Printf("[ChatRepository] Deleted %d old chats for user %d", result.RowsAffected, userID)
+    slog.InfoContext(ctx, "deleted old chats", "count", result.RowsAffected, "user_id", userID)
     return result.RowsAffected, nil
 }
 
@@ -411,11 +504,11 @@ func (r *gormChatRepository) ArchiveInactiveChats(ctx context.Context, inactiveS
         Update("archived", true)
     
     if result.Error != nil {
-        log.Printf("[ChatRepository] Database error archiving inactive chats: %v", result.Error)
+        slog.ErrorContext(ctx, "database error archiving inactive chats", "error", result.Error)
         return 0, errors.New("database error archiving inactive chats")
     }
     
-    log.Printf("[ChatRepository] Archived %d inactive chats", result.RowsAffected)
+    slog.InfoContext(ctx, "archived inactive chats", "count", result.RowsAffected)
     return result.RowsAffected, nil
 }
 
@@ -438,11 +531,11 @@ func (r *gormChatRepository) UpdateMultipleTimestamps(ctx context.Context, chatI
         Update("updated_at", gorm.Expr("CURRENT_TIMESTAMP"))
     
     if result.Error != nil {
-        log.Printf("[ChatRepository] Database error in bulk timestamp update: %v", result.Error)
+        slog.ErrorContext(ctx, "database error in bulk timestamp update", "error", result.Error)
         return errors.New("database error updating multiple timestamps")
     }
     
-    log.Printf("[ChatRepository] Updated timestamps for %d chats", result.RowsAffected)
+    slog.InfoContext(ctx, "updated chat timestamps", "count", result.RowsAffected)
     return nil
 }
 
@@ -470,7 +563,7 @@ func (r *gormChatRepository) SearchChatsByTitle(ctx context.Context, userID uint
         Find(&chats).Error
     
     if err != nil {
-        log.Printf("[ChatRepository] Database error searching chats by title for user ID %d: %v", userID, err)
+        slog.ErrorContext(ctx, "database error searching chats by title", "user_id", userID, "error", err)
         return nil, errors.New("database error searching chats")
     }
     
@@ -527,7 +620,7 @@ func (r *gormChatRepository) validateSearchPattern(pattern string) error {
 // ===== ERROR HANDLING HELPERS =====
 
 // handleFindError - Secure error handling without data leakage
-func (r *gormChatRepository) handleFindError(err error, chat *domain.Chat, operation string) (*domain.Chat, error) {
+func (r *gormChatRepository) handleFindError(ctx context.Context, err error, chat *domain.Chat, operation string) (*domain.Chat, error) {
     if err == nil {
         return chat, nil
     }
@@ -537,7 +630,7 @@ func (r *gormChatRepository) handleFindError(err error, chat *domain.Chat, opera
     }
     
     // Log technical details for debugging
-    log.Printf("[ChatRepository] %s database error: %v", operation, err)
+    slog.ErrorContext(ctx, "chat query failed", "operation", operation, "error", err)
     
     // Return generic error for security
     return nil, errors.New("database query failed")
@@ -16,6 +16,33 @@ type ChatRepository interface {
     FindByUserID(ctx context.Context, userID uint) ([]domain.Chat, error) // [DEPRECATED: Use FindByUserIDWithPagination]
     Delete(ctx context.Context, chatID uint, userID uint) error
     TouchUpdatedAt(ctx context.Context, chatID uint) error
+    UpdateTitle(ctx context.Context, chatID, userID uint, title, source string) error
+    ReplacePlaceholderTitle(ctx context.Context, chatID uint, title string) (bool, error)
+
+    // Running conversation summary
+    FindSummary(ctx context.Context, chatID uint) (*domain.ChatSummary, error)
+    SaveSummary(ctx context.Context, summary *domain.ChatSummary) error
+    DeleteSummary(ctx context.Context, chatID uint) error
+
+    // Read-only share links
+    CreateShare(ctx context.Context, share *domain.ChatShare) error
+    FindShareByToken(ctx context.Context, token string) (*domain.ChatShare, error)
+    FindSharesByChat(ctx context.Context, chatID, userID uint) ([]domain.ChatShare, error)
+    RevokeShare(ctx context.Context, shareID, chatID, userID uint) error
+    RevokeSharesByChat(ctx context.Context, chatID uint) error
+    RecordShareView(ctx context.Context, shareID uint) error
+
+    // Folders, tags, pinning and archiving
+    FindByUserIDFiltered(ctx context.Context, userID uint, filter ChatFilter, limit, offset int) ([]domain.Chat, int64, error)
+    FindByIDsAndUserID(ctx context.Context, chatIDs []uint, userID uint) ([]domain.Chat, error)
+    UpdateOrganization(ctx context.Context, chatIDs []uint, userID uint, fields map[string]interface{}) (int64, error)
+    UpdateTags(ctx context.Context, chatID, userID uint, tags []string) error
+    FindTagsByUserID(ctx context.Context, userID uint) ([]string, error)
+    CreateFolder(ctx context.Context, folder *domain.ChatFolder) error
+    FindFolderByID(ctx context.Context, folderID, userID uint) (*domain.ChatFolder, error)
+    FindFoldersByUserID(ctx context.Context, userID uint) ([]domain.ChatFolder, error)
+    RenameFolder(ctx context.Context, folderID, userID uint, name string) error
+    DeleteFolder(ctx context.Context, folderID, userID uint) error
 
     // ===== PRODUCTION-READY METHODS =====
     
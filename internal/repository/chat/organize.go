@@ -0,0 +1,279 @@
+// File: internal/repository/chat/organize.go
+package chat
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "log/slog"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "gorm.io/gorm"
+)
+
+var (
+    ErrFolderNotFound = errors.New("folder not found")
+    ErrFolderExists   = errors.New("a folder with this name already exists")
+)
+
+// ChatFilter narrows a user's chat list. The zero value lists every chat that is
+// not archived.
+type ChatFilter struct {
+    FolderID *uint  // nil for any folder, 0 for chats in no folder
+    Tag      string // exact, normalized tag
+    Archived bool   // list archived chats instead of active ones
+    Pinned   *bool  // nil for both
+}
+
+// organizationColumns are the columns UpdateOrganization may change
+var organizationColumns = map[string]bool{"folder_id": true, "pinned": true, "archived": true}
+
+// FindByUserIDFiltered lists a user's chats matching the filter, pinned chats first,
+// then most recently active
+func (r *gormChatRepository) FindByUserIDFiltered(ctx context.Context, userID uint, filter ChatFilter, limit, offset int) ([]domain.Chat, int64, error) {
+    if userID == 0 {
+        return nil, 0, errors.New("invalid user ID")
+    }
+    if limit <= 0 || limit > 1000 {
+        return nil, 0, errors.New("invalid limit: must be between 1 and 1000")
+    }
+    if offset < 0 {
+        return nil, 0, errors.New("invalid offset: must be >= 0")
+    }
+
+    scope := func(db *gorm.DB) *gorm.DB {
+        db = db.Where("user_id = ? AND archived = ?", userID, filter.Archived)
+        if filter.FolderID != nil {
+            if *filter.FolderID == 0 {
+                db = db.Where("folder_id IS NULL")
+            } else {
+                db = db.Where("folder_id = ?", *filter.FolderID)
+            }
+        }
+        if filter.Tag != "" {
+            tag, _ := json.Marshal([]string{filter.Tag})
+            db = db.Where("tags @> ?::jsonb", string(tag))
+        }
+        if filter.Pinned != nil {
+            db = db.Where("pinned = ?", *filter.Pinned)
+        }
+        return db
+    }
+
+    var total int64
+    if err := r.db.WithContext(ctx).Model(&domain.Chat{}).Scopes(scope).Count(&total).Error; err != nil {
+        slog.ErrorContext(ctx, "database error counting filtered chats", "user_id", userID, "error", err)
+        return nil, 0, errors.New("database error counting chats")
+    }
+
+    var chats []domain.Chat
+    err := r.db.WithContext(ctx).
+        Scopes(scope).
+        Order("pinned DESC, updated_at DESC, id DESC").
+        Limit(limit).
+        Offset(offset).
+        Find(&chats).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error in filtered query", "user_id", userID, "error", err)
+        return nil, 0, errors.New("database error retrieving chats")
+    }
+    return chats, total, nil
+}
+
+// FindByIDsAndUserID returns the chats among chatIDs that belong to the user
+func (r *gormChatRepository) FindByIDsAndUserID(ctx context.Context, chatIDs []uint, userID uint) ([]domain.Chat, error) {
+    if len(chatIDs) == 0 {
+        return nil, nil
+    }
+    if userID == 0 {
+        return nil, errors.New("invalid user ID")
+    }
+    var chats []domain.Chat
+    if err := r.db.WithContext(ctx).Where("id IN ? AND user_id = ?", chatIDs, userID).Find(&chats).Error; err != nil {
+        slog.ErrorContext(ctx, "database error loading chats", "user_id", userID, "error", err)
+        return nil, errors.New("database error retrieving chats")
+    }
+    return chats, nil
+}
+
+// UpdateOrganization sets folder_id, pinned or archived on the user's chats among
+// chatIDs. updated_at is left alone so pinning or filing a chat does not reorder it.
+func (r *gormChatRepository) UpdateOrganization(ctx context.Context, chatIDs []uint, userID uint, fields map[string]interface{}) (int64, error) {
+    if len(chatIDs) == 0 || len(fields) == 0 {
+        return 0, nil
+    }
+    if userID == 0 {
+        return 0, errors.New("invalid user ID")
+    }
+    for column := range fields {
+        if !organizationColumns[column] {
+            return 0, errors.New("invalid organization field: " + column)
+        }
+    }
+
+    result := r.db.WithContext(ctx).
+        Model(&domain.Chat{}).
+        Where("id IN ? AND user_id = ?", chatIDs, userID).
+        UpdateColumns(fields)
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error updating organization", "user_id", userID, "error", result.Error)
+        return 0, errors.New("database error updating chats")
+    }
+    return result.RowsAffected, nil
+}
+
+// UpdateTags replaces a chat's tags
+func (r *gormChatRepository) UpdateTags(ctx context.Context, chatID, userID uint, tags []string) error {
+    if chatID == 0 || userID == 0 {
+        return errors.New("invalid chat ID or user ID")
+    }
+    if tags == nil {
+        tags = []string{}
+    }
+    encoded, err := json.Marshal(tags)
+    if err != nil {
+        return err
+    }
+
+    result := r.db.WithContext(ctx).
+        Model(&domain.Chat{}).
+        Where("id = ? AND user_id = ?", chatID, userID).
+        UpdateColumn("tags", gorm.Expr("?::jsonb", string(encoded)))
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error updating tags", "chat_id", chatID, "error", result.Error)
+        return errors.New("database error updating chat tags")
+    }
+    if result.RowsAffected == 0 {
+        return ErrUnauthorizedAccess
+    }
+    return nil
+}
+
+// FindTagsByUserID lists the distinct tags on a user's chats, alphabetically
+func (r *gormChatRepository) FindTagsByUserID(ctx context.Context, userID uint) ([]string, error) {
+    if userID == 0 {
+        return nil, errors.New("invalid user ID")
+    }
+    var tags []string
+    err := r.db.WithContext(ctx).Raw(`
+        SELECT DISTINCT tag FROM chats, jsonb_array_elements_text(chats.tags) AS tag
+        WHERE chats.user_id = ? AND chats.deleted_at IS NULL AND jsonb_typeof(chats.tags) = 'array'
+        ORDER BY tag`, userID).
+        Scan(&tags).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error listing tags", "user_id", userID, "error", err)
+        return nil, errors.New("database error listing tags")
+    }
+    return tags, nil
+}
+
+// CreateFolder stores a new folder; names are unique per user
+func (r *gormChatRepository) CreateFolder(ctx context.Context, folder *domain.ChatFolder) error {
+    if folder == nil || folder.UserID == 0 || folder.Name == "" {
+        return errors.New("invalid folder")
+    }
+    if taken, err := r.folderNameTaken(ctx, folder.UserID, folder.Name, 0); err != nil {
+        return err
+    } else if taken {
+        return ErrFolderExists
+    }
+    if err := r.db.WithContext(ctx).Create(folder).Error; err != nil {
+        slog.ErrorContext(ctx, "database error creating folder", "user_id", folder.UserID, "error", err)
+        return errors.New("database error creating folder")
+    }
+    return nil
+}
+
+// FindFolderByID returns one of the user's folders
+func (r *gormChatRepository) FindFolderByID(ctx context.Context, folderID, userID uint) (*domain.ChatFolder, error) {
+    if folderID == 0 || userID == 0 {
+        return nil, ErrFolderNotFound
+    }
+    var folder domain.ChatFolder
+    err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", folderID, userID).First(&folder).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, ErrFolderNotFound
+    }
+    if err != nil {
+        slog.ErrorContext(ctx, "database error finding folder", "folder_id", folderID, "error", err)
+        return nil, errors.New("database error finding folder")
+    }
+    return &folder, nil
+}
+
+// FindFoldersByUserID lists a user's folders by name
+func (r *gormChatRepository) FindFoldersByUserID(ctx context.Context, userID uint) ([]domain.ChatFolder, error) {
+    if userID == 0 {
+        return nil, errors.New("invalid user ID")
+    }
+    var folders []domain.ChatFolder
+    if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("name ASC").Find(&folders).Error; err != nil {
+        slog.ErrorContext(ctx, "database error listing folders", "user_id", userID, "error", err)
+        return nil, errors.New("database error listing folders")
+    }
+    return folders, nil
+}
+
+// RenameFolder renames one of the user's folders
+func (r *gormChatRepository) RenameFolder(ctx context.Context, folderID, userID uint, name string) error {
+    if folderID == 0 || userID == 0 || name == "" {
+        return errors.New("invalid folder")
+    }
+    if taken, err := r.folderNameTaken(ctx, userID, name, folderID); err != nil {
+        return err
+    } else if taken {
+        return ErrFolderExists
+    }
+    result := r.db.WithContext(ctx).
+        Model(&domain.ChatFolder{}).
+        Where("id = ? AND user_id = ?", folderID, userID).
+        Update("name", name)
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error renaming folder", "folder_id", folderID, "error", result.Error)
+        return errors.New("database error renaming folder")
+    }
+    if result.RowsAffected == 0 {
+        return ErrFolderNotFound
+    }
+    return nil
+}
+
+// DeleteFolder removes one of the user's folders and moves its chats out of it
+func (r *gormChatRepository) DeleteFolder(ctx context.Context, folderID, userID uint) error {
+    if folderID == 0 || userID == 0 {
+        return ErrFolderNotFound
+    }
+    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        result := tx.Where("id = ? AND user_id = ?", folderID, userID).Delete(&domain.ChatFolder{})
+        if result.Error != nil {
+            slog.ErrorContext(ctx, "database error deleting folder", "folder_id", folderID, "error", result.Error)
+            return errors.New("database error deleting folder")
+        }
+        if result.RowsAffected == 0 {
+            return ErrFolderNotFound
+        }
+        err := tx.Unscoped().
+            Model(&domain.Chat{}).
+            Where("folder_id = ? AND user_id = ?", folderID, userID).
+            UpdateColumn("folder_id", nil).Error
+        if err != nil {
+            slog.ErrorContext(ctx, "database error unfiling chats", "folder_id", folderID, "error", err)
+            return errors.New("database error deleting folder")
+        }
+        return nil
+    })
+}
+
+// folderNameTaken reports whether the user has another folder with this name
+func (r *gormChatRepository) folderNameTaken(ctx context.Context, userID uint, name string, exceptID uint) (bool, error) {
+    var count int64
+    err := r.db.WithContext(ctx).
+        Model(&domain.ChatFolder{}).
+        Where("user_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", userID, name, exceptID).
+        Count(&count).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error checking folder name", "user_id", userID, "error", err)
+        return false, errors.New("database error checking folder name")
+    }
+    return count > 0, nil
+}
@@ -0,0 +1,102 @@
+// File: internal/repository/chat/share.go
+package chat
+
+import (
+    "context"
+    "errors"
+    "log/slog"
+    "time"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "gorm.io/gorm"
+)
+
+var ErrShareNotFound = errors.New("share link not found")
+
+// CreateShare stores a new share link
+func (r *gormChatRepository) CreateShare(ctx context.Context, share *domain.ChatShare) error {
+    if share == nil || share.ChatID == 0 || share.UserID == 0 || share.Token == "" {
+        return errors.New("invalid share link")
+    }
+    if err := r.db.WithContext(ctx).Create(share).Error; err != nil {
+        slog.ErrorContext(ctx, "database error creating share link", "chat_id", share.ChatID, "error", err)
+        return errors.New("database error creating share link")
+    }
+    return nil
+}
+
+// FindShareByToken looks a share link up by its public token, revoked or not. Links of
+// deleted or suspended owners are not found; they work again once the owner is restored.
+func (r *gormChatRepository) FindShareByToken(ctx context.Context, token string) (*domain.ChatShare, error) {
+    if token == "" {
+        return nil, ErrShareNotFound
+    }
+    var share domain.ChatShare
+    err := r.db.WithContext(ctx).
+        Where("token = ?", token).
+        Where("user_id IN (SELECT id FROM users WHERE deleted_at IS NULL AND status <> ?)", domain.UserStatusSuspended).
+        First(&share).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, ErrShareNotFound
+    }
+    if err != nil {
+        slog.ErrorContext(ctx, "database error finding share link", "error", err)
+        return nil, errors.New("database error finding share link")
+    }
+    return &share, nil
+}
+
+// FindSharesByChat lists a chat's share links, newest first
+func (r *gormChatRepository) FindSharesByChat(ctx context.Context, chatID, userID uint) ([]domain.ChatShare, error) {
+    if chatID == 0 || userID == 0 {
+        return nil, errors.New("invalid chat ID or user ID")
+    }
+    var shares []domain.ChatShare
+    err := r.db.WithContext(ctx).
+        Where("chat_id = ? AND user_id = ?", chatID, userID).
+        Order("created_at DESC").
+        Find(&shares).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error listing share links", "chat_id", chatID, "error", err)
+        return nil, errors.New("database error listing share links")
+    }
+    return shares, nil
+}
+
+// RevokeShare disables a share link; revoking twice is a no-op
+func (r *gormChatRepository) RevokeShare(ctx context.Context, shareID, chatID, userID uint) error {
+    if shareID == 0 || chatID == 0 || userID == 0 {
+        return errors.New("invalid share ID, chat ID or user ID")
+    }
+    result := r.db.WithContext(ctx).Model(&domain.ChatShare{}).
+        Where("id = ? AND chat_id = ? AND user_id = ?", shareID, chatID, userID).
+        Update("revoked_at", gorm.Expr("COALESCE(revoked_at, ?)", time.Now()))
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error revoking share link", "share_id", shareID, "error", result.Error)
+        return errors.New("database error revoking share link")
+    }
+    if result.RowsAffected == 0 {
+        return ErrShareNotFound
+    }
+    return nil
+}
+
+// RevokeSharesByChat disables every link to a chat, used when the chat is deleted
+func (r *gormChatRepository) RevokeSharesByChat(ctx context.Context, chatID uint) error {
+    if chatID == 0 {
+        return errors.New("invalid chat ID")
+    }
+    return r.db.WithContext(ctx).Model(&domain.ChatShare{}).
+        Where("chat_id = ? AND revoked_at IS NULL", chatID).
+        Update("revoked_at", time.Now()).Error
+}
+
+// RecordShareView counts one view of a share link
+func (r *gormChatRepository) RecordShareView(ctx context.Context, shareID uint) error {
+    return r.db.WithContext(ctx).Model(&domain.ChatShare{}).
+        Where("id = ?", shareID).
+        UpdateColumns(map[string]interface{}{
+            "view_count":     gorm.Expr("view_count + 1"),
+            "last_viewed_at": time.Now(),
+        }).Error
+}
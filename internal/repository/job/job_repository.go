@@ -0,0 +1,161 @@
+// File: internal/repository/job/job_repository.go
+package job
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/iyunix/go-internist/internal/domain"
+)
+
+var ErrJobNotFound = errors.New("generation job not found")
+
+// JobRepository persists generation jobs and their progress
+type JobRepository interface {
+	Create(ctx context.Context, job *domain.GenerationJob) error
+	FindByID(ctx context.Context, jobID uint) (*domain.GenerationJob, error)
+	FindByStreamID(ctx context.Context, streamID string) (*domain.GenerationJob, error)
+	MarkRunning(ctx context.Context, jobID uint) error
+	UpdateProgress(ctx context.Context, jobID uint, content string) error
+	MarkCompleted(ctx context.Context, jobID uint, content string, metrics domain.GenerationMetrics) error
+	MarkFailed(ctx context.Context, jobID uint, content, reason string) error
+	MarkCancelled(ctx context.Context, jobID uint, content string, charge int) error
+	FailUnfinished(ctx context.Context, reason string) ([]domain.GenerationJob, error)
+}
+
+// GormJobRepository implements JobRepository using GORM
+type GormJobRepository struct {
+	db *gorm.DB
+}
+
+// NewGormJobRepository creates a new generation job repository
+func NewGormJobRepository(db *gorm.DB) JobRepository {
+	return &GormJobRepository{db: db}
+}
+
+// Create inserts a new job record
+func (r *GormJobRepository) Create(ctx context.Context, job *domain.GenerationJob) error {
+	if job == nil {
+		return errors.New("generation job is nil")
+	}
+	if job.UserID == 0 || job.ChatID == 0 {
+		return errors.New("generation job requires user and chat IDs")
+	}
+	if job.Status == "" {
+		job.Status = domain.JobStatusQueued
+	}
+	if job.Kind == "" {
+		job.Kind = domain.JobKindAsk
+	}
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+// FindByID returns a job by its primary key
+func (r *GormJobRepository) FindByID(ctx context.Context, jobID uint) (*domain.GenerationJob, error) {
+	var job domain.GenerationJob
+	err := r.db.WithContext(ctx).First(&job, jobID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// FindByStreamID returns the job that feeds the given SSE stream
+func (r *GormJobRepository) FindByStreamID(ctx context.Context, streamID string) (*domain.GenerationJob, error) {
+	var job domain.GenerationJob
+	err := r.db.WithContext(ctx).Where("stream_id = ?", streamID).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// MarkRunning records that a worker picked the job up
+func (r *GormJobRepository) MarkRunning(ctx context.Context, jobID uint) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&domain.GenerationJob{}).
+		Where("id = ?", jobID).
+		Updates(map[string]interface{}{"status": domain.JobStatusRunning, "started_at": now}).Error
+}
+
+// UpdateProgress stores the partial answer produced so far
+func (r *GormJobRepository) UpdateProgress(ctx context.Context, jobID uint, content string) error {
+	return r.db.WithContext(ctx).Model(&domain.GenerationJob{}).
+		Where("id = ? AND status = ?", jobID, domain.JobStatusRunning).
+		Update("content", content).Error
+}
+
+// MarkCompleted stores the final answer with its pipeline metrics and closes the job
+func (r *GormJobRepository) MarkCompleted(ctx context.Context, jobID uint, content string, metrics domain.GenerationMetrics) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&domain.GenerationJob{}).
+		Where("id = ?", jobID).
+		Updates(map[string]interface{}{
+			"status":                      domain.JobStatusCompleted,
+			"content":                     content,
+			"finished_at":                 now,
+			"metric_translation_ms":       metrics.TranslationMs,
+			"metric_embedding_ms":         metrics.EmbeddingMs,
+			"metric_retrieval_ms":         metrics.RetrievalMs,
+			"metric_llm_ms":               metrics.LLMMs,
+			"metric_total_ms":             metrics.TotalMs,
+			"metric_retrieval_matches":    metrics.RetrievalMatches,
+			"metric_retrieval_top_score":  metrics.RetrievalTopScore,
+			"metric_retrieval_mean_score": metrics.RetrievalMeanScore,
+		}).Error
+}
+
+// MarkFailed closes the job with an error, keeping whatever was produced
+func (r *GormJobRepository) MarkFailed(ctx context.Context, jobID uint, content, reason string) error {
+	if len(reason) > 500 {
+		reason = reason[:500]
+	}
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&domain.GenerationJob{}).
+		Where("id = ?", jobID).
+		Updates(map[string]interface{}{
+			"status":      domain.JobStatusFailed,
+			"content":     content,
+			"error":       reason,
+			"finished_at": now,
+		}).Error
+}
+
+// MarkCancelled closes a job stopped by the user, recording the reduced charge
+func (r *GormJobRepository) MarkCancelled(ctx context.Context, jobID uint, content string, charge int) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&domain.GenerationJob{}).
+		Where("id = ?", jobID).
+		Updates(map[string]interface{}{
+			"status":      domain.JobStatusCancelled,
+			"content":     content,
+			"charge":      charge,
+			"finished_at": now,
+		}).Error
+}
+
+// FailUnfinished fails jobs left queued or running by a previous process. It returns
+// the ID, user and charge of each job it failed so their charges can be refunded.
+func (r *GormJobRepository) FailUnfinished(ctx context.Context, reason string) ([]domain.GenerationJob, error) {
+	now := time.Now()
+	var jobs []domain.GenerationJob
+	err := r.db.WithContext(ctx).Model(&jobs).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "user_id"}, {Name: "charge"}}}).
+		Where("status IN ?", []string{domain.JobStatusQueued, domain.JobStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":      domain.JobStatusFailed,
+			"error":       reason,
+			"finished_at": now,
+		}).Error
+	return jobs, err
+}
@@ -0,0 +1,220 @@
+// File: internal/repository/message/branch.go
+package message
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log/slog"
+    "strings"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "gorm.io/gorm"
+)
+
+// conversationTypes are the message types that form the branch tree
+var conversationTypes = []string{domain.MessageTypeUser, domain.MessageTypeAssistant}
+
+// maxBranchDepth bounds the walk down a branch, so corrupt parent links that form a
+// cycle cannot recurse forever; it is far beyond any real conversation
+const maxBranchDepth = 10000
+
+// activeBranchCTE walks the chat's active branch from the root into branch(id, depth),
+// picking at each step the newest active child, or the newest child when none is
+// active. Only IDs are carried through the recursion.
+const activeBranchCTE = `
+    WITH RECURSIVE branch AS (
+        SELECT root.id, 1 AS depth FROM (
+            SELECT id FROM messages
+            WHERE chat_id = ? AND parent_id IS NULL AND message_type IN ? AND deleted_at IS NULL
+            ORDER BY active DESC, created_at DESC, id DESC
+            LIMIT 1
+        ) AS root
+        UNION ALL
+        SELECT child.id, b.depth + 1 FROM branch AS b
+        CROSS JOIN LATERAL (
+            SELECT c.id FROM messages AS c
+            WHERE c.parent_id = b.id AND c.message_type IN ? AND c.deleted_at IS NULL
+            ORDER BY c.active DESC, c.created_at DESC, c.id DESC
+            LIMIT 1
+        ) AS child
+        WHERE b.depth < ?
+    )`
+
+// activeBranchQuery reads the selected columns of the last N messages on the branch,
+// oldest first
+const activeBranchQuery = activeBranchCTE + `
+    SELECT %s FROM messages AS m
+    JOIN (SELECT id, depth FROM branch ORDER BY depth DESC LIMIT ?) AS tail ON tail.id = m.id
+    ORDER BY tail.depth`
+
+// activeBranchPageQuery reads a page of the branch, counted from the root
+const activeBranchPageQuery = activeBranchCTE + `
+    SELECT m.* FROM messages AS m
+    JOIN branch ON branch.id = m.id
+    ORDER BY branch.depth
+    LIMIT ? OFFSET ?`
+
+// activeBranchCountQuery counts the messages on the branch
+const activeBranchCountQuery = activeBranchCTE + `
+    SELECT COUNT(*) FROM branch`
+
+// FindActiveBranch returns the last limit questions and answers on the chat's active
+// branch, oldest first; limit <= 0 returns the whole branch
+func (r *gormMessageRepository) FindActiveBranch(ctx context.Context, chatID uint, limit int) ([]domain.Message, error) {
+    return r.findActiveBranch(ctx, chatID, limit, nil)
+}
+
+// findActiveBranch runs activeBranchQuery reading only the given columns of each
+// message, or all of them when columns is empty
+func (r *gormMessageRepository) findActiveBranch(ctx context.Context, chatID uint, limit int, columns []string) ([]domain.Message, error) {
+    if chatID == 0 {
+        return nil, errors.New("invalid chat ID")
+    }
+    if limit <= 0 || limit > maxBranchDepth {
+        limit = maxBranchDepth
+    }
+    selected := "m.*"
+    if len(columns) > 0 {
+        selected = "m." + strings.Join(columns, ", m.")
+    }
+
+    var branch []domain.Message
+    err := r.db.WithContext(ctx).
+        Raw(fmt.Sprintf(activeBranchQuery, selected), chatID, conversationTypes, conversationTypes, maxBranchDepth, limit).
+        Scan(&branch).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error loading active branch", "chat_id", chatID, "error", err)
+        return nil, errors.New("database error loading conversation")
+    }
+    return branch, nil
+}
+
+// FindActiveBranchPage returns limit questions and answers of the chat's active branch
+// after the first offset, oldest first, and the number of messages on the whole branch
+func (r *gormMessageRepository) FindActiveBranchPage(ctx context.Context, chatID uint, limit, offset int) ([]domain.Message, int64, error) {
+    if chatID == 0 {
+        return nil, 0, errors.New("invalid chat ID")
+    }
+    if limit <= 0 || offset < 0 {
+        return nil, 0, errors.New("invalid pagination parameters")
+    }
+
+    var total int64
+    err := r.db.WithContext(ctx).
+        Raw(activeBranchCountQuery, chatID, conversationTypes, conversationTypes, maxBranchDepth).
+        Scan(&total).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error counting active branch", "chat_id", chatID, "error", err)
+        return nil, 0, errors.New("database error loading conversation")
+    }
+    if int64(offset) >= total {
+        return []domain.Message{}, total, nil
+    }
+
+    var page []domain.Message
+    err = r.db.WithContext(ctx).
+        Raw(activeBranchPageQuery, chatID, conversationTypes, conversationTypes, maxBranchDepth, limit, offset).
+        Scan(&page).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error loading active branch page", "chat_id", chatID, "error", err)
+        return nil, 0, errors.New("database error loading conversation")
+    }
+    return page, total, nil
+}
+
+// FindSiblingIDs maps each of the given messages to the IDs of all versions at its
+// position (itself included), oldest first. Messages with a single version are left out.
+func (r *gormMessageRepository) FindSiblingIDs(ctx context.Context, chatID uint, messageIDs []uint) (map[uint][]uint, error) {
+    versions := make(map[uint][]uint)
+    if chatID == 0 || len(messageIDs) == 0 {
+        return versions, nil
+    }
+
+    var rows []struct {
+        MessageID uint
+        SiblingID uint
+    }
+    err := r.db.WithContext(ctx).Raw(`
+        SELECT m.id AS message_id, s.id AS sibling_id FROM messages AS m
+        JOIN messages AS s ON s.chat_id = m.chat_id AND s.parent_id IS NOT DISTINCT FROM m.parent_id
+        WHERE m.chat_id = ? AND m.id IN ? AND s.message_type IN ? AND s.deleted_at IS NULL
+        ORDER BY m.id, s.created_at, s.id`, chatID, messageIDs, conversationTypes).
+        Scan(&rows).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error loading message versions", "chat_id", chatID, "error", err)
+        return nil, errors.New("database error loading conversation")
+    }
+
+    for _, row := range rows {
+        versions[row.MessageID] = append(versions[row.MessageID], row.SiblingID)
+    }
+    for id, ids := range versions {
+        if len(ids) < 2 {
+            delete(versions, id)
+        }
+    }
+    return versions, nil
+}
+
+// ActivateBranch makes a message the active one among its siblings, so the branch
+// through it is shown and used as context. Choices further down are kept.
+func (r *gormMessageRepository) ActivateBranch(ctx context.Context, chatID, messageID uint) error {
+    if chatID == 0 || messageID == 0 {
+        return errors.New("invalid message ID or chat ID")
+    }
+
+    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        var target domain.Message
+        if err := tx.Where("id = ? AND chat_id = ?", messageID, chatID).First(&target).Error; err != nil {
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                return ErrMessageNotFound
+            }
+            return err
+        }
+        if !target.IsConversationTurn() {
+            return errors.New("only questions and answers can be selected")
+        }
+
+        siblings := tx.Model(&domain.Message{}).
+            Where("chat_id = ? AND id <> ? AND message_type = ?", chatID, messageID, target.MessageType)
+        if target.ParentID == nil {
+            siblings = siblings.Where("parent_id IS NULL")
+        } else {
+            siblings = siblings.Where("parent_id = ?", *target.ParentID)
+        }
+        if err := siblings.Update("active", false).Error; err != nil {
+            return err
+        }
+        return tx.Model(&domain.Message{}).Where("id = ?", messageID).Update("active", true).Error
+    })
+}
+
+// BackfillParents links messages created before branching existed into a single
+// linear branch. It must only run once, when the parent_id column is first added,
+// since later root messages legitimately have no parent.
+func BackfillParents(ctx context.Context, db *gorm.DB) error {
+    // Each question or answer follows the previous one in the chat
+    err := db.WithContext(ctx).Exec(`
+        UPDATE messages AS m SET parent_id = o.prev_id
+        FROM (
+            SELECT id, LAG(id) OVER (PARTITION BY chat_id ORDER BY created_at, id) AS prev_id
+            FROM messages
+            WHERE message_type IN ? AND deleted_at IS NULL
+        ) AS o
+        WHERE m.id = o.id AND o.prev_id IS NOT NULL`, conversationTypes).Error
+    if err != nil {
+        return err
+    }
+
+    // Processed questions hang off the question they were derived from
+    return db.WithContext(ctx).Exec(`
+        UPDATE messages AS m SET parent_id = (
+            SELECT u.id FROM messages AS u
+            WHERE u.chat_id = m.chat_id AND u.message_type = ? AND u.created_at <= m.created_at AND u.deleted_at IS NULL
+            ORDER BY u.created_at DESC, u.id DESC
+            LIMIT 1
+        )
+        WHERE m.message_type = ? AND m.parent_id IS NULL`,
+        domain.MessageTypeUser, domain.MessageTypeInternalContext).Error
+}
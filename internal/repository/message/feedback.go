@@ -0,0 +1,204 @@
+// File: internal/repository/message/feedback.go
+package message
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "log/slog"
+    "time"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/repository/transaction"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+var ErrFeedbackNotFound = errors.New("feedback not found")
+
+// FeedbackReviewFilter selects feedback for the review queue. Zero values match anything.
+type FeedbackReviewFilter struct {
+    Rating       int // domain.FeedbackUp or domain.FeedbackDown
+    Category     string
+    ReviewStatus string
+}
+
+// SaveFeedback records a user's rating of an answer, replacing any earlier one. A changed
+// rating goes back into the review queue as open.
+func (r *gormMessageRepository) SaveFeedback(ctx context.Context, feedback *domain.MessageFeedback) error {
+    if feedback == nil || feedback.MessageID == 0 || feedback.UserID == 0 || feedback.ChatID == 0 {
+        return errors.New("invalid feedback")
+    }
+    feedback.ReviewStatus = domain.FeedbackReviewOpen
+
+    err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+        Columns: []clause.Column{{Name: "message_id"}, {Name: "user_id"}},
+        DoUpdates: clause.Assignments(map[string]interface{}{
+            "rating":        feedback.Rating,
+            "category":      feedback.Category,
+            "comment":       feedback.Comment,
+            "review_status": domain.FeedbackReviewOpen,
+            "reviewed_by":   nil,
+            "reviewed_at":   nil,
+            "review_note":   "",
+            "updated_at":    time.Now(),
+        }),
+    }).Create(feedback).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error saving feedback", "message_id", feedback.MessageID, "error", err)
+        return errors.New("database error saving feedback")
+    }
+    return nil
+}
+
+// DeleteFeedback removes a user's rating of an answer
+func (r *gormMessageRepository) DeleteFeedback(ctx context.Context, messageID, userID uint) error {
+    result := r.db.WithContext(ctx).
+        Where("message_id = ? AND user_id = ?", messageID, userID).
+        Delete(&domain.MessageFeedback{})
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error deleting feedback", "message_id", messageID, "error", result.Error)
+        return errors.New("database error deleting feedback")
+    }
+    if result.RowsAffected == 0 {
+        return ErrFeedbackNotFound
+    }
+    return nil
+}
+
+// FindFeedbackByChat returns the user's ratings of answers in a chat
+func (r *gormMessageRepository) FindFeedbackByChat(ctx context.Context, chatID, userID uint) ([]domain.MessageFeedback, error) {
+    var feedback []domain.MessageFeedback
+    err := r.db.WithContext(ctx).
+        Where("chat_id = ? AND user_id = ?", chatID, userID).
+        Order("message_id ASC").
+        Find(&feedback).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error listing feedback", "chat_id", chatID, "error", err)
+        return nil, errors.New("database error listing feedback")
+    }
+    return feedback, nil
+}
+
+// feedbackReviewRow is one review queue row before sources are decoded
+type feedbackReviewRow struct {
+    domain.MessageFeedback
+    ChatTitle       string
+    Question        string
+    InternalContext string
+    Answer          string
+    Sources         string
+    AnsweredAt      time.Time
+}
+
+// FindFeedbackForReview lists feedback newest first, each with the answer it rates, the
+// question it answered, the internal_context rewrite of that question and the sources
+// the answer cited. Answers and chats deleted since are still shown.
+func (r *gormMessageRepository) FindFeedbackForReview(ctx context.Context, filter FeedbackReviewFilter, limit, offset int) ([]domain.FeedbackReview, int64, error) {
+    if limit <= 0 || limit > 1000 {
+        return nil, 0, errors.New("invalid limit: must be between 1 and 1000")
+    }
+    if offset < 0 {
+        return nil, 0, errors.New("invalid offset: must be >= 0")
+    }
+
+    scope := func(db *gorm.DB) *gorm.DB {
+        if filter.Rating != 0 {
+            db = db.Where("f.rating = ?", filter.Rating)
+        }
+        if filter.Category != "" {
+            db = db.Where("f.category = ?", filter.Category)
+        }
+        if filter.ReviewStatus != "" {
+            db = db.Where("f.review_status = ?", filter.ReviewStatus)
+        }
+        return db
+    }
+
+    var total int64
+    if err := r.db.WithContext(ctx).Table("message_feedback AS f").Scopes(scope).Count(&total).Error; err != nil {
+        slog.ErrorContext(ctx, "database error counting feedback for review", "error", err)
+        return nil, 0, errors.New("database error counting feedback")
+    }
+
+    var rows []feedbackReviewRow
+    err := r.db.WithContext(ctx).
+        Table("message_feedback AS f").
+        Select(`f.*,
+            COALESCE(c.title, '') AS chat_title,
+            COALESCE(q.content, '') AS question,
+            COALESCE((
+                SELECT ic.content FROM messages AS ic
+                WHERE ic.parent_id = q.id AND ic.message_type = ?
+                ORDER BY ic.id DESC LIMIT 1
+            ), '') AS internal_context,
+            a.content AS answer,
+            COALESCE(a.sources, '') AS sources,
+            a.created_at AS answered_at`, domain.MessageTypeInternalContext).
+        Joins("JOIN messages AS a ON a.id = f.message_id").
+        Joins("LEFT JOIN messages AS q ON q.id = a.parent_id").
+        Joins("LEFT JOIN chats AS c ON c.id = f.chat_id").
+        Scopes(scope).
+        Order("f.created_at DESC, f.id DESC").
+        Limit(limit).
+        Offset(offset).
+        Scan(&rows).Error
+    if err != nil {
+        slog.ErrorContext(ctx, "database error listing feedback for review", "error", err)
+        return nil, 0, errors.New("database error listing feedback")
+    }
+
+    reviews := make([]domain.FeedbackReview, 0, len(rows))
+    for _, row := range rows {
+        review := domain.FeedbackReview{
+            MessageFeedback: row.MessageFeedback,
+            UserID:          row.MessageFeedback.UserID,
+            ChatTitle:       row.ChatTitle,
+            Question:        row.Question,
+            InternalContext: row.InternalContext,
+            Answer:          row.Answer,
+            AnsweredAt:      row.AnsweredAt,
+        }
+        if row.Sources != "" {
+            if err := json.Unmarshal([]byte(row.Sources), &review.Sources); err != nil {
+                slog.WarnContext(ctx, "unreadable sources on message", "message_id", row.MessageID, "error", err)
+            }
+        }
+        reviews = append(reviews, review)
+    }
+    return reviews, total, nil
+}
+
+// UpdateFeedbackReview records a reviewer's verdict on a piece of feedback
+func (r *gormMessageRepository) UpdateFeedbackReview(ctx context.Context, feedbackID, reviewerID uint, status, note string) (*domain.MessageFeedback, error) {
+    if feedbackID == 0 {
+        return nil, ErrFeedbackNotFound
+    }
+    now := time.Now()
+    updates := map[string]interface{}{
+        "review_status": status,
+        "review_note":   note,
+        "reviewed_by":   reviewerID,
+        "reviewed_at":   now,
+    }
+    if status == domain.FeedbackReviewOpen {
+        updates["reviewed_by"] = nil
+        updates["reviewed_at"] = nil
+    }
+
+    result := transaction.DB(ctx, r.db).Model(&domain.MessageFeedback{}).Where("id = ?", feedbackID).Updates(updates)
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error reviewing feedback", "feedback_id", feedbackID, "error", result.Error)
+        return nil, errors.New("database error updating feedback review")
+    }
+    if result.RowsAffected == 0 {
+        return nil, ErrFeedbackNotFound
+    }
+
+    var feedback domain.MessageFeedback
+    if err := transaction.DB(ctx, r.db).First(&feedback, feedbackID).Error; err != nil {
+        slog.ErrorContext(ctx, "database error loading feedback", "feedback_id", feedbackID, "error", err)
+        return nil, errors.New("database error loading feedback")
+    }
+    return &feedback, nil
+}
@@ -23,6 +23,7 @@ type MessageRepository interface {
 	CountByChatID(ctx context.Context, chatID uint) (int64, error)
 	CountTotalMessages(ctx context.Context) (int64, error)
 	CountMessagesByType(ctx context.Context, chatID uint, messageType string) (int64, error)
+	GetMessageMetrics(ctx context.Context) (*MessageMetrics, error)
 	FindRecentMessages(ctx context.Context, chatID uint, limit int) ([]domain.Message, error)
 	FindMessagesByDateRange(ctx context.Context, chatID uint, startDate, endDate time.Time) ([]domain.Message, error)
 	FindMessagesByType(ctx context.Context, chatID uint, messageType string, limit int) ([]domain.Message, error)
@@ -39,17 +40,33 @@ type MessageRepository interface {
 	FindRecentUserAndAssistantMessagesByType(ctx context.Context, chatID uint, userLimit int, userType string) ([]domain.Message, *domain.Message, error)
 	FindRecentUserAssistantPairs(ctx context.Context, chatID uint, pairLimit int, userType string) ([]domain.Message, error)
 
+	// Branching: edited questions live alongside the original as siblings
+	FindActiveBranch(ctx context.Context, chatID uint, limit int) ([]domain.Message, error)
+	FindActiveBranchPage(ctx context.Context, chatID uint, limit, offset int) ([]domain.Message, int64, error)
+	FindSiblingIDs(ctx context.Context, chatID uint, messageIDs []uint) (map[uint][]uint, error)
+	ActivateBranch(ctx context.Context, chatID, messageID uint) error
+
+	// Full-text search across all of a user's chats
+	SearchUserConversations(ctx context.Context, userID uint, query string, limit, offset int) ([]domain.SearchHit, int64, error)
+
+	// Answer feedback and the reviewers' queue
+	SaveFeedback(ctx context.Context, feedback *domain.MessageFeedback) error
+	DeleteFeedback(ctx context.Context, messageID, userID uint) error
+	FindFeedbackByChat(ctx context.Context, chatID, userID uint) ([]domain.MessageFeedback, error)
+	FindFeedbackForReview(ctx context.Context, filter FeedbackReviewFilter, limit, offset int) ([]domain.FeedbackReview, int64, error)
+	UpdateFeedbackReview(ctx context.Context, feedbackID, reviewerID uint, status, note string) (*domain.MessageFeedback, error)
+
 }
 
 // Supporting types for enhanced functionality
 type MessageMetrics struct {
-    TotalMessages     int64
-    UserMessages      int64
-    AIMessages        int64
-    SystemMessages    int64
-    AverageLength     float64
-    MessagesToday     int64
-    MessagesThisWeek  int64
+    TotalMessages     int64   `json:"total_messages"`
+    UserMessages      int64   `json:"user_messages"`
+    AIMessages        int64   `json:"ai_messages"`
+    SystemMessages    int64   `json:"system_messages"`
+    AverageLength     float64 `json:"average_length"`
+    MessagesToday     int64   `json:"messages_today"`
+    MessagesThisWeek  int64   `json:"messages_this_week"`
 }
 
 type MessageSearchFilter struct {
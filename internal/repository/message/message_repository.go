@@ -6,7 +6,7 @@ import (
     "context"
     "errors"
     "fmt"
-    "log"
+    "log/slog"
     "strings"
     "time"
     "github.com/iyunix/go-internist/internal/domain"
@@ -30,24 +30,24 @@ func NewMessageRepository(db *gorm.DB) MessageRepository {
 func (r *gormMessageRepository) Create(ctx context.Context, message *domain.Message) (*domain.Message, error) {
     // Comprehensive input validation
     if err := r.validateMessageInput(message); err != nil {
-        log.Printf("[MessageRepository] Validation failed: %v", err)
+        slog.ErrorContext(ctx, "message validation failed", "error", err)
         return nil, fmt.Errorf("validation failed: %w", err)
     }
     
     err := r.db.WithContext(ctx).Create(message).Error
     if err != nil {
         // Secure logging - no sensitive medical content exposed
-        log.Printf("[MessageRepository] Database error during message creation for chat ID %d: %v", message.ChatID, err)
+        slog.ErrorContext(ctx, "database error during message creation", "chat_id", message.ChatID, "error", err)
         return nil, errors.New("database error creating message")
     }
     
-    log.Printf("[MessageRepository] Message created successfully with ID: %d for chat: %d", message.ID, message.ChatID)
+    slog.InfoContext(ctx, "message created", "message_id", message.ID, "chat_id", message.ChatID)
     return message, nil
 }
 
 // FindByChatID - Enhanced with memory safety warning (deprecated)
 func (r *gormMessageRepository) FindByChatID(ctx context.Context, chatID uint) ([]domain.Message, error) {
-    log.Printf("[MessageRepository] WARNING: FindByChatID() loads all messages into memory. Use FindByChatIDWithPagination() for production.")
+    slog.WarnContext(ctx, "FindByChatID loads all messages into memory, use FindByChatIDWithPagination in production")
 
     if chatID == 0 {
         return nil, errors.New("invalid chat ID")
@@ -62,7 +62,7 @@ func (r *gormMessageRepository) FindByChatID(ctx context.Context, chatID uint) (
         Find(&messages).Error
 
     if err != nil {
-        log.Printf("[MessageRepository] Database error finding messages for chat ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error finding messages", "chat_id", chatID, "error", err)
         return nil, errors.New("database error fetching messages")
     }
 
@@ -97,7 +97,7 @@ func (r *gormMessageRepository) FindByChatIDWithPagination(ctx context.Context,
     if err := r.db.WithContext(ctx).Model(&domain.Message{}).
         Where("chat_id = ? AND message_type IN ?", chatID, visibleTypes). // <-- ADDED FILTER
         Count(&total).Error; err != nil {
-        log.Printf("[MessageRepository] Database error counting messages for chat ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error counting messages", "chat_id", chatID, "error", err)
         return nil, 0, errors.New("database error counting messages")
     }
 
@@ -111,7 +111,7 @@ func (r *gormMessageRepository) FindByChatIDWithPagination(ctx context.Context,
         Find(&messages).Error
 
     if err != nil {
-        log.Printf("[MessageRepository] Database error in paginated query for chat ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error in paginated query", "chat_id", chatID, "error", err)
         return nil, 0, errors.New("database error retrieving paginated messages")
     }
 
@@ -127,7 +127,7 @@ func (r *gormMessageRepository) FindByID(ctx context.Context, messageID uint) (*
     
     var message domain.Message
     err := r.db.WithContext(ctx).First(&message, messageID).Error
-    return r.handleFindError(err, &message, "FindByID")
+    return r.handleFindError(ctx, err, &message, "FindByID")
 }
 
 // Update - Complete CRUD operation with validation
@@ -142,7 +142,7 @@ func (r *gormMessageRepository) Update(ctx context.Context, message *domain.Mess
     
     result := r.db.WithContext(ctx).Save(message)
     if result.Error != nil {
-        log.Printf("[MessageRepository] Database error updating message ID %d: %v", message.ID, result.Error)
+        slog.ErrorContext(ctx, "database error updating message", "message_id", message.ID, "error", result.Error)
         return errors.New("database error updating message")
     }
     
@@ -150,7 +150,7 @@ func (r *gormMessageRepository) Update(ctx context.Context, message *domain.Mess
         return ErrMessageNotFound
     }
     
-    log.Printf("[MessageRepository] Message updated successfully with ID: %d", message.ID)
+    slog.InfoContext(ctx, "message updated", "message_id", message.ID)
     return nil
 }
 
@@ -165,7 +165,7 @@ func (r *gormMessageRepository) Delete(ctx context.Context, messageID, chatID ui
         Delete(&domain.Message{})
     
     if result.Error != nil {
-        log.Printf("[MessageRepository] Database error deleting message ID %d for chat ID %d: %v", messageID, chatID, result.Error)
+        slog.ErrorContext(ctx, "database error deleting message", "message_id", messageID, "chat_id", chatID, "error", result.Error)
         return errors.New("database error deleting message")
     }
     
@@ -173,7 +173,7 @@ func (r *gormMessageRepository) Delete(ctx context.Context, messageID, chatID ui
         return ErrUnauthorizedMessageAccess
     }
     
-    log.Printf("[MessageRepository] Message deleted successfully: ID %d for chat %d", messageID, chatID)
+    slog.InfoContext(ctx, "message deleted", "message_id", messageID, "chat_id", chatID)
     return nil
 }
 
@@ -204,12 +204,12 @@ func (r *gormMessageRepository) CreateInBatch(ctx context.Context, messages []*d
         
         batch := messages[i:end]
         if err := r.db.WithContext(ctx).CreateInBatches(batch, batchSize).Error; err != nil {
-            log.Printf("[MessageRepository] Batch creation failed for batch %d-%d: %v", i, end, err)
+            slog.ErrorContext(ctx, "batch message creation failed", "from", i, "to", end, "error", err)
             return fmt.Errorf("database error creating batch %d-%d: %w", i, end, err)
         }
     }
     
-    log.Printf("[MessageRepository] Successfully created %d messages in batches", len(messages))
+    slog.InfoContext(ctx, "created messages in batches", "count", len(messages))
     return nil
 }
 
@@ -235,11 +235,11 @@ func (r *gormMessageRepository) DeleteMultipleByChatID(ctx context.Context, mess
         Delete(&domain.Message{})
     
     if result.Error != nil {
-        log.Printf("[MessageRepository] Database error in bulk delete for chat ID %d: %v", chatID, result.Error)
+        slog.ErrorContext(ctx, "database error in bulk delete", "chat_id", chatID, "error", result.Error)
         return errors.New("database error in bulk message deletion")
     }
     
-    log.Printf("[MessageRepository] Bulk deleted %d messages for chat %d", result.RowsAffected, chatID)
+    slog.InfoContext(ctx, "bulk deleted messages", "count", result.RowsAffected, "chat_id", chatID)
     return nil
 }
 
@@ -252,7 +252,7 @@ func (r *gormMessageRepository) ExistsByID(ctx context.Context, messageID uint)
     var count int64
     err := r.db.WithContext(ctx).Model(&domain.Message{}).Where("id = ?", messageID).Count(&count).Error
     if err != nil {
-        log.Printf("[MessageRepository] Database error checking message existence for ID %d: %v", messageID, err)
+        slog.ErrorContext(ctx, "database error checking message existence", "message_id", messageID, "error", err)
         return false, errors.New("database error checking message existence")
     }
     
@@ -268,7 +268,7 @@ func (r *gormMessageRepository) ExistsByIDAndChatID(ctx context.Context, message
     var count int64
     err := r.db.WithContext(ctx).Model(&domain.Message{}).Where("id = ? AND chat_id = ?", messageID, chatID).Count(&count).Error
     if err != nil {
-        log.Printf("[MessageRepository] Database error checking message ownership for message ID %d, chat ID %d: %v", messageID, chatID, err)
+        slog.ErrorContext(ctx, "database error checking message ownership", "message_id", messageID, "chat_id", chatID, "error", err)
         return false, errors.New("database error checking message ownership")
     }
     
@@ -289,7 +289,7 @@ func (r *gormMessageRepository) CountByChatID(ctx context.Context, chatID uint)
     var count int64
     err := r.db.WithContext(ctx).Model(&domain.Message{}).Where("chat_id = ?", chatID).Count(&count).Error
     if err != nil {
-        log.Printf("[MessageRepository] Database error counting messages for chat ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error counting messages", "chat_id", chatID, "error", err)
         return 0, errors.New("database error counting chat messages")
     }
     
@@ -301,7 +301,7 @@ func (r *gormMessageRepository) CountTotalMessages(ctx context.Context) (int64,
     var count int64
     err := r.db.WithContext(ctx).Model(&domain.Message{}).Count(&count).Error
     if err != nil {
-        log.Printf("[MessageRepository] Database error counting total messages: %v", err)
+        slog.ErrorContext(ctx, "database error counting total messages", "error", err)
         return 0, errors.New("database error counting total messages")
     }
     
@@ -324,13 +324,40 @@ func (r *gormMessageRepository) CountMessagesByType(ctx context.Context, chatID
         Count(&count).Error
     
     if err != nil {
-        log.Printf("[MessageRepository] Database error counting messages by type for chat ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error counting messages by type", "chat_id", chatID, "error", err)
         return 0, errors.New("database error counting messages by type")
     }
     
     return count, nil
 }
 
+// GetMessageMetrics - Analytics: system-wide message totals in a single aggregate query.
+// "Today" starts at midnight UTC; "this week" is the last seven days.
+func (r *gormMessageRepository) GetMessageMetrics(ctx context.Context) (*MessageMetrics, error) {
+    now := time.Now().UTC()
+    today := now.Truncate(24 * time.Hour)
+    weekAgo := now.AddDate(0, 0, -7)
+
+    var metrics MessageMetrics
+    err := r.db.WithContext(ctx).Model(&domain.Message{}).
+        Select(`COUNT(*) AS total_messages,
+            COUNT(*) FILTER (WHERE message_type = ?) AS user_messages,
+            COUNT(*) FILTER (WHERE message_type = ?) AS ai_messages,
+            COUNT(*) FILTER (WHERE message_type = ?) AS system_messages,
+            COALESCE(AVG(char_length(content)), 0)::float8 AS average_length,
+            COUNT(*) FILTER (WHERE created_at >= ?) AS messages_today,
+            COUNT(*) FILTER (WHERE created_at >= ?) AS messages_this_week`,
+            domain.MessageTypeUser, domain.MessageTypeAssistant, domain.MessageTypeSystem, today, weekAgo).
+        Scan(&metrics).Error
+
+    if err != nil {
+        slog.ErrorContext(ctx, "database error computing message metrics", "error", err)
+        return nil, errors.New("database error computing message metrics")
+    }
+
+    return &metrics, nil
+}
+
 // FindRecentMessages - Analytics: recent activity tracking
 func (r *gormMessageRepository) FindRecentMessages(ctx context.Context, chatID uint, limit int) ([]domain.Message, error) {
     if chatID == 0 {
@@ -349,7 +376,7 @@ func (r *gormMessageRepository) FindRecentMessages(ctx context.Context, chatID u
         Find(&messages).Error
     
     if err != nil {
-        log.Printf("[MessageRepository] Database error finding recent messages for chat ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error finding recent messages", "chat_id", chatID, "error", err)
         return nil, errors.New("database error finding recent messages")
     }
     
@@ -373,7 +400,7 @@ func (r *gormMessageRepository) FindMessagesByDateRange(ctx context.Context, cha
         Find(&messages).Error
     
     if err != nil {
-        log.Printf("[MessageRepository] Database error finding messages by date range for chat ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error finding messages by date range", "chat_id", chatID, "error", err)
         return nil, errors.New("database error finding messages by date range")
     }
     
@@ -402,7 +429,7 @@ func (r *gormMessageRepository) FindMessagesByType(ctx context.Context, chatID u
         Find(&messages).Error
     
     if err != nil {
-        log.Printf("[MessageRepository] Database error finding messages by type for chat ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error finding messages by type", "chat_id", chatID, "error", err)
         return nil, errors.New("database error finding messages by type")
     }
     
@@ -433,7 +460,7 @@ func (r *gormMessageRepository) SearchMessageContent(ctx context.Context, chatID
         Find(&messages).Error
     
     if err != nil {
-        log.Printf("[MessageRepository] Database error searching message content for chat ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error searching message content", "chat_id", chatID, "error", err)
         return nil, errors.New("database error searching message content")
     }
     
@@ -462,7 +489,7 @@ func (r *gormMessageRepository) FindLongMessages(ctx context.Context, chatID uin
         Find(&messages).Error
     
     if err != nil {
-        log.Printf("[MessageRepository] Database error finding long messages for chat ID %d: %v", chatID, err)
+        slog.ErrorContext(ctx, "database error finding long messages", "chat_id", chatID, "error", err)
         return nil, errors.New("database error finding long messages")
     }
     
@@ -480,11 +507,11 @@ func (r *gormMessageRepository) DeleteOldMessages(ctx context.Context, chatID ui
         Delete(&domain.Message{})
     
     if result.Error != nil {
-        log.Printf("[MessageRepository] Database error deleting old messages for chat ID %d: %v", chatID, result.Error)
+        slog.ErrorContext(ctx, "database error deleting old messages", "chat_id", chatID, "error", result.Error)
         return 0, errors.New("database error deleting old messages")
     }
     
-    log.Printf("[MessageRepository] Deleted %d old messages for chat %d", result.RowsAffected, chatID)
+    slog.InfoContext(ctx, "deleted old messages", "count", result.RowsAffected, "chat_id", chatID)
     return result.RowsAffected, nil
 }
 
@@ -501,11 +528,11 @@ func (r *gormMessageRepository) ArchiveMessagesByChatID(ctx context.Context, cha
         Update("archived", true)
     
     if result.Error != nil {
-        log.Printf("[MessageRepository] Database error archiving messages for chat ID %d: %v", chatID, result.Error)
+        slog.ErrorContext(ctx, "database error archiving messages", "chat_id", chatID, "error", result.Error)
         return 0, errors.New("database error archiving messages")
     }
     
-    log.Printf("[MessageRepository] Archived %d messages for chat %d", result.RowsAffected, chatID)
+    slog.InfoContext(ctx, "archived messages", "count", result.RowsAffected, "chat_id", chatID)
     return result.RowsAffected, nil
 }
 
@@ -528,11 +555,11 @@ func (r *gormMessageRepository) UpdateMultipleTimestamps(ctx context.Context, me
         Update("updated_at", gorm.Expr("CURRENT_TIMESTAMP"))
     
     if result.Error != nil {
-        log.Printf("[MessageRepository] Database error in bulk timestamp update: %v", result.Error)
+        slog.ErrorContext(ctx, "database error in bulk timestamp update", "error", result.Error)
         return errors.New("database error updating multiple timestamps")
     }
     
-    log.Printf("[MessageRepository] Updated timestamps for %d messages", result.RowsAffected)
+    slog.InfoContext(ctx, "updated message timestamps", "count", result.RowsAffected)
     return nil
 }
 
@@ -559,11 +586,11 @@ func (r *gormMessageRepository) BulkUpdateMessageType(ctx context.Context, messa
         Update("message_type", newType)
     
     if result.Error != nil {
-        log.Printf("[MessageRepository] Database error in bulk type update: %v", result.Error)
+        slog.ErrorContext(ctx, "database error in bulk type update", "error", result.Error)
         return errors.New("database error updating multiple message types")
     }
     
-    log.Printf("[MessageRepository] Updated message type for %d messages", result.RowsAffected)
+    slog.InfoContext(ctx, "updated message types", "count", result.RowsAffected)
     return nil
 }
 
@@ -653,7 +680,7 @@ func (r *gormMessageRepository) validateSearchTerm(term string) error {
 // ===== ERROR HANDLING HELPERS =====
 
 // handleFindError - Secure error handling without data leakage
-func (r *gormMessageRepository) handleFindError(err error, message *domain.Message, operation string) (*domain.Message, error) {
+func (r *gormMessageRepository) handleFindError(ctx context.Context, err error, message *domain.Message, operation string) (*domain.Message, error) {
     if err == nil {
         return message, nil
     }
@@ -663,7 +690,7 @@ func (r *gormMessageRepository) handleFindError(err error, message *domain.Messa
     }
     
     // Log technical details for debugging
-    log.Printf("[MessageRepository] %s database error: %v", operation, err)
+    slog.ErrorContext(ctx, "database error", "operation", operation, "error", err)
     
     // Return generic error for security
     return nil, errors.New("database query failed")
@@ -677,11 +704,11 @@ func (r *gormMessageRepository) DeleteByChatID(ctx context.Context, chatID uint)
 
 	result := r.db.WithContext(ctx).Where("chat_id = ?", chatID).Delete(&domain.Message{})
 	if result.Error != nil {
-		log.Printf("[MessageRepository] Database error deleting messages for chat ID %d: %v", chatID, result.Error)
+		slog.ErrorContext(ctx, "database error deleting messages", "chat_id", chatID, "error", result.Error)
 		return errors.New("database error deleting messages by chat ID")
 	}
 
-	log.Printf("[MessageRepository] Deleted %d messages for chat %d", result.RowsAffected, chatID)
+	slog.InfoContext(ctx, "deleted messages", "count", result.RowsAffected, "chat_id", chatID)
 	return nil
 }
 
@@ -777,6 +804,9 @@ func (r *gormMessageRepository) FindRecentUserAndAssistantMessagesByType(
 
 
 
+// pairColumns are the message fields conversation context needs
+var pairColumns = []string{"id", "chat_id", "content", "message_type", "parent_id", "created_at"}
+
 // FindRecentUserAssistantPairs - Efficiently fetch up to pairLimit recent (user→assistant) pairs.
 func (r *gormMessageRepository) FindRecentUserAssistantPairs(
     ctx context.Context,
@@ -792,38 +822,61 @@ func (r *gormMessageRepository) FindRecentUserAssistantPairs(
         return nil, errors.New("pair limit must be > 0")
     }
 
-    // Step 1: Fetch the last N*2 messages of relevant types (user + assistant) in descending order
-    // N*2 ensures we have enough messages to form pairs.
-    fetchLimit := pairLimit * 2
-    var messages []domain.Message
-    err := r.db.WithContext(ctx).
-        Where("chat_id = ? AND (message_type = ? OR message_type = ?)", chatID, userType, domain.MessageTypeAssistant).
-        Order("created_at DESC").
-        Limit(fetchLimit * 5). // fetch more to ensure enough valid pairs in sparse chats
-        Find(&messages).Error
+    // Step 1: Only the active branch counts; edited-away questions and their answers are
+    // skipped. The last pairLimit pairs lie within the last 2*pairLimit+1 messages, the
+    // extra one being a question still waiting for its answer.
+    branch, err := r.findActiveBranch(ctx, chatID, pairLimit*2+1, pairColumns)
     if err != nil {
         return nil, err
     }
 
-    // Step 2: Reverse slice to chronological order (oldest → newest)
-    for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-        messages[i], messages[j] = messages[j], messages[i]
+    // Step 2: When asked for another user type (internal_context), swap each question for
+    // the processed version stored under it
+    substitutes := make(map[uint]domain.Message)
+    if userType != domain.MessageTypeUser {
+        var questionIDs []uint
+        for _, msg := range branch {
+            if msg.MessageType == domain.MessageTypeUser {
+                questionIDs = append(questionIDs, msg.ID)
+            }
+        }
+        if len(questionIDs) > 0 {
+            var processed []domain.Message
+            err := r.db.WithContext(ctx).
+                Select(pairColumns).
+                Where("chat_id = ? AND message_type = ? AND parent_id IN ?", chatID, userType, questionIDs).
+                Order("created_at asc").
+                Find(&processed).Error
+            if err != nil {
+                return nil, err
+            }
+            for _, msg := range processed {
+                substitutes[*msg.ParentID] = msg
+            }
+        }
     }
 
-    // Step 3: Build user→assistant pairs
+    // Step 3: Build user→assistant pairs in chronological order
     var pairs []domain.Message
     var userMsg *domain.Message
-    for _, msg := range messages {
-        if msg.MessageType == userType {
-            userMsg = &msg
+    for i := range branch {
+        msg := branch[i]
+        if msg.MessageType == domain.MessageTypeUser {
+            userMsg = nil
+            if userType == domain.MessageTypeUser {
+                userMsg = &msg
+            } else if sub, ok := substitutes[msg.ID]; ok {
+                userMsg = &sub
+            }
         } else if msg.MessageType == domain.MessageTypeAssistant && userMsg != nil {
-            // append pair
             pairs = append(pairs, *userMsg, msg)
             userMsg = nil
         }
-        if len(pairs)/2 == pairLimit {
-            break
-        }
+    }
+
+    // Step 4: Keep the most recent pairs
+    if len(pairs) > pairLimit*2 {
+        pairs = pairs[len(pairs)-pairLimit*2:]
     }
 
     return pairs, nil
@@ -0,0 +1,139 @@
+// File: internal/repository/message/search.go
+package message
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log/slog"
+    "strings"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "gorm.io/gorm"
+)
+
+// Snippet delimiters produced by ts_headline. They are swapped for <mark> tags only
+// after the snippet has been HTML-escaped, so message content is never rendered as markup.
+const (
+    SnippetMatchStart = "⟦"
+    SnippetMatchStop  = "⟧"
+)
+
+// searchNormalizations maps Arabic-script variants to the forms Persian keyboards
+// produce, and Persian/Arabic digits to ASCII, so "كتاب" finds "کتاب" and "۵۰۰" finds "500".
+// Zero-width non-joiners become spaces so half-spaced compounds match their parts.
+var searchNormalizations = [][2]string{
+    {"\u064a", "\u06cc"}, // Arabic yeh -> Persian yeh
+    {"\u0649", "\u06cc"}, // alef maksura -> Persian yeh
+    {"\u0643", "\u06a9"}, // Arabic kaf -> keheh
+    {"\u0629", "\u0647"}, // teh marbuta -> heh
+    {"\u06c0", "\u0647"}, // heh with yeh above -> heh
+    {"\u0623", "\u0627"}, // alef with hamza above -> alef
+    {"\u0625", "\u0627"}, // alef with hamza below -> alef
+    {"\u0671", "\u0627"}, // alef wasla -> alef
+    {"\u0624", "\u0648"}, // waw with hamza -> waw
+    {"\u200c", " "},      // zero-width non-joiner -> space
+}
+
+// searchRemovals are dropped entirely: tatweel and the short-vowel diacritics
+const searchRemovals = "\u0640\u064b\u064c\u064d\u064e\u064f\u0650\u0651\u0652"
+
+// searchVector is the indexed document expression. Keep it in sync with EnsureSearchIndexes:
+// Postgres only uses an expression index when the query repeats the expression exactly.
+func searchVector(column string) string {
+    return fmt.Sprintf("(to_tsvector('simple', search_normalize(%[1]s)) || to_tsvector('english', search_normalize(%[1]s)))", column)
+}
+
+// searchQuery matches either the exact words or their English stems
+const searchQuery = "(websearch_to_tsquery('simple', search_normalize(?)) || websearch_to_tsquery('english', search_normalize(?)))"
+
+// EnsureSearchIndexes installs the normalisation function and the GIN indexes used by
+// full-text search. It is idempotent and runs with the other migrations.
+func EnsureSearchIndexes(ctx context.Context, db *gorm.DB) error {
+    var from, to strings.Builder
+    for _, n := range searchNormalizations {
+        from.WriteString(n[0])
+        to.WriteString(n[1])
+    }
+    for i := 0; i < 10; i++ {
+        digit := string(rune('0' + i))
+        from.WriteString(string(rune(0x06f0+i)) + string(rune(0x0660+i)))
+        to.WriteString(digit + digit)
+    }
+    from.WriteString(searchRemovals) // translate() deletes characters with no counterpart
+
+    statements := []string{
+        fmt.Sprintf(`CREATE OR REPLACE FUNCTION search_normalize(input text) RETURNS text
+            LANGUAGE sql IMMUTABLE PARALLEL SAFE
+            AS $$ SELECT translate(lower(coalesce(input, '')), '%s', '%s') $$`, from.String(), to.String()),
+        fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_messages_search ON messages USING GIN (%s)", searchVector("content")),
+        fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_chats_title_search ON chats USING GIN (%s)", searchVector("title")),
+    }
+    for _, stmt := range statements {
+        if err := db.WithContext(ctx).Exec(stmt).Error; err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// SearchUserConversations runs a full-text search over the questions, answers and chat
+// titles of all of a user's chats, best matches first. Only messages on an active branch
+// are searched, so every hit can be opened in its chat.
+func (r *gormMessageRepository) SearchUserConversations(ctx context.Context, userID uint, query string, limit, offset int) ([]domain.SearchHit, int64, error) {
+    if userID == 0 {
+        return nil, 0, errors.New("invalid user ID")
+    }
+    if strings.TrimSpace(query) == "" {
+        return nil, 0, errors.New("empty search query")
+    }
+    if limit <= 0 || limit > 100 {
+        limit = 20
+    }
+    if offset < 0 {
+        offset = 0
+    }
+
+    hits := fmt.Sprintf(`
+        SELECT c.id AS chat_id, c.title AS chat_title, m.id AS message_id, m.message_type,
+            m.content AS body, ts_rank(%[1]s, q.query) AS rank, m.created_at
+        FROM messages m
+        JOIN chats c ON c.id = m.chat_id
+        CROSS JOIN (SELECT %[3]s AS query) q
+        WHERE c.user_id = ? AND c.deleted_at IS NULL AND m.deleted_at IS NULL
+            AND m.message_type IN ? AND m.active AND %[1]s @@ q.query
+        UNION ALL
+        SELECT c.id, c.title, 0, '', c.title, ts_rank(%[2]s, q.query) * 2, c.updated_at
+        FROM chats c
+        CROSS JOIN (SELECT %[3]s AS query) q
+        WHERE c.user_id = ? AND c.deleted_at IS NULL AND %[2]s @@ q.query`,
+        searchVector("m.content"), searchVector("c.title"), searchQuery)
+    args := []interface{}{query, query, userID, conversationTypes, query, query, userID}
+
+    var total int64
+    if err := r.db.WithContext(ctx).Raw("SELECT count(*) FROM ("+hits+") AS hits", args...).Scan(&total).Error; err != nil {
+        slog.ErrorContext(ctx, "database error counting search results", "user_id", userID, "error", err)
+        return nil, 0, errors.New("database error searching conversations")
+    }
+    if total == 0 {
+        return []domain.SearchHit{}, 0, nil
+    }
+
+    // Snippets are only built for the page being returned; ts_headline re-parses the whole text
+    page := fmt.Sprintf(`
+        SELECT chat_id, chat_title, message_id, message_type, rank, created_at,
+            ts_headline('english', body, %s,
+                'StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "') AS snippet
+        FROM (SELECT * FROM (%s) AS hits ORDER BY rank DESC, created_at DESC LIMIT ? OFFSET ?) AS page
+        ORDER BY rank DESC, created_at DESC`,
+        searchQuery, SnippetMatchStart, SnippetMatchStop, hits)
+    pageArgs := append([]interface{}{query, query}, args...)
+    pageArgs = append(pageArgs, limit, offset)
+
+    var results []domain.SearchHit
+    if err := r.db.WithContext(ctx).Raw(page, pageArgs...).Scan(&results).Error; err != nil {
+        slog.ErrorContext(ctx, "database error searching conversations", "user_id", userID, "error", err)
+        return nil, 0, errors.New("database error searching conversations")
+    }
+    return results, total, nil
+}
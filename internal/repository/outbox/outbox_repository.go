@@ -0,0 +1,226 @@
+// File: internal/repository/outbox/outbox_repository.go
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/iyunix/go-internist/internal/domain"
+)
+
+var (
+	ErrMessageNotFound = errors.New("sms message not found")
+	// ErrClaimLost means the message was claimed again after its lease ran out, so the
+	// newer claim records the outcome instead
+	ErrClaimLost = errors.New("sms message claim expired")
+)
+
+// Filter selects outbox messages for the admin view. Zero values match anything.
+type Filter struct {
+	Phone  string
+	Status string
+}
+
+// OutboxRepository persists outgoing SMS messages and their delivery state
+type OutboxRepository interface {
+	Enqueue(ctx context.Context, msg *domain.SMSMessage) error
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.SMSMessage, error)
+	MarkSent(ctx context.Context, id uint, claimedAt time.Time, provider, providerMessageID string) error
+	MarkRetry(ctx context.Context, id uint, claimedAt time.Time, next time.Time, reason string) error
+	MarkFailed(ctx context.Context, id uint, claimedAt time.Time, reason string) error
+	RecordDelivery(ctx context.Context, provider, providerMessageID, status, detail string) (int64, error)
+	FindRecent(ctx context.Context, filter Filter, limit, offset int) ([]domain.SMSMessage, int64, error)
+	SummarizeByPhone(ctx context.Context, since time.Time, limit int) ([]domain.SMSPhoneSummary, error)
+}
+
+// GormOutboxRepository implements OutboxRepository using GORM
+type GormOutboxRepository struct {
+	db *gorm.DB
+}
+
+// NewGormOutboxRepository creates a new SMS outbox repository
+func NewGormOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &GormOutboxRepository{db: db}
+}
+
+// Enqueue stores a message to be sent as soon as the dispatcher gets to it
+func (r *GormOutboxRepository) Enqueue(ctx context.Context, msg *domain.SMSMessage) error {
+	if msg == nil {
+		return errors.New("sms message is nil")
+	}
+	if msg.Phone == "" || msg.Template == "" {
+		return errors.New("sms message requires phone and template")
+	}
+	msg.Status = domain.SMSStatusQueued
+	if msg.NextAttemptAt.IsZero() {
+		msg.NextAttemptAt = time.Now()
+	}
+	return r.db.WithContext(ctx).Create(msg).Error
+}
+
+// ClaimDue takes up to limit queued messages that are due, plus messages stuck in
+// sending for longer than lease (their dispatcher died), and marks them sending with a
+// new claimed_at. SKIP LOCKED keeps several instances from claiming the same message.
+func (r *GormOutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.SMSMessage, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	var claimed []domain.SMSMessage
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE sms_messages SET status = ?, attempts = attempts + 1, claimed_at = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM sms_messages
+			WHERE (status = ? AND next_attempt_at <= ?) OR (status = ? AND COALESCE(claimed_at, updated_at) < ?)
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		domain.SMSStatusSending, now, now,
+		domain.SMSStatusQueued, now, domain.SMSStatusSending, now.Add(-lease),
+		limit).
+		Scan(&claimed).Error
+	return claimed, err
+}
+
+// MarkSent records that a provider accepted the message. The params are cleared since
+// they are no longer needed and may hold a verification code.
+func (r *GormOutboxRepository) MarkSent(ctx context.Context, id uint, claimedAt time.Time, provider, providerMessageID string) error {
+	now := time.Now()
+	return r.finishClaim(ctx, id, claimedAt, map[string]interface{}{
+		"status":              domain.SMSStatusSent,
+		"provider":            provider,
+		"provider_message_id": providerMessageID,
+		"params":              "{}",
+		"last_error":          "",
+		"sent_at":             now,
+	})
+}
+
+// MarkRetry puts the message back in the queue for another attempt at next
+func (r *GormOutboxRepository) MarkRetry(ctx context.Context, id uint, claimedAt time.Time, next time.Time, reason string) error {
+	return r.finishClaim(ctx, id, claimedAt, map[string]interface{}{
+		"status":          domain.SMSStatusQueued,
+		"next_attempt_at": next,
+		"last_error":      truncate(reason, 500),
+	})
+}
+
+// MarkFailed gives up on the message
+func (r *GormOutboxRepository) MarkFailed(ctx context.Context, id uint, claimedAt time.Time, reason string) error {
+	return r.finishClaim(ctx, id, claimedAt, map[string]interface{}{
+		"status":     domain.SMSStatusFailed,
+		"params":     "{}",
+		"last_error": truncate(reason, 500),
+	})
+}
+
+// finishClaim applies the outcome of a send, but only while the message is still held
+// by the claim made at claimedAt; otherwise it returns ErrClaimLost
+func (r *GormOutboxRepository) finishClaim(ctx context.Context, id uint, claimedAt time.Time, updates map[string]interface{}) error {
+	result := r.db.WithContext(ctx).Model(&domain.SMSMessage{}).
+		Where("id = ? AND status = ? AND claimed_at = ?", id, domain.SMSStatusSending, claimedAt).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+// RecordDelivery applies a provider's delivery report to the message it sent. Reports
+// for unknown messages are ignored; the count of updated messages is returned.
+func (r *GormOutboxRepository) RecordDelivery(ctx context.Context, provider, providerMessageID, status, detail string) (int64, error) {
+	if providerMessageID == "" {
+		return 0, ErrMessageNotFound
+	}
+	updates := map[string]interface{}{"status": status}
+	switch status {
+	case domain.SMSStatusDelivered:
+		updates["delivered_at"] = time.Now()
+	case domain.SMSStatusFailed:
+		updates["last_error"] = truncate("undelivered: "+detail, 500)
+	default:
+		return 0, errors.New("invalid delivery status: " + status)
+	}
+
+	result := r.db.WithContext(ctx).Model(&domain.SMSMessage{}).
+		Where("provider = ? AND provider_message_id = ? AND status IN ?", provider, providerMessageID,
+			[]string{domain.SMSStatusSent, domain.SMSStatusDelivered, domain.SMSStatusFailed}).
+		Updates(updates)
+	return result.RowsAffected, result.Error
+}
+
+// FindRecent lists messages newest first
+func (r *GormOutboxRepository) FindRecent(ctx context.Context, filter Filter, limit, offset int) ([]domain.SMSMessage, int64, error) {
+	if limit <= 0 || limit > 1000 {
+		return nil, 0, errors.New("invalid limit: must be between 1 and 1000")
+	}
+	if offset < 0 {
+		return nil, 0, errors.New("invalid offset: must be >= 0")
+	}
+
+	scope := func(db *gorm.DB) *gorm.DB {
+		if filter.Phone != "" {
+			db = db.Where("phone = ?", filter.Phone)
+		}
+		if filter.Status != "" {
+			db = db.Where("status = ?", filter.Status)
+		}
+		return db
+	}
+
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&domain.SMSMessage{}).Scopes(scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var messages []domain.SMSMessage
+	err := r.db.WithContext(ctx).
+		Scopes(scope).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&messages).Error
+	return messages, total, err
+}
+
+// SummarizeByPhone counts messages per phone number since the given time, phones with
+// the most failures first
+func (r *GormOutboxRepository) SummarizeByPhone(ctx context.Context, since time.Time, limit int) ([]domain.SMSPhoneSummary, error) {
+	if limit <= 0 || limit > 1000 {
+		return nil, errors.New("invalid limit: must be between 1 and 1000")
+	}
+	var summaries []domain.SMSPhoneSummary
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT phone,
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE status = ?) AS sent,
+			COUNT(*) FILTER (WHERE status = ?) AS delivered,
+			COUNT(*) FILTER (WHERE status = ?) AS failed,
+			COUNT(*) FILTER (WHERE status IN ?) AS pending,
+			COALESCE((ARRAY_AGG(last_error ORDER BY updated_at DESC) FILTER (WHERE last_error <> ''))[1], '') AS last_error,
+			MAX(created_at) AS last_at
+		FROM sms_messages
+		WHERE created_at >= ?
+		GROUP BY phone
+		ORDER BY failed DESC, total DESC, last_at DESC
+		LIMIT ?`,
+		domain.SMSStatusSent, domain.SMSStatusDelivered, domain.SMSStatusFailed,
+		[]string{domain.SMSStatusQueued, domain.SMSStatusSending},
+		since, limit).
+		Scan(&summaries).Error
+	return summaries, err
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
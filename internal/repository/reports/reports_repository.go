@@ -0,0 +1,180 @@
+// File: internal/repository/reports/reports_repository.go
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/iyunix/go-internist/internal/domain"
+)
+
+// Filter narrows an export. The user fields apply to every export; From and To bound
+// the activity (questions, charges, transactions) counted in the usage and credit
+// exports. Zero values mean no bound.
+type Filter struct {
+	Plan           string
+	Status         string
+	RegisteredFrom time.Time // inclusive
+	RegisteredTo   time.Time // exclusive
+	MinBalance     *int
+	MaxBalance     *int
+	From           time.Time // inclusive
+	To             time.Time // exclusive
+}
+
+// ReportsRepository streams export rows off a database cursor, one at a time, so an
+// export never holds the whole result in memory. Soft-deleted users are left out.
+type ReportsRepository interface {
+	EachUser(ctx context.Context, filter Filter, fn func(*domain.User) error) error
+	EachChatUsage(ctx context.Context, filter Filter, fn func(*domain.UserChatUsage) error) error
+	EachCreditTransaction(ctx context.Context, filter Filter, fn func(*domain.CreditTransaction) error) error
+}
+
+// GormReportsRepository implements ReportsRepository using GORM
+type GormReportsRepository struct {
+	db *gorm.DB
+}
+
+// NewGormReportsRepository creates a new reports repository
+func NewGormReportsRepository(db *gorm.DB) ReportsRepository {
+	return &GormReportsRepository{db: db}
+}
+
+// EachUser streams matching users in ID order
+func (r *GormReportsRepository) EachUser(ctx context.Context, filter Filter, fn func(*domain.User) error) error {
+	db := r.db.WithContext(ctx)
+	rows, err := db.Model(&domain.User{}).
+		Scopes(filter.userScope("users")).
+		Order("id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	return each(db, rows, fn)
+}
+
+// EachChatUsage streams one usage row per matching user in ID order. Chats, questions
+// and charges are counted within the activity window, including deleted chats.
+func (r *GormReportsRepository) EachChatUsage(ctx context.Context, filter Filter, fn func(*domain.UserChatUsage) error) error {
+	db := r.db.WithContext(ctx)
+
+	chats := db.Table("chats").
+		Select("user_id, COUNT(*) AS chats").
+		Scopes(filter.activityScope("created_at")).
+		Group("user_id")
+	questions := db.Table("messages m").
+		Select("c.user_id, COUNT(*) AS questions, MAX(m.created_at) AS last_question_at").
+		Joins("JOIN chats c ON c.id = m.chat_id").
+		Where("m.message_type = ?", domain.MessageTypeUser).
+		Scopes(filter.activityScope("m.created_at")).
+		Group("c.user_id")
+	charges := db.Table("generation_jobs").
+		Select("user_id, SUM(charge) AS charged").
+		Where("status IN ?", []string{domain.JobStatusCompleted, domain.JobStatusCancelled}).
+		Scopes(filter.activityScope("created_at")).
+		Group("user_id")
+
+	rows, err := db.Table("users u").
+		Select(`u.id AS user_id, u.username, u.phone_number, u.subscription_plan AS plan,
+			COALESCE(c.chats, 0) AS chats, COALESCE(q.questions, 0) AS questions,
+			COALESCE(j.charged, 0) AS charged, q.last_question_at`).
+		Joins("LEFT JOIN (?) c ON c.user_id = u.id", chats).
+		Joins("LEFT JOIN (?) q ON q.user_id = u.id", questions).
+		Joins("LEFT JOIN (?) j ON j.user_id = u.id", charges).
+		Where("u.deleted_at IS NULL").
+		Scopes(filter.userScope("u")).
+		Order("u.id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	return each(db, rows, fn)
+}
+
+// EachCreditTransaction streams balance changes of matching users, oldest first:
+// answer charges, admin top-ups and renewals
+func (r *GormReportsRepository) EachCreditTransaction(ctx context.Context, filter Filter, fn func(*domain.CreditTransaction) error) error {
+	db := r.db.WithContext(ctx)
+
+	transactions := db.Raw(`
+		SELECT j.created_at AS occurred_at, j.user_id, ? AS kind, -j.charge::bigint AS amount,
+			'job:' || j.id AS reference, 0 AS actor_id, '' AS actor_name
+		FROM generation_jobs j
+		WHERE j.status IN (?, ?) AND j.charge > 0
+		UNION ALL
+		SELECT a.created_at, a.target_user_id, a.action,
+			(a.after::jsonb->>'balance')::bigint - (a.before::jsonb->>'balance')::bigint,
+			'audit:' || a.id, a.actor_id, a.actor_name
+		FROM audit_events a
+		WHERE a.action IN (?, ?) AND a.target_user_id IS NOT NULL`,
+		domain.CreditKindCharge, domain.JobStatusCompleted, domain.JobStatusCancelled,
+		domain.CreditKindTopUp, domain.CreditKindRenewal)
+
+	rows, err := db.Table("(?) AS t", transactions).
+		Select("t.*, u.username, u.phone_number").
+		Joins("JOIN users u ON u.id = t.user_id").
+		Where("u.deleted_at IS NULL").
+		Scopes(filter.userScope("u"), filter.activityScope("t.occurred_at")).
+		Order("t.occurred_at, t.reference").
+		Rows()
+	if err != nil {
+		return err
+	}
+	return each(db, rows, fn)
+}
+
+// each scans rows into T one at a time and closes them
+func each[T any](db *gorm.DB, rows *sql.Rows, fn func(*T) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		var row T
+		if err := db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// userScope applies the user filters to the users table or alias
+func (f Filter) userScope(table string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if f.Plan != "" {
+			db = db.Where(table+".subscription_plan = ?", f.Plan)
+		}
+		if f.Status != "" {
+			db = db.Where(table+".status = ?", f.Status)
+		}
+		if !f.RegisteredFrom.IsZero() {
+			db = db.Where(table+".created_at >= ?", f.RegisteredFrom)
+		}
+		if !f.RegisteredTo.IsZero() {
+			db = db.Where(table+".created_at < ?", f.RegisteredTo)
+		}
+		if f.MinBalance != nil {
+			db = db.Where(table+".character_balance >= ?", *f.MinBalance)
+		}
+		if f.MaxBalance != nil {
+			db = db.Where(table+".character_balance <= ?", *f.MaxBalance)
+		}
+		return db
+	}
+}
+
+// activityScope bounds a timestamp column by the activity window
+func (f Filter) activityScope(column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !f.From.IsZero() {
+			db = db.Where(column+" >= ?", f.From)
+		}
+		if !f.To.IsZero() {
+			db = db.Where(column+" < ?", f.To)
+		}
+		return db
+	}
+}
@@ -0,0 +1,43 @@
+// File: internal/repository/transaction/transaction.go
+package transaction
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Transactor runs work that spans several repositories atomically. Repositories
+// called with the context handed to fn join the transaction (see DB).
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// GormTransactor implements Transactor using GORM
+type GormTransactor struct {
+	db *gorm.DB
+}
+
+// NewGormTransactor creates a new transactor
+func NewGormTransactor(db *gorm.DB) Transactor {
+	return &GormTransactor{db: db}
+}
+
+// WithinTransaction commits if fn returns nil and rolls back otherwise. Nested calls
+// join the outer transaction through a savepoint.
+func (t *GormTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return DB(ctx, t.db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// DB returns the transaction carried by ctx, or db when there is none, bound to ctx.
+// Repositories use it in place of db.WithContext(ctx).
+func DB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -6,9 +6,10 @@ import (
     "context"
     "errors"
     "fmt"
-    "log"
+    "log/slog"
     "strings"
     "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/repository/transaction"
     "gorm.io/gorm"
 )
 
@@ -21,8 +22,8 @@ func (r *gormUserRepository) FindByPhoneNumber(ctx context.Context, phoneNumber
     }
     
     var user domain.User
-    err := r.db.WithContext(ctx).Where("phone_number = ?", phoneNumber).First(&user).Error
-    return r.handleFindError(err, &user)
+    err := transaction.DB(ctx, r.db).Where("phone_number = ?", phoneNumber).First(&user).Error
+    return r.handleFindError(ctx, err, &user)
 }
 
 
@@ -42,18 +43,18 @@ func NewGormUserRepository(db *gorm.DB) UserRepository {
 func (r *gormUserRepository) Create(ctx context.Context, user *domain.User) (*domain.User, error) {
     // Input validation (SQL injection protection)
     if err := r.validateUserInput(user); err != nil {
-        log.Printf("[UserRepository] Validation failed: %v", err)
+        slog.ErrorContext(ctx, "user validation failed", "error", err)
         return nil, fmt.Errorf("validation failed: %w", err)
     }
     
-    if err := r.db.WithContext(ctx).Create(user).Error; err != nil {
+    if err := transaction.DB(ctx, r.db).Create(user).Error; err != nil {
         // Secure logging - no sensitive data exposed
-        log.Printf("[UserRepository] Database error during user creation: %v", err)
+        slog.ErrorContext(ctx, "database error during user creation", "error", err)
         return nil, errors.New("database error creating user")
     }
     
     // Success logging with safe information
-    log.Printf("[UserRepository] User created successfully with ID: %d", user.ID)
+    slog.InfoContext(ctx, "user created", "user_id", user.ID)
     return user, nil
 }
 
@@ -67,12 +68,12 @@ func (r *gormUserRepository) Update(ctx context.Context, user *domain.User) erro
         return fmt.Errorf("validation failed: %w", err)
     }
     
-    if err := r.db.WithContext(ctx).Save(user).Error; err != nil {
-        log.Printf("[UserRepository] Database error during user update for ID %d: %v", user.ID, err)
+    if err := transaction.DB(ctx, r.db).Save(user).Error; err != nil {
+        slog.ErrorContext(ctx, "database error during user update", "user_id", user.ID, "error", err)
         return errors.New("database error updating user")
     }
     
-    log.Printf("[UserRepository] User updated successfully with ID: %d", user.ID)
+    slog.InfoContext(ctx, "user updated", "user_id", user.ID)
     return nil
 }
 
@@ -83,8 +84,8 @@ func (r *gormUserRepository) FindByID(ctx context.Context, id uint) (*domain.Use
     }
     
     var user domain.User
-    err := r.db.WithContext(ctx).First(&user, id).Error
-    return r.handleFindError(err, &user)
+    err := transaction.DB(ctx, r.db).First(&user, id).Error
+    return r.handleFindError(ctx, err, &user)
 }
 
 // FindByUsername - Enhanced with input validation
@@ -94,8 +95,8 @@ func (r *gormUserRepository) FindByUsername(ctx context.Context, username string
     }
     
     var user domain.User
-    err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
-    return r.handleFindError(err, &user)
+    err := transaction.DB(ctx, r.db).Where("username = ?", username).First(&user).Error
+    return r.handleFindError(ctx, err, &user)
 }
 
 // FindByUsernameOrPhone - Enhanced with validation
@@ -108,8 +109,8 @@ func (r *gormUserRepository) FindByUsernameOrPhone(ctx context.Context, username
     }
     
     var user domain.User
-    err := r.db.WithContext(ctx).Where("username = ? OR phone_number = ?", username, phone).First(&user).Error
-    return r.handleFindError(err, &user)
+    err := transaction.DB(ctx, r.db).Where("username = ? OR phone_number = ?", username, phone).First(&user).Error
+    return r.handleFindError(ctx, err, &user)
 }
 
 // FindByPhoneAndStatus - Enhanced with validation
@@ -119,8 +120,8 @@ func (r *gormUserRepository) FindByPhoneAndStatus(ctx context.Context, phone str
     }
     
     var user domain.User
-    err := r.db.WithContext(ctx).Where("phone_number = ? AND status = ?", phone, status).First(&user).Error
-    return r.handleFindError(err, &user)
+    err := transaction.DB(ctx, r.db).Where("phone_number = ? AND status = ?", phone, status).First(&user).Error
+    return r.handleFindError(ctx, err, &user)
 }
 
 // FindByPhone - Enhanced with validation
@@ -130,8 +131,8 @@ func (r *gormUserRepository) FindByPhone(ctx context.Context, phone string) (*do
     }
     
     var user domain.User
-    err := r.db.WithContext(ctx).Where("phone_number = ?", phone).First(&user).Error
-    return r.handleFindError(err, &user)
+    err := transaction.DB(ctx, r.db).Where("phone_number = ?", phone).First(&user).Error
+    return r.handleFindError(ctx, err, &user)
 }
 
 // ResetFailedAttempts - Enhanced with validation and atomic operation
@@ -140,12 +141,12 @@ func (r *gormUserRepository) ResetFailedAttempts(ctx context.Context, id uint) e
         return errors.New("invalid user ID")
     }
     
-    result := r.db.WithContext(ctx).Model(&domain.User{}).
+    result := transaction.DB(ctx, r.db).Model(&domain.User{}).
         Where("id = ?", id).
         Update("failed_login_attempts", 0)
     
     if result.Error != nil {
-        log.Printf("[UserRepository] Database error resetting failed attempts for user ID %d: %v", id, result.Error)
+        slog.ErrorContext(ctx, "database error resetting failed attempts", "user_id", id, "error", result.Error)
         return errors.New("database error resetting failed attempts")
     }
     
@@ -162,9 +163,9 @@ func (r *gormUserRepository) Delete(ctx context.Context, userID uint) error {
         return errors.New("invalid user ID")
     }
     
-    result := r.db.WithContext(ctx).Delete(&domain.User{}, userID)
+    result := transaction.DB(ctx, r.db).Delete(&domain.User{}, userID)
     if result.Error != nil {
-        log.Printf("[UserRepository] Database error deleting user ID %d: %v", userID, result.Error)
+        slog.ErrorContext(ctx, "database error deleting user", "user_id", userID, "error", result.Error)
         return errors.New("database error deleting user")
     }
     
@@ -172,7 +173,42 @@ func (r *gormUserRepository) Delete(ctx context.Context, userID uint) error {
         return ErrUserNotFound
     }
     
-    log.Printf("[UserRepository] User deleted successfully with ID: %d", userID)
+    slog.InfoContext(ctx, "user deleted", "user_id", userID)
+    return nil
+}
+
+// FindDeletedByID - Finds a soft-deleted user, whose data is retained until restored
+func (r *gormUserRepository) FindDeletedByID(ctx context.Context, id uint) (*domain.User, error) {
+    if id == 0 {
+        return nil, errors.New("invalid user ID")
+    }
+    
+    var user domain.User
+    err := transaction.DB(ctx, r.db).Unscoped().
+        Where("id = ? AND deleted_at IS NOT NULL", id).
+        First(&user).Error
+    return r.handleFindError(ctx, err, &user)
+}
+
+// Restore - Brings back a soft-deleted user with all of their retained data
+func (r *gormUserRepository) Restore(ctx context.Context, userID uint) error {
+    if userID == 0 {
+        return errors.New("invalid user ID")
+    }
+    
+    result := transaction.DB(ctx, r.db).Unscoped().Model(&domain.User{}).
+        Where("id = ? AND deleted_at IS NOT NULL", userID).
+        Update("deleted_at", nil)
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error restoring user", "user_id", userID, "error", result.Error)
+        return errors.New("database error restoring user")
+    }
+    
+    if result.RowsAffected == 0 {
+        return ErrUserNotFound
+    }
+    
+    slog.InfoContext(ctx, "user restored", "user_id", userID)
     return nil
 }
 
@@ -183,7 +219,7 @@ func (r *gormUserRepository) GetCharacterBalance(ctx context.Context, userID uin
     }
     
     var balance int
-    err := r.db.WithContext(ctx).Model(&domain.User{}).
+    err := transaction.DB(ctx, r.db).Model(&domain.User{}).
         Select("character_balance").
         Where("id = ?", userID).
         Scan(&balance).Error
@@ -192,7 +228,7 @@ func (r *gormUserRepository) GetCharacterBalance(ctx context.Context, userID uin
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return 0, ErrUserNotFound
         }
-        log.Printf("[UserRepository] Database error getting balance for user ID %d: %v", userID, err)
+        slog.ErrorContext(ctx, "database error getting balance", "user_id", userID, "error", err)
         return 0, errors.New("database error getting character balance")
     }
     
@@ -209,12 +245,12 @@ func (r *gormUserRepository) UpdateCharacterBalance(ctx context.Context, userID
         return errors.New("balance cannot be negative")
     }
     
-    result := r.db.WithContext(ctx).Model(&domain.User{}).
+    result := transaction.DB(ctx, r.db).Model(&domain.User{}).
         Where("id = ?", userID).
         Update("character_balance", newBalance)
     
     if result.Error != nil {
-        log.Printf("[UserRepository] Database error updating balance for user ID %d: %v", userID, result.Error)
+        slog.ErrorContext(ctx, "database error updating balance", "user_id", userID, "error", result.Error)
         return errors.New("database error updating character balance")
     }
     
@@ -225,14 +261,58 @@ func (r *gormUserRepository) UpdateCharacterBalance(ctx context.Context, userID
     return nil
 }
 
+// UpdatePreferredLanguage sets the user's response language without touching other columns
+func (r *gormUserRepository) UpdatePreferredLanguage(ctx context.Context, userID uint, lang string) error {
+    if userID == 0 {
+        return errors.New("invalid user ID")
+    }
+
+    result := transaction.DB(ctx, r.db).Model(&domain.User{}).
+        Where("id = ?", userID).
+        Update("preferred_language", lang)
+
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error updating language", "user_id", userID, "error", result.Error)
+        return errors.New("database error updating preferred language")
+    }
+
+    if result.RowsAffected == 0 {
+        return ErrUserNotFound
+    }
+
+    return nil
+}
+
+// UpdateSMSNotifications turns the user's SMS notifications on or off
+func (r *gormUserRepository) UpdateSMSNotifications(ctx context.Context, userID uint, enabled bool) error {
+    if userID == 0 {
+        return errors.New("invalid user ID")
+    }
+
+    result := transaction.DB(ctx, r.db).Model(&domain.User{}).
+        Where("id = ?", userID).
+        Update("sms_notifications", enabled)
+
+    if result.Error != nil {
+        slog.ErrorContext(ctx, "database error updating SMS notifications", "user_id", userID, "error", result.Error)
+        return errors.New("database error updating SMS notifications")
+    }
+
+    if result.RowsAffected == 0 {
+        return ErrUserNotFound
+    }
+
+    return nil
+}
+
 // FindAll - Enhanced with memory safety warning (deprecated in favor of pagination)
 func (r *gormUserRepository) FindAll(ctx context.Context) ([]domain.User, error) {
-    log.Printf("[UserRepository] WARNING: FindAll() loads all users into memory. Use FindAllWithPagination() for production.")
+    slog.WarnContext(ctx, "FindAll loads all users into memory, use FindAllWithPagination in production")
     
     var users []domain.User
-    err := r.db.WithContext(ctx).Find(&users).Error
+    err := transaction.DB(ctx, r.db).Find(&users).Error
     if err != nil {
-        log.Printf("[UserRepository] Database error finding all users: %v", err)
+        slog.ErrorContext(ctx, "database error finding all users", "error", err)
         return nil, errors.New("database error retrieving users")
     }
     
@@ -255,20 +335,20 @@ func (r *gormUserRepository) FindAllWithPagination(ctx context.Context, limit, o
     }
     
     // Efficient counting without loading data
-    if err := r.db.WithContext(ctx).Model(&domain.User{}).Count(&total).Error; err != nil {
-        log.Printf("[UserRepository] Database error counting users: %v", err)
+    if err := transaction.DB(ctx, r.db).Model(&domain.User{}).Count(&total).Error; err != nil {
+        slog.ErrorContext(ctx, "database error counting users", "error", err)
         return nil, 0, errors.New("database error counting users")
     }
     
     // Load only requested page
-    err := r.db.WithContext(ctx).
+    err := transaction.DB(ctx, r.db).
         Order("id asc").
         Limit(limit).
         Offset(offset).
         Find(&users).Error
     
     if err != nil {
-        log.Printf("[UserRepository] Database error in paginated query: %v", err)
+        slog.ErrorContext(ctx, "database error in paginated query", "error", err)
         return nil, 0, errors.New("database error retrieving paginated users")
     }
     
@@ -301,42 +381,44 @@ func (r *gormUserRepository) CreateInBatch(ctx context.Context, users []*domain.
         }
         
         batch := users[i:end]
-        if err := r.db.WithContext(ctx).CreateInBatches(batch, batchSize).Error; err != nil {
-            log.Printf("[UserRepository] Batch creation failed for batch %d-%d: %v", i, end, err)
+        if err := transaction.DB(ctx, r.db).CreateInBatches(batch, batchSize).Error; err != nil {
+            slog.ErrorContext(ctx, "batch user creation failed", "from", i, "to", end, "error", err)
             return fmt.Errorf("database error creating batch %d-%d: %w", i, end, err)
         }
     }
     
-    log.Printf("[UserRepository] Successfully created %d users in batches", len(users))
+    slog.InfoContext(ctx, "created users in batches", "count", len(users))
     return nil
 }
 
-// ExistsByUsername - Security: check without exposing data
+// ExistsByUsername - Security: check without exposing data. Soft-deleted users count,
+// since they keep their username until restored.
 func (r *gormUserRepository) ExistsByUsername(ctx context.Context, username string) (bool, error) {
     if err := r.validateUsername(username); err != nil {
         return false, err
     }
     
     var count int64
-    err := r.db.WithContext(ctx).Model(&domain.User{}).Where("username = ?", username).Count(&count).Error
+    err := transaction.DB(ctx, r.db).Unscoped().Model(&domain.User{}).Where("username = ?", username).Count(&count).Error
     if err != nil {
-        log.Printf("[UserRepository] Database error checking username existence: %v", err)
+        slog.ErrorContext(ctx, "database error checking username existence", "error", err)
         return false, errors.New("database error checking username existence")
     }
     
     return count > 0, nil
 }
 
-// ExistsByPhone - Security: check without exposing data
+// ExistsByPhone - Security: check without exposing data. Soft-deleted users count,
+// since they keep their phone number until restored.
 func (r *gormUserRepository) ExistsByPhone(ctx context.Context, phone string) (bool, error) {
     if err := r.validatePhone(phone); err != nil {
         return false, err
     }
     
     var count int64
-    err := r.db.WithContext(ctx).Model(&domain.User{}).Where("phone_number = ?", phone).Count(&count).Error
+    err := transaction.DB(ctx, r.db).Unscoped().Model(&domain.User{}).Where("phone_number = ?", phone).Count(&count).Error
     if err != nil {
-        log.Printf("[UserRepository] Database error checking phone existence: %v", err)
+        slog.ErrorContext(ctx, "database error checking phone existence", "error", err)
         return false, errors.New("database error checking phone existence")
     }
     
@@ -346,9 +428,9 @@ func (r *gormUserRepository) ExistsByPhone(ctx context.Context, phone string) (b
 // CountUsers - Performance: efficient counting
 func (r *gormUserRepository) CountUsers(ctx context.Context) (int64, error) {
     var count int64
-    err := r.db.WithContext(ctx).Model(&domain.User{}).Count(&count).Error
+    err := transaction.DB(ctx, r.db).Model(&domain.User{}).Count(&count).Error
     if err != nil {
-        log.Printf("[UserRepository] Database error counting users: %v", err)
+        slog.ErrorContext(ctx, "database error counting users", "error", err)
         return 0, errors.New("database error counting users")
     }
     return count, nil
@@ -357,9 +439,9 @@ func (r *gormUserRepository) CountUsers(ctx context.Context) (int64, error) {
 // CountActiveUsers - Performance: efficient filtered counting
 func (r *gormUserRepository) CountActiveUsers(ctx context.Context) (int64, error) {
     var count int64
-    err := r.db.WithContext(ctx).Model(&domain.User{}).Where("status = ?", "active").Count(&count).Error
+    err := transaction.DB(ctx, r.db).Model(&domain.User{}).Where("status = ?", "active").Count(&count).Error
     if err != nil {
-        log.Printf("[UserRepository] Database error counting active users: %v", err)
+        slog.ErrorContext(ctx, "database error counting active users", "error", err)
         return 0, errors.New("database error counting active users")
     }
     return count, nil
@@ -371,12 +453,12 @@ func (r *gormUserRepository) IncrementFailedAttempts(ctx context.Context, userID
         return errors.New("invalid user ID")
     }
     
-    result := r.db.WithContext(ctx).Model(&domain.User{}).
+    result := transaction.DB(ctx, r.db).Model(&domain.User{}).
         Where("id = ?", userID).
         Update("failed_login_attempts", gorm.Expr("failed_login_attempts + 1"))
     
     if result.Error != nil {
-        log.Printf("[UserRepository] Database error incrementing failed attempts for user ID %d: %v", userID, result.Error)
+        slog.ErrorContext(ctx, "database error incrementing failed attempts", "user_id", userID, "error", result.Error)
         return errors.New("database error incrementing failed attempts")
     }
     
@@ -394,7 +476,7 @@ func (r *gormUserRepository) UpdateMultipleBalances(ctx context.Context, updates
     }
     
     // Use transaction for atomicity
-    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+    return transaction.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
         for _, update := range updates {
             if update.UserID == 0 {
                 return errors.New("invalid user ID in balance update")
@@ -416,6 +498,29 @@ func (r *gormUserRepository) UpdateMultipleBalances(ctx context.Context, updates
     })
 }
 
+// ApplyBulkChanges creates users, changes plans and adjusts balances in one
+// transaction: either every change is saved or none is
+func (r *gormUserRepository) ApplyBulkChanges(ctx context.Context, creates []*domain.User, plans []domain.PlanUpdate, balances []domain.BalanceUpdate) error {
+    return transaction.DB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+        txRepo := &gormUserRepository{db: tx}
+        if err := txRepo.CreateInBatch(ctx, creates, 100); err != nil {
+            return err
+        }
+        for _, update := range plans {
+            result := tx.Model(&domain.User{}).
+                Where("id = ?", update.UserID).
+                Update("subscription_plan", update.Plan)
+            if result.Error != nil {
+                return fmt.Errorf("failed to update plan for user %d: %w", update.UserID, result.Error)
+            }
+            if result.RowsAffected == 0 {
+                return fmt.Errorf("user %d not found for plan update", update.UserID)
+            }
+        }
+        return txRepo.UpdateMultipleBalances(ctx, balances)
+    })
+}
+
 // ===== SECURITY VALIDATION HELPERS =====
 
 // validateUserInput - Comprehensive input validation
@@ -477,7 +582,7 @@ func (r *gormUserRepository) validatePhone(phone string) error {
 // ===== ERROR HANDLING HELPERS =====
 
 // handleFindError - Secure error handling without data leakage
-func (r *gormUserRepository) handleFindError(err error, user *domain.User) (*domain.User, error) {
+func (r *gormUserRepository) handleFindError(ctx context.Context, err error, user *domain.User) (*domain.User, error) {
     if err == nil {
         return user, nil
     }
@@ -487,7 +592,7 @@ func (r *gormUserRepository) handleFindError(err error, user *domain.User) (*dom
     }
     
     // Log technical details for debugging
-    log.Printf("[UserRepository] Database query error: %v", err)
+    slog.ErrorContext(ctx, "user query failed", "error", err)
     
     // Return generic error for security
     return nil, errors.New("database query failed")
@@ -499,7 +604,7 @@ func (r *gormUserRepository) FindAllWithPaginationAndSearch(ctx context.Context,
 	var total int64
 
 	// Build the base query
-	query := r.db.WithContext(ctx).Model(&domain.User{})
+	query := transaction.DB(ctx, r.db).Model(&domain.User{})
 
 	// Apply search filter if a search term is provided
 	if search != "" {
@@ -510,7 +615,7 @@ func (r *gormUserRepository) FindAllWithPaginationAndSearch(ctx context.Context,
 
 	// First, count the total number of records that match the query
 	if err := query.Count(&total).Error; err != nil {
-		log.Printf("[UserRepository] Database error counting users with search: %v", err)
+		slog.ErrorContext(ctx, "database error counting users with search", "error", err)
 		return nil, 0, errors.New("database error counting users")
 	}
 
@@ -518,7 +623,7 @@ func (r *gormUserRepository) FindAllWithPaginationAndSearch(ctx context.Context,
 	offset := (page - 1) * limit
 	err := query.Order("id asc").Limit(limit).Offset(offset).Find(&users).Error
 	if err != nil {
-		log.Printf("[UserRepository] Database error in paginated search query: %v", err)
+		slog.ErrorContext(ctx, "database error in paginated search query", "error", err)
 		return nil, 0, errors.New("database error retrieving paginated users")
 	}
 
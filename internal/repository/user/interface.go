@@ -19,8 +19,12 @@ type UserRepository interface {
     FindByPhoneNumber(ctx context.Context, phoneNumber string) (*domain.User, error) // ADD THIS LINE
     ResetFailedAttempts(ctx context.Context, id uint) error
     Delete(ctx context.Context, userID uint) error
+    FindDeletedByID(ctx context.Context, id uint) (*domain.User, error)
+    Restore(ctx context.Context, userID uint) error
     GetCharacterBalance(ctx context.Context, userID uint) (int, error)
     UpdateCharacterBalance(ctx context.Context, userID uint, newBalance int) error
+    UpdatePreferredLanguage(ctx context.Context, userID uint, lang string) error
+    UpdateSMSNotifications(ctx context.Context, userID uint, enabled bool) error
     FindAll(ctx context.Context) ([]domain.User, error)
 
     // ===== NEW PRODUCTION-READY METHODS =====
@@ -32,5 +36,6 @@ type UserRepository interface {
     CountActiveUsers(ctx context.Context) (int64, error)
     IncrementFailedAttempts(ctx context.Context, userID uint) error
     UpdateMultipleBalances(ctx context.Context, updates []domain.BalanceUpdate) error
+    ApplyBulkChanges(ctx context.Context, creates []*domain.User, plans []domain.PlanUpdate, balances []domain.BalanceUpdate) error
     FindAllWithPaginationAndSearch(ctx context.Context, page, limit int, search string) ([]domain.User, int64, error)
 }
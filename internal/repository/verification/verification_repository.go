@@ -4,12 +4,13 @@ package verification
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"time"
 
 	"gorm.io/gorm"
 
 	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
 )
 
 // VerificationRepository interface for verification code operations
@@ -19,6 +20,18 @@ type VerificationRepository interface {
 	DeleteByPhone(ctx context.Context, phone string, codeType domain.VerificationCodeType) error
 	Update(ctx context.Context, verification *domain.VerificationCode) error
 	DeleteExpired(ctx context.Context) error
+
+	// Send log for resend limits and SMS-pumping detection
+	RecordSend(ctx context.Context, send *domain.VerificationSend) error
+	LastSendAt(ctx context.Context, phone string) (*time.Time, error)
+	CountSends(ctx context.Context, phone string, since time.Time) (int64, error)
+	CountPhonesByIP(ctx context.Context, clientIP, excludePhone string, since time.Time) (int64, error)
+	CountPhonesByPrefix(ctx context.Context, prefix, excludePhone string, since time.Time) (int64, error)
+	DeleteSendsBefore(ctx context.Context, before time.Time) error
+
+	// WithPhoneLock runs fn in a transaction that holds a lock on the phone number, so
+	// concurrent sends to one number are checked and recorded one after the other
+	WithPhoneLock(ctx context.Context, phone string, fn func(repo VerificationRepository) error) error
 }
 
 // GormVerificationRepository implements VerificationRepository using GORM
@@ -43,7 +56,7 @@ func (r *GormVerificationRepository) Create(ctx context.Context, verification *d
 
 	err := r.db.WithContext(ctx).Create(verification).Error
 	if err == nil {
-		log.Printf("[VerificationRepository] Created verification code for phone: %s, type: %s", verification.PhoneNumber, verification.Type)
+		slog.InfoContext(ctx, "created verification code", "phone", logging.MaskPhone(verification.PhoneNumber), "type", verification.Type)
 	}
 	return err
 }
@@ -75,7 +88,7 @@ func (r *GormVerificationRepository) DeleteByPhone(ctx context.Context, phone st
 	}
 
 	if result.RowsAffected > 0 {
-		log.Printf("[VerificationRepository] Deleted %d verification code(s) for phone: %s, type: %s", result.RowsAffected, phone, codeType)
+		slog.InfoContext(ctx, "deleted verification codes", "count", result.RowsAffected, "phone", logging.MaskPhone(phone), "type", codeType)
 	}
 
 	return nil
@@ -89,16 +102,17 @@ func (r *GormVerificationRepository) Update(ctx context.Context, verification *d
 
 	err := r.db.WithContext(ctx).Save(verification).Error
 	if err == nil {
-		log.Printf("[VerificationRepository] Updated verification code for phone: %s, type: %s", verification.PhoneNumber, verification.Type)
+		slog.InfoContext(ctx, "updated verification code", "phone", logging.MaskPhone(verification.PhoneNumber), "type", verification.Type)
 	}
 	return err
 }
 
-// DeleteExpired removes expired verification codes (cleanup job)
+// DeleteExpired permanently removes expired codes and codes already deleted after use
+// (cleanup job). Soft-deleted rows are purged too, since they only hold dead hashes.
 func (r *GormVerificationRepository) DeleteExpired(ctx context.Context) error {
 	now := time.Now()
-	result := r.db.WithContext(ctx).
-		Where("expires_at < ?", now).
+	result := r.db.WithContext(ctx).Unscoped().
+		Where("expires_at < ? OR deleted_at IS NOT NULL", now).
 		Delete(&domain.VerificationCode{})
 
 	if result.Error != nil {
@@ -106,10 +120,88 @@ func (r *GormVerificationRepository) DeleteExpired(ctx context.Context) error {
 	}
 
 	if result.RowsAffected > 0 {
-		log.Printf("[VerificationRepository] Deleted %d expired verification code(s) at %v", result.RowsAffected, now)
-	} else {
-		log.Printf("[VerificationRepository] No expired verification codes to delete at %v", now)
+		slog.InfoContext(ctx, "deleted expired verification codes", "count", result.RowsAffected, "before", now)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// RecordSend logs a code request, sent or blocked
+func (r *GormVerificationRepository) RecordSend(ctx context.Context, send *domain.VerificationSend) error {
+	if send == nil {
+		return errors.New("verification send is nil")
+	}
+	return r.db.WithContext(ctx).Create(send).Error
+}
+
+// LastSendAt returns when a code was last actually sent to the phone, or nil if never
+func (r *GormVerificationRepository) LastSendAt(ctx context.Context, phone string) (*time.Time, error) {
+	var send domain.VerificationSend
+	err := r.db.WithContext(ctx).
+		Where("phone_number = ? AND blocked = ''", phone).
+		Order("created_at DESC").
+		First(&send).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &send.CreatedAt, nil
+}
+
+// CountSends counts codes actually sent to the phone since the given time
+func (r *GormVerificationRepository) CountSends(ctx context.Context, phone string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.VerificationSend{}).
+		Where("phone_number = ? AND blocked = '' AND created_at >= ?", phone, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CountPhonesByIP counts the other phone numbers the client IP asked codes for since
+// the given time, including refused requests so a blocked client stays blocked
+func (r *GormVerificationRepository) CountPhonesByIP(ctx context.Context, clientIP, excludePhone string, since time.Time) (int64, error) {
+	return r.countPhones(ctx, "client_ip = ?", clientIP, excludePhone, since)
+}
+
+// CountPhonesByPrefix counts the other phone numbers in the number range that codes
+// were asked for since the given time
+func (r *GormVerificationRepository) CountPhonesByPrefix(ctx context.Context, prefix, excludePhone string, since time.Time) (int64, error) {
+	return r.countPhones(ctx, "prefix = ?", prefix, excludePhone, since)
+}
+
+func (r *GormVerificationRepository) countPhones(ctx context.Context, condition, value, excludePhone string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.VerificationSend{}).
+		Where(condition, value).
+		Where("phone_number <> ? AND created_at >= ?", excludePhone, since).
+		Distinct("phone_number").
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteSendsBefore prunes the send log (cleanup job)
+func (r *GormVerificationRepository) DeleteSendsBefore(ctx context.Context, before time.Time) error {
+	result := r.db.WithContext(ctx).
+		Where("created_at < ?", before).
+		Delete(&domain.VerificationSend{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		slog.InfoContext(ctx, "pruned verification send log rows", "count", result.RowsAffected, "before", before)
+	}
+	return nil
+}
+
+// WithPhoneLock takes a transaction-scoped advisory lock keyed on the phone number; it
+// is released when fn returns and the transaction commits or rolls back
+func (r *GormVerificationRepository) WithPhoneLock(ctx context.Context, phone string, fn func(repo VerificationRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "verification_send:"+phone).Error; err != nil {
+			return err
+		}
+		return fn(&GormVerificationRepository{db: tx})
+	})
+}
@@ -5,27 +5,55 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/repository/analytics"
+	"github.com/iyunix/go-internist/internal/repository/audit"
+	"github.com/iyunix/go-internist/internal/repository/chat"
+	"github.com/iyunix/go-internist/internal/repository/message"
+	"github.com/iyunix/go-internist/internal/repository/outbox"
+	"github.com/iyunix/go-internist/internal/repository/reports"
+	"github.com/iyunix/go-internist/internal/repository/transaction"
 	"github.com/iyunix/go-internist/internal/repository/user"
+	"github.com/iyunix/go-internist/internal/services/sms"
 )
 
-type Logger interface {
-	Info(msg string, keysAndValues ...interface{})
-	Error(msg string, keysAndValues ...interface{})
-	Debug(msg string, keysAndValues ...interface{})
-	Warn(msg string, keysAndValues ...interface{})
+// Notifier sends account notifications by SMS, skipping users who opted out
+// (services.SMSService)
+type Notifier interface {
+	Notify(ctx context.Context, user *domain.User, template string, params map[string]string) error
 }
 
+// MaxTopUpAmount caps the credits an admin can add to a balance in one step
+const MaxTopUpAmount = 10000
+
 type AdminService struct {
-	userRepo user.UserRepository
-	logger   Logger
+	userRepo      user.UserRepository
+	messageRepo   message.MessageRepository
+	chatRepo      chat.ChatRepository
+	outboxRepo    outbox.OutboxRepository
+	auditRepo     audit.AuditRepository
+	analyticsRepo analytics.AnalyticsRepository
+	reportsRepo   reports.ReportsRepository
+	transactor    transaction.Transactor
+	notifier      Notifier
+	logger        logging.Logger
 }
 
-func NewAdminService(userRepo user.UserRepository, logger Logger) *AdminService {
+func NewAdminService(userRepo user.UserRepository, messageRepo message.MessageRepository, chatRepo chat.ChatRepository, outboxRepo outbox.OutboxRepository, auditRepo audit.AuditRepository, analyticsRepo analytics.AnalyticsRepository, reportsRepo reports.ReportsRepository, transactor transaction.Transactor, notifier Notifier, logger logging.Logger) *AdminService {
 	return &AdminService{
-		userRepo: userRepo,
-		logger:   logger,
+		userRepo:      userRepo,
+		messageRepo:   messageRepo,
+		chatRepo:      chatRepo,
+		outboxRepo:    outboxRepo,
+		auditRepo:     auditRepo,
+		analyticsRepo: analyticsRepo,
+		reportsRepo:   reportsRepo,
+		transactor:    transactor,
+		notifier:      notifier,
+		logger:        logger,
 	}
 }
 
@@ -55,8 +83,8 @@ func (s *AdminService) GetAllUsers(ctx context.Context, page, limit int, search
 	return users, total, nil
 }
 
-// ChangeUserPlan updates a user's subscription plan. (No changes needed)
-func (s *AdminService) ChangeUserPlan(ctx context.Context, userID uint, newPlan domain.SubscriptionPlan) error {
+// ChangeUserPlan updates a user's subscription plan
+func (s *AdminService) ChangeUserPlan(ctx context.Context, actor Actor, userID uint, newPlan domain.SubscriptionPlan) error {
 	if userID == 0 {
 		s.logger.Warn("attempt to change plan with invalid user ID", "user_id", userID)
 		return errors.New("user ID must be provided")
@@ -73,16 +101,21 @@ func (s *AdminService) ChangeUserPlan(ctx context.Context, userID uint, newPlan
 	}
 	oldPlan := user.SubscriptionPlan
 	user.SubscriptionPlan = newPlan
-	if err := s.userRepo.Update(ctx, user); err != nil {
+	err = s.audited(ctx, actor, domain.AuditActionPlanChange, userID,
+		map[string]interface{}{"plan": oldPlan},
+		map[string]interface{}{"plan": newPlan},
+		func(ctx context.Context) error { return s.userRepo.Update(ctx, user) })
+	if err != nil {
 		s.logger.Error("failed to update user plan", "error", err, "user_id", userID, "new_plan", newPlan)
 		return fmt.Errorf("failed to update user plan: %w", err)
 	}
 	s.logger.Info("user subscription plan changed successfully", "user_id", userID, "old_plan", oldPlan, "new_plan", newPlan)
+	s.notifyPlanActivated(ctx, user)
 	return nil
 }
 
-// RenewSubscription resets a user's balance to their plan's full amount. (No changes needed)
-func (s *AdminService) RenewSubscription(ctx context.Context, userID uint) error {
+// RenewSubscription resets a user's balance to their plan's full amount
+func (s *AdminService) RenewSubscription(ctx context.Context, actor Actor, userID uint) error {
 	if userID == 0 {
 		s.logger.Warn("attempt to renew subscription with invalid user ID", "user_id", userID)
 		return errors.New("user ID must be provided")
@@ -98,19 +131,39 @@ func (s *AdminService) RenewSubscription(ctx context.Context, userID uint) error
 		s.logger.Error("user has unknown subscription plan", "user_id", userID, "unknown_plan", user.SubscriptionPlan)
 		return fmt.Errorf("user has an unknown subscription plan: %s", user.SubscriptionPlan)
 	}
-	oldBalance := user.CharacterBalance
+	oldBalance, oldTotal := user.CharacterBalance, user.TotalCharacterBalance
 	user.CharacterBalance = creditsForPlan
 	user.TotalCharacterBalance = creditsForPlan
-	if err := s.userRepo.Update(ctx, user); err != nil {
+	err = s.audited(ctx, actor, domain.AuditActionRenew, userID,
+		map[string]interface{}{"balance": oldBalance, "total_balance": oldTotal},
+		map[string]interface{}{"balance": creditsForPlan, "total_balance": creditsForPlan, "plan": user.SubscriptionPlan},
+		func(ctx context.Context) error { return s.userRepo.Update(ctx, user) })
+	if err != nil {
 		s.logger.Error("failed to save subscription renewal", "error", err, "user_id", userID)
 		return fmt.Errorf("failed to renew subscription: %w", err)
 	}
 	s.logger.Info("subscription renewed successfully", "user_id", userID, "plan", user.SubscriptionPlan, "old_balance", oldBalance, "new_balance", creditsForPlan)
+	s.notifyPlanActivated(ctx, user)
 	return nil
 }
 
-// TopUpBalance adds credits to a user's current balance. (No changes needed)
-func (s *AdminService) TopUpBalance(ctx context.Context, userID uint, amountToAdd int) error {
+// notifyPlanActivated tells the user their plan is active with its balance. A failed
+// notification does not undo the change.
+func (s *AdminService) notifyPlanActivated(ctx context.Context, user *domain.User) {
+	if s.notifier == nil {
+		return
+	}
+	params := map[string]string{
+		"plan":    user.GetPlanName(),
+		"balance": strconv.Itoa(user.CharacterBalance),
+	}
+	if err := s.notifier.Notify(ctx, user, sms.TemplatePlanActivated, params); err != nil {
+		s.logger.Warn("failed to queue plan notification", "error", err, "user_id", user.ID)
+	}
+}
+
+// TopUpBalance adds credits to a user's current balance
+func (s *AdminService) TopUpBalance(ctx context.Context, actor Actor, userID uint, amountToAdd int) error {
 	if userID == 0 {
 		s.logger.Warn("attempt to top up balance with invalid user ID", "user_id", userID)
 		return errors.New("user ID must be provided")
@@ -119,9 +172,9 @@ func (s *AdminService) TopUpBalance(ctx context.Context, userID uint, amountToAd
 		s.logger.Warn("attempt to top up balance with invalid amount", "user_id", userID, "amount", amountToAdd)
 		return errors.New("amount to add must be a positive number")
 	}
-	if amountToAdd > 10000 {
-		s.logger.Warn("attempt to top up balance with excessive amount", "user_id", userID, "amount", amountToAdd, "max_allowed", 10000)
-		return errors.New("amount to add exceeds maximum allowed (10000)")
+	if amountToAdd > MaxTopUpAmount {
+		s.logger.Warn("attempt to top up balance with excessive amount", "user_id", userID, "amount", amountToAdd, "max_allowed", MaxTopUpAmount)
+		return fmt.Errorf("amount to add exceeds maximum allowed (%d)", MaxTopUpAmount)
 	}
 	s.logger.Info("topping up user balance", "user_id", userID, "amount", amountToAdd)
 	user, err := s.userRepo.FindByID(ctx, userID)
@@ -131,7 +184,11 @@ func (s *AdminService) TopUpBalance(ctx context.Context, userID uint, amountToAd
 	}
 	oldBalance := user.CharacterBalance
 	user.AddCharacters(amountToAdd)
-	if err := s.userRepo.Update(ctx, user); err != nil {
+	err = s.audited(ctx, actor, domain.AuditActionBalanceTopUp, userID,
+		map[string]interface{}{"balance": oldBalance},
+		map[string]interface{}{"balance": user.CharacterBalance, "amount": amountToAdd},
+		func(ctx context.Context) error { return s.userRepo.Update(ctx, user) })
+	if err != nil {
 		s.logger.Error("failed to save balance top-up", "error", err, "user_id", userID, "amount", amountToAdd)
 		return fmt.Errorf("failed to top-up balance: %w", err)
 	}
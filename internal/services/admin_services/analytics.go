@@ -0,0 +1,68 @@
+// File: internal/services/admin_services/analytics.go
+package admin_services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/repository/analytics"
+	"github.com/iyunix/go-internist/internal/repository/message"
+)
+
+var ErrUnknownSeries = errors.New("unknown analytics series")
+
+// AnalyticsSummary holds headline numbers shown above the charts
+type AnalyticsSummary struct {
+	Messages    *message.MessageMetrics `json:"messages"`
+	ActiveChats int64                   `json:"active_chats"` // chats with activity since the range start
+	From        time.Time               `json:"from"`
+	To          time.Time               `json:"to"`
+}
+
+// GetAnalyticsSeries computes one named time series (domain.Series*)
+func (s *AdminService) GetAnalyticsSeries(ctx context.Context, series string, rng analytics.Range) ([]domain.SeriesPoint, error) {
+	var query func(context.Context, analytics.Range) ([]domain.SeriesPoint, error)
+	switch series {
+	case domain.SeriesActiveUsers:
+		query = s.analyticsRepo.ActiveUsers
+	case domain.SeriesQuestions:
+		query = s.analyticsRepo.Questions
+	case domain.SeriesChargesByPlan:
+		query = s.analyticsRepo.ChargesByPlan
+	case domain.SeriesRegistrations:
+		query = s.analyticsRepo.Registrations
+	case domain.SeriesVerificationConversion:
+		query = s.analyticsRepo.VerificationConversion
+	case domain.SeriesRetrievalScore:
+		query = s.analyticsRepo.RetrievalScore
+	case domain.SeriesStageLatency:
+		query = s.analyticsRepo.StageLatency
+	default:
+		return nil, ErrUnknownSeries
+	}
+
+	points, err := query(ctx, rng)
+	if err != nil {
+		s.logger.Error("failed to compute analytics series", "series", series, "error", err)
+		return nil, err
+	}
+	return points, nil
+}
+
+// GetAnalyticsSummary returns system-wide message totals and the number of chats
+// active since the start of the range
+func (s *AdminService) GetAnalyticsSummary(ctx context.Context, rng analytics.Range) (*AnalyticsSummary, error) {
+	metrics, err := s.messageRepo.GetMessageMetrics(ctx)
+	if err != nil {
+		s.logger.Error("failed to compute message metrics", "error", err)
+		return nil, err
+	}
+	activeChats, err := s.chatRepo.CountActiveChats(ctx, rng.From)
+	if err != nil {
+		s.logger.Error("failed to count active chats", "error", err)
+		return nil, err
+	}
+	return &AnalyticsSummary{Messages: metrics, ActiveChats: activeChats, From: rng.From, To: rng.To}, nil
+}
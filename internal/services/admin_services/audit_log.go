@@ -0,0 +1,73 @@
+// File: internal/services/admin_services/audit_log.go
+package admin_services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/repository/audit"
+)
+
+// Actor identifies the admin behind a privileged action, for the audit log
+type Actor struct {
+	UserID    uint
+	Username  string
+	IP        string
+	RequestID string
+}
+
+// recordAudit appends an event. Changes record it through audited, in their own
+// transaction; an action that is not a change must not go ahead if this fails.
+func (s *AdminService) recordAudit(ctx context.Context, actor Actor, action string, targetUserID uint, before, after map[string]interface{}) error {
+	event := &domain.AuditEvent{
+		ActorID:   actor.UserID,
+		ActorName: actor.Username,
+		Action:    action,
+		Before:    before,
+		After:     after,
+		RequestID: actor.RequestID,
+		IP:        actor.IP,
+	}
+	if targetUserID != 0 {
+		event.TargetUserID = &targetUserID
+	}
+	if err := s.auditRepo.Record(ctx, event); err != nil {
+		s.logger.Error("failed to write audit event", "error", err,
+			"action", action, "actor_id", actor.UserID, "target_user_id", targetUserID)
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+	return nil
+}
+
+// audited applies a change and records its audit event in one transaction, so neither
+// is saved without the other
+func (s *AdminService) audited(ctx context.Context, actor Actor, action string, targetUserID uint, before, after map[string]interface{}, change func(ctx context.Context) error) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := change(ctx); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, actor, action, targetUserID, before, after)
+	})
+}
+
+// RecordExport logs that an admin is downloading data, with what is being exported.
+// It runs before any data is sent; the export must not start if it fails.
+func (s *AdminService) RecordExport(ctx context.Context, actor Actor, action string, details map[string]interface{}) error {
+	return s.recordAudit(ctx, actor, action, 0, nil, details)
+}
+
+// GetAuditEvents lists audit events newest first
+func (s *AdminService) GetAuditEvents(ctx context.Context, filter audit.Filter, page, limit int) ([]domain.AuditEvent, int64, error) {
+	events, total, err := s.auditRepo.Find(ctx, filter, limit, (page-1)*limit)
+	if err != nil {
+		s.logger.Error("failed to retrieve audit events", "error", err)
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// EachAuditEvent streams matching audit events oldest first, for exports
+func (s *AdminService) EachAuditEvent(ctx context.Context, filter audit.Filter, fn func(*domain.AuditEvent) error) error {
+	return s.auditRepo.Each(ctx, filter, fn)
+}
@@ -0,0 +1,433 @@
+// File: internal/services/admin_services/bulk_import.go
+package admin_services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/dtos"
+	"github.com/iyunix/go-internist/internal/repository/user"
+	"github.com/iyunix/go-internist/internal/services/sms"
+)
+
+// MaxBulkRows caps the rows of one import file
+const MaxBulkRows = 1000
+
+var (
+	ErrBulkHeader  = errors.New("CSV needs a header row with phone, plan and credits columns (username is optional)")
+	ErrBulkEmpty   = errors.New("CSV has no rows")
+	ErrBulkTooMany = fmt.Errorf("CSV has more than %d rows", MaxBulkRows)
+	ErrBulkInvalid = errors.New("import has invalid rows; nothing was applied")
+)
+
+// Bulk import row actions
+const (
+	BulkActionCreate = "create" // a new account, invited by SMS
+	BulkActionUpdate = "update" // an existing account gets credits and/or a new plan
+)
+
+// BulkRow is one line of an import file as written
+type BulkRow struct {
+	Line     int
+	Phone    string
+	Username string
+	Plan     string
+	Credits  string
+}
+
+// BulkRowResult is what an import does, or would do, with one row
+type BulkRowResult struct {
+	Line     int                     `json:"line"`
+	Phone    string                  `json:"phone"`
+	Action   string                  `json:"action,omitempty"` // BulkAction*; empty when invalid
+	UserID   uint                    `json:"user_id,omitempty"`
+	Username string                  `json:"username,omitempty"`
+	Plan     domain.SubscriptionPlan `json:"plan,omitempty"`
+	OldPlan  domain.SubscriptionPlan `json:"old_plan,omitempty"`
+	Credits  int                     `json:"credits"`
+	Balance  int                     `json:"balance"` // balance after the import
+	Errors   []string                `json:"errors,omitempty"`
+
+	oldBalance int
+}
+
+// BulkReport summarises an import. A dry run validates and reports without saving.
+type BulkReport struct {
+	DryRun  bool            `json:"dry_run"`
+	Applied bool            `json:"applied"`
+	Creates int             `json:"creates"`
+	Updates int             `json:"updates"`
+	Invalid int             `json:"invalid"`
+	Invited int             `json:"invited"`
+	Rows    []BulkRowResult `json:"rows"`
+}
+
+// ParseBulkCSV reads an import file. Columns are found by header name, in any order:
+// phone, plan, credits and an optional username.
+func ParseBulkCSV(r io.Reader) ([]BulkRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrBulkEmpty
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"phone", "plan", "credits"} {
+		if _, ok := columns[required]; !ok {
+			return nil, ErrBulkHeader
+		}
+	}
+	cell := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []BulkRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		row := BulkRow{
+			Line:     line,
+			Phone:    cell(record, "phone"),
+			Username: cell(record, "username"),
+			Plan:     cell(record, "plan"),
+			Credits:  cell(record, "credits"),
+		}
+		if row == (BulkRow{Line: line}) {
+			continue // blank line
+		}
+		if len(rows) == MaxBulkRows {
+			return nil, ErrBulkTooMany
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, ErrBulkEmpty
+	}
+	return rows, nil
+}
+
+// PreviewBulkImport validates every row against the database and reports what an
+// import would do, without changing anything
+func (s *AdminService) PreviewBulkImport(ctx context.Context, rows []BulkRow) (*BulkReport, error) {
+	report := &BulkReport{DryRun: true, Rows: make([]BulkRowResult, 0, len(rows))}
+	phones := map[string]int{}
+	usernames := map[string]int{}
+
+	for _, row := range rows {
+		result := BulkRowResult{Line: row.Line, Phone: row.Phone}
+		invalid := func(format string, args ...interface{}) {
+			result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+		}
+
+		if phone, ok := normalizeBulkPhone(row.Phone); !ok {
+			invalid("invalid phone number")
+		} else if line, dup := phones[phone]; dup {
+			invalid("phone number repeats line %d", line)
+		} else {
+			phones[phone] = row.Line
+			row.Phone = phone
+			result.Phone = phone
+		}
+
+		var plan domain.SubscriptionPlan
+		if row.Plan != "" {
+			p, ok := dtos.GetPlanByValue(strings.ToLower(row.Plan))
+			if !ok {
+				invalid("unknown plan %q", row.Plan)
+			}
+			plan = p
+		}
+
+		if row.Credits != "" {
+			credits, err := strconv.Atoi(row.Credits)
+			switch {
+			case err != nil:
+				invalid("credits must be a whole number")
+			case credits < 0 || credits > MaxTopUpAmount:
+				invalid("credits must be between 0 and %d", MaxTopUpAmount)
+			default:
+				result.Credits = credits
+			}
+		}
+
+		if len(result.Errors) == 0 {
+			existing, err := s.userRepo.FindByPhone(ctx, row.Phone)
+			switch {
+			case errors.Is(err, user.ErrUserNotFound):
+				// A deleted account keeps its phone number until it is restored
+				deleted, err := s.userRepo.ExistsByPhone(ctx, row.Phone)
+				if err != nil {
+					s.logger.Error("failed to look up user for bulk import", "error", err, "line", row.Line)
+					return nil, fmt.Errorf("failed to look up line %d: %w", row.Line, err)
+				}
+				if deleted {
+					invalid("phone number belongs to a deleted account; restore it instead")
+					break
+				}
+				s.planBulkCreate(ctx, row, plan, &result, usernames)
+			case err != nil:
+				s.logger.Error("failed to look up user for bulk import", "error", err, "line", row.Line)
+				return nil, fmt.Errorf("failed to look up line %d: %w", row.Line, err)
+			default:
+				planBulkUpdate(existing, row, plan, &result)
+			}
+		}
+
+		switch {
+		case len(result.Errors) > 0:
+			report.Invalid++
+		case result.Action == BulkActionCreate:
+			report.Creates++
+		default:
+			report.Updates++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+	return report, nil
+}
+
+// planBulkCreate fills in a new account. Without credits it starts with the plan's
+// full allowance.
+func (s *AdminService) planBulkCreate(ctx context.Context, row BulkRow, plan domain.SubscriptionPlan, result *BulkRowResult, usernames map[string]int) {
+	if plan == "" {
+		plan = domain.PlanBasic
+	}
+	username := row.Username
+	if username == "" {
+		username = "u" + strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, row.Phone)
+	}
+
+	switch {
+	case len(username) < 3 || len(username) > 20:
+		result.Errors = append(result.Errors, "username must be between 3 and 20 characters")
+	case usernames[username] != 0:
+		result.Errors = append(result.Errors, fmt.Sprintf("username repeats line %d", usernames[username]))
+	default:
+		usernames[username] = row.Line
+		taken, err := s.userRepo.ExistsByUsername(ctx, username)
+		if err != nil {
+			result.Errors = append(result.Errors, "invalid username")
+		} else if taken {
+			result.Errors = append(result.Errors, fmt.Sprintf("username %q is taken", username))
+		}
+	}
+	if row.Credits != "" && result.Credits == 0 {
+		result.Errors = append(result.Errors, "new accounts need credits above 0; leave credits empty for the plan allowance")
+	}
+	if len(result.Errors) > 0 {
+		return
+	}
+
+	result.Action = BulkActionCreate
+	result.Username = username
+	result.Plan = plan
+	if row.Credits == "" {
+		result.Credits = domain.PlanCredits[plan]
+	}
+	result.Balance = result.Credits
+}
+
+// planBulkUpdate tops up an existing account and moves it to the row's plan, if any
+func planBulkUpdate(existing *domain.User, row BulkRow, plan domain.SubscriptionPlan, result *BulkRowResult) {
+	if row.Username != "" && row.Username != existing.Username {
+		result.Errors = append(result.Errors, fmt.Sprintf("phone number belongs to %q", existing.Username))
+		return
+	}
+	result.Action = BulkActionUpdate
+	result.UserID = existing.ID
+	result.Username = existing.Username
+	result.Plan = existing.SubscriptionPlan
+	if plan != "" && plan != existing.SubscriptionPlan {
+		result.OldPlan = existing.SubscriptionPlan
+		result.Plan = plan
+	}
+	result.oldBalance = existing.CharacterBalance
+	result.Balance = existing.CharacterBalance + result.Credits
+}
+
+// ApplyBulkImport validates the rows again and, if all are valid, saves every change
+// in one transaction. New accounts must set a password through the SMS reset flow and
+// are sent an invitation; a failed invitation does not undo the import.
+func (s *AdminService) ApplyBulkImport(ctx context.Context, actor Actor, rows []BulkRow) (*BulkReport, error) {
+	report, err := s.PreviewBulkImport(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+	report.DryRun = false
+	if report.Invalid > 0 {
+		return report, ErrBulkInvalid
+	}
+
+	var creates []*domain.User
+	var plans []domain.PlanUpdate
+	var balances []domain.BalanceUpdate
+	created := map[int]*domain.User{} // by row index
+	for i, result := range report.Rows {
+		if result.Action == BulkActionCreate {
+			u, err := newInvitedUser(result)
+			if err != nil {
+				return nil, err
+			}
+			creates = append(creates, u)
+			created[i] = u
+			continue
+		}
+		if result.OldPlan != "" {
+			plans = append(plans, domain.PlanUpdate{UserID: result.UserID, Plan: result.Plan})
+		}
+		if result.Credits > 0 {
+			balances = append(balances, domain.BalanceUpdate{UserID: result.UserID, Amount: result.Credits})
+		}
+	}
+
+	// The changes and their audit events are saved together or not at all
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.ApplyBulkChanges(ctx, creates, plans, balances); err != nil {
+			return err
+		}
+		for i := range report.Rows {
+			result := &report.Rows[i]
+			if u, ok := created[i]; ok {
+				result.UserID = u.ID
+				if err := s.recordAudit(ctx, actor, domain.AuditActionUserCreate, u.ID, nil,
+					map[string]interface{}{"plan": u.SubscriptionPlan, "balance": u.CharacterBalance, "source": "bulk_import"}); err != nil {
+					return err
+				}
+				continue
+			}
+			if result.OldPlan != "" {
+				if err := s.recordAudit(ctx, actor, domain.AuditActionPlanChange, result.UserID,
+					map[string]interface{}{"plan": result.OldPlan},
+					map[string]interface{}{"plan": result.Plan, "source": "bulk_import"}); err != nil {
+					return err
+				}
+			}
+			if result.Credits > 0 {
+				if err := s.recordAudit(ctx, actor, domain.AuditActionBalanceTopUp, result.UserID,
+					map[string]interface{}{"balance": result.oldBalance},
+					map[string]interface{}{"balance": result.Balance, "amount": result.Credits, "source": "bulk_import"}); err != nil {
+					return err
+				}
+			}
+		}
+		return s.recordAudit(ctx, actor, domain.AuditActionBulkImport, 0, nil,
+			map[string]interface{}{"rows": len(rows), "creates": report.Creates, "updates": report.Updates})
+	})
+	if err != nil {
+		s.logger.Error("failed to apply bulk import", "error", err, "rows", len(rows), "admin_id", actor.UserID)
+		return nil, fmt.Errorf("failed to apply import: %w", err)
+	}
+	report.Applied = true
+
+	// Invitations go out only once the accounts are committed
+	if s.notifier != nil {
+		for _, u := range created {
+			params := map[string]string{"plan": u.GetPlanName(), "balance": strconv.Itoa(u.CharacterBalance)}
+			if err := s.notifier.Notify(ctx, u, sms.TemplateInvitation, params); err != nil {
+				s.logger.Warn("failed to queue invitation", "error", err, "user_id", u.ID)
+			} else {
+				report.Invited++
+			}
+		}
+	}
+
+	s.logger.Info("bulk import applied", "creates", report.Creates, "updates", report.Updates,
+		"invited", report.Invited, "admin_id", actor.UserID)
+	return report, nil
+}
+
+// normalizeBulkPhone rewrites a mobile number to the 09XXXXXXXXX form registration
+// stores, so 0912..., +98912..., 0098912... and 912... all find the same account.
+// Persian and Arabic digits are read as ASCII; spaces, dashes and brackets are dropped.
+func normalizeBulkPhone(raw string) (string, bool) {
+	var b strings.Builder
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r >= '\u06f0' && r <= '\u06f9':
+			b.WriteRune('0' + r - '\u06f0')
+		case r >= '\u0660' && r <= '\u0669':
+			b.WriteRune('0' + r - '\u0660')
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '(' || r == ')':
+		default:
+			return "", false
+		}
+	}
+	phone := b.String()
+	for _, prefix := range []string{"+98", "0098", "98"} {
+		if strings.HasPrefix(phone, prefix) && len(phone)-len(prefix) == 10 {
+			phone = "0" + phone[len(prefix):]
+			break
+		}
+	}
+	if len(phone) == 10 && phone[0] == '9' {
+		phone = "0" + phone
+	}
+	if len(phone) != 11 || !strings.HasPrefix(phone, "09") || strings.Contains(phone, "+") {
+		return "", false
+	}
+	return phone, true
+}
+
+// newInvitedUser builds an account for a bulk import row. Its password is random and
+// unknown to anyone; the user picks their own through forgot password, which also
+// proves they hold the phone.
+func newInvitedUser(result BulkRowResult) (*domain.User, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(secret)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	now := time.Now()
+	u := domain.NewUser(result.Username, result.Phone, string(hashed))
+	u.Status = domain.UserStatusActive
+	u.IsVerified = true
+	u.VerifiedAt = &now
+	u.PasswordResetRequired = true
+	u.SMSNotifications = true
+	u.SubscriptionPlan = result.Plan
+	u.CharacterBalance = result.Credits
+	u.TotalCharacterBalance = result.Credits
+	return u, nil
+}
@@ -0,0 +1,24 @@
+// File: internal/services/admin_services/exports.go
+package admin_services
+
+import (
+	"context"
+
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/repository/reports"
+)
+
+// EachExportUser streams matching users in ID order, for exports
+func (s *AdminService) EachExportUser(ctx context.Context, filter reports.Filter, fn func(*domain.User) error) error {
+	return s.reportsRepo.EachUser(ctx, filter, fn)
+}
+
+// EachChatUsage streams per-user chat usage within the filter's activity window
+func (s *AdminService) EachChatUsage(ctx context.Context, filter reports.Filter, fn func(*domain.UserChatUsage) error) error {
+	return s.reportsRepo.EachChatUsage(ctx, filter, fn)
+}
+
+// EachCreditTransaction streams balance changes oldest first
+func (s *AdminService) EachCreditTransaction(ctx context.Context, filter reports.Filter, fn func(*domain.CreditTransaction) error) error {
+	return s.reportsRepo.EachCreditTransaction(ctx, filter, fn)
+}
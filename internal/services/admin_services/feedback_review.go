@@ -0,0 +1,56 @@
+// File: internal/services/admin_services/feedback_review.go
+package admin_services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/repository/message"
+)
+
+// GetFeedbackForReview lists rated answers for medical reviewers, newest first. Without a
+// rating it lists thumbs-down answers, which is the review queue.
+func (s *AdminService) GetFeedbackForReview(ctx context.Context, filter message.FeedbackReviewFilter, page, limit int) ([]domain.FeedbackReview, int64, error) {
+	if filter.Rating == 0 {
+		filter.Rating = domain.FeedbackDown
+	}
+	if filter.Category != "" && !domain.IsValidFeedbackCategory(filter.Category) {
+		return nil, 0, errors.New("invalid feedback category")
+	}
+	if filter.ReviewStatus != "" && !domain.IsValidFeedbackReviewStatus(filter.ReviewStatus) {
+		return nil, 0, errors.New("invalid review status")
+	}
+
+	reviews, total, err := s.messageRepo.FindFeedbackForReview(ctx, filter, limit, (page-1)*limit)
+	if err != nil {
+		s.logger.Error("failed to retrieve feedback for review", "error", err)
+		return nil, 0, err
+	}
+	return reviews, total, nil
+}
+
+// ReviewFeedback records a reviewer's verdict on rated feedback; reopening clears it
+func (s *AdminService) ReviewFeedback(ctx context.Context, actor Actor, feedbackID uint, status, note string) (*domain.MessageFeedback, error) {
+	if !domain.IsValidFeedbackReviewStatus(status) {
+		return nil, errors.New("invalid review status")
+	}
+	note = strings.TrimSpace(note)
+	var feedback *domain.MessageFeedback
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		feedback, err = s.messageRepo.UpdateFeedbackReview(ctx, feedbackID, actor.UserID, status, note)
+		if err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, actor, domain.AuditActionFeedbackReview, feedback.UserID, nil,
+			map[string]interface{}{"feedback_id": feedbackID, "review_status": status, "review_note": note})
+	})
+	if err != nil {
+		s.logger.Error("failed to review feedback", "error", err, "feedback_id", feedbackID)
+		return nil, err
+	}
+	s.logger.Info("feedback reviewed", "feedback_id", feedbackID, "reviewer_id", actor.UserID, "status", status)
+	return feedback, nil
+}
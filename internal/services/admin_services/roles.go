@@ -0,0 +1,50 @@
+// File: internal/services/admin_services/roles.go
+package admin_services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/iyunix/go-internist/internal/domain"
+)
+
+var (
+	ErrInvalidRole   = errors.New("invalid role")
+	ErrOwnRoleChange = errors.New("admins cannot change their own role")
+)
+
+// AssignRole gives a user an admin role, or takes it away with an empty role. IsAdmin
+// follows the role, so a superadmin stays an admin in code that predates roles.
+func (s *AdminService) AssignRole(ctx context.Context, actor Actor, userID uint, role string) (*domain.User, error) {
+	if userID == 0 {
+		return nil, errors.New("user ID must be provided")
+	}
+	if role != "" && !domain.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if userID == actor.UserID {
+		s.logger.Warn("admin attempted to change their own role", "user_id", userID, "role", role)
+		return nil, ErrOwnRoleChange
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("failed to find user for role change", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to find user with ID %d: %w", userID, err)
+	}
+	oldRole := user.AdminRole()
+	user.Role = role
+	user.IsAdmin = role == domain.RoleSuperadmin
+	err = s.audited(ctx, actor, domain.AuditActionRoleAssign, userID,
+		map[string]interface{}{"role": oldRole},
+		map[string]interface{}{"role": role},
+		func(ctx context.Context) error { return s.userRepo.Update(ctx, user) })
+	if err != nil {
+		s.logger.Error("failed to update user role", "error", err, "user_id", userID, "role", role)
+		return nil, fmt.Errorf("failed to update user role: %w", err)
+	}
+
+	s.logger.Info("user admin role changed", "user_id", userID, "old_role", oldRole, "new_role", role, "actor_id", actor.UserID)
+	return user, nil
+}
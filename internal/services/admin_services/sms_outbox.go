@@ -0,0 +1,39 @@
+// File: internal/services/admin_services/sms_outbox.go
+package admin_services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/repository/outbox"
+)
+
+// GetRecentSMS lists outbox messages newest first, optionally for one phone number or status
+func (s *AdminService) GetRecentSMS(ctx context.Context, filter outbox.Filter, page, limit int) ([]domain.SMSMessage, int64, error) {
+	switch filter.Status {
+	case "", domain.SMSStatusQueued, domain.SMSStatusSending, domain.SMSStatusSent,
+		domain.SMSStatusDelivered, domain.SMSStatusFailed:
+	default:
+		return nil, 0, errors.New("invalid SMS status")
+	}
+
+	messages, total, err := s.outboxRepo.FindRecent(ctx, filter, limit, (page-1)*limit)
+	if err != nil {
+		s.logger.Error("failed to retrieve SMS outbox", "error", err)
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+// GetSMSPhoneSummary counts sends and failures per phone number over the last window,
+// phones with the most failures first
+func (s *AdminService) GetSMSPhoneSummary(ctx context.Context, window time.Duration, limit int) ([]domain.SMSPhoneSummary, error) {
+	summaries, err := s.outboxRepo.SummarizeByPhone(ctx, time.Now().Add(-window), limit)
+	if err != nil {
+		s.logger.Error("failed to summarize SMS outbox", "error", err)
+		return nil, err
+	}
+	return summaries, nil
+}
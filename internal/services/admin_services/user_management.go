@@ -0,0 +1,227 @@
+// File: internal/services/admin_services/user_management.go
+package admin_services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iyunix/go-internist/internal/domain"
+)
+
+var (
+	ErrOwnAccount         = errors.New("admins cannot perform this action on their own account")
+	ErrTargetIsAdmin      = errors.New("remove the user's admin role first")
+	ErrAlreadySuspended   = errors.New("user is already suspended")
+	ErrNotSuspended       = errors.New("user is not suspended")
+	ErrSuspendReasonLimit = errors.New("suspend reason must be at most 255 characters")
+)
+
+// SuspendUser blocks a user from signing in and ends their sessions until reactivated
+func (s *AdminService) SuspendUser(ctx context.Context, actor Actor, userID uint, reason string) (*domain.User, error) {
+	reason = strings.TrimSpace(reason)
+	if len(reason) > 255 {
+		return nil, ErrSuspendReasonLimit
+	}
+	user, err := s.manageableUser(ctx, actor, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsSuspended() {
+		return nil, ErrAlreadySuspended
+	}
+
+	before := map[string]interface{}{"status": user.Status}
+	now := time.Now()
+	user.Status = domain.UserStatusSuspended
+	user.SuspendedAt = &now
+	user.SuspendReason = reason
+	user.RevokeSessions()
+	err = s.audited(ctx, actor, domain.AuditActionUserSuspend, userID, before,
+		map[string]interface{}{"status": user.Status, "reason": reason},
+		func(ctx context.Context) error { return s.userRepo.Update(ctx, user) })
+	if err != nil {
+		s.logger.Error("failed to suspend user", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to suspend user: %w", err)
+	}
+
+	s.logger.Info("user suspended", "user_id", userID, "admin_id", actor.UserID)
+	return user, nil
+}
+
+// ReactivateUser lifts a suspension
+func (s *AdminService) ReactivateUser(ctx context.Context, actor Actor, userID uint) (*domain.User, error) {
+	user, err := s.manageableUser(ctx, actor, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsSuspended() {
+		return nil, ErrNotSuspended
+	}
+
+	before := map[string]interface{}{"status": user.Status, "reason": user.SuspendReason}
+	user.Status = domain.UserStatusActive
+	user.SuspendedAt = nil
+	user.SuspendReason = ""
+	err = s.audited(ctx, actor, domain.AuditActionUserReactivate, userID, before,
+		map[string]interface{}{"status": user.Status},
+		func(ctx context.Context) error { return s.userRepo.Update(ctx, user) })
+	if err != nil {
+		s.logger.Error("failed to reactivate user", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to reactivate user: %w", err)
+	}
+
+	s.logger.Info("user reactivated", "user_id", userID, "admin_id", actor.UserID)
+	return user, nil
+}
+
+// UnlockUser clears the failed-login lockout set by user_services.LockoutService
+func (s *AdminService) UnlockUser(ctx context.Context, actor Actor, userID uint) (*domain.User, error) {
+	user, err := s.manageableUser(ctx, actor, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := map[string]interface{}{
+		"failed_login_attempts": user.FailedLoginAttempts,
+		"locked_until":          user.LockedUntil,
+	}
+	user.UnlockAccount()
+	user.LastFailedLoginAt = nil
+	err = s.audited(ctx, actor, domain.AuditActionUserUnlock, userID, before,
+		map[string]interface{}{"failed_login_attempts": 0, "locked_until": nil},
+		func(ctx context.Context) error { return s.userRepo.Update(ctx, user) })
+	if err != nil {
+		s.logger.Error("failed to unlock user", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to unlock user: %w", err)
+	}
+
+	s.logger.Info("user unlocked", "user_id", userID, "admin_id", actor.UserID)
+	return user, nil
+}
+
+// ForcePasswordReset signs the user out everywhere and makes them set a new password
+// through the SMS reset flow before they can sign in again
+func (s *AdminService) ForcePasswordReset(ctx context.Context, actor Actor, userID uint) (*domain.User, error) {
+	user, err := s.manageableUser(ctx, actor, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.PasswordResetRequired = true
+	user.RevokeSessions()
+	err = s.audited(ctx, actor, domain.AuditActionForcePasswordReset, userID, nil,
+		map[string]interface{}{"password_reset_required": true},
+		func(ctx context.Context) error { return s.userRepo.Update(ctx, user) })
+	if err != nil {
+		s.logger.Error("failed to force password reset", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to force password reset: %w", err)
+	}
+
+	s.logger.Info("password reset forced", "user_id", userID, "admin_id", actor.UserID)
+	return user, nil
+}
+
+// DeleteUser soft-deletes a user. Their chats, messages and billing history are
+// retained, and RestoreUser brings the account back.
+func (s *AdminService) DeleteUser(ctx context.Context, actor Actor, userID uint) error {
+	user, err := s.manageableUser(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
+	err = s.audited(ctx, actor, domain.AuditActionUserDelete, userID,
+		map[string]interface{}{"username": user.Username, "phone_number": user.PhoneNumber}, nil,
+		func(ctx context.Context) error { return s.userRepo.Delete(ctx, userID) })
+	if err != nil {
+		s.logger.Error("failed to delete user", "error", err, "user_id", userID)
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	s.logger.Info("user soft-deleted", "user_id", userID, "admin_id", actor.UserID)
+	return nil
+}
+
+// RestoreUser undoes DeleteUser
+func (s *AdminService) RestoreUser(ctx context.Context, actor Actor, userID uint) (*domain.User, error) {
+	if userID == 0 {
+		return nil, errors.New("user ID must be provided")
+	}
+	if _, err := s.userRepo.FindDeletedByID(ctx, userID); err != nil {
+		s.logger.Warn("restore requested for a user that is not deleted", "user_id", userID, "error", err)
+		return nil, err
+	}
+	var user *domain.User
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Restore(ctx, userID); err != nil {
+			return err
+		}
+		var err error
+		if user, err = s.userRepo.FindByID(ctx, userID); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, actor, domain.AuditActionUserRestore, userID, nil,
+			map[string]interface{}{"username": user.Username})
+	})
+	if err != nil {
+		s.logger.Error("failed to restore user", "error", err, "user_id", userID)
+		return nil, fmt.Errorf("failed to restore user: %w", err)
+	}
+
+	s.logger.Info("user restored", "user_id", userID, "admin_id", actor.UserID)
+	return user, nil
+}
+
+// StartImpersonation checks that the admin may view the app as the user and audits the
+// start of the session. The caller issues the impersonation token only if this succeeds.
+func (s *AdminService) StartImpersonation(ctx context.Context, actor Actor, userID uint) (*domain.User, error) {
+	user, err := s.manageableUser(ctx, actor, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recordAudit(ctx, actor, domain.AuditActionImpersonationStart, userID, nil,
+		map[string]interface{}{"username": user.Username, "read_only": true}); err != nil {
+		return nil, err
+	}
+	s.logger.Info("impersonation started", "user_id", userID, "admin_id", actor.UserID)
+	return user, nil
+}
+
+// StopImpersonation audits the end of a "view as user" session
+func (s *AdminService) StopImpersonation(ctx context.Context, actor Actor, userID uint) error {
+	if err := s.recordAudit(ctx, actor, domain.AuditActionImpersonationStop, userID, nil, nil); err != nil {
+		return err
+	}
+	s.logger.Info("impersonation stopped", "user_id", userID, "admin_id", actor.UserID)
+	return nil
+}
+
+// RecordImpersonatedRequest audits one request made while viewing as a user
+// (middleware.ImpersonationRecorder). The request must not be served if this fails.
+func (s *AdminService) RecordImpersonatedRequest(ctx context.Context, adminID uint, adminName string, userID uint, method, path, ip, requestID string) error {
+	actor := Actor{UserID: adminID, Username: adminName, IP: ip, RequestID: requestID}
+	return s.recordAudit(ctx, actor, domain.AuditActionImpersonatedRequest, userID, nil,
+		map[string]interface{}{"method": method, "path": path})
+}
+
+// manageableUser loads a user an admin may suspend, delete or view as: never the admin
+// themselves, and never another admin, whose role must be removed first
+func (s *AdminService) manageableUser(ctx context.Context, actor Actor, userID uint) (*domain.User, error) {
+	if userID == 0 {
+		return nil, errors.New("user ID must be provided")
+	}
+	if userID == actor.UserID {
+		s.logger.Warn("admin attempted to manage their own account", "user_id", userID)
+		return nil, ErrOwnAccount
+	}
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.AdminRole() != "" {
+		s.logger.Warn("admin attempted to manage another admin", "user_id", userID, "admin_id", actor.UserID)
+		return nil, ErrTargetIsAdmin
+	}
+	return user, nil
+}
@@ -15,6 +15,7 @@ type Config struct {
     // LLM Configuration  
     LLMKey           string
     LLMBaseURL       string
+    CompletionModel  string // model checked by health probes
     
     // Performance Configuration - ✅ FIXED: Longer timeouts
     Timeout          time.Duration
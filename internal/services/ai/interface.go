@@ -14,6 +14,7 @@ type ProviderStatus struct {
 // EmbeddingProvider handles text embeddings
 type EmbeddingProvider interface {
     CreateEmbedding(ctx context.Context, text string) ([]float32, error)
+    CheckEmbedding(ctx context.Context) error
     HealthCheck(ctx context.Context) error
 }
 
@@ -21,6 +22,7 @@ type EmbeddingProvider interface {
 type CompletionProvider interface {
     GetCompletion(ctx context.Context, model, prompt string) (string, error)
     StreamCompletion(ctx context.Context, model, prompt string, onDelta func(string) error) error
+    CheckCompletion(ctx context.Context) error
     HealthCheck(ctx context.Context) error
 }
 
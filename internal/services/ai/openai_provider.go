@@ -3,7 +3,10 @@ package ai
 
 import (
     "context"
+    "fmt"
     "io"
+    "strings"
+
     openai "github.com/sashabaranov/go-openai"
 )
 
@@ -126,15 +129,67 @@ func (p *OpenAIProvider) StreamCompletion(ctx context.Context, model, prompt str
     }
 }
 
+// CheckEmbedding verifies the embedding endpoint is reachable and serves the
+// configured model. It lists models instead of embedding text, so it is free.
+func (p *OpenAIProvider) CheckEmbedding(ctx context.Context) error {
+    return checkModelListed(ctx, p.embeddingClient, p.config.EmbeddingModel, "embedding_health")
+}
+
+// CheckCompletion verifies the LLM endpoint is reachable and serves the
+// configured completion model without generating any tokens.
+func (p *OpenAIProvider) CheckCompletion(ctx context.Context) error {
+    return checkModelListed(ctx, p.llmClient, p.config.CompletionModel, "completion_health")
+}
+
+// checkModelListed calls GET /models and, when the provider returns a
+// non-empty list, requires the model to be present in it.
+func checkModelListed(ctx context.Context, client *openai.Client, model, operation string) error {
+    models, err := client.ListModels(ctx)
+    if err != nil {
+        return NewProviderError(operation, "failed to list models", err)
+    }
+    if model == "" || len(models.Models) == 0 {
+        return nil
+    }
+    for _, m := range models.Models {
+        if m.ID == model {
+            return nil
+        }
+    }
+    return &AIError{
+        Type:      ErrTypeProvider,
+        Operation: operation,
+        Message:   fmt.Sprintf("model %q not offered by provider", model),
+    }
+}
+
 func (p *OpenAIProvider) HealthCheck(ctx context.Context) error {
-    return nil
+    if err := p.CheckEmbedding(ctx); err != nil {
+        return err
+    }
+    return p.CheckCompletion(ctx)
 }
 
 func (p *OpenAIProvider) GetStatus(ctx context.Context) ProviderStatus {
-    return ProviderStatus{
+    status := ProviderStatus{
         IsHealthy:        true,
         EmbeddingHealthy: true,
         LLMHealthy:       true,
         Message:          "OpenAI provider healthy",
     }
+
+    var problems []string
+    if err := p.CheckEmbedding(ctx); err != nil {
+        status.EmbeddingHealthy = false
+        problems = append(problems, err.Error())
+    }
+    if err := p.CheckCompletion(ctx); err != nil {
+        status.LLMHealthy = false
+        problems = append(problems, err.Error())
+    }
+    if len(problems) > 0 {
+        status.IsHealthy = false
+        status.Message = strings.Join(problems, "; ")
+    }
+    return status
 }
@@ -4,6 +4,7 @@ package services
 import (
     "context"
     "time"
+    "github.com/iyunix/go-internist/internal/logging"
     "github.com/iyunix/go-internist/internal/services/ai"
 )
 
@@ -16,42 +17,43 @@ type AIProviderStatus struct {
     LLMHealthy       bool   `json:"llm_healthy"`
 }
 func (s *AIService) GetProviderStatus() AIProviderStatus {
-    // ✅ Reduce timeout from 10s to 3s for health checks
     ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
     defer cancel()
-    
+
+    // Provider status uses model-listing probes; no embedding or tokens are billed
+    providerStatus := s.provider.GetStatus(ctx)
     status := AIProviderStatus{
-        IsHealthy:        true,
-        Message:          "All AI providers are healthy",
-        EmbeddingHealthy: true,
-        LLMHealthy:       true,
-    }
-    
-    // Test embedding provider
-    _, err := s.provider.CreateEmbedding(ctx, "test")
-    if err != nil {
-        s.logger.Warn("embedding provider health check failed", "error", err)
-        status.EmbeddingHealthy = false
-        status.IsHealthy = false
-        status.Message = "Embedding provider is unhealthy"
+        IsHealthy:        providerStatus.IsHealthy,
+        Message:          providerStatus.Message,
+        EmbeddingHealthy: providerStatus.EmbeddingHealthy,
+        LLMHealthy:       providerStatus.LLMHealthy,
     }
 
-    
-    s.logger.Info("AI provider health check completed", 
+    s.logger.Debug("AI provider health check completed",
         "embedding_healthy", status.EmbeddingHealthy,
         "llm_healthy", status.LLMHealthy,
         "overall_healthy", status.IsHealthy)
-        
+
     return status
 }
 
+// CheckEmbedding probes the embedding model without creating an embedding
+func (s *AIService) CheckEmbedding(ctx context.Context) error {
+    return s.provider.CheckEmbedding(ctx)
+}
+
+// CheckCompletion probes the completion model without generating tokens
+func (s *AIService) CheckCompletion(ctx context.Context) error {
+    return s.provider.CheckCompletion(ctx)
+}
+
 
 type AIService struct {
     provider ai.AIProvider
-    logger   Logger
+    logger   logging.Logger
 }
 
-func NewAIService(provider ai.AIProvider, logger Logger) *AIService {
+func NewAIService(provider ai.AIProvider, logger logging.Logger) *AIService {
     return &AIService{
         provider: provider,
         logger:   logger,
@@ -5,16 +5,18 @@ import (
     "fmt"
     "strings"
     "unicode/utf8"
+
+    "github.com/iyunix/go-internist/internal/logging"
 )
 
 // ContextHelper provides utilities for managing chat context and text processing
 type ContextHelper struct {
     config *Config
-    logger Logger
+    logger logging.Logger
 }
 
 // NewContextHelper creates a new context helper with configuration
-func NewContextHelper(config *Config, logger Logger) *ContextHelper {
+func NewContextHelper(config *Config, logger logging.Logger) *ContextHelper {
     return &ContextHelper{
         config: config,
         logger: logger,
@@ -1,7 +1,14 @@
 // G:\go_internist\internal\services\chat\errors.go
 package chat
 
-import "fmt"
+import (
+    "errors"
+    "fmt"
+)
+
+// ErrStoppedByUser is the cancellation cause of a generation the user stopped, as
+// opposed to a shutdown or timeout; only then is the partial answer kept
+var ErrStoppedByUser = errors.New("generation stopped by the user")
 
 type ErrorType string
 
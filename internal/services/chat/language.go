@@ -0,0 +1,48 @@
+// File: internal/services/chat/language.go
+package chat
+
+import (
+    "unicode"
+
+    "github.com/iyunix/go-internist/internal/domain"
+)
+
+// DetectLanguage guesses the language a question was written in. Persian questions
+// often mix in English drug names and abbreviations, so the script with more letters wins.
+func DetectLanguage(text string) string {
+    var arabicScript, latin int
+    for _, r := range text {
+        switch {
+        case unicode.Is(unicode.Arabic, r):
+            arabicScript++
+        case unicode.Is(unicode.Latin, r):
+            latin++
+        }
+    }
+    if arabicScript > 0 && arabicScript >= latin {
+        return domain.LanguagePersian
+    }
+    return domain.LanguageEnglish
+}
+
+// ResolveLanguage picks the answer language from the user's preference, falling back
+// to the detected question language when the preference is "auto" or unknown.
+func ResolveLanguage(preference, detected string) string {
+    switch preference {
+    case domain.LanguageEnglish, domain.LanguagePersian:
+        return preference
+    }
+    if detected == domain.LanguagePersian {
+        return domain.LanguagePersian
+    }
+    return domain.LanguageEnglish
+}
+
+// languageInstruction is the prompt rule telling the model which language to answer in
+func languageInstruction(language string) string {
+    if language == domain.LanguagePersian {
+        return "- Your reply must be in Persian (Farsi) and concise, organized with headings, bullets, or tables as needed.\n" +
+            "    - Keep medical terms, drug names, doses and lab units in English next to their Persian translation, e.g. \"فشار خون بالا (hypertension)\", \"متفورمین (metformin) 500 mg\"."
+    }
+    return "- Your reply must be in English and concise, organized with headings, bullets, or tables as needed."
+}
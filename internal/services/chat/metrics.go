@@ -0,0 +1,52 @@
+// File: internal/services/chat/metrics.go
+package chat
+
+import (
+    "context"
+    "time"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "github.com/qdrant/go-client/qdrant"
+)
+
+type metricsKey struct{}
+
+// WithMetrics attaches m to ctx so each pipeline stage can record its timing and the
+// retrieval scores into it. The pipeline runs on the caller's goroutine, so m needs no
+// locking as long as it is read after the pipeline returns.
+func WithMetrics(ctx context.Context, m *domain.GenerationMetrics) context.Context {
+    return context.WithValue(ctx, metricsKey{}, m)
+}
+
+// MetricsFromContext returns the metrics attached to ctx, or a throwaway value when
+// nobody is collecting them, so callers never need a nil check
+func MetricsFromContext(ctx context.Context) *domain.GenerationMetrics {
+    if m, ok := ctx.Value(metricsKey{}).(*domain.GenerationMetrics); ok && m != nil {
+        return m
+    }
+    return &domain.GenerationMetrics{}
+}
+
+// elapsedMs is the time since start in whole milliseconds
+func elapsedMs(start time.Time) int64 {
+    return time.Since(start).Milliseconds()
+}
+
+// recordScores stores the best and mean similarity of the retrieved passages
+func recordScores(m *domain.GenerationMetrics, matches []*qdrant.ScoredPoint) {
+    m.RetrievalMatches = len(matches)
+    if len(matches) == 0 {
+        return
+    }
+    var top, sum float64
+    for i, match := range matches {
+        score := float64(match.GetScore())
+        if i == 0 || score > top {
+            top = score
+        }
+        sum += score
+    }
+    mean := sum / float64(len(matches))
+    m.RetrievalTopScore = &top
+    m.RetrievalMeanScore = &mean
+}
@@ -3,12 +3,13 @@ package chat
 
 import (
     "fmt"
-    "log"
     "sort"
     "strconv"
     "strings"
 
     "github.com/qdrant/go-client/qdrant"
+
+    "github.com/iyunix/go-internist/internal/logging"
 )
 
 // contextEntry represents a normalized RAG context chunk
@@ -24,11 +25,11 @@ type contextEntry struct {
 // RAGService handles building structured context from Qdrant embeddings
 type RAGService struct {
     config *Config
-    logger Logger
+    logger logging.Logger
 }
 
 // NewRAGService initializes the RAG service
-func NewRAGService(config *Config, logger Logger) *RAGService {
+func NewRAGService(config *Config, logger logging.Logger) *RAGService {
     return &RAGService{
         config: config,
         logger: logger,
@@ -95,7 +96,7 @@ func (r *RAGService) extractContextEntry(match *qdrant.ScoredPoint, index int) c
         }
     }
 
-    log.Printf("[RAG Context] Chunk %d Source: %s Id: %s", index+1, entry.SourceFile, entry.ChunkID)
+    r.logger.Debug("RAG context chunk", "index", index+1, "source_file", entry.SourceFile, "chunk_id", entry.ChunkID)
     return entry
 }
 
@@ -157,7 +158,7 @@ func (r *RAGService) serializeContext(entries []contextEntry) string {
             return s
         }
 
-        fmt.Fprintf(&b, `  {"chunk_id":"%s","source_file":"%s","section_heading":"%s",,"text":"%s","similarity":%s}`,
+        fmt.Fprintf(&b, `  {"chunk_id":"%s","source_file":"%s","section_heading":"%s","key_takeaways":"%s","text":"%s","similarity":%s}`,
             esc(e.ChunkID), esc(e.SourceFile), esc(e.SectionHeading),
             esc(e.KeyTakeaways), esc(e.Text), e.Similarity)
     }
@@ -167,8 +168,9 @@ func (r *RAGService) serializeContext(entries []contextEntry) string {
 }
 
 // BuildPrompt generates a medical AI prompt from context JSON, user question, and entries
-// The entries are used to deterministically generate a References section
-func (r *RAGService) BuildPrompt(contextJSON, question string, entries []contextEntry) string {
+// The entries are used to deterministically generate a References section, and language
+// ("en" or "fa") selects the language of the reply
+func (r *RAGService) BuildPrompt(contextJSON, question string, entries []contextEntry, language string) string {
     if strings.TrimSpace(contextJSON) == "" {
         contextJSON = "[]"
     }
@@ -193,7 +195,7 @@ func (r *RAGService) BuildPrompt(contextJSON, question string, entries []context
     - Use only the above context to answer the question.
     - Return your answer in valid Markdown (no JSON, no extra explanations).
     - If info is missing, clearly state what can't be answered.
-    - Your reply must be in English and concise, organized with headings, bullets, or tables as needed.
     %s
-`, contextJSON, question, references.String())
+    %s
+`, contextJSON, question, languageInstruction(language), references.String())
 }
@@ -4,14 +4,16 @@ package chat
 import (
     "strings"
     "github.com/qdrant/go-client/qdrant"
+
+    "github.com/iyunix/go-internist/internal/logging"
 )
 
 type SourceExtractor struct {
     config *Config
-    logger Logger
+    logger logging.Logger
 }
 
-func NewSourceExtractor(config *Config, logger Logger) *SourceExtractor {
+func NewSourceExtractor(config *Config, logger logging.Logger) *SourceExtractor {
     return &SourceExtractor{
         config: config,
         logger: logger,
@@ -3,10 +3,12 @@ package chat
 
 import (
     "context"
+    "errors"
     "strings"
     "time"
 
     "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/logging"
     "github.com/iyunix/go-internist/internal/repository/chat"
     "github.com/iyunix/go-internist/internal/repository/message"
     "github.com/qdrant/go-client/qdrant"
@@ -17,7 +19,7 @@ const (
     embeddingAPITimeout = 30 * time.Second
     pineconeAPITimeout  = 30 * time.Second // Keep name for compatibility
     llmStreamTimeout    = 60 * time.Second // Streaming can take longer
-    dbSaveTimeout       = 5 * time.Second  // Timeout for background saves
+    dbSaveTimeout       = 5 * time.Second  // Timeout for saving the assistant reply
 )
 
 // StreamingService orchestrates the RAG pipeline for a chat.
@@ -29,7 +31,7 @@ type StreamingService struct {
     pineconeService PineconeProvider // Keep name for compatibility
     ragService      *RAGService
     sourceExtractor *SourceExtractor
-    logger          Logger
+    logger          logging.Logger
 }
 
 // AIProvider defines the interface for AI model interactions (embedding and completion).
@@ -52,7 +54,7 @@ func NewStreamingService(
     pineconeService PineconeProvider,
     ragService *RAGService,
     sourceExtractor *SourceExtractor,
-    logger Logger,
+    logger logging.Logger,
 ) *StreamingService {
     return &StreamingService{
         config:          config,
@@ -66,18 +68,29 @@ func NewStreamingService(
     }
 }
 
+// StreamOptions holds per-answer settings. Model and TopK override the configured
+// defaults when set.
+type StreamOptions struct {
+    Language string // answer language: "en" or "fa"
+    Model    string
+    TopK     int
+}
+
 // StreamChatResponse orchestrates the full RAG pipeline with timeouts for each external call.
 // StreamChatResponse orchestrates the full RAG pipeline with separate embedding and LLM contexts.
 func (s *StreamingService) StreamChatResponse(
     ctx context.Context,
     userID, chatID uint,
+    questionID uint,      // saved question the answer replies to
     embeddingText string, // ONLY previous user questions + current
     llmText string,       // previous user questions + last assistant + current
+    opts StreamOptions,
     onDelta func(string) error,
     onSources func([]string),
     onStatus func(status, message string),
 ) error {
-    s.logger.Info("starting stream chat", "user_id", userID, "chat_id", chatID)
+    s.logger.Info("starting stream chat", "user_id", userID, "chat_id", chatID,
+        "language", opts.Language, "model", opts.Model, "top_k", opts.TopK)
     onStatus("understanding", "Understanding question...")
 
     // Validate chat ownership
@@ -86,10 +99,14 @@ func (s *StreamingService) StreamChatResponse(
         return NewUnauthorizedError(userID, chatID)
     }
 
+    metrics := MetricsFromContext(ctx)
+
     // --- 1️⃣ Harden Embedding Call with a Timeout ---
     embeddingCtx, embeddingCancel := context.WithTimeout(ctx, embeddingAPITimeout)
     defer embeddingCancel()
+    stageStart := time.Now()
     embedding, err := s.aiService.CreateEmbedding(embeddingCtx, embeddingText) // ✅ use embeddingText
+    metrics.EmbeddingMs = elapsedMs(stageStart)
     if err != nil {
         s.logger.Error("embedding call failed", "error", err)
         return NewRAGError("embedding", "failed to create embedding", err)
@@ -100,41 +117,67 @@ func (s *StreamingService) StreamChatResponse(
     // --- 2️⃣ Harden Qdrant Call with a Timeout ---
     pineconeCtx, pineconeCancel := context.WithTimeout(ctx, pineconeAPITimeout)
     defer pineconeCancel()
-    matches, err := s.pineconeService.QuerySimilar(pineconeCtx, embedding, s.config.RetrievalTopK)
+    topK := s.config.RetrievalTopK
+    if opts.TopK > 0 {
+        topK = opts.TopK
+    }
+    stageStart = time.Now()
+    matches, err := s.pineconeService.QuerySimilar(pineconeCtx, embedding, topK)
+    metrics.RetrievalMs = elapsedMs(stageStart)
     if err != nil {
         s.logger.Error("qdrant call failed", "error", err)
         return NewRAGError("qdrant_query", "failed to query Qdrant", err)
     }
+    recordScores(metrics, matches)
 
-    // Send sources if configured
-    if s.config.EnableSources && onSources != nil {
-        sources := s.sourceExtractor.ExtractSources(matches)
-        if len(sources) > 0 {
+    // Send sources if configured; they are also kept with the answer for exports
+    var sources []string
+    if s.config.EnableSources {
+        sources = s.sourceExtractor.ExtractSources(matches)
+        if len(sources) > 0 && onSources != nil {
             onSources(sources)
         }
     }
 
     // Build final LLM prompt using llmText
     contextJSON, entries := s.ragService.BuildContext(matches)
-    finalPrompt := s.ragService.BuildPrompt(contextJSON, llmText, entries) // ✅ use llmText
+    finalPrompt := s.ragService.BuildPrompt(contextJSON, llmText, entries, opts.Language) // ✅ use llmText
     onStatus("thinking", "AI is generating a response...")
 
     // --- 3️⃣ Harden LLM Stream Call with a Timeout ---
     var fullReply strings.Builder
     llmCtx, llmCancel := context.WithTimeout(ctx, llmStreamTimeout)
     defer llmCancel()
-    streamErr := s.aiService.StreamCompletion(llmCtx, s.config.StreamModel, finalPrompt, func(token string) error {
+    model := s.config.StreamModel
+    if opts.Model != "" {
+        model = opts.Model
+    }
+    stageStart = time.Now()
+    streamErr := s.aiService.StreamCompletion(llmCtx, model, finalPrompt, func(token string) error {
         fullReply.WriteString(token)
         return onDelta(token)
     })
+    metrics.LLMMs = elapsedMs(stageStart)
 
     if streamErr != nil {
+        // An answer the user stopped keeps what was produced so the history matches
+        // what the user saw (and was charged for). Any other cancellation, such as a
+        // shutdown, fails the job and is refunded, so nothing is kept.
+        if errors.Is(context.Cause(ctx), ErrStoppedByUser) && fullReply.Len() > 0 {
+            if err := s.saveAssistantMessage(ctx, chatID, questionID, fullReply.String(), sources); err != nil {
+                s.logger.Error("failed to save stopped assistant message", "error", err)
+            }
+        }
         s.logger.Error("stream completion failed", "error", streamErr)
         return NewRAGError("streaming", "AI streaming failed", streamErr)
     }
 
-    // Save assistant message in background
-    go s.saveAssistantMessage(chatID, fullReply.String())
+    // Save before returning so the caller (a generation worker) only reports
+    // completion once the answer is persisted; shutdown drains these workers.
+    if err := s.saveAssistantMessage(ctx, chatID, questionID, fullReply.String(), sources); err != nil {
+        s.logger.Error("failed to save assistant message", "error", err)
+        return NewRAGError("save_answer", "failed to save the answer", err)
+    }
 
     s.logger.Info("stream chat completed", "response_length", fullReply.Len())
     return nil
@@ -155,22 +198,35 @@ func (s *StreamingService) saveUserMessage(ctx context.Context, chatID uint, con
     return nil
 }
 
-// saveAssistantMessage saves the AI's response to the database in the background.
-func (s *StreamingService) saveAssistantMessage(chatID uint, content string) {
+// saveAssistantMessage saves the AI's response to the database as the active answer
+// to questionID. It detaches from ctx cancellation so an answer that finished
+// streaming is never dropped.
+func (s *StreamingService) saveAssistantMessage(parent context.Context, chatID, questionID uint, content string, sources []string) error {
     if len(content) > 0 {
-        // FIXED: Create a new context with a timeout for this background task.
-        ctx, cancel := context.WithTimeout(context.Background(), dbSaveTimeout)
+        ctx, cancel := context.WithTimeout(context.WithoutCancel(parent), dbSaveTimeout)
         defer cancel()
 
         aiMessage := &domain.Message{
             ChatID:      chatID,
             MessageType: domain.MessageTypeAssistant,
             Content:     content,
+            Sources:     sources,
+            Active:      true,
+        }
+        if questionID != 0 {
+            aiMessage.ParentID = &questionID
         }
         if _, err := s.messageRepo.Create(ctx, aiMessage); err != nil {
-            s.logger.Error("failed to save assistant message", "error", err)
+            return err
+        }
+        if questionID != 0 {
+            // A regenerated answer replaces the previous one on the branch
+            if err := s.messageRepo.ActivateBranch(ctx, chatID, aiMessage.ID); err != nil {
+                s.logger.Warn("failed to activate assistant message", "error", err, "message_id", aiMessage.ID)
+            }
         }
         // FIXED: Pass the correct argument (chatID) to the function.
         _ = s.chatRepo.TouchUpdatedAt(ctx, chatID)
     }
+    return nil
 }
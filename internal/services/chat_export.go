@@ -0,0 +1,96 @@
+// File: internal/services/chat_export.go
+package services
+
+import (
+    "archive/zip"
+    "context"
+    "errors"
+    "io"
+    "time"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/services/export"
+)
+
+const exportPageSize = 100 // chats loaded per query by ExportAllChats
+
+// ExportChat builds the export view of a chat: the questions and answers on its active
+// branch, with the sources each answer was grounded on.
+func (s *ChatService) ExportChat(ctx context.Context, userID, chatID uint) (*export.Conversation, error) {
+    dbCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+    defer cancel()
+
+    chatRecord, err := s.chatRepo.FindByID(dbCtx, chatID)
+    if err != nil || chatRecord.UserID != userID {
+        return nil, errors.New("unauthorized or chat not found")
+    }
+    branch, err := s.messageRepo.FindActiveBranch(dbCtx, chatID, 0)
+    if err != nil {
+        return nil, err
+    }
+    return conversationExport(chatRecord, branch), nil
+}
+
+// ExportAllChats writes every chat of the user into a zip archive, one file per chat
+// in the given format. Chats are loaded a page at a time so large histories stream.
+func (s *ChatService) ExportAllChats(ctx context.Context, userID uint, format export.Format, w io.Writer) error {
+    archive := zip.NewWriter(w)
+    exported := 0
+    for offset := 0; ; offset += exportPageSize {
+        chats, _, err := s.GetUserChatsWithPagination(ctx, userID, exportPageSize, offset)
+        if err != nil {
+            return err
+        }
+        for i := range chats {
+            if err := ctx.Err(); err != nil {
+                return err
+            }
+            conv, err := s.ExportChat(ctx, userID, chats[i].ID)
+            if err != nil {
+                return err
+            }
+            f, err := archive.CreateHeader(&zip.FileHeader{
+                Name:     export.FileName(conv, format),
+                Method:   zip.Deflate,
+                Modified: chats[i].UpdatedAt,
+            })
+            if err != nil {
+                return err
+            }
+            if err := export.Write(f, format, conv); err != nil {
+                return err
+            }
+            exported++
+        }
+        if len(chats) < exportPageSize {
+            break
+        }
+    }
+    s.logger.Info("chats exported", ctx, "user_id", userID, "format", string(format), "chats", exported)
+    return archive.Close()
+}
+
+// conversationExport maps a chat and its active branch to the export model
+func conversationExport(chatRecord *domain.Chat, branch []domain.Message) *export.Conversation {
+    conv := &export.Conversation{
+        ChatID:     chatRecord.ID,
+        Title:      chatRecord.Title,
+        CreatedAt:  chatRecord.CreatedAt,
+        ExportedAt: time.Now().UTC(),
+        Messages:   make([]export.Message, 0, len(branch)),
+    }
+    for _, m := range branch {
+        role := export.RoleQuestion
+        if m.MessageType == domain.MessageTypeAssistant {
+            role = export.RoleAnswer
+        }
+        conv.Messages = append(conv.Messages, export.Message{
+            ID:        m.ID,
+            Role:      role,
+            Content:   m.Content,
+            Sources:   m.Sources,
+            CreatedAt: m.CreatedAt,
+        })
+    }
+    return conv
+}
@@ -0,0 +1,81 @@
+// File: internal/services/chat_feedback.go
+package services
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "time"
+
+    "github.com/iyunix/go-internist/internal/domain"
+)
+
+const maxFeedbackCommentRunes = 2000
+
+// ErrInvalidFeedback is returned for a rating other than up or down or an unknown category
+var ErrInvalidFeedback = errors.New("invalid feedback: rating must be up or down, category one of inaccurate, outdated, missing_source, unsafe")
+
+// RateAnswer records the user's thumbs up or down on an answer in their chat, with an
+// optional category and comment. Rating the same answer again replaces the feedback.
+func (s *ChatService) RateAnswer(ctx context.Context, userID, chatID, messageID uint, rating int, category, comment string) (*domain.MessageFeedback, error) {
+    category = strings.TrimSpace(category)
+    if (rating != domain.FeedbackUp && rating != domain.FeedbackDown) || !domain.IsValidFeedbackCategory(category) {
+        return nil, ErrInvalidFeedback
+    }
+    comment = truncateRunes(strings.TrimSpace(comment), maxFeedbackCommentRunes)
+
+    dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    if _, err := s.findAnswer(dbCtx, userID, chatID, messageID); err != nil {
+        return nil, err
+    }
+
+    feedback := &domain.MessageFeedback{
+        MessageID: messageID,
+        UserID:    userID,
+        ChatID:    chatID,
+        Rating:    rating,
+        Category:  category,
+        Comment:   comment,
+    }
+    if err := s.messageRepo.SaveFeedback(dbCtx, feedback); err != nil {
+        return nil, err
+    }
+    s.logger.Info("answer rated", ctx, "user_id", userID, "message_id", messageID,
+        "rating", rating, "category", category, "has_comment", comment != "")
+    return feedback, nil
+}
+
+// RemoveAnswerRating withdraws the user's feedback on an answer
+func (s *ChatService) RemoveAnswerRating(ctx context.Context, userID, chatID, messageID uint) error {
+    if _, err := s.findAnswer(ctx, userID, chatID, messageID); err != nil {
+        return err
+    }
+    return s.messageRepo.DeleteFeedback(ctx, messageID, userID)
+}
+
+// GetChatFeedback returns the user's ratings of answers in one of their chats
+func (s *ChatService) GetChatFeedback(ctx context.Context, userID, chatID uint) ([]domain.MessageFeedback, error) {
+    chatRecord, err := s.chatRepo.FindByID(ctx, chatID)
+    if err != nil || chatRecord.UserID != userID {
+        return nil, errors.New("unauthorized or chat not found")
+    }
+    return s.messageRepo.FindFeedbackByChat(ctx, chatID, userID)
+}
+
+// findAnswer returns an assistant message of the user's chat
+func (s *ChatService) findAnswer(ctx context.Context, userID, chatID, messageID uint) (*domain.Message, error) {
+    chatRecord, err := s.chatRepo.FindByID(ctx, chatID)
+    if err != nil || chatRecord.UserID != userID {
+        return nil, errors.New("unauthorized or chat not found")
+    }
+    msg, err := s.messageRepo.FindByID(ctx, messageID)
+    if err != nil || msg.ChatID != chatID {
+        return nil, errors.New("message not found")
+    }
+    if msg.MessageType != domain.MessageTypeAssistant {
+        return nil, errors.New("only answers can be rated")
+    }
+    return msg, nil
+}
@@ -0,0 +1,296 @@
+// File: internal/services/chat_organize.go
+package services
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "time"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/repository/chat"
+)
+
+const (
+    maxFolderNameRunes = 60
+    maxFoldersPerUser  = 100
+    maxTagRunes        = 32
+    maxTagsPerChat     = 20
+    maxBulkChats       = 100
+)
+
+// Bulk actions on chats
+const (
+    BulkDelete    = "delete"
+    BulkArchive   = "archive"
+    BulkUnarchive = "unarchive"
+    BulkPin       = "pin"
+    BulkUnpin     = "unpin"
+    BulkMove      = "move"  // into FolderID, or out of any folder when it is nil
+    BulkTag       = "tag"   // add Tags
+    BulkUntag     = "untag" // remove Tags
+)
+
+// ErrInvalidBulkAction is returned for an unknown action or an empty or oversized ID list
+var ErrInvalidBulkAction = errors.New("invalid bulk action: give an action and 1-100 chat IDs")
+
+// BulkChatAction applies one action to several of a user's chats
+type BulkChatAction struct {
+    Action   string
+    ChatIDs  []uint
+    FolderID *uint    // for BulkMove
+    Tags     []string // for BulkTag and BulkUntag
+}
+
+// ListChats lists a user's chats, pinned first. Archived chats are only listed when
+// the filter asks for them.
+func (s *ChatService) ListChats(ctx context.Context, userID uint, filter chat.ChatFilter, limit, offset int) ([]domain.Chat, int64, error) {
+    dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+    defer cancel()
+
+    if filter.Tag != "" {
+        filter.Tag = normalizeTag(filter.Tag)
+    }
+    return s.chatRepo.FindByUserIDFiltered(dbCtx, userID, filter, limit, offset)
+}
+
+// ListTags returns every tag the user has put on a chat
+func (s *ChatService) ListTags(ctx context.Context, userID uint) ([]string, error) {
+    return s.chatRepo.FindTagsByUserID(ctx, userID)
+}
+
+// SetChatTags replaces a chat's tags and returns them normalized
+func (s *ChatService) SetChatTags(ctx context.Context, userID, chatID uint, tags []string) ([]string, error) {
+    normalized, err := normalizeTags(tags)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.chatRepo.UpdateTags(ctx, chatID, userID, normalized); err != nil {
+        return nil, err
+    }
+    return normalized, nil
+}
+
+// BulkUpdateChats applies an action to the user's chats among the given IDs and returns
+// how many were changed. IDs of other users' chats are ignored.
+func (s *ChatService) BulkUpdateChats(ctx context.Context, userID uint, action BulkChatAction) (int64, error) {
+    if len(action.ChatIDs) == 0 || len(action.ChatIDs) > maxBulkChats {
+        return 0, ErrInvalidBulkAction
+    }
+
+    dbCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+    defer cancel()
+
+    owned, err := s.chatRepo.FindByIDsAndUserID(dbCtx, action.ChatIDs, userID)
+    if err != nil {
+        return 0, err
+    }
+    if len(owned) == 0 {
+        return 0, nil
+    }
+    ids := make([]uint, len(owned))
+    for i := range owned {
+        ids[i] = owned[i].ID
+    }
+
+    var affected int64
+    switch action.Action {
+    case BulkDelete:
+        affected, err = s.deleteChats(dbCtx, userID, ids)
+    case BulkArchive:
+        affected, err = s.chatRepo.UpdateOrganization(dbCtx, ids, userID, map[string]interface{}{"archived": true})
+    case BulkUnarchive:
+        affected, err = s.chatRepo.UpdateOrganization(dbCtx, ids, userID, map[string]interface{}{"archived": false})
+        if err == nil {
+            // Restored chats come back at the top of the list
+            err = s.chatRepo.UpdateMultipleTimestamps(dbCtx, ids)
+        }
+    case BulkPin, BulkUnpin:
+        affected, err = s.chatRepo.UpdateOrganization(dbCtx, ids, userID, map[string]interface{}{"pinned": action.Action == BulkPin})
+    case BulkMove:
+        var folderID interface{}
+        if action.FolderID != nil {
+            if _, err := s.chatRepo.FindFolderByID(dbCtx, *action.FolderID, userID); err != nil {
+                return 0, err
+            }
+            folderID = *action.FolderID
+        }
+        affected, err = s.chatRepo.UpdateOrganization(dbCtx, ids, userID, map[string]interface{}{"folder_id": folderID})
+    case BulkTag, BulkUntag:
+        affected, err = s.retagChats(dbCtx, userID, owned, action.Tags, action.Action == BulkTag)
+    default:
+        return 0, ErrInvalidBulkAction
+    }
+    if err != nil {
+        s.logger.Error("bulk chat update failed", ctx, "error", err, "user_id", userID, "action", action.Action, "chats", len(ids))
+        return 0, err
+    }
+
+    s.logger.Info("bulk chat update", ctx, "user_id", userID, "action", action.Action, "requested", len(action.ChatIDs), "affected", affected)
+    return affected, nil
+}
+
+// deleteChats removes chats the user owns along with their messages, summaries and share links
+func (s *ChatService) deleteChats(ctx context.Context, userID uint, chatIDs []uint) (int64, error) {
+    for _, chatID := range chatIDs {
+        if err := s.messageRepo.DeleteByChatID(ctx, chatID); err != nil {
+            s.logger.Error("failed to delete messages for chat",
+                "error", err, "chat_id", chatID, "user_id", userID)
+            return 0, err
+        }
+        if err := s.chatRepo.RevokeSharesByChat(ctx, chatID); err != nil {
+            s.logger.Warn("failed to revoke share links for chat",
+                "error", err, "chat_id", chatID, "user_id", userID)
+        }
+        if err := s.chatRepo.DeleteSummary(ctx, chatID); err != nil {
+            s.logger.Warn("failed to delete summary for chat",
+                "error", err, "chat_id", chatID, "user_id", userID)
+        }
+    }
+    if err := s.chatRepo.DeleteMultipleByUserID(ctx, chatIDs, userID); err != nil {
+        return 0, err
+    }
+    return int64(len(chatIDs)), nil
+}
+
+// retagChats adds tags to, or removes them from, each chat
+func (s *ChatService) retagChats(ctx context.Context, userID uint, chats []domain.Chat, tags []string, add bool) (int64, error) {
+    change, err := normalizeTags(tags)
+    if err != nil {
+        return 0, err
+    }
+    if len(change) == 0 {
+        return 0, errors.New("no tags given")
+    }
+
+    var affected int64
+    for _, c := range chats {
+        var updated []string
+        if add {
+            updated, err = normalizeTags(append(append([]string{}, c.Tags...), change...))
+            if err != nil {
+                return affected, err
+            }
+        } else {
+            for _, t := range c.Tags {
+                if !containsString(change, t) {
+                    updated = append(updated, t)
+                }
+            }
+        }
+        if equalStrings(updated, c.Tags) {
+            continue
+        }
+        if err := s.chatRepo.UpdateTags(ctx, c.ID, userID, updated); err != nil {
+            return affected, err
+        }
+        affected++
+    }
+    return affected, nil
+}
+
+// CreateFolder adds a folder for the user's chats
+func (s *ChatService) CreateFolder(ctx context.Context, userID uint, name string) (*domain.ChatFolder, error) {
+    name, err := normalizeFolderName(name)
+    if err != nil {
+        return nil, err
+    }
+    folders, err := s.chatRepo.FindFoldersByUserID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if len(folders) >= maxFoldersPerUser {
+        return nil, errors.New("folder limit reached (max 100)")
+    }
+
+    folder := &domain.ChatFolder{UserID: userID, Name: name}
+    if err := s.chatRepo.CreateFolder(ctx, folder); err != nil {
+        return nil, err
+    }
+    return folder, nil
+}
+
+// ListFolders returns the user's folders by name
+func (s *ChatService) ListFolders(ctx context.Context, userID uint) ([]domain.ChatFolder, error) {
+    return s.chatRepo.FindFoldersByUserID(ctx, userID)
+}
+
+// RenameFolder renames one of the user's folders
+func (s *ChatService) RenameFolder(ctx context.Context, userID, folderID uint, name string) (*domain.ChatFolder, error) {
+    name, err := normalizeFolderName(name)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.chatRepo.RenameFolder(ctx, folderID, userID, name); err != nil {
+        return nil, err
+    }
+    return s.chatRepo.FindFolderByID(ctx, folderID, userID)
+}
+
+// DeleteFolder removes a folder; its chats are kept, outside any folder
+func (s *ChatService) DeleteFolder(ctx context.Context, userID, folderID uint) error {
+    if err := s.chatRepo.DeleteFolder(ctx, folderID, userID); err != nil {
+        return err
+    }
+    s.logger.Info("folder deleted", ctx, "user_id", userID, "folder_id", folderID)
+    return nil
+}
+
+// normalizeFolderName trims a folder name and checks its length
+func normalizeFolderName(name string) (string, error) {
+    name = strings.Join(strings.Fields(name), " ")
+    if name == "" {
+        return "", errors.New("folder name cannot be empty")
+    }
+    if len([]rune(name)) > maxFolderNameRunes {
+        return "", errors.New("folder name too long (max 60 characters)")
+    }
+    return name, nil
+}
+
+// normalizeTag lower-cases a tag, drops a leading '#' and collapses inner whitespace
+// to single dashes, so "#Heart Failure" and "heart-failure" are the same tag
+func normalizeTag(tag string) string {
+    tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+    return strings.ToLower(strings.Join(strings.Fields(tag), "-"))
+}
+
+// normalizeTags normalizes and de-duplicates tags, keeping their order
+func normalizeTags(tags []string) ([]string, error) {
+    normalized := make([]string, 0, len(tags))
+    for _, t := range tags {
+        t = normalizeTag(t)
+        if t == "" || containsString(normalized, t) {
+            continue
+        }
+        if len([]rune(t)) > maxTagRunes {
+            return nil, errors.New("tag too long (max 32 characters)")
+        }
+        normalized = append(normalized, t)
+    }
+    if len(normalized) > maxTagsPerChat {
+        return nil, errors.New("too many tags (max 20 per chat)")
+    }
+    return normalized, nil
+}
+
+func containsString(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+func equalStrings(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
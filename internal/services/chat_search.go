@@ -0,0 +1,51 @@
+// File: internal/services/chat_search.go
+package services
+
+import (
+    "context"
+    "errors"
+    "html"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/repository/message"
+)
+
+const (
+    minSearchQueryRunes = 2
+    maxSearchQueryRunes = 200
+    searchTimeout       = 10 * time.Second
+)
+
+// ErrInvalidSearchQuery is returned for empty, too short or too long queries
+var ErrInvalidSearchQuery = errors.New("search query must be between 2 and 200 characters")
+
+// snippetMarkup turns the repository's match delimiters into <mark> tags
+var snippetMarkup = strings.NewReplacer(
+    message.SnippetMatchStart, "<mark>",
+    message.SnippetMatchStop, "</mark>",
+)
+
+// SearchConversations finds questions, answers and chat titles matching query across all
+// of the user's chats. Snippets are HTML-escaped with the matched words wrapped in <mark>.
+func (s *ChatService) SearchConversations(ctx context.Context, userID uint, query string, limit, offset int) ([]domain.SearchHit, int64, error) {
+    query = strings.TrimSpace(query)
+    if n := utf8.RuneCountInString(query); n < minSearchQueryRunes || n > maxSearchQueryRunes {
+        return nil, 0, ErrInvalidSearchQuery
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, searchTimeout)
+    defer cancel()
+
+    hits, total, err := s.messageRepo.SearchUserConversations(ctx, userID, query, limit, offset)
+    if err != nil {
+        return nil, 0, err
+    }
+    for i := range hits {
+        hits[i].Snippet = snippetMarkup.Replace(html.EscapeString(hits[i].Snippet))
+    }
+    s.logger.Debug("conversation search completed", ctx, "user_id", userID, "hits", len(hits), "total", total)
+    return hits, total, nil
+}
@@ -8,11 +8,13 @@ import (
     "strings"
     "time"
     "sync"
-    "log"
+    "unicode/utf8"
     "github.com/iyunix/go-internist/internal/config"
     "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/logging"
     "github.com/iyunix/go-internist/internal/repository/chat"
     "github.com/iyunix/go-internist/internal/repository/message"
+    "github.com/iyunix/go-internist/internal/repository/user"
     chatservice "github.com/iyunix/go-internist/internal/services/chat"
 )
 
@@ -134,9 +136,12 @@ type ChatService struct {
     config             *chatservice.Config
     chatRepo           chat.ChatRepository
     messageRepo        message.MessageRepository
+    userRepo           user.UserRepository
     streamService      *chatservice.StreamingService
+    aiService          *AIService
     translationService *TranslationService
-    logger             Logger
+    alternateModels    []string
+    logger             logging.Logger
     
     // Performance & Resilience
     timeouts           *ServiceTimeouts
@@ -152,12 +157,12 @@ func NewChatService(
     retrievalTopK int, // This value (15) is injected by Wire
     appConfig *config.Config,
     translationService *TranslationService, // <--- Only injected!
+    userRepo user.UserRepository,
 ) (*ChatService, error) {
     if chatRepo == nil || messageRepo == nil || aiService == nil || pineconeService == nil {
         return nil, errors.New("all dependencies are required for ChatService")
     }
 
-    log.Printf("[DEBUG] NewChatService created with RetrievalTopK: %d", retrievalTopK)
     config := chatservice.DefaultConfig()
     if retrievalTopK > 0 {
         config.RetrievalTopK = retrievalTopK
@@ -166,7 +171,8 @@ func NewChatService(
         return nil, err
     }
 
-    logger := NewLogger("chat_service")
+    logger := logging.NewLogger("chat_service")
+    logger.Debug("NewChatService created", "retrieval_top_k", config.RetrievalTopK)
 
     // Initialize performance & resilience components
     timeouts := DefaultTimeouts()
@@ -180,6 +186,11 @@ func NewChatService(
         "llm":         NewSimpleCircuitBreaker("llm", 3, 30*time.Second),
     }
 
+    var alternateModels []string
+    if appConfig != nil {
+        alternateModels = appConfig.AlternateModels
+    }
+
     // Initialize other services with standard constructors
     ragService := chatservice.NewRAGService(config, logger)
     sourceExtractor := chatservice.NewSourceExtractor(config, logger)
@@ -192,8 +203,11 @@ func NewChatService(
         config:             config,
         chatRepo:           chatRepo,
         messageRepo:        messageRepo,
+        userRepo:           userRepo,
         streamService:      streamService,
+        aiService:          aiService,
         translationService: translationService, // <--- Only set once!
+        alternateModels:    alternateModels,
         logger:             logger,
         timeouts:           timeouts,
         circuitBreakers:    circuitBreakers,
@@ -202,14 +216,20 @@ func NewChatService(
 }
 
 // ENHANCED: LLM context with current query prioritization and attention guidance
-func (s *ChatService) buildEnhancedLLMContext(pairs []domain.Message, currentQuery string) string {
+// summary carries what was established earlier in the chat, beyond the recent pairs.
+func (s *ChatService) buildEnhancedLLMContext(pairs []domain.Message, currentQuery, summary string) string {
     var contextParts []string
     
     // 1. PRIORITY: Current question with clear emphasis
     currentEmphasis := fmt.Sprintf("🎯 CURRENT MEDICAL QUESTION: %s", currentQuery)
     contextParts = append(contextParts, currentEmphasis)
+
+    // 2. Running summary of the whole case so far
+    if summary != "" {
+        contextParts = append(contextParts, "\n📋 CONVERSATION SUMMARY:", summary)
+    }
     
-    // 2. Add conversation context if exists (compressed)
+    // 3. Add conversation context if exists (compressed)
     if len(pairs) > 0 {
         contextParts = append(contextParts, "\n💬 CONVERSATION CONTEXT:")
         
@@ -227,7 +247,7 @@ func (s *ChatService) buildEnhancedLLMContext(pairs []domain.Message, currentQue
         }
     }
     
-    // 3. INSTRUCTION: Clear task definition
+    // 4. INSTRUCTION: Clear task definition
     instruction := fmt.Sprintf("\n⚡ FOCUS INSTRUCTION: Answer the CURRENT question above. Previous context is for reference only. Topic: %s", 
         s.detectMedicalIntent(currentQuery))
     contextParts = append(contextParts, instruction)
@@ -290,28 +310,201 @@ func (s *ChatService) StreamChatMessageWithSources(
     onDelta func(string) error,
     onSources func([]string),
     onStatus func(status string, message string),
+) error {
+    return s.streamChat(ctx, userID, chatID, prompt, chatTurn{}, onDelta, onSources, onStatus)
+}
+
+// chatTurn says how a pipeline run relates to the messages already in the chat
+type chatTurn struct {
+    regenerate *domain.Message        // answer this saved question again
+    editOf     *domain.Message        // save the prompt as a new version of this question
+    options    domain.GenerationOptions
+}
+
+// maxRetrievalTopK bounds the top-K a regeneration may ask for
+const maxRetrievalTopK = 50
+
+// ValidateGenerationOptions checks regeneration overrides against what this deployment allows.
+// A zero TopK means the field was left out and the configured top-K is used.
+func (s *ChatService) ValidateGenerationOptions(opts domain.GenerationOptions) error {
+    if opts.TopK < 0 || opts.TopK > maxRetrievalTopK {
+        return fmt.Errorf("top_k must be between 1 and %d, or omitted for the default", maxRetrievalTopK)
+    }
+    if opts.Model != "" && !s.isAllowedModel(opts.Model) {
+        return fmt.Errorf("model %q is not available", opts.Model)
+    }
+    return nil
+}
+
+// AvailableModels lists the models an answer can be regenerated with, the default first
+func (s *ChatService) AvailableModels() []string {
+    models := []string{s.config.StreamModel}
+    for _, m := range s.alternateModels {
+        if m != "" && m != s.config.StreamModel {
+            models = append(models, m)
+        }
+    }
+    return models
+}
+
+func (s *ChatService) isAllowedModel(model string) bool {
+    for _, m := range s.AvailableModels() {
+        if m == model {
+            return true
+        }
+    }
+    return false
+}
+
+// FindRegenerateQuestion returns the question whose answer messageID would be regenerated.
+// messageID may be an answer or the question itself; 0 means the last question on the
+// active branch.
+func (s *ChatService) FindRegenerateQuestion(ctx context.Context, userID, chatID, messageID uint) (*domain.Message, error) {
+    dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    chatRecord, err := s.chatRepo.FindByID(dbCtx, chatID)
+    if err != nil || chatRecord.UserID != userID {
+        return nil, errors.New("unauthorized or chat not found")
+    }
+
+    if messageID == 0 {
+        // The last question is the leaf or the leaf's parent
+        branch, err := s.messageRepo.FindActiveBranch(dbCtx, chatID, 2)
+        if err != nil {
+            return nil, err
+        }
+        for i := len(branch) - 1; i >= 0; i-- {
+            if branch[i].MessageType == domain.MessageTypeUser {
+                return &branch[i], nil
+            }
+        }
+        return nil, errors.New("no question to regenerate")
+    }
+
+    msg, err := s.messageRepo.FindByID(dbCtx, messageID)
+    if err != nil || msg.ChatID != chatID {
+        return nil, errors.New("message not found")
+    }
+    switch msg.MessageType {
+    case domain.MessageTypeUser:
+        return msg, nil
+    case domain.MessageTypeAssistant:
+        if msg.ParentID == nil {
+            return nil, errors.New("answer has no question to regenerate")
+        }
+        question, err := s.messageRepo.FindByID(dbCtx, *msg.ParentID)
+        if err != nil || question.ChatID != chatID || question.MessageType != domain.MessageTypeUser {
+            return nil, errors.New("answer has no question to regenerate")
+        }
+        return question, nil
+    default:
+        return nil, errors.New("message cannot be regenerated")
+    }
+}
+
+// GetLastQuestion returns the prompt a regeneration would re-run, for charging up front
+func (s *ChatService) GetLastQuestion(ctx context.Context, userID, chatID uint) (string, error) {
+    question, err := s.FindRegenerateQuestion(ctx, userID, chatID, 0)
+    if err != nil {
+        return "", err
+    }
+    return question.Content, nil
+}
+
+// RegenerateAnswer re-runs the pipeline for a saved question, optionally with another
+// model, a larger top-K or without translation. The new answer is added next to the
+// previous ones, which stay available as alternate versions, and becomes the active one.
+func (s *ChatService) RegenerateAnswer(
+    ctx context.Context,
+    userID, chatID, messageID uint,
+    opts domain.GenerationOptions,
+    onDelta func(string) error,
+    onSources func([]string),
+    onStatus func(status string, message string),
+) error {
+    if err := s.ValidateGenerationOptions(opts); err != nil {
+        return err
+    }
+    question, err := s.FindRegenerateQuestion(ctx, userID, chatID, messageID)
+    if err != nil {
+        return err
+    }
+    turn := chatTurn{regenerate: question, options: opts}
+    return s.streamChat(ctx, userID, chatID, question.Content, turn, onDelta, onSources, onStatus)
+}
+
+// pairsBefore keeps the pairs asked before messageID. Everything from that question
+// onwards on its branch was created later, so it has a larger ID.
+func pairsBefore(pairs []domain.Message, messageID uint) []domain.Message {
+    for i := range pairs {
+        if pairs[i].ID >= messageID {
+            return pairs[:i-i%2]
+        }
+    }
+    return pairs
+}
+
+// conversationPairs returns the recent question/answer pairs that precede this turn.
+// An edited or regenerated question may sit further back than the window, so the whole
+// branch is loaded and cut there.
+func (s *ChatService) conversationPairs(ctx context.Context, chatID uint, turn chatTurn, pairLimit int) ([]domain.Message, error) {
+    var before uint
+    switch {
+    case turn.regenerate != nil:
+        before = turn.regenerate.ID
+    case turn.editOf != nil:
+        before = turn.editOf.ID
+    default:
+        return s.messageRepo.FindRecentUserAssistantPairs(ctx, chatID, pairLimit, "internal_context")
+    }
+
+    pairs, err := s.messageRepo.FindRecentUserAssistantPairs(ctx, chatID, 100, "internal_context")
+    if err != nil {
+        return nil, err
+    }
+    pairs = pairsBefore(pairs, before)
+    if len(pairs) > pairLimit*2 {
+        pairs = pairs[len(pairs)-pairLimit*2:]
+    }
+    return pairs, nil
+}
+
+// streamChat runs the full pipeline. For a regeneration the question is already saved,
+// so only the new answer is persisted. For an edit the prompt is saved as a new version
+// of the original question, starting a branch from the same point.
+func (s *ChatService) streamChat(
+    ctx context.Context,
+    userID, chatID uint,
+    prompt string,
+    turn chatTurn,
+    onDelta func(string) error,
+    onSources func([]string),
+    onStatus func(status string, message string),
 ) error {
     startTime := time.Now()
     s.logger.Info("starting stream chat",
         "user_id", userID, "chat_id", chatID, "prompt_length", len(prompt))
 
     originalPrompt := prompt
+    language := s.responseLanguage(ctx, userID, originalPrompt)
     
     // ----- STEP 1: Get conversation context BEFORE processing -----
     const memoryPairLimit = 3
     
     onStatus("retrieving_context", "Analyzing conversation history...")
     
-    pairs, err := s.messageRepo.FindRecentUserAssistantPairs(ctx, chatID, memoryPairLimit, "internal_context")
+    pairs, err := s.conversationPairs(ctx, chatID, turn, memoryPairLimit)
     if err != nil {
         s.logger.Warn("Failed to retrieve conversation history", "error", err)
         pairs = []domain.Message{} // Continue with empty context
     }
+    summary := s.branchSummary(ctx, chatID, turn)
 
     var embeddingQuery, llmQuery string
 
     // ----- STEP 2: ENHANCED processing for ALL queries (Persian + English) -----
-    if s.translationService != nil {
+    if s.translationService != nil && !turn.options.DisableTranslation {
         onStatus("processing", "Processing your question...")
         
         translationCB := s.circuitBreakers["translation"]
@@ -320,6 +513,7 @@ func (s *ChatService) StreamChatMessageWithSources(
             embeddingQuery = prompt
             llmQuery = prompt
         } else {
+            translationStart := time.Now()
             err := translationCB.Call(func() error {
                 timeoutCtx, cancel := context.WithTimeout(ctx, s.timeouts.Translation)
                 defer cancel()
@@ -329,6 +523,7 @@ func (s *ChatService) StreamChatMessageWithSources(
                     timeoutCtx, 
                     prompt, 
                     pairs, // Pass conversation history
+                    summary,
                 )
                 if err != nil {
                     return err
@@ -337,6 +532,7 @@ func (s *ChatService) StreamChatMessageWithSources(
                 llmQuery = llmQ
                 return nil
             })
+            chatservice.MetricsFromContext(ctx).TranslationMs = time.Since(translationStart).Milliseconds()
             
             if err != nil {
                 s.logger.Warn("Enhanced processing failed, using original query", "error", err)
@@ -348,33 +544,47 @@ func (s *ChatService) StreamChatMessageWithSources(
         
         onStatus("processed", "Processing your question...")
     } else {
-        // No translation service available
+        // No translation service available, or disabled for this regeneration
         embeddingQuery = prompt
         llmQuery = prompt
     }
 
     // ----- STEP 3: Save messages -----
-    if originalPrompt != llmQuery {
-        // Save original user message for display
-        _, err := s.SaveMessage(ctx, userID, chatID, originalPrompt, "user")
-        if err != nil {
-            s.logger.Error("failed to save original user message", "error", err)
-            return err
+    var questionID uint
+    if turn.regenerate != nil {
+        s.logger.Info("regenerating answer", "question_id", turn.regenerate.ID,
+            "model", turn.options.Model, "top_k", turn.options.TopK, "translation_disabled", turn.options.DisableTranslation)
+        questionID = turn.regenerate.ID
+    } else {
+        // A new question continues the active branch; an edited one sits beside the original
+        var parentID *uint
+        if turn.editOf != nil {
+            parentID = turn.editOf.ParentID
+        } else {
+            parentID, err = s.activeLeafID(ctx, chatID)
+            if err != nil {
+                s.logger.Error("failed to load active branch", "error", err)
+                return err
+            }
         }
 
-        // Save processed version for internal context
-        _, err = s.SaveMessage(ctx, userID, chatID, llmQuery, "internal_context")
-        if err != nil {
-            s.logger.Error("failed to save internal context message", "error", err)
-        }
-    } else {
-        // No processing - save for both display and internal use
-        _, err := s.SaveMessage(ctx, userID, chatID, originalPrompt, "user")
+        // Save original user message for display
+        question, err := s.saveMessage(ctx, userID, chatID, parentID, originalPrompt, domain.MessageTypeUser)
         if err != nil {
             s.logger.Error("failed to save user message", "error", err)
             return err
         }
-        _, err = s.SaveMessage(ctx, userID, chatID, originalPrompt, "internal_context")
+        questionID = question.ID
+        if turn.editOf != nil {
+            if err := s.messageRepo.ActivateBranch(ctx, chatID, questionID); err != nil {
+                s.logger.Error("failed to switch to edited branch", "error", err)
+                return err
+            }
+            s.logger.Info("question edited", "message_id", turn.editOf.ID, "new_message_id", questionID)
+        }
+
+        // Save processed version for internal context (same text when nothing was translated)
+        _, err = s.saveMessage(ctx, userID, chatID, &questionID, llmQuery, domain.MessageTypeInternalContext)
         if err != nil {
             s.logger.Error("failed to save internal context message", "error", err)
         }
@@ -382,13 +592,13 @@ func (s *ChatService) StreamChatMessageWithSources(
 
     // ----- STEP 4: Build SEPARATE embedding and LLM windows -----
     // Refresh pairs to include the new message
-    pairs, err = s.messageRepo.FindRecentUserAssistantPairs(ctx, chatID, memoryPairLimit, "internal_context")
+    pairs, err = s.conversationPairs(ctx, chatID, turn, memoryPairLimit)
     if err != nil {
         pairs = []domain.Message{}
     }
 
     // Use enhanced LLM context instead of simple concatenation
-    llmText := s.buildEnhancedLLMContext(pairs, llmQuery)
+    llmText := s.buildEnhancedLLMContext(pairs, llmQuery, summary)
 
     // For EMBEDDING: Use the FOCUSED query only (KEY ENHANCEMENT)
     embeddingText := embeddingQuery
@@ -397,7 +607,8 @@ func (s *ChatService) StreamChatMessageWithSources(
         "embedding_query", embeddingText,
         "embedding_length", len(embeddingText),
         "llm_context_length", len(llmText),
-        "pairs_count", len(pairs))
+        "pairs_count", len(pairs),
+        "has_summary", summary != "")
 
     // ----- STEP 5: Stream with SEPARATE embedding and LLM text -----
     onStatus("searching", "Finding relevant medical information...")
@@ -406,8 +617,14 @@ func (s *ChatService) StreamChatMessageWithSources(
         ctx,
         userID,
         chatID,
+        questionID,    // The answer is saved under this question
         embeddingText, // FOCUSED query for vector search
         llmText,       // Enhanced context for LLM prompt
+        chatservice.StreamOptions{
+            Language: language, // Answer in the user's language, not the translated one
+            Model:    turn.options.Model,
+            TopK:     turn.options.TopK,
+        },
         onDelta,
         onSources,
         onStatus,
@@ -427,34 +644,245 @@ func (s *ChatService) StreamChatMessageWithSources(
     return err
 }
 
+// responseLanguage decides the answer language from the user's preference and the
+// language the question was asked in. Retrieval still runs on the English translation.
+func (s *ChatService) responseLanguage(ctx context.Context, userID uint, prompt string) string {
+    detected := chatservice.DetectLanguage(prompt)
+    preference := domain.LanguageAuto
+    if s.userRepo != nil {
+        u, err := s.userRepo.FindByID(ctx, userID)
+        if err != nil {
+            s.logger.Warn("failed to load language preference", "user_id", userID, "error", err)
+        } else if u.PreferredLanguage != "" {
+            preference = u.PreferredLanguage
+        }
+    }
+    language := chatservice.ResolveLanguage(preference, detected)
+    s.logger.Debug("resolved response language",
+        "user_id", userID, "detected", detected, "preference", preference, "language", language)
+    return language
+}
+
 // CreateChat creates a new chat with validation and timeout
+// An empty title gives the chat a placeholder that is replaced by a generated one
+// after the first answer.
 func (s *ChatService) CreateChat(ctx context.Context, userID uint, title string) (*domain.Chat, error) {
-    if strings.TrimSpace(title) == "" {
-        return nil, errors.New("chat title cannot be empty")
-    }
-    if len(title) > 100 {
-        title = title[:100]
+    title = strings.TrimSpace(title)
+    source := domain.ChatTitleUser
+    if title == "" {
+        title = domain.DefaultChatTitle
+        source = domain.ChatTitleDefault
     }
+    title = truncateRunes(title, maxChatTitleRunes)
     
     // Add timeout for database operations
     dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
     defer cancel()
     
-    newChat := &domain.Chat{UserID: userID, Title: title}
+    newChat := &domain.Chat{UserID: userID, Title: title, TitleSource: source}
     return s.chatRepo.Create(dbCtx, newChat)
 }
 
+// RenameChat sets a title chosen by the user; it is never replaced automatically
+func (s *ChatService) RenameChat(ctx context.Context, userID, chatID uint, title string) (*domain.Chat, error) {
+    title = truncateRunes(strings.TrimSpace(title), maxChatTitleRunes)
+    if title == "" {
+        return nil, errors.New("chat title cannot be empty")
+    }
+
+    dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    if err := s.chatRepo.UpdateTitle(dbCtx, chatID, userID, title, domain.ChatTitleUser); err != nil {
+        return nil, err
+    }
+    return s.chatRepo.FindByID(dbCtx, chatID)
+}
+
+const (
+    maxChatTitleRunes    = 100
+    maxGeneratedTitleLen = 60 // runes; sidebar-friendly
+    titleTimeout         = 15 * time.Second
+)
+
+// GenerateTitle names a chat that still has its placeholder title from its first question
+// and answer, in the language the user reads answers in. It returns "" when the chat
+// already has a title or no answer has been saved yet.
+func (s *ChatService) GenerateTitle(ctx context.Context, userID, chatID uint) (string, error) {
+    ctx, cancel := context.WithTimeout(ctx, titleTimeout)
+    defer cancel()
+
+    chatRecord, err := s.chatRepo.FindByID(ctx, chatID)
+    if err != nil || chatRecord.UserID != userID {
+        return "", errors.New("unauthorized or chat not found")
+    }
+    if !chatRecord.HasPlaceholderTitle() {
+        return "", nil
+    }
+
+    branch, err := s.messageRepo.FindActiveBranch(ctx, chatID, 0)
+    if err != nil {
+        return "", err
+    }
+    if len(branch) < 2 || branch[0].MessageType != domain.MessageTypeUser || branch[1].MessageType != domain.MessageTypeAssistant {
+        return "", nil
+    }
+    question, answer := branch[0].Content, branch[1].Content
+
+    language := s.responseLanguage(ctx, userID, question)
+    completion, err := s.aiService.GetCompletion(ctx, s.config.ChatModel, titlePrompt(question, answer, language))
+    if err != nil {
+        return "", err
+    }
+    title := cleanGeneratedTitle(completion)
+    if title == "" {
+        return "", errors.New("empty title generated")
+    }
+
+    updated, err := s.chatRepo.ReplacePlaceholderTitle(ctx, chatID, title)
+    if err != nil || !updated {
+        return "", err // renamed by the user meanwhile
+    }
+    s.logger.Info("chat title generated", ctx, "title_length", utf8.RuneCountInString(title))
+    return title, nil
+}
+
+// titlePrompt asks for a short conversation title
+func titlePrompt(question, answer, language string) string {
+    lang := "English"
+    if language == domain.LanguagePersian {
+        lang = "Persian (Farsi), keeping drug names and medical abbreviations in English"
+    }
+    return fmt.Sprintf(`Write a title of at most 6 words for the medical conversation below.
+The title must be in %s. Reply with the title only: no quotes, no trailing punctuation.
+
+Question: %s
+
+Answer: %s`, lang, truncateRunes(question, 500), truncateRunes(answer, 1000))
+}
+
+// cleanGeneratedTitle keeps the first line of a model reply, without quotes, markdown or
+// angle brackets (the sidebar renders titles as HTML)
+func cleanGeneratedTitle(completion string) string {
+    title := strings.NewReplacer("<", "", ">", "").Replace(strings.TrimSpace(completion))
+    if i := strings.IndexByte(title, '\n'); i >= 0 {
+        title = title[:i]
+    }
+    title = strings.TrimLeft(title, "#*- ")
+    title = strings.TrimPrefix(title, "Title:")
+    title = strings.Trim(title, "\"'`*«»“”. ")
+    return truncateRunes(strings.TrimSpace(title), maxGeneratedTitleLen)
+}
+
+// truncateRunes cuts s to at most n characters without splitting a multi-byte rune
+func truncateRunes(s string, n int) string {
+    if utf8.RuneCountInString(s) <= n {
+        return s
+    }
+    return string([]rune(s)[:n])
+}
+
+// ChatBranch is one page of the active branch of a chat
+type ChatBranch struct {
+    Messages []domain.Message
+    Total    int64
+    // Versions lists, for each message on the page that was edited or regenerated,
+    // the IDs of every version at that position, oldest first
+    Versions map[uint][]uint
+}
+
 // GetChatMessages retrieves messages with timeout protection
 func (s *ChatService) GetChatMessagesWithPagination(ctx context.Context, userID, chatID uint, limit, offset int) ([]domain.Message, int64, error) {
+    branch, err := s.GetActiveBranch(ctx, userID, chatID, limit, offset)
+    if err != nil {
+        return nil, 0, err
+    }
+    return branch.Messages, branch.Total, nil
+}
+
+// GetActiveBranch returns a page of the questions and answers on the chat's active branch
+func (s *ChatService) GetActiveBranch(ctx context.Context, userID, chatID uint, limit, offset int) (*ChatBranch, error) {
+    if limit <= 0 || limit > 1000 {
+        return nil, errors.New("invalid limit: must be between 1 and 1000")
+    }
+    if offset < 0 {
+        return nil, errors.New("invalid offset: must be >= 0")
+    }
+
     dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
     defer cancel()
 
     chatRecord, err := s.chatRepo.FindByID(dbCtx, chatID)
     if err != nil || chatRecord.UserID != userID {
-        return nil, 0, errors.New("unauthorized or chat not found")
+        return nil, errors.New("unauthorized or chat not found")
+    }
+
+    page, total, err := s.messageRepo.FindActiveBranchPage(dbCtx, chatID, limit, offset)
+    if err != nil {
+        return nil, err
+    }
+    ids := make([]uint, len(page))
+    for i, m := range page {
+        ids[i] = m.ID
+    }
+    versions, err := s.messageRepo.FindSiblingIDs(dbCtx, chatID, ids)
+    if err != nil {
+        return nil, err
+    }
+    return &ChatBranch{Messages: page, Total: total, Versions: versions}, nil
+}
+
+// SelectBranch switches the chat to the branch through messageID, one of the versions
+// listed in ChatBranch.Versions
+func (s *ChatService) SelectBranch(ctx context.Context, userID, chatID, messageID uint) error {
+    dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    chatRecord, err := s.chatRepo.FindByID(dbCtx, chatID)
+    if err != nil || chatRecord.UserID != userID {
+        return errors.New("unauthorized or chat not found")
+    }
+    if err := s.messageRepo.ActivateBranch(dbCtx, chatID, messageID); err != nil {
+        return err
+    }
+    s.logger.Info("branch selected", ctx, "message_id", messageID)
+    return nil
+}
+
+// GetEditableQuestion returns a question of the user's chat that can be edited
+func (s *ChatService) GetEditableQuestion(ctx context.Context, userID, chatID, messageID uint) (*domain.Message, error) {
+    dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    chatRecord, err := s.chatRepo.FindByID(dbCtx, chatID)
+    if err != nil || chatRecord.UserID != userID {
+        return nil, errors.New("unauthorized or chat not found")
+    }
+    msg, err := s.messageRepo.FindByID(dbCtx, messageID)
+    if err != nil || msg.ChatID != chatID {
+        return nil, errors.New("message not found")
+    }
+    if msg.MessageType != domain.MessageTypeUser {
+        return nil, errors.New("only questions can be edited")
     }
-    // Always fetch paginated
-    return s.messageRepo.FindByChatIDWithPagination(dbCtx, chatID, limit, offset)
+    return msg, nil
+}
+
+// EditQuestion answers a new version of an earlier question. The original question and
+// everything after it stay in the chat as a sibling branch the user can switch back to.
+func (s *ChatService) EditQuestion(
+    ctx context.Context,
+    userID, chatID, messageID uint,
+    prompt string,
+    onDelta func(string) error,
+    onSources func([]string),
+    onStatus func(status string, message string),
+) error {
+    original, err := s.GetEditableQuestion(ctx, userID, chatID, messageID)
+    if err != nil {
+        return err
+    }
+    return s.streamChat(ctx, userID, chatID, prompt, chatTurn{editOf: original}, onDelta, onSources, onStatus)
 }
 
 // DeleteChat deletes a chat with proper cleanup and timeout
@@ -475,13 +903,22 @@ func (s *ChatService) DeleteChat(ctx context.Context, userID, chatID uint) error
         return err
     }
     
+    if err := s.chatRepo.RevokeSharesByChat(dbCtx, chatID); err != nil {
+        s.logger.Warn("failed to revoke share links for chat",
+            "error", err, "chat_id", chatID, "user_id", userID)
+    }
+    if err := s.chatRepo.DeleteSummary(dbCtx, chatID); err != nil {
+        s.logger.Warn("failed to delete summary for chat",
+            "error", err, "chat_id", chatID, "user_id", userID)
+    }
+
     // Then delete the chat
     return s.chatRepo.Delete(dbCtx, chatID, userID)
 }
 
-// GetUserChats retrieves user chats with default pagination
+// GetUserChats retrieves the user's unarchived chats, pinned first, with default pagination
 func (s *ChatService) GetUserChats(ctx context.Context, userID uint) ([]domain.Chat, error) {
-    chats, _, err := s.GetUserChatsWithPagination(ctx, userID, 100, 0)
+    chats, _, err := s.ListChats(ctx, userID, chat.ChatFilter{}, 100, 0)
     return chats, err
 }
 
@@ -494,8 +931,33 @@ func (s *ChatService) GetUserChatsWithPagination(ctx context.Context, userID uin
     return s.chatRepo.FindByUserIDWithPagination(dbCtx, userID, limit, offset)
 }
 
-// SaveMessage saves a message with validation and timeout
+// SaveMessage saves a message with validation and timeout, appending it to the active branch
 func (s *ChatService) SaveMessage(ctx context.Context, userID, chatID uint, content, messageType string) (*domain.Message, error) {
+    parentID, err := s.activeLeafID(ctx, chatID)
+    if err != nil {
+        return nil, err
+    }
+    return s.saveMessage(ctx, userID, chatID, parentID, content, messageType)
+}
+
+// activeLeafID returns the last message on the chat's active branch, nil for an empty chat
+func (s *ChatService) activeLeafID(ctx context.Context, chatID uint) (*uint, error) {
+    dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+    defer cancel()
+
+    branch, err := s.messageRepo.FindActiveBranch(dbCtx, chatID, 1)
+    if err != nil {
+        return nil, err
+    }
+    if len(branch) == 0 {
+        return nil, nil
+    }
+    leafID := branch[len(branch)-1].ID
+    return &leafID, nil
+}
+
+// saveMessage saves a message under parentID
+func (s *ChatService) saveMessage(ctx context.Context, userID, chatID uint, parentID *uint, content, messageType string) (*domain.Message, error) {
     // Add timeout for database operations
     dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
     defer cancel()
@@ -505,12 +967,29 @@ func (s *ChatService) SaveMessage(ctx context.Context, userID, chatID uint, cont
         return nil, errors.New("unauthorized or chat not found")
     }
     
-    message := &domain.Message{
+    msg := &domain.Message{
         ChatID:      chatID,
         Content:     content,
         MessageType: messageType,
+        ParentID:    parentID,
+        Active:      true,
     }
-    return s.messageRepo.Create(dbCtx, message)
+    return s.messageRepo.Create(dbCtx, msg)
+}
+
+// CheckChatOwner returns chat.ErrChatNotFound unless the chat exists and belongs to the user
+func (s *ChatService) CheckChatOwner(ctx context.Context, userID, chatID uint) error {
+    dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    owned, err := s.chatRepo.VerifyOwnership(dbCtx, chatID, userID)
+    if err != nil {
+        return err
+    }
+    if !owned {
+        return chat.ErrChatNotFound
+    }
+    return nil
 }
 
 // GetPerformanceMetrics returns current performance and health metrics
@@ -0,0 +1,110 @@
+// File: internal/services/chat_share.go
+package services
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "time"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/repository/chat"
+)
+
+const (
+    shareTokenBytes = 24                   // 192 bits, 32 URL-safe characters
+    maxShareTTL     = 365 * 24 * time.Hour // longest expiry a link can be given
+)
+
+// ErrShareUnavailable is returned for unknown, revoked and expired share links alike,
+// so a visitor cannot tell which
+var ErrShareUnavailable = errors.New("share link not found or no longer available")
+
+// SharedChat is what a share link shows: the title and the questions and answers on
+// the chat's active branch, without anything identifying the owner
+type SharedChat struct {
+    Title     string
+    CreatedAt time.Time
+    Messages  []domain.Message
+}
+
+// CreateShare issues a read-only link to a chat. ttl of zero means the link never expires.
+func (s *ChatService) CreateShare(ctx context.Context, userID, chatID uint, ttl time.Duration) (*domain.ChatShare, error) {
+    if ttl < 0 || ttl > maxShareTTL {
+        return nil, errors.New("share expiry must be at most 365 days")
+    }
+
+    chatRecord, err := s.chatRepo.FindByID(ctx, chatID)
+    if err != nil || chatRecord.UserID != userID {
+        return nil, errors.New("unauthorized or chat not found")
+    }
+
+    raw := make([]byte, shareTokenBytes)
+    if _, err := rand.Read(raw); err != nil {
+        return nil, err
+    }
+    share := &domain.ChatShare{
+        ChatID: chatID,
+        UserID: userID,
+        Token:  base64.RawURLEncoding.EncodeToString(raw),
+    }
+    if ttl > 0 {
+        expires := time.Now().Add(ttl)
+        share.ExpiresAt = &expires
+    }
+    if err := s.chatRepo.CreateShare(ctx, share); err != nil {
+        return nil, err
+    }
+    s.logger.Info("share link created", ctx, "user_id", userID, "chat_id", chatID, "share_id", share.ID, "expires", share.ExpiresAt != nil)
+    return share, nil
+}
+
+// ListShares returns the chat's share links, including revoked and expired ones, with
+// their view counts
+func (s *ChatService) ListShares(ctx context.Context, userID, chatID uint) ([]domain.ChatShare, error) {
+    chatRecord, err := s.chatRepo.FindByID(ctx, chatID)
+    if err != nil || chatRecord.UserID != userID {
+        return nil, errors.New("unauthorized or chat not found")
+    }
+    return s.chatRepo.FindSharesByChat(ctx, chatID, userID)
+}
+
+// RevokeShare disables one of the user's share links
+func (s *ChatService) RevokeShare(ctx context.Context, userID, chatID, shareID uint) error {
+    if err := s.chatRepo.RevokeShare(ctx, shareID, chatID, userID); err != nil {
+        return err
+    }
+    s.logger.Info("share link revoked", ctx, "user_id", userID, "chat_id", chatID, "share_id", shareID)
+    return nil
+}
+
+// ViewSharedChat resolves a share token to the conversation it exposes and counts the view.
+// Internal context messages are never part of the active branch, so they are not shown.
+// Links of deleted or suspended accounts are unavailable like revoked ones.
+func (s *ChatService) ViewSharedChat(ctx context.Context, token string) (*SharedChat, error) {
+    share, err := s.chatRepo.FindShareByToken(ctx, token)
+    if err != nil {
+        if errors.Is(err, chat.ErrShareNotFound) {
+            return nil, ErrShareUnavailable
+        }
+        return nil, err
+    }
+    if !share.IsActive(time.Now()) {
+        return nil, ErrShareUnavailable
+    }
+
+    chatRecord, err := s.chatRepo.FindByID(ctx, share.ChatID)
+    if err != nil || chatRecord.UserID != share.UserID {
+        return nil, ErrShareUnavailable // chat deleted since the link was made
+    }
+    branch, err := s.messageRepo.FindActiveBranch(ctx, share.ChatID, 0)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := s.chatRepo.RecordShareView(ctx, share.ID); err != nil {
+        s.logger.Warn("failed to count share view", ctx, "share_id", share.ID, "error", err)
+    }
+    return &SharedChat{Title: chatRecord.Title, CreatedAt: chatRecord.CreatedAt, Messages: branch}, nil
+}
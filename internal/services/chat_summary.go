@@ -0,0 +1,157 @@
+// File: internal/services/chat_summary.go
+package services
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/repository/chat"
+)
+
+const (
+    summaryTimeout        = 30 * time.Second
+    maxSummaryRunes       = 2000 // stored summary; keeps the prompt overhead bounded
+    maxSummaryRebuildPairs = 10   // pairs read when a summary is rebuilt from scratch
+)
+
+// UpdateSummary folds the answers added to the chat's active branch since the last
+// update into its running summary. When the summary was built on another branch
+// (after an edit or a version switch) it is rebuilt from the branch's latest pairs.
+func (s *ChatService) UpdateSummary(ctx context.Context, userID, chatID uint) error {
+    ctx, cancel := context.WithTimeout(ctx, summaryTimeout)
+    defer cancel()
+
+    chatRecord, err := s.chatRepo.FindByID(ctx, chatID)
+    if err != nil || chatRecord.UserID != userID {
+        return errors.New("unauthorized or chat not found")
+    }
+
+    branch, err := s.messageRepo.FindActiveBranch(ctx, chatID, 0)
+    if err != nil {
+        return err
+    }
+
+    previous := ""
+    start := 0
+    existing, err := s.chatRepo.FindSummary(ctx, chatID)
+    switch {
+    case err == nil:
+        if i := messageIndex(branch, existing.ThroughMessageID); i >= 0 {
+            previous = existing.Summary
+            start = i + 1
+        }
+    case !errors.Is(err, chat.ErrSummaryNotFound):
+        return err
+    }
+
+    exchanges, throughID := summaryExchanges(branch[start:])
+    if len(exchanges) == 0 {
+        return nil
+    }
+    if previous == "" && len(exchanges) > maxSummaryRebuildPairs {
+        exchanges = exchanges[len(exchanges)-maxSummaryRebuildPairs:]
+    }
+
+    completion, err := s.aiService.GetCompletion(ctx, s.config.ChatModel, summaryPrompt(previous, exchanges))
+    if err != nil {
+        return err
+    }
+    summary := truncateRunes(strings.TrimSpace(completion), maxSummaryRunes)
+    if summary == "" {
+        return errors.New("empty summary generated")
+    }
+
+    if err := s.chatRepo.SaveSummary(ctx, &domain.ChatSummary{
+        ChatID:           chatID,
+        ThroughMessageID: throughID,
+        Summary:          summary,
+    }); err != nil {
+        return err
+    }
+    s.logger.Info("chat summary updated", ctx,
+        "exchanges", len(exchanges), "through_message_id", throughID, "rebuilt", previous == "")
+    return nil
+}
+
+// branchSummary returns the running summary when it describes the branch this turn
+// continues. A summary that already covers the edited or regenerated question, or was
+// built on another branch, would leak the answer being replaced, so it is skipped.
+func (s *ChatService) branchSummary(ctx context.Context, chatID uint, turn chatTurn) string {
+    existing, err := s.chatRepo.FindSummary(ctx, chatID)
+    if err != nil {
+        if !errors.Is(err, chat.ErrSummaryNotFound) {
+            s.logger.Warn("failed to load chat summary", "error", err)
+        }
+        return ""
+    }
+
+    switch {
+    case turn.regenerate != nil && existing.ThroughMessageID >= turn.regenerate.ID:
+        return ""
+    case turn.editOf != nil && existing.ThroughMessageID >= turn.editOf.ID:
+        return ""
+    }
+
+    branch, err := s.messageRepo.FindActiveBranch(ctx, chatID, 0)
+    if err != nil || messageIndex(branch, existing.ThroughMessageID) < 0 {
+        return ""
+    }
+    return existing.Summary
+}
+
+// summaryExchanges renders the completed question/answer pairs of a branch segment and
+// returns the ID of the last answer included
+func summaryExchanges(branch []domain.Message) ([]string, uint) {
+    var exchanges []string
+    var throughID uint
+    var question string
+    for _, m := range branch {
+        switch m.MessageType {
+        case domain.MessageTypeUser:
+            question = m.Content
+        case domain.MessageTypeAssistant:
+            if question == "" {
+                continue
+            }
+            exchanges = append(exchanges, fmt.Sprintf("Question: %s\nAnswer: %s",
+                truncateRunes(question, 1000), truncateRunes(m.Content, 3000)))
+            throughID = m.ID
+            question = ""
+        }
+    }
+    return exchanges, throughID
+}
+
+// messageIndex is the position of a message on a branch, -1 when it is not on it
+func messageIndex(branch []domain.Message, messageID uint) int {
+    for i := range branch {
+        if branch[i].ID == messageID {
+            return i
+        }
+    }
+    return -1
+}
+
+// summaryPrompt asks the completion model to extend the running summary with new exchanges
+func summaryPrompt(previous string, exchanges []string) string {
+    if previous == "" {
+        previous = "(none yet)"
+    }
+    return fmt.Sprintf(`You maintain a running summary of a clinical conversation between a physician and a medical assistant.
+Update the summary with the new exchanges below. Write it in English, in at most 200 words, under these headings:
+Patient facts: age, sex, history, findings, labs and medications mentioned.
+Differentials: diagnoses under consideration and what argues for or against them.
+Decisions: tests, treatments and plans agreed on.
+Open questions: what is still unresolved.
+Drop details that were superseded. Do not invent facts. Reply with the summary only.
+
+Current summary:
+%s
+
+New exchanges:
+%s`, previous, strings.Join(exchanges, "\n\n"))
+}
@@ -0,0 +1,139 @@
+// File: internal/services/export/export.go
+package export
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+    "time"
+    "unicode"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+    FormatPDF      Format = "pdf"
+    FormatMarkdown Format = "md"
+    FormatJSON     Format = "json"
+)
+
+// ErrUnsupportedFormat is returned for formats other than pdf, md and json
+var ErrUnsupportedFormat = errors.New("unsupported export format, use pdf, md or json")
+
+// ParseFormat validates a format query parameter; empty means PDF
+func ParseFormat(s string) (Format, error) {
+    switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+    case "":
+        return FormatPDF, nil
+    case FormatPDF, FormatMarkdown, FormatJSON:
+        return f, nil
+    case "markdown":
+        return FormatMarkdown, nil
+    }
+    return "", ErrUnsupportedFormat
+}
+
+// ContentType is the MIME type served for the format
+func (f Format) ContentType() string {
+    switch f {
+    case FormatMarkdown:
+        return "text/markdown; charset=utf-8"
+    case FormatJSON:
+        return "application/json; charset=utf-8"
+    }
+    return "application/pdf"
+}
+
+// Conversation is the exported view of a chat's active branch
+type Conversation struct {
+    ChatID     uint      `json:"chat_id"`
+    Title      string    `json:"title"`
+    CreatedAt  time.Time `json:"created_at"`
+    ExportedAt time.Time `json:"exported_at"`
+    Messages   []Message `json:"messages"`
+}
+
+// Message is one question or answer. Sources are only set on answers.
+type Message struct {
+    ID        uint      `json:"id"`
+    Role      string    `json:"role"` // "question" or "answer"
+    Content   string    `json:"content"`
+    Sources   []string  `json:"sources,omitempty"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// Message roles
+const (
+    RoleQuestion = "question"
+    RoleAnswer   = "answer"
+)
+
+// Write renders a conversation in the given format
+func Write(w io.Writer, format Format, conv *Conversation) error {
+    switch format {
+    case FormatPDF:
+        return writePDF(w, conv)
+    case FormatMarkdown:
+        return writeMarkdown(w, conv)
+    case FormatJSON:
+        enc := json.NewEncoder(w)
+        enc.SetIndent("", "  ")
+        return enc.Encode(conv)
+    }
+    return ErrUnsupportedFormat
+}
+
+// FileName is a download name for the conversation, e.g. "chat-42-chest-pain.pdf".
+// Only ASCII letters and digits from the title are kept so it is safe in any header.
+func FileName(conv *Conversation, format Format) string {
+    var slug strings.Builder
+    dash := false
+    for _, r := range strings.ToLower(conv.Title) {
+        switch {
+        case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+            slug.WriteRune(r)
+            dash = false
+        case slug.Len() > 0 && !dash:
+            slug.WriteByte('-')
+            dash = true
+        }
+        if slug.Len() >= 40 {
+            break
+        }
+    }
+    name := strings.Trim(slug.String(), "-")
+    if name == "" {
+        return fmt.Sprintf("chat-%d.%s", conv.ChatID, format)
+    }
+    return fmt.Sprintf("chat-%d-%s.%s", conv.ChatID, name, format)
+}
+
+// dateTime formats timestamps the same way in every format
+func dateTime(t time.Time) string {
+    return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+// writeMarkdown renders the conversation as Markdown; answers already are Markdown
+func writeMarkdown(w io.Writer, conv *Conversation) error {
+    var b strings.Builder
+    fmt.Fprintf(&b, "# %s\n\n", conv.Title)
+    fmt.Fprintf(&b, "_Created %s · Exported %s_\n", dateTime(conv.CreatedAt), dateTime(conv.ExportedAt))
+    for _, m := range conv.Messages {
+        label := "Question"
+        if m.Role == RoleAnswer {
+            label = "Answer"
+        }
+        fmt.Fprintf(&b, "\n---\n\n## %s\n\n_%s_\n\n%s\n", label, dateTime(m.CreatedAt), strings.TrimSpace(m.Content))
+        if len(m.Sources) > 0 {
+            b.WriteString("\n**Sources**\n\n")
+            for _, s := range m.Sources {
+                fmt.Fprintf(&b, "- %s\n", s)
+            }
+        }
+    }
+    _, err := io.WriteString(w, b.String())
+    return err
+}
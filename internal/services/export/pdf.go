@@ -0,0 +1,618 @@
+// File: internal/services/export/pdf.go
+package export
+
+import (
+    "bytes"
+    "compress/zlib"
+    "encoding/binary"
+    "embed"
+    "fmt"
+    "io"
+    "path"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "unicode"
+    "unicode/utf16"
+)
+
+// Fonts embedded in every PDF. Inter covers Latin text and DejaVu Sans draws
+// Arabic-script runs; any other TrueType font dropped into fonts/ is used as a
+// fallback for the characters both lack. Files with "Bold" in the name serve the
+// bold style.
+//
+//go:embed fonts/*.ttf
+var fontFiles embed.FS
+
+const (
+    primaryRegularFont = "Inter-Regular.ttf"
+    primaryBoldFont    = "Inter-Bold.ttf"
+    arabicRegularFont  = "DejaVuSans.ttf"
+    arabicBoldFont     = "DejaVuSans-Bold.ttf"
+)
+
+var (
+    loadFontsOnce sync.Once
+    regularFonts  []*trueTypeFont
+    boldFonts     []*trueTypeFont
+    loadFontsErr  error
+)
+
+// loadFonts parses the embedded fonts once. The chains start with Inter and the
+// Arabic-script font, followed by the other fallbacks in file-name order.
+func loadFonts() ([]*trueTypeFont, []*trueTypeFont, error) {
+    loadFontsOnce.Do(func() {
+        entries, err := fontFiles.ReadDir("fonts")
+        if err != nil {
+            loadFontsErr = err
+            return
+        }
+        names := make([]string, 0, len(entries))
+        for _, e := range entries {
+            names = append(names, e.Name())
+        }
+        sort.SliceStable(names, func(i, j int) bool {
+            rank := func(n string) int {
+                switch n {
+                case primaryRegularFont, primaryBoldFont:
+                    return 0
+                case arabicRegularFont, arabicBoldFont:
+                    return 1
+                }
+                return 2
+            }
+            return rank(names[i]) < rank(names[j])
+        })
+
+        var regularFallbacks, boldFallbacks []*trueTypeFont
+        for _, name := range names {
+            data, err := fontFiles.ReadFile(path.Join("fonts", name))
+            if err != nil {
+                loadFontsErr = err
+                return
+            }
+            f, err := parseTrueType(strings.TrimSuffix(name, ".ttf"), data)
+            if err != nil {
+                loadFontsErr = err
+                return
+            }
+            switch {
+            case name == primaryRegularFont:
+                regularFonts = append([]*trueTypeFont{f}, regularFonts...)
+            case name == primaryBoldFont:
+                boldFonts = append([]*trueTypeFont{f}, boldFonts...)
+            case name == arabicRegularFont:
+                f.arabic = true
+                regularFonts = append(regularFonts, f)
+            case name == arabicBoldFont:
+                f.arabic = true
+                boldFonts = append(boldFonts, f)
+            case strings.Contains(name, "Bold"):
+                boldFallbacks = append(boldFallbacks, f)
+            default:
+                regularFallbacks = append(regularFallbacks, f)
+            }
+        }
+        if len(regularFonts) != 2 || len(boldFonts) != 2 || !regularFonts[1].arabic || !boldFonts[1].arabic {
+            loadFontsErr = fmt.Errorf("export fonts %s, %s, %s and %s are required",
+                primaryRegularFont, primaryBoldFont, arabicRegularFont, arabicBoldFont)
+            return
+        }
+        // Bold text falls back to the regular fallbacks when no bold variant exists
+        regularFonts = append(regularFonts, regularFallbacks...)
+        boldFonts = append(boldFonts, boldFallbacks...)
+        boldFonts = append(boldFonts, regularFallbacks...)
+    })
+    return regularFonts, boldFonts, loadFontsErr
+}
+
+// Page geometry in points (A4)
+const (
+    pageWidth   = 595.28
+    pageHeight  = 841.89
+    pageMargin  = 56.0
+    lineSpacing = 1.45
+)
+
+// pdfFont is a font as used by one document: the glyphs drawn with it are recorded
+// for the width table and the ToUnicode map that makes text copyable.
+type pdfFont struct {
+    ttf      *trueTypeFont
+    resource string // resource name, e.g. F1
+    used     map[uint16]rune
+}
+
+// textStyle describes how a paragraph is drawn
+type textStyle struct {
+    size       float64
+    bold       bool
+    gray       float64 // 0 is black
+    indent     float64
+    spaceAfter float64
+}
+
+var (
+    styleTitle   = textStyle{size: 18, bold: true, spaceAfter: 4}
+    styleMeta    = textStyle{size: 9, gray: 0.45, spaceAfter: 2}
+    styleLabel   = textStyle{size: 11, bold: true, gray: 0.1, spaceAfter: 2}
+    styleHeading = textStyle{size: 11.5, bold: true, spaceAfter: 3}
+    styleBody    = textStyle{size: 10.5, spaceAfter: 5}
+    styleBullet  = textStyle{size: 10.5, indent: 14, spaceAfter: 2}
+    styleSource  = textStyle{size: 9, gray: 0.35, indent: 10, spaceAfter: 1}
+)
+
+// pdfDocument lays text out on pages top to bottom
+type pdfDocument struct {
+    fonts   map[*trueTypeFont]*pdfFont
+    order   []*pdfFont
+    regular []*trueTypeFont
+    bold    []*trueTypeFont
+    pages   []*bytes.Buffer
+    page    *bytes.Buffer
+    y       float64
+}
+
+func newPDFDocument() (*pdfDocument, error) {
+    regular, bold, err := loadFonts()
+    if err != nil {
+        return nil, err
+    }
+    d := &pdfDocument{fonts: make(map[*trueTypeFont]*pdfFont), regular: regular, bold: bold}
+    d.newPage()
+    return d, nil
+}
+
+func (d *pdfDocument) newPage() {
+    d.page = &bytes.Buffer{}
+    d.pages = append(d.pages, d.page)
+    d.y = pageHeight - pageMargin
+}
+
+// font returns the document font for a TrueType font, registering it on first use
+func (d *pdfDocument) font(ttf *trueTypeFont) *pdfFont {
+    f, ok := d.fonts[ttf]
+    if !ok {
+        f = &pdfFont{ttf: ttf, resource: fmt.Sprintf("F%d", len(d.order)+1), used: make(map[uint16]rune)}
+        d.fonts[ttf] = f
+        d.order = append(d.order, f)
+    }
+    return f
+}
+
+// glyph picks the font that draws r. Arabic-script characters go to the Arabic font
+// first, so a Persian run keeps one typeface even where Inter has a few of its
+// letters; anything else goes to the first font in the chain that has it. Characters
+// no font has are drawn with the primary font's missing-glyph box so the gap stays
+// visible.
+func glyph(chain []*trueTypeFont, r rune) (*trueTypeFont, uint16) {
+    if unicode.Is(unicode.Arabic, r) {
+        for _, f := range chain {
+            if !f.arabic {
+                continue
+            }
+            if gid, ok := f.glyph(r); ok {
+                return f, gid
+            }
+        }
+    }
+    for _, f := range chain {
+        if gid, ok := f.glyph(r); ok {
+            return f, gid
+        }
+    }
+    return chain[0], 0
+}
+
+// drawable reports whether r should occupy space on the line; invisible format
+// characters such as the zero-width non-joiner are dropped when no font has them.
+func drawable(chain []*trueTypeFont, r rune) bool {
+    if !unicode.Is(unicode.Cf, r) {
+        return true
+    }
+    for _, f := range chain {
+        if _, ok := f.glyph(r); ok {
+            return true
+        }
+    }
+    return false
+}
+
+func (d *pdfDocument) chain(style textStyle) []*trueTypeFont {
+    if style.bold {
+        return d.bold
+    }
+    return d.regular
+}
+
+// runeWidth is the advance of r in points at the style's size
+func (d *pdfDocument) runeWidth(style textStyle, r rune) float64 {
+    f, gid := glyph(d.chain(style), r)
+    return f.advance(gid) * style.size / 1000
+}
+
+// paragraph wraps, shapes and draws a block of text. Right-to-left paragraphs are
+// right-aligned.
+func (d *pdfDocument) paragraph(text string, style textStyle) {
+    chain := d.chain(style)
+    logical := make([]rune, 0, len(text))
+    for _, r := range strings.TrimSpace(text) {
+        if r == '\t' {
+            r = ' '
+        }
+        if drawable(chain, r) {
+            logical = append(logical, r)
+        }
+    }
+    if len(logical) == 0 {
+        return
+    }
+
+    rtl := isRTLParagraph(logical)
+    shaped := shapeArabic(logical, func(r rune) bool {
+        _, gid := glyph(chain, r)
+        return gid != 0
+    })
+
+    width := pageWidth - 2*pageMargin - style.indent
+    lineHeight := style.size * lineSpacing
+    for _, line := range d.wrap(shaped, style, width) {
+        if d.y-lineHeight < pageMargin {
+            d.newPage()
+        }
+        d.y -= lineHeight
+        visual := visualOrder(line, rtl)
+
+        var lineWidth float64
+        for _, r := range visual {
+            lineWidth += d.runeWidth(style, r)
+        }
+        x := pageMargin + style.indent
+        if rtl {
+            x = pageWidth - pageMargin - style.indent - lineWidth
+        }
+        d.drawLine(visual, x, d.y+style.size*0.25, style)
+    }
+    d.y -= style.spaceAfter
+}
+
+// wrap breaks text into lines at spaces, splitting words wider than a line
+func (d *pdfDocument) wrap(text []rune, style textStyle, width float64) [][]rune {
+    var lines [][]rune
+    var line []rune
+    var lineWidth float64
+
+    flush := func() {
+        for len(line) > 0 && line[len(line)-1] == ' ' {
+            line = line[:len(line)-1]
+        }
+        lines = append(lines, line)
+        line, lineWidth = nil, 0
+    }
+
+    for i := 0; i < len(text); {
+        j := i
+        for j < len(text) && text[j] != ' ' {
+            j++
+        }
+        for j < len(text) && text[j] == ' ' {
+            j++
+        }
+        word := text[i:j]
+        var wordWidth float64
+        for _, r := range word {
+            wordWidth += d.runeWidth(style, r)
+        }
+
+        if lineWidth+wordWidth > width && len(line) > 0 {
+            flush()
+        }
+        if wordWidth > width {
+            for _, r := range word {
+                w := d.runeWidth(style, r)
+                if lineWidth+w > width && len(line) > 0 {
+                    flush()
+                }
+                line = append(line, r)
+                lineWidth += w
+            }
+        } else {
+            line = append(line, word...)
+            lineWidth += wordWidth
+        }
+        i = j
+    }
+    if len(line) > 0 {
+        flush()
+    }
+    return lines
+}
+
+// drawLine writes one visual-order line, switching fonts where the fallback chain does
+func (d *pdfDocument) drawLine(visual []rune, x, y float64, style textStyle) {
+    chain := d.chain(style)
+    fmt.Fprintf(d.page, "%.3f g\n", style.gray)
+    for i := 0; i < len(visual); {
+        ttf, _ := glyph(chain, visual[i])
+        font := d.font(ttf)
+
+        var hex strings.Builder
+        var runWidth float64
+        j := i
+        for ; j < len(visual); j++ {
+            f, gid := glyph(chain, visual[j])
+            if f != ttf {
+                break
+            }
+            font.used[gid] = visual[j]
+            fmt.Fprintf(&hex, "%04X", gid)
+            runWidth += f.advance(gid) * style.size / 1000
+        }
+        fmt.Fprintf(d.page, "BT /%s %.2f Tf 1 0 0 1 %.2f %.2f Tm <%s> Tj ET\n", font.resource, style.size, x, y, hex.String())
+        x += runWidth
+        i = j
+    }
+}
+
+// rule draws a thin horizontal separator
+func (d *pdfDocument) rule() {
+    if d.y-12 < pageMargin {
+        d.newPage()
+        return
+    }
+    d.y -= 6
+    fmt.Fprintf(d.page, "0.85 G 0.5 w %.2f %.2f m %.2f %.2f l S\n", pageMargin, d.y, pageWidth-pageMargin, d.y)
+    d.y -= 8
+}
+
+// Markdown constructs flattened for print
+var (
+    mdLink     = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+    mdEmphasis = strings.NewReplacer("**", "", "__", "", "`", "")
+    mdHeading  = regexp.MustCompile(`^#{1,6}\s+`)
+    mdBullet   = regexp.MustCompile(`^\s*[-*+]\s+`)
+    mdNumbered = regexp.MustCompile(`^\s*\d+[.)]\s+`)
+    mdTableSep = regexp.MustCompile(`^\s*\|?\s*:?-{2,}`)
+)
+
+// markdown draws an answer, keeping its headings, lists and table rows readable
+func (d *pdfDocument) markdown(content string) {
+    var para []string
+    flush := func() {
+        if len(para) > 0 {
+            d.paragraph(strings.Join(para, " "), styleBody)
+            para = nil
+        }
+    }
+
+    for _, raw := range strings.Split(content, "\n") {
+        line := mdEmphasis.Replace(mdLink.ReplaceAllString(strings.TrimRight(raw, " \r"), "$1 ($2)"))
+        trimmed := strings.TrimSpace(line)
+        switch {
+        case trimmed == "":
+            flush()
+        case mdHeading.MatchString(trimmed):
+            flush()
+            d.paragraph(mdHeading.ReplaceAllString(trimmed, ""), styleHeading)
+        case mdTableSep.MatchString(trimmed) && strings.Trim(trimmed, "|:- ") == "":
+            // table header separator
+        case strings.HasPrefix(trimmed, "|"):
+            flush()
+            cells := strings.Split(strings.Trim(trimmed, "|"), "|")
+            for i := range cells {
+                cells[i] = strings.TrimSpace(cells[i])
+            }
+            d.paragraph(strings.Join(cells, "  |  "), styleBullet)
+        case mdBullet.MatchString(line):
+            flush()
+            d.paragraph("• "+mdBullet.ReplaceAllString(line, ""), styleBullet)
+        case mdNumbered.MatchString(line):
+            flush()
+            d.paragraph(trimmed, styleBullet)
+        default:
+            para = append(para, trimmed)
+        }
+    }
+    flush()
+}
+
+// writePDF renders the conversation and serialises the document
+func writePDF(w io.Writer, conv *Conversation) error {
+    d, err := newPDFDocument()
+    if err != nil {
+        return err
+    }
+
+    d.paragraph(conv.Title, styleTitle)
+    d.paragraph("Created "+dateTime(conv.CreatedAt)+"  ·  Exported "+dateTime(conv.ExportedAt), styleMeta)
+    for _, m := range conv.Messages {
+        d.rule()
+        label := "Question"
+        if m.Role == RoleAnswer {
+            label = "Answer"
+        }
+        d.paragraph(label+"  ·  "+dateTime(m.CreatedAt), styleLabel)
+        if m.Role == RoleAnswer {
+            d.markdown(m.Content)
+        } else {
+            for _, p := range strings.Split(m.Content, "\n") {
+                d.paragraph(p, styleBody)
+            }
+        }
+        if len(m.Sources) > 0 {
+            d.paragraph("Sources", styleLabel)
+            for _, s := range m.Sources {
+                d.paragraph("• "+s, styleSource)
+            }
+        }
+    }
+    return d.serialize(w, conv.Title)
+}
+
+// pdfWriter numbers objects and records their offsets for the cross-reference table
+type pdfWriter struct {
+    buf     bytes.Buffer
+    offsets []int
+}
+
+// reserve allocates an object number to be written later
+func (p *pdfWriter) reserve() int {
+    p.offsets = append(p.offsets, 0)
+    return len(p.offsets)
+}
+
+func (p *pdfWriter) object(num int, body string) {
+    p.offsets[num-1] = p.buf.Len()
+    fmt.Fprintf(&p.buf, "%d 0 obj\n%s\nendobj\n", num, body)
+}
+
+// stream writes a Flate-compressed stream object
+func (p *pdfWriter) stream(num int, dict string, data []byte) error {
+    var z bytes.Buffer
+    zw := zlib.NewWriter(&z)
+    if _, err := zw.Write(data); err != nil {
+        return err
+    }
+    if err := zw.Close(); err != nil {
+        return err
+    }
+    p.offsets[num-1] = p.buf.Len()
+    fmt.Fprintf(&p.buf, "%d 0 obj\n<< %s /Filter /FlateDecode /Length %d >>\nstream\n", num, dict, z.Len())
+    p.buf.Write(z.Bytes())
+    p.buf.WriteString("\nendstream\nendobj\n")
+    return nil
+}
+
+// serialize writes the pages and the fonts they use as a PDF 1.7 file
+func (d *pdfDocument) serialize(w io.Writer, title string) error {
+    p := &pdfWriter{}
+    p.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+    catalog := p.reserve()
+    pagesObj := p.reserve()
+    info := p.reserve()
+
+    fontRefs := make([]string, 0, len(d.order))
+    for _, f := range d.order {
+        num, err := p.writeFont(f)
+        if err != nil {
+            return err
+        }
+        fontRefs = append(fontRefs, fmt.Sprintf("/%s %d 0 R", f.resource, num))
+    }
+    resources := fmt.Sprintf("<< /Font << %s >> >>", strings.Join(fontRefs, " "))
+
+    kids := make([]string, 0, len(d.pages))
+    for _, content := range d.pages {
+        pageObj := p.reserve()
+        contentObj := p.reserve()
+        if err := p.stream(contentObj, "", content.Bytes()); err != nil {
+            return err
+        }
+        p.object(pageObj, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+            pagesObj, pageWidth, pageHeight, resources, contentObj))
+        kids = append(kids, fmt.Sprintf("%d 0 R", pageObj))
+    }
+
+    p.object(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+    p.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+    p.object(info, fmt.Sprintf("<< /Title %s /Producer (Internist) >>", pdfTextString(title)))
+
+    xref := p.buf.Len()
+    fmt.Fprintf(&p.buf, "xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+    for _, off := range p.offsets {
+        fmt.Fprintf(&p.buf, "%010d 00000 n \n", off)
+    }
+    fmt.Fprintf(&p.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+        len(p.offsets)+1, catalog, info, xref)
+
+    _, err := w.Write(p.buf.Bytes())
+    return err
+}
+
+// writeFont embeds a TrueType font as a CID-keyed Type0 font. Only the glyphs the
+// document draws are embedded; CIDs stay the original glyph IDs, so the widths and
+// ToUnicode map are keyed by them, and CIDToGIDMap points each at its subset glyph.
+func (p *pdfWriter) writeFont(f *pdfFont) (int, error) {
+    ttf := f.ttf
+    fontObj := p.reserve()
+    cidObj := p.reserve()
+    descObj := p.reserve()
+    fileObj := p.reserve()
+    cmapObj := p.reserve()
+    gidMapObj := p.reserve()
+
+    gids := make([]int, 0, len(f.used))
+    for gid := range f.used {
+        gids = append(gids, int(gid))
+    }
+    sort.Ints(gids)
+
+    data, newGIDs, err := ttf.subset(gids)
+    if err != nil {
+        return 0, err
+    }
+    if err := p.stream(fileObj, fmt.Sprintf("/Length1 %d", len(data)), data); err != nil {
+        return 0, err
+    }
+    gidMap := make([]byte, 2*(gids[len(gids)-1]+1))
+    for _, gid := range gids {
+        binary.BigEndian.PutUint16(gidMap[2*gid:], newGIDs[uint16(gid)])
+    }
+    if err := p.stream(gidMapObj, "", gidMap); err != nil {
+        return 0, err
+    }
+
+    var widths strings.Builder
+    for _, gid := range gids {
+        fmt.Fprintf(&widths, "%d [%.0f] ", gid, ttf.advance(uint16(gid)))
+    }
+
+    name := subsetTag(ttf.name, gids) + "+" + strings.ReplaceAll(ttf.name, " ", "")
+    p.object(descObj, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+        name, ttf.scale(ttf.bbox[0]), ttf.scale(ttf.bbox[1]), ttf.scale(ttf.bbox[2]), ttf.scale(ttf.bbox[3]),
+        ttf.scale(ttf.ascent), ttf.scale(ttf.descent), ttf.scale(ttf.capHeight), fileObj))
+    p.object(cidObj, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /W [%s] /CIDToGIDMap %d 0 R >>",
+        name, descObj, strings.TrimSpace(widths.String()), gidMapObj))
+    if err := p.stream(cmapObj, "", toUnicodeCMap(f.used, gids)); err != nil {
+        return 0, err
+    }
+    p.object(fontObj, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+        name, cidObj, cmapObj))
+    return fontObj, nil
+}
+
+// toUnicodeCMap maps drawn glyphs back to text so the PDF can be searched and copied
+func toUnicodeCMap(used map[uint16]rune, gids []int) []byte {
+    var b bytes.Buffer
+    b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+    b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+    b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+    b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+    for start := 0; start < len(gids); start += 100 {
+        end := start + 100
+        if end > len(gids) {
+            end = len(gids)
+        }
+        fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+        for _, gid := range gids[start:end] {
+            fmt.Fprintf(&b, "<%04X> <%s>\n", gid, utf16Hex(string(used[uint16(gid)])))
+        }
+        b.WriteString("endbfchar\n")
+    }
+    b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+    return b.Bytes()
+}
+
+// pdfTextString encodes a document-info string as UTF-16BE with a byte order mark
+func pdfTextString(s string) string {
+    return "<FEFF" + utf16Hex(s) + ">"
+}
+
+func utf16Hex(s string) string {
+    var b strings.Builder
+    for _, u := range utf16.Encode([]rune(s)) {
+        fmt.Fprintf(&b, "%04X", u)
+    }
+    return b.String()
+}
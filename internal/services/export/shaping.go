@@ -0,0 +1,376 @@
+// File: internal/services/export/shaping.go
+package export
+
+import (
+    "unicode"
+)
+
+// PDF text is drawn glyph by glyph, so the two jobs a browser does for Persian have to
+// happen here: joining letters into their contextual forms, and laying out mixed
+// right-to-left and left-to-right runs in visual order.
+
+// arabicForms lists the presentation forms of each letter: isolated, final, initial,
+// medial. Right-joining letters only have the first two.
+var arabicForms = map[rune][]rune{
+    0x0621: {0xFE80},                         // hamza
+    0x0622: {0xFE81, 0xFE82},                 // alef with madda
+    0x0623: {0xFE83, 0xFE84},                 // alef with hamza above
+    0x0624: {0xFE85, 0xFE86},                 // waw with hamza
+    0x0625: {0xFE87, 0xFE88},                 // alef with hamza below
+    0x0626: {0xFE89, 0xFE8A, 0xFE8B, 0xFE8C}, // yeh with hamza
+    0x0627: {0xFE8D, 0xFE8E},                 // alef
+    0x0628: {0xFE8F, 0xFE90, 0xFE91, 0xFE92}, // beh
+    0x0629: {0xFE93, 0xFE94},                 // teh marbuta
+    0x062A: {0xFE95, 0xFE96, 0xFE97, 0xFE98}, // teh
+    0x062B: {0xFE99, 0xFE9A, 0xFE9B, 0xFE9C}, // theh
+    0x062C: {0xFE9D, 0xFE9E, 0xFE9F, 0xFEA0}, // jeem
+    0x062D: {0xFEA1, 0xFEA2, 0xFEA3, 0xFEA4}, // hah
+    0x062E: {0xFEA5, 0xFEA6, 0xFEA7, 0xFEA8}, // khah
+    0x062F: {0xFEA9, 0xFEAA},                 // dal
+    0x0630: {0xFEAB, 0xFEAC},                 // thal
+    0x0631: {0xFEAD, 0xFEAE},                 // reh
+    0x0632: {0xFEAF, 0xFEB0},                 // zain
+    0x0633: {0xFEB1, 0xFEB2, 0xFEB3, 0xFEB4}, // seen
+    0x0634: {0xFEB5, 0xFEB6, 0xFEB7, 0xFEB8}, // sheen
+    0x0635: {0xFEB9, 0xFEBA, 0xFEBB, 0xFEBC}, // sad
+    0x0636: {0xFEBD, 0xFEBE, 0xFEBF, 0xFEC0}, // dad
+    0x0637: {0xFEC1, 0xFEC2, 0xFEC3, 0xFEC4}, // tah
+    0x0638: {0xFEC5, 0xFEC6, 0xFEC7, 0xFEC8}, // zah
+    0x0639: {0xFEC9, 0xFECA, 0xFECB, 0xFECC}, // ain
+    0x063A: {0xFECD, 0xFECE, 0xFECF, 0xFED0}, // ghain
+    0x0641: {0xFED1, 0xFED2, 0xFED3, 0xFED4}, // feh
+    0x0642: {0xFED5, 0xFED6, 0xFED7, 0xFED8}, // qaf
+    0x0643: {0xFED9, 0xFEDA, 0xFEDB, 0xFEDC}, // kaf
+    0x0644: {0xFEDD, 0xFEDE, 0xFEDF, 0xFEE0}, // lam
+    0x0645: {0xFEE1, 0xFEE2, 0xFEE3, 0xFEE4}, // meem
+    0x0646: {0xFEE5, 0xFEE6, 0xFEE7, 0xFEE8}, // noon
+    0x0647: {0xFEE9, 0xFEEA, 0xFEEB, 0xFEEC}, // heh
+    0x0648: {0xFEED, 0xFEEE},                 // waw
+    0x0649: {0xFEEF, 0xFEF0},                 // alef maksura
+    0x064A: {0xFEF1, 0xFEF2, 0xFEF3, 0xFEF4}, // yeh
+    0x067E: {0xFB56, 0xFB57, 0xFB58, 0xFB59}, // peh
+    0x0686: {0xFB7A, 0xFB7B, 0xFB7C, 0xFB7D}, // tcheh
+    0x0698: {0xFB8A, 0xFB8B},                 // jeh
+    0x06A9: {0xFB8E, 0xFB8F, 0xFB90, 0xFB91}, // keheh
+    0x06AF: {0xFB92, 0xFB93, 0xFB94, 0xFB95}, // gaf
+    0x06C0: {0xFBA4, 0xFBA5},                 // heh with yeh above
+    0x06CC: {0xFBFC, 0xFBFD, 0xFBFE, 0xFBFF}, // farsi yeh
+}
+
+// lamAlef maps the alef following a lam to the ligature's isolated and final forms
+var lamAlef = map[rune][2]rune{
+    0x0622: {0xFEF5, 0xFEF6},
+    0x0623: {0xFEF7, 0xFEF8},
+    0x0625: {0xFEF9, 0xFEFA},
+    0x0627: {0xFEFB, 0xFEFC},
+}
+
+const (
+    formIsolated = iota
+    formFinal
+    formInitial
+    formMedial
+)
+
+const (
+    arabicLam = 0x0644
+    tatweel   = 0x0640
+    zwj       = 0x200D
+)
+
+// isTransparent reports marks that sit on a letter without breaking its joining
+func isTransparent(r rune) bool {
+    return unicode.Is(unicode.Mn, r)
+}
+
+// joinsForward reports whether r connects to the letter after it (dual-joining or tatweel)
+func joinsForward(r rune) bool {
+    if r == tatweel || r == zwj {
+        return true
+    }
+    return len(arabicForms[r]) == 4
+}
+
+// joinsBackward reports whether r connects to the letter before it
+func joinsBackward(r rune) bool {
+    if r == tatweel || r == zwj {
+        return true
+    }
+    return len(arabicForms[r]) >= 2
+}
+
+// shapeArabic replaces Arabic-script letters with their contextual presentation forms,
+// in logical order. hasGlyph lets it keep the base letter when a font lacks a form.
+func shapeArabic(text []rune, hasGlyph func(rune) bool) []rune {
+    out := make([]rune, 0, len(text))
+    neighbour := func(i, step int) rune {
+        for j := i + step; j >= 0 && j < len(text); j += step {
+            if !isTransparent(text[j]) {
+                return text[j]
+            }
+        }
+        return 0
+    }
+
+    for i := 0; i < len(text); i++ {
+        r := text[i]
+        forms, ok := arabicForms[r]
+        if !ok {
+            out = append(out, r)
+            continue
+        }
+        prev := neighbour(i, -1)
+        joinPrev := len(forms) >= 2 && joinsForward(prev)
+
+        // Lam followed by alef becomes a single ligature
+        if r == arabicLam {
+            if j := nextNonTransparent(text, i); j > 0 {
+                if lig, ok := lamAlef[text[j]]; ok {
+                    form := lig[0]
+                    if joinPrev {
+                        form = lig[1]
+                    }
+                    if hasGlyph(form) {
+                        out = append(out, form)
+                        out = append(out, text[i+1:j]...) // marks on the lam
+                        i = j
+                        continue
+                    }
+                }
+            }
+        }
+
+        joinNext := len(forms) == 4 && joinsBackward(neighbour(i, 1))
+        form := formIsolated
+        switch {
+        case joinPrev && joinNext:
+            form = formMedial
+        case joinPrev:
+            form = formFinal
+        case joinNext:
+            form = formInitial
+        }
+        if form < len(forms) && hasGlyph(forms[form]) {
+            out = append(out, forms[form])
+        } else {
+            out = append(out, r)
+        }
+    }
+    return out
+}
+
+func nextNonTransparent(text []rune, i int) int {
+    for j := i + 1; j < len(text); j++ {
+        if !isTransparent(text[j]) {
+            return j
+        }
+    }
+    return -1
+}
+
+// Bidi classes, simplified from UAX #9 to what chat text needs
+const (
+    bidiNeutral = iota
+    bidiLTR
+    bidiRTL
+    bidiNumber
+)
+
+func bidiClass(r rune) int {
+    switch {
+    case unicode.In(r, unicode.Arabic, unicode.Hebrew) && (unicode.IsLetter(r) || unicode.Is(unicode.Mn, r)):
+        return bidiRTL
+    case r >= 0xFB50 && r <= 0xFEFC: // presentation forms produced by shapeArabic
+        return bidiRTL
+    case unicode.IsDigit(r):
+        return bidiNumber
+    case unicode.IsLetter(r):
+        return bidiLTR
+    }
+    return bidiNeutral
+}
+
+// isRTLParagraph follows the first strong character, like dir="auto"
+func isRTLParagraph(text []rune) bool {
+    for _, r := range text {
+        switch bidiClass(r) {
+        case bidiRTL:
+            return true
+        case bidiLTR:
+            return false
+        }
+    }
+    return false
+}
+
+// mirrored swaps paired punctuation drawn inside right-to-left runs
+var mirrored = map[rune]rune{
+    '(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{',
+    '<': '>', '>': '<', '«': '»', '»': '«',
+}
+
+// visualOrder reorders one line of logical text for left-to-right drawing. Neutrals
+// between runs of the same direction take that direction, others the paragraph's.
+// Numbers always read left to right.
+func visualOrder(line []rune, rtl bool) []rune {
+    if len(line) == 0 {
+        return line
+    }
+    base := 0
+    if rtl {
+        base = 1
+    }
+
+    ltrLevel := 0
+    if rtl {
+        ltrLevel = 2 // embedded left-to-right run
+    }
+    // direction collapses numbers into left-to-right; neutrals stay neutral
+    classes := make([]int, len(line))
+    for i, r := range line {
+        classes[i] = bidiClass(r)
+        if classes[i] == bidiNumber {
+            classes[i] = bidiLTR
+        }
+    }
+    resolveBrackets(line, classes, rtl)
+    direction := func(i int) int { return classes[i] }
+
+    levels := make([]int, len(line))
+    for i := 0; i < len(line); {
+        switch direction(i) {
+        case bidiRTL:
+            levels[i] = 1
+            i++
+            continue
+        case bidiLTR:
+            levels[i] = ltrLevel
+            i++
+            continue
+        }
+        j := i
+        for j < len(line) && direction(j) == bidiNeutral {
+            j++
+        }
+        level := base
+        if i > 0 && j < len(line) {
+            switch before, after := direction(i-1), direction(j); {
+            case before == bidiRTL && after == bidiRTL:
+                level = 1
+            case before == bidiLTR && after == bidiLTR:
+                level = ltrLevel
+            }
+        }
+        for k := i; k < j; k++ {
+            levels[k] = level
+        }
+        i = j
+    }
+
+    // Trailing whitespace belongs to the paragraph direction
+    for i := len(line) - 1; i >= 0 && unicode.IsSpace(line[i]); i-- {
+        levels[i] = base
+    }
+
+    out := make([]rune, len(line))
+    copy(out, line)
+    for i := range out {
+        if levels[i]%2 == 1 {
+            if m, ok := mirrored[out[i]]; ok {
+                out[i] = m
+            }
+        }
+    }
+
+    // Reverse every run at or above each level, highest first (UAX #9 rule L2)
+    maxLevel := 0
+    for _, l := range levels {
+        if l > maxLevel {
+            maxLevel = l
+        }
+    }
+    lv := append([]int(nil), levels...)
+    for level := maxLevel; level >= 1; level-- {
+        for i := 0; i < len(out); {
+            if lv[i] < level {
+                i++
+                continue
+            }
+            j := i
+            for j < len(out) && lv[j] >= level {
+                j++
+            }
+            reverseRunes(out[i:j])
+            reverseInts(lv[i:j])
+            i = j
+        }
+    }
+    return out
+}
+
+// brackets pairs opening with closing punctuation for resolveBrackets
+var brackets = map[rune]rune{'(': ')', '[': ']', '{': '}', '«': '»'}
+
+// resolveBrackets gives both halves of a bracket pair one direction (UAX #9 rule N0),
+// so "(metformin)" inside Persian text keeps its brackets around the word. A pair takes
+// the paragraph direction when its content has that direction, otherwise the direction
+// of the text before it; pairs with no strong content stay neutral.
+func resolveBrackets(line []rune, classes []int, rtl bool) {
+    embedding, opposite := bidiLTR, bidiRTL
+    if rtl {
+        embedding, opposite = bidiRTL, bidiLTR
+    }
+
+    type opener struct {
+        pos   int
+        close rune
+    }
+    var stack []opener
+    for i, r := range line {
+        if c, ok := brackets[r]; ok {
+            stack = append(stack, opener{i, c})
+            continue
+        }
+        for k := len(stack) - 1; k >= 0; k-- {
+            if stack[k].close != r {
+                continue
+            }
+            open := stack[k].pos
+            stack = stack[:k]
+
+            inside := make(map[int]bool)
+            for _, c := range classes[open+1 : i] {
+                inside[c] = true
+            }
+            dir := bidiNeutral
+            switch {
+            case inside[embedding]:
+                dir = embedding
+            case inside[opposite]:
+                dir = embedding
+                for j := open - 1; j >= 0; j-- {
+                    if classes[j] != bidiNeutral {
+                        if classes[j] == opposite {
+                            dir = opposite
+                        }
+                        break
+                    }
+                }
+            }
+            classes[open], classes[i] = dir, dir
+            break
+        }
+    }
+}
+
+func reverseRunes(s []rune) {
+    for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+        s[i], s[j] = s[j], s[i]
+    }
+}
+
+func reverseInts(s []int) {
+    for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+        s[i], s[j] = s[j], s[i]
+    }
+}
@@ -0,0 +1,226 @@
+// File: internal/services/export/subset.go
+package export
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/fnv"
+    "sort"
+)
+
+// Composite glyph component flags (TrueType glyf table)
+const (
+    componentArgsAreWords = 0x0001
+    componentHasScale     = 0x0008
+    componentMore         = 0x0020
+    componentHasXYScale   = 0x0040
+    componentHasTwoByTwo  = 0x0080
+)
+
+// subsetTables are the tables a PDF viewer reads from an embedded TrueType font
+// (ISO 32000-1, 9.9). cmap is left out: text is addressed by glyph ID.
+var subsetTables = []string{"cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// subset builds a font file holding only the given glyphs, .notdef and the parts of
+// any composite among them, renumbered from 0 in their original order. It returns the
+// file and the new glyph ID of each original one, for the PDF's CIDToGIDMap.
+func (f *trueTypeFont) subset(gids []int) ([]byte, map[uint16]uint16, error) {
+    keep := map[uint16]bool{0: true}
+    queue := make([]uint16, 0, len(gids))
+    for _, gid := range gids {
+        if gid < len(f.advances) && !keep[uint16(gid)] {
+            keep[uint16(gid)] = true
+            queue = append(queue, uint16(gid))
+        }
+    }
+    for len(queue) > 0 {
+        data := f.glyphData(queue[0])
+        queue = queue[1:]
+        for _, at := range componentOffsets(data) {
+            part := binary.BigEndian.Uint16(data[at:])
+            if int(part) < len(f.advances) && !keep[part] {
+                keep[part] = true
+                queue = append(queue, part)
+            }
+        }
+    }
+
+    order := make([]uint16, 0, len(keep))
+    for gid := range keep {
+        order = append(order, gid)
+    }
+    sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+    newGIDs := make(map[uint16]uint16, len(order))
+    for i, gid := range order {
+        newGIDs[gid] = uint16(i)
+    }
+
+    var glyf []byte
+    loca := make([]byte, 4*(len(order)+1))
+    hmtx := make([]byte, 0, 4*len(order))
+    for i, gid := range order {
+        binary.BigEndian.PutUint32(loca[4*i:], uint32(len(glyf)))
+        data := append([]byte(nil), f.glyphData(gid)...)
+        for _, at := range componentOffsets(data) {
+            part := binary.BigEndian.Uint16(data[at:])
+            binary.BigEndian.PutUint16(data[at:], newGIDs[part])
+        }
+        glyf = append(glyf, data...)
+        for len(glyf)%4 != 0 {
+            glyf = append(glyf, 0)
+        }
+        hmtx = binary.BigEndian.AppendUint16(hmtx, f.advances[gid])
+        hmtx = binary.BigEndian.AppendUint16(hmtx, f.leftSideBearing(gid))
+    }
+    binary.BigEndian.PutUint32(loca[4*len(order):], uint32(len(glyf)))
+
+    tables := make(map[string][]byte, len(subsetTables))
+    for _, tag := range subsetTables {
+        if data, ok := f.tables[tag]; ok {
+            tables[tag] = append([]byte(nil), data...)
+        }
+    }
+    tables["glyf"] = glyf
+    tables["loca"] = loca
+    tables["hmtx"] = hmtx
+    // checkSumAdjustment is set once the file is laid out; loca uses 32-bit offsets;
+    // every kept glyph has a full metric
+    binary.BigEndian.PutUint32(tables["head"][8:], 0)
+    binary.BigEndian.PutUint16(tables["head"][50:], 1)
+    binary.BigEndian.PutUint16(tables["hhea"][34:], uint16(len(order)))
+    binary.BigEndian.PutUint16(tables["maxp"][4:], uint16(len(order)))
+
+    file, err := writeFontFile(tables)
+    if err != nil {
+        return nil, nil, fmt.Errorf("%s: %w", f.name, err)
+    }
+    return file, newGIDs, nil
+}
+
+// glyphData returns a glyph's outline; empty glyphs such as the space have none
+func (f *trueTypeFont) glyphData(gid uint16) []byte {
+    if int(gid)+1 >= len(f.loca) {
+        return nil
+    }
+    return f.tables["glyf"][f.loca[gid]:f.loca[gid+1]]
+}
+
+// leftSideBearing reads a glyph's left side bearing from hmtx, where glyphs past the
+// last full metric only store the bearing
+func (f *trueTypeFont) leftSideBearing(gid uint16) uint16 {
+    hmtx := f.tables["hmtx"]
+    at := 4*int(gid) + 2
+    if int(gid) >= f.numHMetrics {
+        at = 4*f.numHMetrics + 2*(int(gid)-f.numHMetrics)
+    }
+    if at+2 > len(hmtx) {
+        return 0
+    }
+    return binary.BigEndian.Uint16(hmtx[at:])
+}
+
+// componentOffsets lists where a composite glyph stores the glyph IDs of its parts;
+// simple glyphs have none
+func componentOffsets(data []byte) []int {
+    if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+        return nil
+    }
+    var offsets []int
+    for at := 10; at+4 <= len(data); {
+        flags := binary.BigEndian.Uint16(data[at:])
+        offsets = append(offsets, at+2)
+        at += 4
+        if flags&componentArgsAreWords != 0 {
+            at += 4
+        } else {
+            at += 2
+        }
+        switch {
+        case flags&componentHasScale != 0:
+            at += 2
+        case flags&componentHasXYScale != 0:
+            at += 4
+        case flags&componentHasTwoByTwo != 0:
+            at += 8
+        }
+        if flags&componentMore == 0 {
+            break
+        }
+    }
+    return offsets
+}
+
+// writeFontFile lays tables out as an sfnt file, tag-ordered and 4-byte aligned, with
+// the checksums the format requires
+func writeFontFile(tables map[string][]byte) ([]byte, error) {
+    head, ok := tables["head"]
+    if !ok || len(head) < 12 {
+        return nil, errors.New("missing head table")
+    }
+    tags := make([]string, 0, len(tables))
+    for tag := range tables {
+        tags = append(tags, tag)
+    }
+    sort.Strings(tags)
+
+    entrySelector := 0
+    for 1<<(entrySelector+1) <= len(tags) {
+        entrySelector++
+    }
+    searchRange := 16 << entrySelector
+
+    file := make([]byte, 12+16*len(tags))
+    binary.BigEndian.PutUint32(file, 0x00010000)
+    binary.BigEndian.PutUint16(file[4:], uint16(len(tags)))
+    binary.BigEndian.PutUint16(file[6:], uint16(searchRange))
+    binary.BigEndian.PutUint16(file[8:], uint16(entrySelector))
+    binary.BigEndian.PutUint16(file[10:], uint16(16*len(tags)-searchRange))
+
+    headAt := 0
+    for i, tag := range tags {
+        data := tables[tag]
+        rec := file[12+16*i:]
+        copy(rec, tag)
+        binary.BigEndian.PutUint32(rec[4:], tableChecksum(data))
+        binary.BigEndian.PutUint32(rec[8:], uint32(len(file)))
+        binary.BigEndian.PutUint32(rec[12:], uint32(len(data)))
+        if tag == "head" {
+            headAt = len(file)
+        }
+        file = append(file, data...)
+        for len(file)%4 != 0 {
+            file = append(file, 0)
+        }
+    }
+    binary.BigEndian.PutUint32(file[headAt+8:], 0xB1B0AFBA-tableChecksum(file))
+    return file, nil
+}
+
+// tableChecksum sums the data as big-endian 32-bit words, zero-padded
+func tableChecksum(data []byte) uint32 {
+    var sum uint32
+    for i := 0; i < len(data); i += 4 {
+        var word [4]byte
+        copy(word[:], data[i:])
+        sum += binary.BigEndian.Uint32(word[:])
+    }
+    return sum
+}
+
+// subsetTag is the six-letter prefix that marks a subset font's name, derived from
+// the glyphs it holds so different subsets of one font get different names
+func subsetTag(name string, gids []int) string {
+    h := fnv.New32a()
+    h.Write([]byte(name))
+    for _, gid := range gids {
+        h.Write([]byte{byte(gid >> 8), byte(gid)})
+    }
+    sum := h.Sum32()
+    tag := make([]byte, 6)
+    for i := range tag {
+        tag[i] = 'A' + byte(sum%26)
+        sum /= 26
+    }
+    return string(tag)
+}
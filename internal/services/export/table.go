@@ -0,0 +1,201 @@
+// File: internal/services/export/table.go
+package export
+
+import (
+    "archive/zip"
+    "encoding/csv"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+)
+
+// TableFormat is the file format of a tabular admin export
+type TableFormat string
+
+const (
+    TableCSV  TableFormat = "csv"
+    TableXLSX TableFormat = "xlsx"
+)
+
+// ErrUnsupportedTableFormat is returned for formats other than csv and xlsx
+var ErrUnsupportedTableFormat = errors.New("unsupported export format, use csv or xlsx")
+
+// ParseTableFormat validates a format query parameter; empty means CSV
+func ParseTableFormat(s string) (TableFormat, error) {
+    switch f := TableFormat(strings.ToLower(strings.TrimSpace(s))); f {
+    case "":
+        return TableCSV, nil
+    case TableCSV, TableXLSX:
+        return f, nil
+    }
+    return "", ErrUnsupportedTableFormat
+}
+
+// ContentType is the MIME type served for the format
+func (f TableFormat) ContentType() string {
+    if f == TableXLSX {
+        return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+    }
+    return "text/csv; charset=utf-8"
+}
+
+// TableWriter writes rows of a single-sheet table as they arrive. Flush pushes buffered
+// rows to the underlying writer; Close finishes the file and must be called once.
+type TableWriter interface {
+    WriteRow(cells []string) error
+    Flush() error
+    Close() error
+}
+
+// NewTableWriter starts a table in the given format with a header row
+func NewTableWriter(w io.Writer, format TableFormat, header []string) (TableWriter, error) {
+    var tw TableWriter
+    switch format {
+    case TableCSV:
+        tw = &csvTable{w: csv.NewWriter(w)}
+    case TableXLSX:
+        x, err := newXLSXTable(w)
+        if err != nil {
+            return nil, err
+        }
+        tw = x
+    default:
+        return nil, ErrUnsupportedTableFormat
+    }
+    if err := tw.WriteRow(header); err != nil {
+        return nil, err
+    }
+    return tw, nil
+}
+
+type csvTable struct {
+    w *csv.Writer
+}
+
+func (t *csvTable) WriteRow(cells []string) error {
+    return t.w.Write(cells)
+}
+
+func (t *csvTable) Flush() error {
+    t.w.Flush()
+    return t.w.Error()
+}
+
+func (t *csvTable) Close() error {
+    return t.Flush()
+}
+
+// xlsxTable streams a minimal SpreadsheetML workbook: the fixed package parts are
+// written up front, then sheet rows go straight into the zip entry as they arrive.
+// Strings are stored inline, so no shared-strings table has to be kept in memory.
+type xlsxTable struct {
+    zw    *zip.Writer
+    sheet io.Writer
+    row   int
+}
+
+const xlsxSheetName = "Export"
+
+var xlsxParts = []struct{ name, body string }{
+    {"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+        `<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+        `<Default Extension="xml" ContentType="application/xml"/>` +
+        `<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+        `<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+        `</Types>`},
+    {"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+        `<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+        `</Relationships>`},
+    {"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+        `<sheets><sheet name="` + xlsxSheetName + `" sheetId="1" r:id="rId1"/></sheets>` +
+        `</workbook>`},
+    {"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+        `<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+        `</Relationships>`},
+}
+
+func newXLSXTable(w io.Writer) (*xlsxTable, error) {
+    zw := zip.NewWriter(w)
+    for _, part := range xlsxParts {
+        f, err := zw.Create(part.name)
+        if err != nil {
+            return nil, err
+        }
+        if _, err := io.WriteString(f, part.body); err != nil {
+            return nil, err
+        }
+    }
+    sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+    if err != nil {
+        return nil, err
+    }
+    if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+        return nil, err
+    }
+    return &xlsxTable{zw: zw, sheet: sheet}, nil
+}
+
+func (t *xlsxTable) WriteRow(cells []string) error {
+    t.row++
+    var b strings.Builder
+    fmt.Fprintf(&b, `<row r="%d">`, t.row)
+    for i, cell := range cells {
+        ref := xlsxColumn(i) + strconv.Itoa(t.row)
+        if isPlainInteger(cell) {
+            fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, cell)
+            continue
+        }
+        fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+        if err := xml.EscapeText(&b, []byte(cell)); err != nil {
+            return err
+        }
+        b.WriteString(`</t></is></c>`)
+    }
+    b.WriteString(`</row>`)
+    _, err := io.WriteString(t.sheet, b.String())
+    return err
+}
+
+func (t *xlsxTable) Flush() error {
+    return t.zw.Flush()
+}
+
+func (t *xlsxTable) Close() error {
+    if _, err := io.WriteString(t.sheet, `</sheetData></worksheet>`); err != nil {
+        return err
+    }
+    return t.zw.Close()
+}
+
+// xlsxColumn converts a zero-based column index to its letters: 0 is A, 26 is AA
+func xlsxColumn(i int) string {
+    name := ""
+    for i++; i > 0; i = (i - 1) / 26 {
+        name = string(rune('A'+(i-1)%26)) + name
+    }
+    return name
+}
+
+// isPlainInteger reports whether a cell can be stored as a number without changing
+// how it reads. Leading zeros, plus signs and long digit runs (phone numbers, IDs past
+// float precision) stay text.
+func isPlainInteger(s string) bool {
+    s = strings.TrimPrefix(s, "-")
+    if s == "" || len(s) > 15 || (len(s) > 1 && s[0] == '0') {
+        return false
+    }
+    for _, r := range s {
+        if r < '0' || r > '9' {
+            return false
+        }
+    }
+    return true
+}
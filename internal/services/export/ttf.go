@@ -0,0 +1,242 @@
+// File: internal/services/export/ttf.go
+package export
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+)
+
+// trueTypeFont is the subset of a TrueType font the PDF writer needs: the glyph for
+// each rune, advance widths and the metrics for the font descriptor. The tables are
+// kept so each document embeds only the glyphs it draws (see subset).
+type trueTypeFont struct {
+    name       string
+    unitsPerEm int
+    ascent     int
+    descent    int
+    capHeight  int
+    bbox       [4]int
+    glyphs     map[rune]uint16
+    advances   []uint16
+    arabic     bool // preferred for Arabic-script characters
+
+    tables      map[string][]byte
+    numHMetrics int
+    loca        []int // glyph offsets into glyf, numGlyphs+1 entries
+}
+
+// parseTrueType reads the tables needed for text layout. Only glyf-outline (TrueType)
+// fonts are supported; CFF-flavoured OpenType fonts are rejected.
+func parseTrueType(name string, data []byte) (*trueTypeFont, error) {
+    if len(data) < 12 {
+        return nil, errors.New("font file too short")
+    }
+    if v := binary.BigEndian.Uint32(data); v != 0x00010000 && v != 0x74727565 {
+        return nil, fmt.Errorf("%s: not a TrueType font", name)
+    }
+
+    tables := make(map[string][]byte)
+    numTables := int(binary.BigEndian.Uint16(data[4:]))
+    for i := 0; i < numTables; i++ {
+        rec := 12 + 16*i
+        if rec+16 > len(data) {
+            return nil, fmt.Errorf("%s: truncated table directory", name)
+        }
+        tag := string(data[rec : rec+4])
+        offset := int(binary.BigEndian.Uint32(data[rec+8:]))
+        length := int(binary.BigEndian.Uint32(data[rec+12:]))
+        if offset+length > len(data) {
+            return nil, fmt.Errorf("%s: table %s out of range", name, tag)
+        }
+        tables[tag] = data[offset : offset+length]
+    }
+    for _, tag := range []string{"head", "hhea", "hmtx", "cmap", "maxp", "loca", "glyf"} {
+        if _, ok := tables[tag]; !ok {
+            return nil, fmt.Errorf("%s: missing %s table", name, tag)
+        }
+    }
+
+    f := &trueTypeFont{name: name, tables: tables}
+
+    head := tables["head"]
+    if len(head) < 54 {
+        return nil, fmt.Errorf("%s: bad head table", name)
+    }
+    f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+    for i := range f.bbox {
+        f.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+    }
+
+    hhea := tables["hhea"]
+    if len(hhea) < 36 {
+        return nil, fmt.Errorf("%s: bad hhea table", name)
+    }
+    f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+    f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+    numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+
+    f.capHeight = f.ascent
+    if os2 := tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+        f.capHeight = int(int16(binary.BigEndian.Uint16(os2[88:])))
+    }
+
+    if len(tables["maxp"]) < 6 {
+        return nil, fmt.Errorf("%s: bad maxp table", name)
+    }
+    numGlyphs := int(binary.BigEndian.Uint16(tables["maxp"][4:]))
+    f.numHMetrics = numHMetrics
+    hmtx := tables["hmtx"]
+    if numHMetrics == 0 || len(hmtx) < 4*numHMetrics {
+        return nil, fmt.Errorf("%s: bad hmtx table", name)
+    }
+    f.advances = make([]uint16, numGlyphs)
+    for i := 0; i < numGlyphs; i++ {
+        if i < numHMetrics {
+            f.advances[i] = binary.BigEndian.Uint16(hmtx[4*i:])
+        } else {
+            f.advances[i] = f.advances[numHMetrics-1]
+        }
+    }
+
+    loca, err := parseLoca(tables["loca"], binary.BigEndian.Uint16(head[50:]), numGlyphs, len(tables["glyf"]))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", name, err)
+    }
+    f.loca = loca
+
+    glyphs, err := parseCmap(tables["cmap"])
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", name, err)
+    }
+    f.glyphs = glyphs
+    return f, nil
+}
+
+// parseLoca reads the glyph offsets, stored as halved 16-bit values (format 0) or
+// 32-bit values (format 1)
+func parseLoca(loca []byte, format uint16, numGlyphs, glyfLen int) ([]int, error) {
+    size := 2
+    if format == 1 {
+        size = 4
+    }
+    if len(loca) < size*(numGlyphs+1) {
+        return nil, errors.New("bad loca table")
+    }
+    offsets := make([]int, numGlyphs+1)
+    for i := range offsets {
+        if format == 1 {
+            offsets[i] = int(binary.BigEndian.Uint32(loca[4*i:]))
+        } else {
+            offsets[i] = 2 * int(binary.BigEndian.Uint16(loca[2*i:]))
+        }
+        if offsets[i] > glyfLen || i > 0 && offsets[i] < offsets[i-1] {
+            return nil, errors.New("bad loca table")
+        }
+    }
+    return offsets, nil
+}
+
+// parseCmap reads the Windows Unicode subtable, preferring the full-repertoire format 12
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+    if len(cmap) < 4 {
+        return nil, errors.New("bad cmap table")
+    }
+    var bmp, full []byte
+    n := int(binary.BigEndian.Uint16(cmap[2:]))
+    for i := 0; i < n; i++ {
+        rec := 4 + 8*i
+        if rec+8 > len(cmap) {
+            break
+        }
+        platform := binary.BigEndian.Uint16(cmap[rec:])
+        encoding := binary.BigEndian.Uint16(cmap[rec+2:])
+        offset := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+        if offset+4 > len(cmap) {
+            continue
+        }
+        sub := cmap[offset:]
+        switch {
+        case platform == 3 && encoding == 10 && binary.BigEndian.Uint16(sub) == 12:
+            full = sub
+        case (platform == 3 && encoding == 1 || platform == 0) && binary.BigEndian.Uint16(sub) == 4:
+            bmp = sub
+        }
+    }
+
+    glyphs := make(map[rune]uint16)
+    switch {
+    case full != nil:
+        if len(full) < 16 {
+            return nil, errors.New("bad cmap format 12")
+        }
+        groups := int(binary.BigEndian.Uint32(full[12:]))
+        for g := 0; g < groups && 16+12*g+12 <= len(full); g++ {
+            rec := full[16+12*g:]
+            start := binary.BigEndian.Uint32(rec)
+            end := binary.BigEndian.Uint32(rec[4:])
+            gid := binary.BigEndian.Uint32(rec[8:])
+            for c := start; c <= end && c-start < 0x10000; c++ {
+                glyphs[rune(c)] = uint16(gid + c - start)
+            }
+        }
+    case bmp != nil:
+        if len(bmp) < 14 {
+            return nil, errors.New("bad cmap format 4")
+        }
+        segX2 := int(binary.BigEndian.Uint16(bmp[6:]))
+        ends := bmp[14:]
+        starts := bmp[16+segX2:]
+        deltas := bmp[16+2*segX2:]
+        rangeOffsets := bmp[16+3*segX2:]
+        if len(rangeOffsets) < segX2 {
+            return nil, errors.New("bad cmap format 4")
+        }
+        for s := 0; s < segX2; s += 2 {
+            end := int(binary.BigEndian.Uint16(ends[s:]))
+            start := int(binary.BigEndian.Uint16(starts[s:]))
+            delta := int(binary.BigEndian.Uint16(deltas[s:]))
+            ro := int(binary.BigEndian.Uint16(rangeOffsets[s:]))
+            for c := start; c <= end && c != 0xFFFF; c++ {
+                var gid int
+                if ro == 0 {
+                    gid = (c + delta) & 0xFFFF
+                } else {
+                    idx := s + ro + 2*(c-start)
+                    if idx+2 > len(rangeOffsets) {
+                        continue
+                    }
+                    gid = int(binary.BigEndian.Uint16(rangeOffsets[idx:]))
+                    if gid != 0 {
+                        gid = (gid + delta) & 0xFFFF
+                    }
+                }
+                if gid != 0 {
+                    glyphs[rune(c)] = uint16(gid)
+                }
+            }
+        }
+    default:
+        return nil, errors.New("no Unicode cmap subtable")
+    }
+    return glyphs, nil
+}
+
+// glyph returns the glyph for r, or false when the font has none
+func (f *trueTypeFont) glyph(r rune) (uint16, bool) {
+    gid, ok := f.glyphs[r]
+    return gid, ok
+}
+
+// advance is the width of a glyph in PDF text-space units (1/1000 of the font size)
+func (f *trueTypeFont) advance(gid uint16) float64 {
+    if int(gid) >= len(f.advances) {
+        return 0
+    }
+    return float64(f.advances[gid]) * 1000 / float64(f.unitsPerEm)
+}
+
+// scale converts font units to PDF text-space units
+func (f *trueTypeFont) scale(v int) int {
+    return v * 1000 / f.unitsPerEm
+}
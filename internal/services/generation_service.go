@@ -0,0 +1,585 @@
+// File: internal/services/generation_service.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/repository/job"
+	chatservice "github.com/iyunix/go-internist/internal/services/chat"
+	"github.com/iyunix/go-internist/internal/streams"
+)
+
+var (
+	ErrGenerationQueueFull    = errors.New("generation queue is full")
+	ErrGenerationShuttingDown = errors.New("generation service is shutting down")
+	ErrGenerationJobNotFound  = errors.New("generation job not found")
+	ErrGenerationJobFinished  = errors.New("generation job already finished")
+)
+
+// CreditRefunder returns characters to a user's balance (user_services.BalanceService)
+type CreditRefunder interface {
+	AddCredits(ctx context.Context, userID uint, amount int, operation string) error
+}
+
+// GenerationConfig sizes the worker pool
+type GenerationConfig struct {
+	Workers          int
+	QueueSize        int
+	JobTimeout       time.Duration // upper bound for one RAG pipeline run
+	ProgressInterval time.Duration // how often partial answers are persisted
+}
+
+// DefaultGenerationConfig returns settings for a single-instance deployment
+func DefaultGenerationConfig() *GenerationConfig {
+	return &GenerationConfig{
+		Workers:          4,
+		QueueSize:        64,
+		JobTimeout:       2 * time.Minute,
+		ProgressInterval: 2 * time.Second,
+	}
+}
+
+// generationTask is a queued job together with the stream its output goes to
+type generationTask struct {
+	job       *domain.GenerationJob
+	stream    *streams.Stream
+	requestID string
+}
+
+// summaryTask asks for a chat's running summary to be brought up to date
+type summaryTask struct {
+	userID    uint
+	chatID    uint
+	requestID string
+}
+
+// summaryQueueSize bounds the summary updates waiting for the summarizer. When it is
+// full an update is skipped; the next answer in that chat folds in both.
+const summaryQueueSize = 64
+
+// activeJob lets Cancel reach a queued or running job
+type activeJob struct {
+	userID    uint
+	cancel    context.CancelCauseFunc // set once a worker starts the job
+	cancelled bool
+}
+
+// GenerationService runs the RAG pipeline on a worker pool, detached from
+// HTTP requests. Every frame is published into the stream hub and progress is
+// persisted, so closing the tab neither aborts the LLM call nor loses the answer.
+type GenerationService struct {
+	config      *GenerationConfig
+	chatService *ChatService
+	jobRepo     job.JobRepository
+	hub         *streams.Hub
+	refunder    CreditRefunder
+	logger      logging.Logger
+
+	queue     chan *generationTask
+	summaries chan summaryTask
+	wg        sync.WaitGroup
+	summaryWG sync.WaitGroup
+	baseCtx   context.Context
+	cancel    context.CancelFunc
+
+	mu             sync.Mutex
+	closed         bool
+	active         map[uint]*activeJob
+	summaryPending map[uint]bool // chats with an update queued but not started
+}
+
+// NewGenerationService creates the service and starts its workers
+func NewGenerationService(config *GenerationConfig, chatService *ChatService, jobRepo job.JobRepository, hub *streams.Hub, refunder CreditRefunder, logger logging.Logger) (*GenerationService, error) {
+	if chatService == nil || jobRepo == nil || hub == nil || refunder == nil {
+		return nil, errors.New("chat service, job repository, stream hub and refunder are required for GenerationService")
+	}
+	if config == nil {
+		config = DefaultGenerationConfig()
+	}
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if logger == nil {
+		logger = logging.NewLogger("generation_service")
+	}
+
+	baseCtx, cancel := context.WithCancel(context.Background())
+	s := &GenerationService{
+		config:         config,
+		chatService:    chatService,
+		jobRepo:        jobRepo,
+		hub:            hub,
+		refunder:       refunder,
+		logger:         logger,
+		queue:          make(chan *generationTask, config.QueueSize),
+		summaries:      make(chan summaryTask, summaryQueueSize),
+		baseCtx:        baseCtx,
+		cancel:         cancel,
+		active:         make(map[uint]*activeJob),
+		summaryPending: make(map[uint]bool),
+	}
+	for i := 0; i < config.Workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	s.summaryWG.Add(1)
+	go s.summarizer()
+	return s, nil
+}
+
+// RecoverInterrupted fails jobs that a previous process left unfinished; their
+// in-memory streams are gone and the work will not resume.
+func (s *GenerationService) RecoverInterrupted(ctx context.Context) error {
+	failed, err := s.jobRepo.FailUnfinished(ctx, "interrupted by server restart")
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		s.logger.Warn("failed generation jobs interrupted by restart", "count", len(failed))
+	}
+	for i := range failed {
+		s.refundFailed(ctx, &failed[i])
+	}
+	return nil
+}
+
+// RefundCharge returns a charge taken for a job that could not be queued
+func (s *GenerationService) RefundCharge(ctx context.Context, userID uint, charge int) error {
+	if charge <= 0 {
+		return nil
+	}
+	return s.refunder.AddCredits(ctx, userID, charge, "generation_not_queued")
+}
+
+// refundFailed returns the full charge of a failed job; the user got no answer
+func (s *GenerationService) refundFailed(ctx context.Context, genJob *domain.GenerationJob) {
+	if genJob.Charge <= 0 {
+		return
+	}
+	if err := s.refunder.AddCredits(ctx, genJob.UserID, genJob.Charge, "generation_failed"); err != nil {
+		s.logger.Error("failed to refund failed generation", ctx, "job_id", genJob.ID, "user_id", genJob.UserID, "refund", genJob.Charge, "error", err)
+		return
+	}
+	s.logger.Info("refunded failed generation", ctx, "job_id", genJob.ID, "refund", genJob.Charge)
+}
+
+// CanAccept reports whether Enqueue is likely to succeed. Callers check it
+// before charging the user.
+func (s *GenerationService) CanAccept() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrGenerationShuttingDown
+	}
+	if len(s.queue) >= cap(s.queue) {
+		return ErrGenerationQueueFull
+	}
+	return nil
+}
+
+// Enqueue persists a job answering prompt, creates its stream and hands it to the worker pool
+func (s *GenerationService) Enqueue(ctx context.Context, userID, chatID uint, prompt string, charge int) (*domain.GenerationJob, *streams.Stream, error) {
+	return s.enqueue(ctx, &domain.GenerationJob{
+		UserID: userID, ChatID: chatID, Kind: domain.JobKindAsk, Prompt: prompt, Charge: charge,
+	})
+}
+
+// EnqueueRegenerate queues a job that adds a new answer to a saved question. messageID is
+// the answer or question to re-run, 0 for the chat's last question; prompt is the question
+// text, as returned by ChatService.FindRegenerateQuestion.
+func (s *GenerationService) EnqueueRegenerate(ctx context.Context, userID, chatID, messageID uint, prompt string, charge int, opts domain.GenerationOptions) (*domain.GenerationJob, *streams.Stream, error) {
+	return s.enqueue(ctx, &domain.GenerationJob{
+		UserID: userID, ChatID: chatID, Kind: domain.JobKindRegenerate, MessageID: messageID,
+		Prompt: prompt, Charge: charge, Options: opts,
+	})
+}
+
+// EnqueueEdit queues a job that answers prompt as a new version of question messageID
+func (s *GenerationService) EnqueueEdit(ctx context.Context, userID, chatID, messageID uint, prompt string, charge int) (*domain.GenerationJob, *streams.Stream, error) {
+	return s.enqueue(ctx, &domain.GenerationJob{
+		UserID: userID, ChatID: chatID, Kind: domain.JobKindEdit, MessageID: messageID, Prompt: prompt, Charge: charge,
+	})
+}
+
+func (s *GenerationService) enqueue(ctx context.Context, genJob *domain.GenerationJob) (*domain.GenerationJob, *streams.Stream, error) {
+	if err := s.CanAccept(); err != nil {
+		return nil, nil, err
+	}
+
+	stream := s.hub.Create(genJob.UserID, genJob.ChatID)
+	genJob.StreamID = stream.ID
+	genJob.Status = domain.JobStatusQueued
+	if err := s.jobRepo.Create(ctx, genJob); err != nil {
+		stream.Close()
+		return nil, nil, err
+	}
+
+	task := &generationTask{job: genJob, stream: stream, requestID: logging.RequestIDFromContext(ctx)}
+
+	s.mu.Lock()
+	var enqueueErr error
+	if s.closed {
+		enqueueErr = ErrGenerationShuttingDown
+	} else {
+		select {
+		case s.queue <- task:
+			s.active[genJob.ID] = &activeJob{userID: genJob.UserID}
+		default:
+			enqueueErr = ErrGenerationQueueFull
+		}
+	}
+	s.mu.Unlock()
+
+	if enqueueErr != nil {
+		_ = s.jobRepo.MarkFailed(context.WithoutCancel(ctx), genJob.ID, "", enqueueErr.Error())
+		stream.Close()
+		return nil, nil, enqueueErr
+	}
+
+	s.logger.Info("generation job queued", ctx, "job_id", genJob.ID, "kind", genJob.Kind, "stream_id", stream.ID, "queue_depth", len(s.queue))
+	return genJob, stream, nil
+}
+
+// GetJob returns a job owned by the user
+func (s *GenerationService) GetJob(ctx context.Context, userID, jobID uint) (*domain.GenerationJob, error) {
+	genJob, err := s.jobRepo.FindByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, job.ErrJobNotFound) {
+			return nil, ErrGenerationJobNotFound
+		}
+		return nil, err
+	}
+	if genJob.UserID != userID {
+		return nil, ErrGenerationJobNotFound
+	}
+	return genJob, nil
+}
+
+// GetJobByStreamID returns the job behind an SSE stream owned by the user
+func (s *GenerationService) GetJobByStreamID(ctx context.Context, userID uint, streamID string) (*domain.GenerationJob, error) {
+	genJob, err := s.jobRepo.FindByStreamID(ctx, streamID)
+	if err != nil {
+		if errors.Is(err, job.ErrJobNotFound) {
+			return nil, ErrGenerationJobNotFound
+		}
+		return nil, err
+	}
+	if genJob.UserID != userID {
+		return nil, ErrGenerationJobNotFound
+	}
+	return genJob, nil
+}
+
+// Cancel stops a queued or running job owned by the user. The worker records the
+// cancellation and refunds the part of the charge not covered by produced output.
+func (s *GenerationService) Cancel(userID, jobID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active, ok := s.active[jobID]
+	if !ok {
+		return ErrGenerationJobFinished
+	}
+	if active.userID != userID {
+		return ErrGenerationJobNotFound
+	}
+	active.cancelled = true
+	if active.cancel != nil {
+		active.cancel(chatservice.ErrStoppedByUser)
+	}
+	return nil
+}
+
+// Shutdown stops accepting jobs and waits for queued and running ones, then the
+// summary updates they queued, to finish. If ctx expires first, running jobs are
+// cancelled and marked failed and pending summary updates are skipped.
+func (s *GenerationService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	first := !s.closed
+	if first {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		if first {
+			// Workers are the only senders, so the queue can close once they exit
+			close(s.summaries)
+		}
+		s.summaryWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.logger.Warn("generation drain timed out, cancelling running jobs")
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *GenerationService) worker() {
+	defer s.wg.Done()
+	for task := range s.queue {
+		s.run(task)
+	}
+}
+
+// summarizer brings chat summaries up to date one at a time, off the workers, so a
+// slow summary call never holds a generation slot or the job's context
+func (s *GenerationService) summarizer() {
+	defer s.summaryWG.Done()
+	for task := range s.summaries {
+		s.mu.Lock()
+		delete(s.summaryPending, task.chatID)
+		s.mu.Unlock()
+		if s.baseCtx.Err() != nil {
+			continue // shutdown gave up waiting
+		}
+
+		ctx := logging.WithChatID(logging.WithUserID(s.baseCtx, task.userID), task.chatID)
+		if task.requestID != "" {
+			ctx = logging.WithRequestID(ctx, task.requestID)
+		}
+		if err := s.chatService.UpdateSummary(ctx, task.userID, task.chatID); err != nil {
+			s.logger.Warn("chat summary update failed", ctx, "error", err)
+		}
+	}
+}
+
+// queueSummary asks the summarizer to fold a chat's new answer into its summary. A chat
+// already waiting is not queued twice; that update will read the new answer too.
+func (s *GenerationService) queueSummary(task summaryTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.summaryPending[task.chatID] {
+		return
+	}
+	select {
+	case s.summaries <- task:
+		s.summaryPending[task.chatID] = true
+	default:
+		s.logger.Warn("summary queue full, skipping update", "chat_id", task.chatID, "queue_size", summaryQueueSize)
+	}
+}
+
+// run executes one job and publishes every SSE frame into its stream
+func (s *GenerationService) run(task *generationTask) {
+	genJob, stream := task.job, task.stream
+	defer stream.Close()
+
+	ctx := logging.WithChatID(logging.WithUserID(s.baseCtx, genJob.UserID), genJob.ChatID)
+	if task.requestID != "" {
+		ctx = logging.WithRequestID(ctx, task.requestID)
+	}
+	// Bookkeeping writes must land even when the job itself was cancelled
+	dbCtx := context.WithoutCancel(ctx)
+
+	// Cancel stops the job with ErrStoppedByUser as the cause, which tells the
+	// pipeline to keep the partial answer
+	stopCtx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	jobCtx, cancel := context.WithTimeout(stopCtx, s.config.JobTimeout)
+	defer cancel()
+	if !s.start(genJob.ID, stop) {
+		s.finishCancelled(ctx, dbCtx, genJob, stream, "")
+		return
+	}
+	defer s.forget(genJob.ID)
+
+	if err := s.jobRepo.MarkRunning(dbCtx, genJob.ID); err != nil {
+		s.logger.Warn("failed to mark generation job running", ctx, "job_id", genJob.ID, "error", err)
+	}
+
+	var mu sync.Mutex
+	var reply strings.Builder
+	var documentSources []string
+	lastFlush := time.Now()
+
+	onStatus := func(status, message string) {
+		if b, err := json.Marshal(map[string]string{"status": status, "message": message}); err == nil {
+			stream.Publish("status", b)
+		}
+	}
+
+	onSources := func(sources []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		documentSources = sources
+	}
+
+	onDelta := func(token string) error {
+		if payload, err := json.Marshal(map[string]string{"content": token}); err == nil {
+			stream.Publish("", payload)
+		}
+
+		mu.Lock()
+		reply.WriteString(token)
+		var partial string
+		if time.Since(lastFlush) >= s.config.ProgressInterval {
+			partial = reply.String()
+			lastFlush = time.Now()
+		}
+		mu.Unlock()
+
+		if partial != "" {
+			if err := s.jobRepo.UpdateProgress(dbCtx, genJob.ID, partial); err != nil {
+				s.logger.Warn("failed to persist generation progress", ctx, "job_id", genJob.ID, "error", err)
+			}
+		}
+		return nil
+	}
+
+	metrics := &domain.GenerationMetrics{}
+	jobCtx = chatservice.WithMetrics(jobCtx, metrics)
+
+	startTime := time.Now()
+	var err error
+	switch genJob.Kind {
+	case domain.JobKindRegenerate:
+		err = s.chatService.RegenerateAnswer(jobCtx, genJob.UserID, genJob.ChatID, genJob.MessageID, genJob.Options, onDelta, onSources, onStatus)
+	case domain.JobKindEdit:
+		err = s.chatService.EditQuestion(jobCtx, genJob.UserID, genJob.ChatID, genJob.MessageID, genJob.Prompt, onDelta, onSources, onStatus)
+	default:
+		err = s.chatService.StreamChatMessageWithSources(jobCtx, genJob.UserID, genJob.ChatID, genJob.Prompt, onDelta, onSources, onStatus)
+	}
+
+	mu.Lock()
+	content := reply.String()
+	sources := documentSources
+	mu.Unlock()
+
+	if err != nil && s.wasCancelled(genJob.ID) {
+		s.finishCancelled(ctx, dbCtx, genJob, stream, content)
+		return
+	}
+	if err != nil {
+		s.logger.Error("generation job failed", ctx, "job_id", genJob.ID, "error", err)
+		if markErr := s.jobRepo.MarkFailed(dbCtx, genJob.ID, content, err.Error()); markErr != nil {
+			s.logger.Error("failed to mark generation job failed", ctx, "job_id", genJob.ID, "error", markErr)
+		}
+		s.refundFailed(dbCtx, genJob)
+		errorPayload, _ := json.Marshal(map[string]string{"error": "An error occurred during the stream."})
+		stream.Publish("error", errorPayload)
+		return
+	}
+
+	metrics.TotalMs = time.Since(startTime).Milliseconds()
+	if err := s.jobRepo.MarkCompleted(dbCtx, genJob.ID, content, *metrics); err != nil {
+		s.logger.Error("failed to mark generation job completed", ctx, "job_id", genJob.ID, "error", err)
+	}
+
+	s.publishFinalIfTruncated(stream, genJob, content)
+
+	if len(sources) > 0 {
+		finalSourcesPayload, _ := json.Marshal(map[string]interface{}{"type": "final_sources", "sources": sources})
+		stream.Publish("metadata", finalSourcesPayload)
+	}
+
+	completion := map[string]interface{}{
+		"type":         "complete",
+		"jobId":        genJob.ID,
+		"responseTime": time.Since(startTime).Milliseconds(),
+		"chargeAmount": genJob.Charge,
+	}
+	// A new chat is named after its first exchange; the client updates its sidebar from here
+	if title, err := s.chatService.GenerateTitle(dbCtx, genJob.UserID, genJob.ChatID); err != nil {
+		s.logger.Warn("chat title generation failed", ctx, "job_id", genJob.ID, "error", err)
+	} else if title != "" {
+		completion["chatTitle"] = title
+	}
+	completionPayload, _ := json.Marshal(completion)
+	stream.Publish("complete", completionPayload)
+	stream.Publish("done", []byte(`{"message": "Stream complete"}`))
+
+	s.logger.Info("generation job completed", ctx, "job_id", genJob.ID, "duration", time.Since(startTime).String())
+
+	// Fold the new answer into the chat's running summary once the client has it
+	s.queueSummary(summaryTask{userID: genJob.UserID, chatID: genJob.ChatID, requestID: task.requestID})
+}
+
+// start registers the worker's cancel func; false means the job was cancelled while queued
+func (s *GenerationService) start(jobID uint, cancel context.CancelCauseFunc) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active, ok := s.active[jobID]
+	if !ok {
+		return true
+	}
+	if active.cancelled {
+		delete(s.active, jobID)
+		return false
+	}
+	active.cancel = cancel
+	return true
+}
+
+func (s *GenerationService) wasCancelled(jobID uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active, ok := s.active[jobID]
+	return ok && active.cancelled
+}
+
+func (s *GenerationService) forget(jobID uint) {
+	s.mu.Lock()
+	delete(s.active, jobID)
+	s.mu.Unlock()
+}
+
+// publishFinalIfTruncated sends the whole answer when the stream dropped deltas, in
+// the same "final" frame a reconnect gets once the stream has expired
+func (s *GenerationService) publishFinalIfTruncated(stream *streams.Stream, genJob *domain.GenerationJob, content string) {
+	if content == "" || !stream.Truncated() {
+		return
+	}
+	finalPayload, _ := json.Marshal(map[string]interface{}{"job_id": genJob.ID, "content": content})
+	stream.Publish("final", finalPayload)
+}
+
+// finishCancelled charges for the produced content (capped at the original charge),
+// refunds the rest and tells subscribers the job stopped. Content is measured with
+// len, the same byte count the prompt was charged by.
+func (s *GenerationService) finishCancelled(ctx, dbCtx context.Context, genJob *domain.GenerationJob, stream *streams.Stream, content string) {
+	charged := len(content)
+	if charged > genJob.Charge {
+		charged = genJob.Charge
+	}
+	refund := genJob.Charge - charged
+	if refund > 0 {
+		if err := s.refunder.AddCredits(dbCtx, genJob.UserID, refund, "generation_cancelled"); err != nil {
+			s.logger.Error("failed to refund cancelled generation", ctx, "job_id", genJob.ID, "refund", refund, "error", err)
+			charged = genJob.Charge
+			refund = 0
+		}
+	}
+	if err := s.jobRepo.MarkCancelled(dbCtx, genJob.ID, content, charged); err != nil {
+		s.logger.Error("failed to mark generation job cancelled", ctx, "job_id", genJob.ID, "error", err)
+	}
+
+	s.publishFinalIfTruncated(stream, genJob, content)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type":         "cancelled",
+		"jobId":        genJob.ID,
+		"chargeAmount": charged,
+		"refunded":     refund,
+	})
+	stream.Publish("cancelled", payload)
+	stream.Publish("done", []byte(`{"message": "Stream cancelled"}`))
+
+	s.logger.Info("generation job cancelled", ctx, "job_id", genJob.ID, "charged", charged, "refunded", refund)
+}
@@ -5,12 +5,15 @@ import (
     "bytes"
     "context"
     "encoding/json"
+    "errors"
     "fmt"
     "io"
     "net/http"
     "time"
     
     "github.com/qdrant/go-client/qdrant"
+
+    "github.com/iyunix/go-internist/internal/logging"
 )
 
 // ClientService implements Qdrant operations using direct HTTP REST API calls
@@ -18,11 +21,11 @@ type ClientService struct {
     config *Config
     client *http.Client
     baseURL string
-    logger Logger
+    logger logging.Logger
 }
 
 // NewClientService creates a new HTTP-based Qdrant client
-func NewClientService(config *Config, logger Logger) (*ClientService, error) {
+func NewClientService(config *Config, logger logging.Logger) (*ClientService, error) {
     if err := config.Validate(); err != nil {
         return nil, NewConfigError(err.Error())
     }
@@ -81,7 +84,7 @@ func (c *ClientService) HealthCheck(ctx context.Context) error {
     if resp.StatusCode != 200 {
         body, _ := io.ReadAll(resp.Body)
         c.logger.Error("HTTP health check failed", "status", resp.StatusCode, "body", string(body))
-        return NewConnectionError("health_check", fmt.Sprintf("HTTP %d", resp.StatusCode), fmt.Errorf(string(body)))
+        return NewConnectionError("health_check", fmt.Sprintf("HTTP %d", resp.StatusCode), errors.New(string(body)))
     }
     
     c.logger.Debug("Qdrant HTTP health check passed")
@@ -42,11 +42,3 @@ type ServiceStatus struct {
     IndexHost         string // Keep same field name for compatibility
     Namespace         string // Keep same field name for compatibility
 }
-
-// Logger interface for Qdrant operations
-type Logger interface {
-    Info(msg string, keysAndValues ...interface{})
-    Error(msg string, keysAndValues ...interface{})
-    Debug(msg string, keysAndValues ...interface{})
-    Warn(msg string, keysAndValues ...interface{})
-}
@@ -4,16 +4,18 @@ package pinecone
 import (
     "context"    
     "github.com/qdrant/go-client/qdrant"
+
+    "github.com/iyunix/go-internist/internal/logging"
 )
 
 type VectorService struct {
     client  *ClientService
     retry   *RetryService
     config  *Config
-    logger  Logger
+    logger  logging.Logger
 }
 
-func NewVectorService(client *ClientService, retry *RetryService, config *Config, logger Logger) *VectorService {
+func NewVectorService(client *ClientService, retry *RetryService, config *Config, logger logging.Logger) *VectorService {
     return &VectorService{
         client: client,
         retry:  retry,
@@ -4,14 +4,16 @@ package pinecone
 import (
     "context"
     "time"
+
+    "github.com/iyunix/go-internist/internal/logging"
 )
 
 type RetryService struct {
     config *Config
-    logger Logger
+    logger logging.Logger
 }
 
-func NewRetryService(config *Config, logger Logger) *RetryService {
+func NewRetryService(config *Config, logger logging.Logger) *RetryService {
     return &RetryService{
         config: config,
         logger: logger,
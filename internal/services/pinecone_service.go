@@ -3,6 +3,7 @@ package services
 
 import (
     "context"
+    "github.com/iyunix/go-internist/internal/logging"
     "github.com/iyunix/go-internist/internal/services/pinecone"
     qdrantSDK "github.com/qdrant/go-client/qdrant"
 )
@@ -12,10 +13,10 @@ type PineconeService struct {
     clientService *pinecone.ClientService
     retryService  *pinecone.RetryService
     vectorService *pinecone.VectorService
-    logger        Logger
+    logger        logging.Logger
 }
 
-func NewPineconeService(apiKey, indexHost, namespace string, logger Logger) (*PineconeService, error) {
+func NewPineconeService(apiKey, indexHost, namespace string, logger logging.Logger) (*PineconeService, error) {
     config := pinecone.DefaultConfig()
     config.APIKey = apiKey
     config.IndexHost = indexHost  // This will be Qdrant URL
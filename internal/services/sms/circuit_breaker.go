@@ -0,0 +1,100 @@
+// File: internal/services/sms/circuit_breaker.go
+package sms
+
+import (
+    "sync"
+    "time"
+)
+
+// Circuit breaker states
+const (
+    BreakerClosed   = "closed"    // sending normally
+    BreakerOpen     = "open"      // skipped until the cooldown passes
+    BreakerHalfOpen = "half_open" // one trial send decides whether to close again
+)
+
+// CircuitBreaker stops sending through a provider after consecutive failures, so a
+// provider that is down is skipped instead of costing every user the full retry time.
+// After the cooldown a single trial request is let through.
+type CircuitBreaker struct {
+    mu        sync.Mutex
+    threshold int
+    cooldown  time.Duration
+    failures  int
+    state     string
+    openedAt  time.Time
+    trial     bool // a half-open trial is in flight
+}
+
+// NewCircuitBreaker opens after threshold consecutive failures for cooldown
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+    if threshold <= 0 {
+        threshold = 3
+    }
+    if cooldown <= 0 {
+        cooldown = time.Minute
+    }
+    return &CircuitBreaker{threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+// Allow reports whether a request may be sent now
+func (b *CircuitBreaker) Allow() bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    switch b.state {
+    case BreakerOpen:
+        if time.Since(b.openedAt) < b.cooldown {
+            return false
+        }
+        b.state = BreakerHalfOpen
+        b.trial = true
+        return true
+    case BreakerHalfOpen:
+        if b.trial {
+            return false
+        }
+        b.trial = true
+        return true
+    }
+    return true
+}
+
+// Success closes the breaker
+func (b *CircuitBreaker) Success() {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.state = BreakerClosed
+    b.failures = 0
+    b.trial = false
+}
+
+// Failure counts a failed request; a failed trial reopens the breaker at once
+func (b *CircuitBreaker) Failure() {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.failures++
+    b.trial = false
+    if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+        b.state = BreakerOpen
+        b.openedAt = time.Now()
+    }
+}
+
+// Release ends a request that says nothing about the provider's health, such as one
+// cancelled by the caller, letting the next request through as the trial instead
+func (b *CircuitBreaker) Release() {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.trial = false
+}
+
+// State returns closed, open or half_open
+func (b *CircuitBreaker) State() string {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+        return BreakerHalfOpen
+    }
+    return b.state
+}
@@ -8,7 +8,8 @@ import (
 
 type Config struct {
     AccessKey     string
-    TemplateID    int
+    TemplateID    int       // verification template
+    Templates     Templates // template IDs for the other messages, see SMS_TEMPLATES
     APIURL        string
     Timeout       time.Duration
     MaxRetries    int
@@ -11,6 +11,7 @@ const (
     ErrTypeProvider    ErrorType = "PROVIDER"
     ErrTypeRateLimit   ErrorType = "RATE_LIMIT"
     ErrTypeValidation  ErrorType = "VALIDATION"
+    ErrTypeUnsupported ErrorType = "UNSUPPORTED" // the provider has no template for the message
 )
 
 type SMSError struct {
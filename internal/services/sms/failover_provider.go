@@ -0,0 +1,162 @@
+// File: internal/services/sms/failover_provider.go
+package sms
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "time"
+
+    "github.com/iyunix/go-internist/internal/logging"
+)
+
+// NamedProvider is one link of a failover chain
+type NamedProvider struct {
+    Name     string
+    Provider Provider
+}
+
+// FailoverConfig tunes retries and circuit breaking for each provider in a chain
+type FailoverConfig struct {
+    Retry            *RetryConfig  // attempts on one provider before moving to the next
+    BreakerThreshold int           // consecutive failures that open a provider's breaker
+    BreakerCooldown  time.Duration // how long an open breaker skips the provider
+}
+
+// DefaultFailoverConfig retries each provider twice and skips one for a minute after
+// three failed sends in a row
+func DefaultFailoverConfig() FailoverConfig {
+    return FailoverConfig{
+        Retry: &RetryConfig{
+            MaxAttempts: 2,
+            Delay:       300 * time.Millisecond,
+            MaxDelay:    2 * time.Second,
+        },
+        BreakerThreshold: 3,
+        BreakerCooldown:  time.Minute,
+    }
+}
+
+type failoverLink struct {
+    name     string
+    provider Provider
+    breaker  *CircuitBreaker
+}
+
+// FailoverProvider sends through the first provider in order that accepts the message.
+// Each provider is retried with backoff and has its own circuit breaker, so an outage
+// at the first provider costs one breaker-threshold of slow sends and is then skipped.
+type FailoverProvider struct {
+    links  []failoverLink
+    retry  *RetryConfig
+    logger logging.Logger
+}
+
+// NewFailoverProvider chains providers in the given order
+func NewFailoverProvider(providers []NamedProvider, config FailoverConfig, logger logging.Logger) (*FailoverProvider, error) {
+    if len(providers) == 0 {
+        return nil, &SMSError{Type: ErrTypeConfig, Message: "no SMS providers configured"}
+    }
+    if config.Retry == nil {
+        config.Retry = DefaultFailoverConfig().Retry
+    }
+    f := &FailoverProvider{retry: config.Retry, logger: logger}
+    for _, p := range providers {
+        f.links = append(f.links, failoverLink{
+            name:     p.Name,
+            provider: p.Provider,
+            breaker:  NewCircuitBreaker(config.BreakerThreshold, config.BreakerCooldown),
+        })
+    }
+    return f, nil
+}
+
+// SendTemplate returns the receipt of the provider that accepted the message. Providers
+// with no mapping for the template are passed over without counting against them.
+func (f *FailoverProvider) SendTemplate(ctx context.Context, phone, template string, params map[string]string) (Receipt, error) {
+    var errs []error
+    unsupported := 0
+    for i, link := range f.links {
+        if !link.breaker.Allow() {
+            errs = append(errs, fmt.Errorf("%s: circuit open", link.name))
+            continue
+        }
+
+        var receipt Receipt
+        err := RetryWithBackoff(ctx, f.retry, func(retryCtx context.Context) error {
+            var err error
+            receipt, err = link.provider.SendTemplate(retryCtx, phone, template, params)
+            return err
+        })
+        receipt.Provider = link.name
+        if err == nil {
+            link.breaker.Success()
+            if i > 0 {
+                f.logger.Info("SMS sent via fallback provider", "provider", link.name, "skipped", len(errs))
+            }
+            return receipt, nil
+        }
+        if ctx.Err() != nil {
+            link.breaker.Release()
+            return receipt, ctx.Err()
+        }
+        if smsErr, ok := err.(*SMSError); ok && smsErr.Type == ErrTypeValidation {
+            // The provider is up and rejected the number; others would too
+            link.breaker.Success()
+            return receipt, err
+        }
+        if smsErr, ok := err.(*SMSError); ok && smsErr.Type == ErrTypeUnsupported {
+            link.breaker.Release()
+            unsupported++
+            errs = append(errs, fmt.Errorf("%s: %w", link.name, err))
+            continue
+        }
+
+        link.breaker.Failure()
+        f.logger.Warn("SMS provider failed", "provider", link.name, "error", err, "breaker", link.breaker.State())
+        errs = append(errs, fmt.Errorf("%s: %w", link.name, err))
+    }
+    if unsupported == len(f.links) {
+        return Receipt{}, &SMSError{Type: ErrTypeUnsupported, Message: fmt.Sprintf("no SMS provider has a template for %q", template), Cause: errors.Join(errs...)}
+    }
+    return Receipt{}, &SMSError{Type: ErrTypeProvider, Message: "all SMS providers failed", Cause: errors.Join(errs...)}
+}
+
+// ParseDeliveryReports hands a callback to the named provider in the chain, if it
+// supports delivery reports
+func (f *FailoverProvider) ParseDeliveryReports(provider string, r *http.Request) ([]DeliveryReport, error) {
+    for _, link := range f.links {
+        if link.name != provider {
+            continue
+        }
+        parser, ok := link.provider.(ReportParser)
+        if !ok {
+            break
+        }
+        return parser.ParseDeliveryReports(r)
+    }
+    return nil, &SMSError{Type: ErrTypeConfig, Message: fmt.Sprintf("provider %q does not send delivery reports", provider)}
+}
+
+// HealthCheck passes while at least one provider is healthy
+func (f *FailoverProvider) HealthCheck(ctx context.Context) error {
+    var errs []error
+    for _, link := range f.links {
+        err := link.provider.HealthCheck(ctx)
+        if err == nil {
+            return nil
+        }
+        errs = append(errs, fmt.Errorf("%s: %w", link.name, err))
+    }
+    return errors.Join(errs...)
+}
+
+// BreakerStates reports each provider's circuit breaker state by provider name
+func (f *FailoverProvider) BreakerStates() map[string]string {
+    states := make(map[string]string, len(f.links))
+    for _, link := range f.links {
+        states[link.name] = link.breaker.State()
+    }
+    return states
+}
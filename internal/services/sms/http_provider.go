@@ -0,0 +1,239 @@
+// File: internal/services/sms/http_provider.go
+package sms
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// HTTPConfig describes a gateway with a plain HTTP API. In URL and Body, {phone} is
+// replaced with the recipient, {text} with the message text from Templates, and {code},
+// {balance} and the other template parameters with their values, each escaped for
+// where it appears.
+type HTTPConfig struct {
+    URL         string    // e.g. https://gw.example.com/send?to={phone}
+    Method      string    // defaults to POST
+    Body        string    // e.g. {"to":"{phone}","text":"{text}"}
+    ContentType string    // defaults to application/json
+    Headers     map[string]string
+    HealthURL   string    // optional GET that must return 2xx
+    Templates   Templates // message texts, e.g. low_balance=Only {balance} characters left
+    Timeout     time.Duration
+}
+
+// Validate checks the settings needed to send
+func (c *HTTPConfig) Validate() error {
+    if c.URL == "" {
+        return fmt.Errorf("SMS_HTTP_URL is required")
+    }
+    if !strings.Contains(c.URL+c.Body, "{code}") && !strings.Contains(c.URL+c.Body, "{text}") {
+        return fmt.Errorf("SMS_HTTP_URL or SMS_HTTP_BODY must contain {code} or {text}")
+    }
+    return nil
+}
+
+// HTTPTemplateProvider sends codes through any gateway that can be described by a
+// request template
+type HTTPTemplateProvider struct {
+    config *HTTPConfig
+    client *http.Client
+}
+
+func NewHTTPTemplateProvider(config *HTTPConfig) *HTTPTemplateProvider {
+    if config.Method == "" {
+        config.Method = "POST"
+    }
+    if config.ContentType == "" {
+        config.ContentType = "application/json"
+    }
+    return &HTTPTemplateProvider{
+        config: config,
+        client: &http.Client{
+            Timeout: config.Timeout,
+        },
+    }
+}
+
+// SendTemplate renders the template's text and fills the request. A verification code
+// with no text configured goes through a URL or body that uses {code} directly.
+func (p *HTTPTemplateProvider) SendTemplate(ctx context.Context, phone, template string, params map[string]string) (Receipt, error) {
+    receipt := Receipt{Provider: "http"}
+    if err := checkParams(template, params); err != nil {
+        return receipt, err
+    }
+    text, err := p.config.Templates.lookup(template)
+    if err != nil {
+        if template != TemplateVerification || !strings.Contains(p.config.URL+p.config.Body, "{code}") {
+            return receipt, err
+        }
+    }
+
+    values := map[string]string{"phone": phone, "template": template}
+    for name, value := range params {
+        values[name] = value
+    }
+    values["text"] = fillTemplate(text, values, func(s string) string { return s })
+
+    endpoint := fillTemplate(p.config.URL, values, url.QueryEscape)
+
+    var body io.Reader
+    if p.config.Body != "" {
+        escape := func(s string) string { return s }
+        switch {
+        case strings.Contains(p.config.ContentType, "json"):
+            escape = jsonEscape
+        case strings.Contains(p.config.ContentType, "x-www-form-urlencoded"):
+            escape = url.QueryEscape
+        }
+        body = strings.NewReader(fillTemplate(p.config.Body, values, escape))
+    }
+
+    req, err := http.NewRequestWithContext(ctx, p.config.Method, endpoint, body)
+    if err != nil {
+        return receipt, &SMSError{Type: ErrTypeConfig, Message: "invalid SMS_HTTP_URL or method", Cause: err}
+    }
+    if body != nil {
+        req.Header.Set("Content-Type", p.config.ContentType)
+    }
+    for name, value := range p.config.Headers {
+        req.Header.Set(name, value)
+    }
+
+    responseBody, err := p.do(req)
+    if err != nil {
+        return receipt, err
+    }
+    receipt.MessageID = messageIDFromJSON(responseBody)
+    return receipt, nil
+}
+
+// messageIDFromJSON picks a message ID out of a JSON response, looking at the usual
+// field names at the top level and under "data"
+func messageIDFromJSON(body []byte) string {
+    var result map[string]interface{}
+    if json.Unmarshal(body, &result) != nil {
+        return ""
+    }
+    if data, ok := result["data"].(map[string]interface{}); ok {
+        if id := messageIDFromJSONMap(data); id != "" {
+            return id
+        }
+    }
+    return messageIDFromJSONMap(result)
+}
+
+func messageIDFromJSONMap(m map[string]interface{}) string {
+    for _, key := range []string{"message_id", "messageId", "messageid", "id"} {
+        switch v := m[key].(type) {
+        case string:
+            return v
+        case float64:
+            return strconv.FormatFloat(v, 'f', -1, 64)
+        }
+    }
+    return ""
+}
+
+// ParseDeliveryReports reads a generic callback: JSON {"message_id": "...", "status": "..."}
+// or a list of those, or the same fields as a form post. Statuses other than delivered
+// and failed/undelivered are ignored.
+func (p *HTTPTemplateProvider) ParseDeliveryReports(r *http.Request) ([]DeliveryReport, error) {
+    type report struct {
+        MessageID string `json:"message_id"`
+        Status    string `json:"status"`
+    }
+    var raw []report
+    if strings.Contains(r.Header.Get("Content-Type"), "json") {
+        body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+        if err != nil {
+            return nil, &SMSError{Type: ErrTypeValidation, Message: "invalid delivery report", Cause: err}
+        }
+        var one report
+        if json.Unmarshal(body, &raw) != nil {
+            if err := json.Unmarshal(body, &one); err != nil {
+                return nil, &SMSError{Type: ErrTypeValidation, Message: "invalid delivery report", Cause: err}
+            }
+            raw = []report{one}
+        }
+    } else {
+        if err := r.ParseForm(); err != nil {
+            return nil, &SMSError{Type: ErrTypeValidation, Message: "invalid delivery report", Cause: err}
+        }
+        raw = []report{{MessageID: r.Form.Get("message_id"), Status: r.Form.Get("status")}}
+    }
+
+    var reports []DeliveryReport
+    for _, rep := range raw {
+        if rep.MessageID == "" {
+            return nil, &SMSError{Type: ErrTypeValidation, Message: "delivery report needs message_id"}
+        }
+        switch strings.ToLower(rep.Status) {
+        case "delivered":
+            reports = append(reports, DeliveryReport{MessageID: rep.MessageID, Status: DeliveryDelivered, Detail: rep.Status})
+        case "failed", "undelivered", "rejected", "expired":
+            reports = append(reports, DeliveryReport{MessageID: rep.MessageID, Status: DeliveryFailed, Detail: rep.Status})
+        }
+    }
+    return reports, nil
+}
+
+// HealthCheck calls the health URL when one is configured
+func (p *HTTPTemplateProvider) HealthCheck(ctx context.Context) error {
+    if p.config.HealthURL == "" {
+        return nil
+    }
+    req, err := http.NewRequestWithContext(ctx, "GET", p.config.HealthURL, nil)
+    if err != nil {
+        return &SMSError{Type: ErrTypeConfig, Message: "invalid SMS_HTTP_HEALTH_URL", Cause: err}
+    }
+    for name, value := range p.config.Headers {
+        req.Header.Set(name, value)
+    }
+    _, err = p.do(req)
+    return err
+}
+
+func (p *HTTPTemplateProvider) do(req *http.Request) ([]byte, error) {
+    resp, err := p.client.Do(req)
+    if err != nil {
+        return nil, &SMSError{Type: ErrTypeNetwork, Message: "request failed", Cause: err}
+    }
+    defer resp.Body.Close()
+
+    responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+    if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+        return responseBody, nil
+    }
+    if len(responseBody) > 4<<10 {
+        responseBody = responseBody[:4<<10]
+    }
+    switch resp.StatusCode {
+    case 401, 403:
+        return nil, &SMSError{Type: ErrTypeConfig, Code: resp.StatusCode, Message: string(responseBody)}
+    case 429:
+        return nil, &SMSError{Type: ErrTypeRateLimit, Code: resp.StatusCode, Message: "rate limit exceeded"}
+    }
+    return nil, &SMSError{Type: ErrTypeProvider, Code: resp.StatusCode, Message: string(responseBody)}
+}
+
+// fillTemplate replaces each {name} placeholder with its escaped value
+func fillTemplate(template string, values map[string]string, escape func(string) string) string {
+    pairs := make([]string, 0, 2*len(values))
+    for name, value := range values {
+        pairs = append(pairs, "{"+name+"}", escape(value))
+    }
+    return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// jsonEscape escapes s for use inside a JSON string literal
+func jsonEscape(s string) string {
+    b, _ := json.Marshal(s)
+    return string(b[1 : len(b)-1])
+}
@@ -1,7 +1,10 @@
 // G:\go_internist\internal\services\sms\interface.go
 package sms
 
-import "context"
+import (
+    "context"
+    "net/http"
+)
 
 // ProviderStatus represents the health status of SMS provider
 type ProviderStatus struct {
@@ -9,11 +12,39 @@ type ProviderStatus struct {
     Message   string
 }
 
+// Receipt identifies a message accepted by a provider, for matching delivery reports
+type Receipt struct {
+    Provider  string
+    MessageID string // empty when the provider returns none
+}
+
+// Provider sends templated messages. template is one of the Template* keys and params
+// holds the values TemplateParams lists for it. A provider with no mapping for the
+// template returns an ErrTypeUnsupported error without sending.
 type Provider interface {
-    SendVerificationCode(ctx context.Context, phone, code string) error
+    SendTemplate(ctx context.Context, phone, template string, params map[string]string) (Receipt, error)
     HealthCheck(ctx context.Context) error
 }
 
+// Delivery report statuses
+const (
+    DeliveryDelivered = "delivered"
+    DeliveryFailed    = "failed"
+)
+
+// DeliveryReport is a provider's final word on a message it accepted
+type DeliveryReport struct {
+    MessageID string
+    Status    string // DeliveryDelivered or DeliveryFailed
+    Detail    string // provider's status text
+}
+
+// ReportParser is implemented by providers that push delivery reports to a callback URL.
+// Reports still in progress are left out.
+type ReportParser interface {
+    ParseDeliveryReports(r *http.Request) ([]DeliveryReport, error)
+}
+
 type Service interface {
     SendCode(ctx context.Context, phone, code string) error
     GetProviderStatus() ProviderStatus
@@ -0,0 +1,184 @@
+// File: internal/services/sms/kavenegar_provider.go
+package sms
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// KavenegarConfig configures the Kavenegar verify-lookup API
+type KavenegarConfig struct {
+    APIKey   string
+    Template  string    // verification template with a %token placeholder
+    Templates Templates // template names for the other messages, see KAVENEGAR_TEMPLATES
+    APIURL   string // e.g. https://api.kavenegar.com/v1
+    Timeout  time.Duration
+}
+
+// Validate checks the settings needed to send
+func (c *KavenegarConfig) Validate() error {
+    if c.APIKey == "" {
+        return fmt.Errorf("KAVENEGAR_API_KEY is required")
+    }
+    if c.Template == "" {
+        return fmt.Errorf("KAVENEGAR_TEMPLATE is required")
+    }
+    if c.APIURL == "" {
+        return fmt.Errorf("KAVENEGAR_API_URL is required")
+    }
+    return nil
+}
+
+// KavenegarProvider sends codes through Kavenegar's verify/lookup endpoint
+type KavenegarProvider struct {
+    config *KavenegarConfig
+    client *http.Client
+}
+
+func NewKavenegarProvider(config *KavenegarConfig) *KavenegarProvider {
+    return &KavenegarProvider{
+        config: config,
+        client: &http.Client{
+            Timeout: config.Timeout,
+        },
+    }
+}
+
+// kavenegarTokens are the lookup parameters template parameters are passed in, in order
+var kavenegarTokens = []string{"token", "token2", "token3"}
+
+// SendTemplate sends through Kavenegar's verify/lookup endpoint, passing the template's
+// parameters as token, token2 and token3 in the order TemplateParams lists them
+func (p *KavenegarProvider) SendTemplate(ctx context.Context, phone, template string, params map[string]string) (Receipt, error) {
+    receipt := Receipt{Provider: "kavenegar"}
+    if err := checkParams(template, params); err != nil {
+        return receipt, err
+    }
+    name, err := p.config.Templates.lookup(template)
+    if err != nil {
+        if template != TemplateVerification || p.config.Template == "" {
+            return receipt, err
+        }
+        name = p.config.Template
+    }
+
+    query := url.Values{
+        "receptor": {phone},
+        "template": {name},
+    }
+    names, _ := TemplateParams(template)
+    for i, param := range names {
+        if i >= len(kavenegarTokens) {
+            break
+        }
+        // Lookup tokens cannot contain spaces
+        query.Set(kavenegarTokens[i], strings.ReplaceAll(params[param], " ", "-"))
+    }
+    body, err := p.get(ctx, p.endpoint("verify/lookup.json")+"?"+query.Encode())
+    if err != nil {
+        return receipt, err
+    }
+
+    var result struct {
+        Entries []struct {
+            MessageID json.Number `json:"messageid"`
+        } `json:"entries"`
+    }
+    if json.Unmarshal(body, &result) == nil && len(result.Entries) > 0 {
+        receipt.MessageID = result.Entries[0].MessageID.String()
+    }
+    return receipt, nil
+}
+
+// HealthCheck reads the account info, which validates the API key without sending
+func (p *KavenegarProvider) HealthCheck(ctx context.Context) error {
+    _, err := p.get(ctx, p.endpoint("account/info.json"))
+    return err
+}
+
+// kavenegarStatuses maps Kavenegar's final message statuses; others are still in flight
+var kavenegarStatuses = map[string]string{
+    "10": DeliveryDelivered, // delivered to the handset
+    "6":  DeliveryFailed,    // failed to send
+    "11": DeliveryFailed,    // not delivered
+    "13": DeliveryFailed,    // cancelled
+    "14": DeliveryFailed,    // blocked by the recipient
+}
+
+// ParseDeliveryReports reads Kavenegar's callback, a form post with messageid and status
+func (p *KavenegarProvider) ParseDeliveryReports(r *http.Request) ([]DeliveryReport, error) {
+    if err := r.ParseForm(); err != nil {
+        return nil, &SMSError{Type: ErrTypeValidation, Message: "invalid delivery report", Cause: err}
+    }
+    ids, statuses := r.Form["messageid"], r.Form["status"]
+    if len(ids) == 0 || len(ids) != len(statuses) {
+        return nil, &SMSError{Type: ErrTypeValidation, Message: "delivery report needs messageid and status"}
+    }
+
+    var reports []DeliveryReport
+    for i, id := range ids {
+        if status, ok := kavenegarStatuses[statuses[i]]; ok {
+            reports = append(reports, DeliveryReport{MessageID: id, Status: status, Detail: "status " + statuses[i]})
+        }
+    }
+    return reports, nil
+}
+
+// endpoint builds https://api.kavenegar.com/v1/{API-KEY}/{path}; the key is part of the path
+func (p *KavenegarProvider) endpoint(path string) string {
+    return strings.TrimRight(p.config.APIURL, "/") + "/" + url.PathEscape(p.config.APIKey) + "/" + path
+}
+
+func (p *KavenegarProvider) get(ctx context.Context, endpoint string) ([]byte, error) {
+    req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+    if err != nil {
+        return nil, &SMSError{Type: ErrTypeConfig, Message: "invalid Kavenegar API URL", Cause: err}
+    }
+    req.Header.Set("Accept", "application/json")
+
+    resp, err := p.client.Do(req)
+    if err != nil {
+        // The request URL contains the API key; keep it out of the error
+        if urlErr, ok := err.(*url.Error); ok {
+            err = urlErr.Err
+        }
+        return nil, &SMSError{Type: ErrTypeNetwork, Message: "request failed", Cause: err}
+    }
+    defer resp.Body.Close()
+
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+    return body, p.handleResponse(resp.StatusCode, body)
+}
+
+// handleResponse reads Kavenegar's {"return": {"status", "message"}} envelope
+func (p *KavenegarProvider) handleResponse(statusCode int, body []byte) error {
+    var envelope struct {
+        Return struct {
+            Status  int    `json:"status"`
+            Message string `json:"message"`
+        } `json:"return"`
+    }
+    status, message := statusCode, string(body)
+    if json.Unmarshal(body, &envelope) == nil && envelope.Return.Status != 0 {
+        status, message = envelope.Return.Status, envelope.Return.Message
+    }
+
+    switch {
+    case status >= 200 && status < 300:
+        return nil
+    case status == 401 || status == 403 || status == 407:
+        return &SMSError{Type: ErrTypeConfig, Code: status, Message: message}
+    case status == 411 || status == 414:
+        // invalid or too many receptors
+        return &SMSError{Type: ErrTypeValidation, Code: status, Message: message}
+    case status == 429:
+        return &SMSError{Type: ErrTypeRateLimit, Code: status, Message: "rate limit exceeded"}
+    }
+    return &SMSError{Type: ErrTypeProvider, Code: status, Message: message}
+}
@@ -3,13 +3,17 @@ package sms
 
 import (
     "context"
+    "math/rand/v2"
     "time"
 )
 
-// RetryConfig defines simple retry behavior
+// RetryConfig defines retry behavior. The wait before retry n is drawn uniformly from
+// [0, min(MaxDelay, Delay*2^n)] ("full jitter"), so clients retrying after a shared
+// outage do not hit the provider in lockstep.
 type RetryConfig struct {
     MaxAttempts int
-    Delay       time.Duration
+    Delay       time.Duration // base delay
+    MaxDelay    time.Duration // cap on the exponential delay
 }
 
 // DefaultRetryConfig provides sensible defaults
@@ -17,10 +21,36 @@ func DefaultRetryConfig() *RetryConfig {
     return &RetryConfig{
         MaxAttempts: 3,
         Delay:       500 * time.Millisecond,
+        MaxDelay:    5 * time.Second,
     }
 }
 
-// RetryWithBackoff executes a function with simple retry logic
+// backoff returns the jittered wait before the retry following attempt (0-based)
+func (c *RetryConfig) backoff(attempt int) time.Duration {
+    delay := c.Delay
+    for i := 0; i < attempt && (c.MaxDelay <= 0 || delay < c.MaxDelay); i++ {
+        delay *= 2
+    }
+    if c.MaxDelay > 0 && delay > c.MaxDelay {
+        delay = c.MaxDelay
+    }
+    if delay <= 0 {
+        return 0
+    }
+    return rand.N(delay + 1)
+}
+
+// IsRetryable reports whether sending again may succeed. Configuration, validation
+// and missing-template errors fail the same way every time.
+func IsRetryable(err error) bool {
+    if smsErr, ok := err.(*SMSError); ok {
+        return smsErr.Type != ErrTypeConfig && smsErr.Type != ErrTypeValidation && smsErr.Type != ErrTypeUnsupported
+    }
+    return true
+}
+
+// RetryWithBackoff executes a function, retrying retryable errors with exponential
+// backoff and jitter
 func RetryWithBackoff(ctx context.Context, config *RetryConfig, fn func(ctx context.Context) error) error {
     var lastErr error
     
@@ -33,10 +63,8 @@ func RetryWithBackoff(ctx context.Context, config *RetryConfig, fn func(ctx cont
         lastErr = err
         
         // Don't retry non-retryable errors
-        if smsErr, ok := err.(*SMSError); ok {
-            if smsErr.Type == ErrTypeConfig || smsErr.Type == ErrTypeValidation {
-                return err
-            }
+        if !IsRetryable(err) {
+            return err
         }
         
         // Don't wait after last attempt
@@ -44,7 +72,7 @@ func RetryWithBackoff(ctx context.Context, config *RetryConfig, fn func(ctx cont
             select {
             case <-ctx.Done():
                 return ctx.Err()
-            case <-time.After(config.Delay):
+            case <-time.After(config.backoff(attempt)):
             }
         }
     }
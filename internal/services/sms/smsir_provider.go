@@ -5,8 +5,12 @@ import (
     "bytes"
     "context"
     "encoding/json"
+    "fmt"
     "io"
     "net/http"
+    "net/url"
+    "strconv"
+    "strings"
 )
 
 type SMSIRProvider struct {
@@ -23,27 +27,61 @@ func NewSMSIRProvider(config *Config) *SMSIRProvider {
     }
 }
 
-func (p *SMSIRProvider) SendVerificationCode(ctx context.Context, phone, code string) error {
+// SendTemplate sends through SMS.ir's verify endpoint. Parameters are named after the
+// template's parameters with the first letter capitalized (Code, Balance, Plan, Days),
+// which is how the SMS.ir templates must declare them.
+func (p *SMSIRProvider) SendTemplate(ctx context.Context, phone, template string, params map[string]string) (Receipt, error) {
+    if err := checkParams(template, params); err != nil {
+        return Receipt{Provider: "smsir"}, err
+    }
+    templateID, err := p.templateID(template)
+    if err != nil {
+        return Receipt{Provider: "smsir"}, err
+    }
+
+    names, _ := TemplateParams(template)
+    parameters := make([]map[string]string, 0, len(names))
+    for _, name := range names {
+        parameters = append(parameters, map[string]string{
+            "name":  strings.ToUpper(name[:1]) + name[1:],
+            "value": params[name],
+        })
+    }
     payload := map[string]interface{}{
         "mobile":     phone,
-        "templateId": p.config.TemplateID,
-        "parameters": []map[string]string{
-            {"name": "Code", "value": code},
-        },
+        "templateId": templateID,
+        "parameters": parameters,
     }
 
     return p.sendRequest(ctx, payload)
 }
 
-func (p *SMSIRProvider) sendRequest(ctx context.Context, payload interface{}) error {
+// templateID resolves a template; verification falls back to SMS_TEMPLATE_ID
+func (p *SMSIRProvider) templateID(template string) (int, error) {
+    ref, err := p.config.Templates.lookup(template)
+    if err != nil {
+        if template == TemplateVerification && p.config.TemplateID != 0 {
+            return p.config.TemplateID, nil
+        }
+        return 0, err
+    }
+    id, convErr := strconv.Atoi(ref)
+    if convErr != nil {
+        return 0, &SMSError{Type: ErrTypeConfig, Message: fmt.Sprintf("SMS.ir template ID for %q must be a number", template), Cause: convErr}
+    }
+    return id, nil
+}
+
+func (p *SMSIRProvider) sendRequest(ctx context.Context, payload interface{}) (Receipt, error) {
+    receipt := Receipt{Provider: "smsir"}
     body, err := json.Marshal(payload)
     if err != nil {
-        return &SMSError{Type: ErrTypeValidation, Message: "invalid payload", Cause: err}
+        return receipt, &SMSError{Type: ErrTypeValidation, Message: "invalid payload", Cause: err}
     }
 
     req, err := http.NewRequestWithContext(ctx, "POST", p.config.APIURL, bytes.NewBuffer(body))
     if err != nil {
-        return &SMSError{Type: ErrTypeNetwork, Message: "failed to create request", Cause: err}
+        return receipt, &SMSError{Type: ErrTypeNetwork, Message: "failed to create request", Cause: err}
     }
 
     req.Header.Set("Content-Type", "application/json")
@@ -51,35 +89,85 @@ func (p *SMSIRProvider) sendRequest(ctx context.Context, payload interface{}) er
 
     resp, err := p.client.Do(req)
     if err != nil {
-        return &SMSError{Type: ErrTypeNetwork, Message: "request failed", Cause: err}
+        return receipt, &SMSError{Type: ErrTypeNetwork, Message: "request failed", Cause: err}
     }
     defer resp.Body.Close()
 
-    return p.handleResponse(resp)
+    responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+    if err := p.handleResponse(resp.StatusCode, responseBody); err != nil {
+        return receipt, err
+    }
+
+    // {"status": 1, "message": "...", "data": {"messageId": 89545112, "cost": 1.0}}
+    var result struct {
+        Data struct {
+            MessageID json.Number `json:"messageId"`
+        } `json:"data"`
+    }
+    if json.Unmarshal(responseBody, &result) == nil {
+        receipt.MessageID = result.Data.MessageID.String()
+    }
+    return receipt, nil
 }
 
-func (p *SMSIRProvider) handleResponse(resp *http.Response) error {
-    if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+func (p *SMSIRProvider) handleResponse(statusCode int, responseBody []byte) error {
+    if statusCode >= 200 && statusCode < 300 {
         return nil
     }
-
-    responseBody, _ := io.ReadAll(resp.Body)
     
-    if resp.StatusCode == 429 {
+    if statusCode == 429 {
         return &SMSError{
             Type:    ErrTypeRateLimit,
-            Code:    resp.StatusCode,
+            Code:    statusCode,
             Message: "rate limit exceeded",
         }
     }
 
     return &SMSError{
         Type:    ErrTypeProvider,
-        Code:    resp.StatusCode,
+        Code:    statusCode,
         Message: string(responseBody),
     }
 }
 
+// HealthCheck queries the account credit endpoint, which validates the API key
+// and reachability without sending a message.
 func (p *SMSIRProvider) HealthCheck(ctx context.Context) error {
-    return nil
+    creditURL, err := p.creditURL()
+    if err != nil {
+        return &SMSError{Type: ErrTypeValidation, Message: "invalid SMS API URL", Cause: err}
+    }
+
+    req, err := http.NewRequestWithContext(ctx, "GET", creditURL, nil)
+    if err != nil {
+        return &SMSError{Type: ErrTypeNetwork, Message: "failed to create request", Cause: err}
+    }
+    req.Header.Set("Accept", "application/json")
+    req.Header.Set("X-API-KEY", p.config.AccessKey)
+
+    resp, err := p.client.Do(req)
+    if err != nil {
+        return &SMSError{Type: ErrTypeNetwork, Message: "health check request failed", Cause: err}
+    }
+    defer resp.Body.Close()
+
+    responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+    return p.handleResponse(resp.StatusCode, responseBody)
+}
+
+// creditURL derives the sms.ir credit endpoint from the configured send URL,
+// e.g. https://api.sms.ir/v1/send/verify -> https://api.sms.ir/v1/credit
+func (p *SMSIRProvider) creditURL() (string, error) {
+    u, err := url.Parse(p.config.APIURL)
+    if err != nil {
+        return "", err
+    }
+    if u.Scheme == "" || u.Host == "" {
+        return "", fmt.Errorf("missing scheme or host in %q", p.config.APIURL)
+    }
+    prefix := ""
+    if idx := strings.Index(u.Path, "/v1"); idx >= 0 {
+        prefix = u.Path[:idx]
+    }
+    return u.Scheme + "://" + u.Host + prefix + "/v1/credit", nil
 }
@@ -0,0 +1,91 @@
+// File: internal/services/sms/templates.go
+package sms
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// Logical message templates. Each provider maps them to its own template IDs or texts.
+const (
+    TemplateVerification  = "verification"   // params: code
+    TemplatePasswordReset = "password_reset" // params: code
+    TemplateLowBalance    = "low_balance"    // params: balance
+    TemplatePlanActivated = "plan_activated" // params: plan, balance
+    TemplateInvitation    = "invitation"     // params: plan, balance
+)
+
+// templateParams lists each template's parameters in the order positional providers
+// (Kavenegar's token, token2, token3) receive them
+var templateParams = map[string][]string{
+    TemplateVerification:  {"code"},
+    TemplatePasswordReset: {"code"},
+    TemplateLowBalance:    {"balance"},
+    TemplatePlanActivated: {"plan", "balance"},
+    TemplateInvitation:    {"plan", "balance"},
+}
+
+// TemplateParams returns the parameters a template takes, in order
+func TemplateParams(template string) ([]string, bool) {
+    params, ok := templateParams[template]
+    return params, ok
+}
+
+// Templates maps logical templates to one provider's template references: an SMS.ir
+// template ID, a Kavenegar template name, or a message text for the HTTP gateway.
+type Templates map[string]string
+
+// ParseTemplates reads "key=reference" pairs separated by semicolons, e.g.
+// "password_reset=123457;low_balance=123458". Semicolons are used because HTTP
+// gateway texts often contain commas.
+func ParseTemplates(s string) (Templates, error) {
+    templates := Templates{}
+    for _, pair := range strings.Split(s, ";") {
+        if strings.TrimSpace(pair) == "" {
+            continue
+        }
+        key, ref, ok := strings.Cut(pair, "=")
+        key, ref = strings.TrimSpace(key), strings.TrimSpace(ref)
+        if !ok || ref == "" {
+            return nil, fmt.Errorf("template %q needs key=value", pair)
+        }
+        if _, known := templateParams[key]; !known {
+            return nil, fmt.Errorf("unknown SMS template %q (known: %s)", key, strings.Join(templateKeys(), ", "))
+        }
+        templates[key] = ref
+    }
+    return templates, nil
+}
+
+// lookup returns the provider's reference for a template, or an unsupported error so
+// the failover chain moves on to a provider that has it
+func (t Templates) lookup(template string) (string, error) {
+    if ref, ok := t[template]; ok && ref != "" {
+        return ref, nil
+    }
+    return "", &SMSError{Type: ErrTypeUnsupported, Message: fmt.Sprintf("no template configured for %q", template)}
+}
+
+// checkParams makes sure every parameter of the template has a value
+func checkParams(template string, params map[string]string) error {
+    names, ok := templateParams[template]
+    if !ok {
+        return &SMSError{Type: ErrTypeValidation, Message: fmt.Sprintf("unknown SMS template %q", template)}
+    }
+    for _, name := range names {
+        if params[name] == "" {
+            return &SMSError{Type: ErrTypeValidation, Message: fmt.Sprintf("template %q needs parameter %q", template, name)}
+        }
+    }
+    return nil
+}
+
+func templateKeys() []string {
+    keys := make([]string, 0, len(templateParams))
+    for key := range templateParams {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
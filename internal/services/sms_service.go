@@ -3,108 +3,358 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
+	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
+	"github.com/iyunix/go-internist/internal/repository/outbox"
 	"github.com/iyunix/go-internist/internal/services/sms"
 )
 
-// SMSService handles SMS delivery with rate limiting and retry logic
+// SMS dispatcher settings
+const (
+	smsDispatchInterval = 2 * time.Second  // poll for due messages even without a wake-up
+	smsSendTimeout      = 30 * time.Second // one send through the provider chain
+	smsClaimLease       = 2 * time.Minute  // a message stuck in sending longer is claimed again
+	smsMaxAttempts      = 5
+	smsRetryBase        = 10 * time.Second
+	smsRetryMax         = 10 * time.Minute
+	smsVerificationTTL  = 10 * time.Minute // matches the verification code lifetime
+	smsNotificationTTL  = 24 * time.Hour   // a day-old notice is no longer worth sending
+)
+
+// smsDispatchBatch is how many messages are claimed per round. They are sent one by one,
+// so the whole batch has to finish within the lease or its tail would be claimed again.
+const smsDispatchBatch = int(smsClaimLease/smsSendTimeout) - 1
+
+// smsTemplateFallbacks is sent instead when no provider has a template; a reset code
+// reads fine in the verification template
+var smsTemplateFallbacks = map[string]string{
+	sms.TemplatePasswordReset: sms.TemplateVerification,
+}
+
+// SMSService handles SMS delivery. Messages go through the sms_messages outbox:
+// SendVerificationCode only queues them, and a background dispatcher sends and retries
+// them, so a provider outage or a restart delays a code instead of losing it. Resend
+// limits and SMS-pumping checks are enforced by user_services.VerificationService.
 type SMSService struct {
 	provider   sms.Provider
-	logger     Logger
-	limiter    map[string]*rateEntry // phone -> attempts/hour
+	outboxRepo outbox.OutboxRepository
+	logger     logging.Logger
 	mu         sync.Mutex
-	maxPerHour int
-}
 
-// rateEntry tracks attempts and reset time per phone number
-type rateEntry struct {
-	Attempts  int
-	LastReset time.Time
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	started bool
+	stopped bool
 }
 
-// NewSMSService creates a new SMS service with rate limiting (default: 6/hour per phone)
-func NewSMSService(provider sms.Provider, logger Logger) *SMSService {
+// NewSMSService creates a new SMS service. Call StartDispatcher to begin sending queued
+// messages.
+func NewSMSService(provider sms.Provider, outboxRepo outbox.OutboxRepository, logger logging.Logger) *SMSService {
 	return &SMSService{
 		provider:   provider,
+		outboxRepo: outboxRepo,
 		logger:     logger,
-		limiter:    make(map[string]*rateEntry),
-		maxPerHour: 6, // 6 SMS per phone per hour — adjust as needed
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// SendVerificationCode queues a verification code for sending. It returns once the
+// message is in the outbox; delivery happens in the dispatcher.
+func (s *SMSService) SendVerificationCode(ctx context.Context, phone, code string) error {
+	return s.sendCode(ctx, phone, sms.TemplateVerification, code)
+}
+
+// SendPasswordResetCode queues a password reset code
+func (s *SMSService) SendPasswordResetCode(ctx context.Context, phone, code string) error {
+	return s.sendCode(ctx, phone, sms.TemplatePasswordReset, code)
+}
+
+func (s *SMSService) sendCode(ctx context.Context, phone, template, code string) error {
+	msg, err := s.enqueue(ctx, phone, template, map[string]string{"code": code}, smsVerificationTTL)
+	if err != nil {
+		return errors.New("could not send verification code, please try again")
 	}
+	s.logger.Info("SMS code queued",
+		"phone", logging.MaskPhone(phone),
+		"template", template,
+		"sms_id", msg.ID,
+		"code_length", len(code))
+	return nil
 }
 
-// allowSend checks if sending an SMS to the given phone is within rate limits
-func (s *SMSService) allowSend(phone string) bool {
+// SendTemplate queues any templated message
+func (s *SMSService) SendTemplate(ctx context.Context, phone, template string, params map[string]string) error {
+	if _, ok := sms.TemplateParams(template); !ok {
+		return fmt.Errorf("unknown SMS template %q", template)
+	}
+	msg, err := s.enqueue(ctx, phone, template, params, smsNotificationTTL)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("SMS queued", "phone", logging.MaskPhone(phone), "template", template, "sms_id", msg.ID)
+	return nil
+}
+
+// Notify sends an account notification to a user unless they turned SMS
+// notifications off. Verification and reset codes are not notifications and are
+// always sent.
+func (s *SMSService) Notify(ctx context.Context, user *domain.User, template string, params map[string]string) error {
+	if user == nil || user.PhoneNumber == "" {
+		return nil
+	}
+	if !user.SMSNotifications {
+		s.logger.Debug("SMS notification skipped, user opted out", "user_id", user.ID, "template", template)
+		return nil
+	}
+	return s.SendTemplate(ctx, user.PhoneNumber, template, params)
+}
+
+// enqueue writes a message to the outbox and wakes the dispatcher
+func (s *SMSService) enqueue(ctx context.Context, phone, template string, params map[string]string, ttl time.Duration) (*domain.SMSMessage, error) {
+	expires := time.Now().Add(ttl)
+	msg := &domain.SMSMessage{
+		Phone:     phone,
+		Template:  template,
+		Params:    params,
+		ExpiresAt: &expires,
+	}
+	if err := s.outboxRepo.Enqueue(ctx, msg); err != nil {
+		s.logger.Error("failed to queue SMS", "error", err, "phone", logging.MaskPhone(phone), "template", template)
+		return nil, err
+	}
+	s.notify()
+	return msg, nil
+}
+
+// StartDispatcher starts the background loop that sends queued messages
+func (s *SMSService) StartDispatcher() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.started || s.stopped {
+		return
+	}
+	s.started = true
+	go s.dispatchLoop()
+}
+
+// Shutdown stops the dispatcher after the round in progress. Messages not yet sent
+// stay queued for the next start.
+func (s *SMSService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	started := s.started && !s.stopped
+	if started {
+		close(s.stop)
+	}
+	s.stopped = true
+	s.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notify wakes the dispatcher without blocking
+func (s *SMSService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
 
-	entry, exists := s.limiter[phone]
-	now := time.Now()
+func (s *SMSService) dispatchLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(smsDispatchInterval)
+	defer ticker.Stop()
 
-	if !exists || now.Sub(entry.LastReset) > time.Hour {
-		// Reset counter for new hour or new phone
-		s.limiter[phone] = &rateEntry{
-			Attempts:  1,
-			LastReset: now,
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		// Keep going while full batches come back, so a backlog drains quickly
+		for s.dispatchBatch() == smsDispatchBatch {
+			select {
+			case <-s.stop:
+				return
+			default:
+			}
 		}
-		return true
+	}
+}
+
+// dispatchBatch sends one batch of due messages and returns how many it claimed
+func (s *SMSService) dispatchBatch() int {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	messages, err := s.outboxRepo.ClaimDue(ctx, smsDispatchBatch, smsClaimLease)
+	cancel()
+	if err != nil {
+		s.logger.Error("failed to claim queued SMS", "error", err)
+		return 0
+	}
+	for i := range messages {
+		s.dispatch(&messages[i])
+	}
+	return len(messages)
+}
+
+// dispatch sends one claimed message and records the outcome
+func (s *SMSService) dispatch(msg *domain.SMSMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), smsSendTimeout)
+	defer cancel()
+
+	if msg.ExpiresAt != nil && time.Now().After(*msg.ExpiresAt) {
+		s.markFailed(ctx, msg, "expired before it could be sent")
+		return
 	}
 
-	if entry.Attempts < s.maxPerHour {
-		entry.Attempts++
-		return true
+	receipt, err := s.send(ctx, msg)
+	if err == nil {
+		if err := s.outboxRepo.MarkSent(ctx, msg.ID, claimedAt(msg), receipt.Provider, receipt.MessageID); err != nil {
+			s.logRecordError("failed to record sent SMS", err, msg)
+		}
+		s.logger.Info("SMS sent", "sms_id", msg.ID, "phone", logging.MaskPhone(msg.Phone),
+			"provider", receipt.Provider, "attempt", msg.Attempts)
+		return
 	}
 
-	return false
+	if !sms.IsRetryable(err) || msg.Attempts >= smsMaxAttempts {
+		s.markFailed(ctx, msg, err.Error())
+		return
+	}
+	next := time.Now().Add(smsRetryDelay(msg.Attempts))
+	if msg.ExpiresAt != nil && next.After(*msg.ExpiresAt) {
+		s.markFailed(ctx, msg, err.Error())
+		return
+	}
+	if err := s.outboxRepo.MarkRetry(ctx, msg.ID, claimedAt(msg), next, err.Error()); err != nil {
+		s.logRecordError("failed to requeue SMS", err, msg)
+	}
+	s.logger.Warn("SMS send failed, will retry", "error", err, "sms_id", msg.ID,
+		"phone", logging.MaskPhone(msg.Phone), "attempt", msg.Attempts, "next_attempt", next)
 }
 
-// SendVerificationCode sends a verification code via SMS with retry logic and rate limiting
-func (s *SMSService) SendVerificationCode(ctx context.Context, phone, code string) error {
-	if !s.allowSend(phone) {
-		s.logger.Warn("SMS rate limit reached", "phone", maskPhone(phone))
-		return fmt.Errorf("too many verification codes sent. Please wait before trying again")
+// send hands the message to the provider, falling back to a stand-in template when
+// no provider has the message's own
+func (s *SMSService) send(ctx context.Context, msg *domain.SMSMessage) (sms.Receipt, error) {
+	receipt, err := s.provider.SendTemplate(ctx, msg.Phone, msg.Template, msg.Params)
+	if smsErr, ok := err.(*sms.SMSError); ok && smsErr.Type == sms.ErrTypeUnsupported {
+		if fallback, ok := smsTemplateFallbacks[msg.Template]; ok {
+			s.logger.Debug("SMS template not configured, using fallback",
+				"sms_id", msg.ID, "template", msg.Template, "fallback", fallback)
+			return s.provider.SendTemplate(ctx, msg.Phone, fallback, msg.Params)
+		}
 	}
+	return receipt, err
+}
 
-	s.logger.Info("sending SMS verification",
-		"phone", maskPhone(phone),
-		"code_length", len(code))
+func (s *SMSService) markFailed(ctx context.Context, msg *domain.SMSMessage, reason string) {
+	if err := s.outboxRepo.MarkFailed(ctx, msg.ID, claimedAt(msg), reason); err != nil {
+		s.logRecordError("failed to record failed SMS", err, msg)
+	}
+	s.logger.Error("SMS send failed", "error", reason, "sms_id", msg.ID,
+		"phone", logging.MaskPhone(msg.Phone), "attempts", msg.Attempts)
+}
 
-	// Apply retry with exponential backoff
-	retryConfig := sms.DefaultRetryConfig()
-	err := sms.RetryWithBackoff(ctx, retryConfig, func(retryCtx context.Context) error {
-		return s.provider.SendVerificationCode(retryCtx, phone, code)
-	})
+// claimedAt is the claim a dispatched message was taken with
+func claimedAt(msg *domain.SMSMessage) time.Time {
+	if msg.ClaimedAt == nil {
+		return time.Time{}
+	}
+	return *msg.ClaimedAt
+}
+
+// logRecordError logs a failure to save a send's outcome. A lost claim means the
+// message outlived its lease and was claimed again; that claim records the outcome.
+func (s *SMSService) logRecordError(text string, err error, msg *domain.SMSMessage) {
+	if errors.Is(err, outbox.ErrClaimLost) {
+		s.logger.Warn(text+": claim expired", "sms_id", msg.ID, "attempt", msg.Attempts)
+		return
+	}
+	s.logger.Error(text, "error", err, "sms_id", msg.ID)
+}
 
+// smsRetryDelay doubles the wait after each attempt, up to smsRetryMax
+func smsRetryDelay(attempt int) time.Duration {
+	delay := smsRetryBase
+	for i := 1; i < attempt && delay < smsRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > smsRetryMax {
+		delay = smsRetryMax
+	}
+	return delay
+}
+
+// HandleDeliveryReport applies the delivery reports a provider posted to its callback
+// and returns how many outbox messages they updated
+func (s *SMSService) HandleDeliveryReport(ctx context.Context, provider string, r *http.Request) (int, error) {
+	chain, ok := s.provider.(interface {
+		ParseDeliveryReports(provider string, r *http.Request) ([]sms.DeliveryReport, error)
+	})
+	if !ok {
+		return 0, &sms.SMSError{Type: sms.ErrTypeConfig, Message: "delivery reports are not supported"}
+	}
+	reports, err := chain.ParseDeliveryReports(provider, r)
 	if err != nil {
-		s.logger.Error("SMS send failed after retries", "error", err, "phone", maskPhone(phone))
-		return err
+		return 0, err
 	}
 
-	s.logger.Info("SMS sent successfully", "phone", maskPhone(phone))
-	return nil
+	updated := 0
+	for _, report := range reports {
+		status := domain.SMSStatusDelivered
+		if report.Status == sms.DeliveryFailed {
+			status = domain.SMSStatusFailed
+		}
+		n, err := s.outboxRepo.RecordDelivery(ctx, provider, report.MessageID, status, report.Detail)
+		if err != nil {
+			s.logger.Error("failed to record SMS delivery report", "error", err,
+				"provider", provider, "message_id", report.MessageID)
+			return updated, err
+		}
+		updated += int(n)
+	}
+	s.logger.Info("SMS delivery reports received", "provider", provider,
+		"reports", len(reports), "updated", updated)
+	return updated, nil
+}
+
+// HealthCheck probes the SMS provider without sending a message
+func (s *SMSService) HealthCheck(ctx context.Context) error {
+	return s.provider.HealthCheck(ctx)
 }
 
 // GetProviderStatus checks the health of the underlying SMS provider
 func (s *SMSService) GetProviderStatus(ctx context.Context) sms.ProviderStatus {
+	breakers := ""
+	if chain, ok := s.provider.(interface{ BreakerStates() map[string]string }); ok {
+		breakers = fmt.Sprintf(" (breakers: %v)", chain.BreakerStates())
+	}
 	err := s.provider.HealthCheck(ctx)
 	if err != nil {
 		return sms.ProviderStatus{
 			IsHealthy: false,
-			Message:   fmt.Sprintf("SMS provider unhealthy: %v", err),
+			Message:   fmt.Sprintf("SMS provider unhealthy: %v%s", err, breakers),
 		}
 	}
 	return sms.ProviderStatus{
 		IsHealthy: true,
-		Message:   "SMS provider healthy",
+		Message:   "SMS provider healthy" + breakers,
 	}
 }
-
-// maskPhone hides part of the phone number for logging (e.g., +1234****5678)
-func maskPhone(phone string) string {
-	if len(phone) <= 8 {
-		return "****" // fallback for very short numbers
-	}
-	return phone[:4] + "****" + phone[len(phone)-4:]
-}
\ No newline at end of file
@@ -11,6 +11,7 @@ import (
     "regexp"
     "strings"
     "time"
+    "github.com/iyunix/go-internist/internal/logging"
     "github.com/iyunix/go-internist/internal/domain"  // ← ADD THIS LINE
 
 )
@@ -20,10 +21,10 @@ type TranslationService struct {
     baseURL string
     model   string
     client  *http.Client
-    logger  Logger
+    logger  logging.Logger
 }
 
-func NewTranslationService(apiKey, model string, logger Logger) *TranslationService {
+func NewTranslationService(apiKey, model string, logger logging.Logger) *TranslationService {
     if model == "" {
         model = "gemini-2.5-flash-lite"
     }
@@ -38,6 +39,53 @@ func NewTranslationService(apiKey, model string, logger Logger) *TranslationServ
     }
 }
 
+// IsConfigured reports whether an API key was provided for translation
+func (ts *TranslationService) IsConfigured() bool {
+    return ts.apiKey != ""
+}
+
+// HealthCheck lists the provider's models to confirm the endpoint, key and
+// translation model are usable. No completion is requested, so it is free.
+func (ts *TranslationService) HealthCheck(ctx context.Context) error {
+    if !ts.IsConfigured() {
+        return fmt.Errorf("translation API key not configured")
+    }
+
+    req, err := http.NewRequestWithContext(ctx, "GET", ts.baseURL+"/models", nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Authorization", "Bearer "+ts.apiKey)
+
+    resp, err := ts.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("models endpoint returned status %d", resp.StatusCode)
+    }
+
+    var result struct {
+        Data []struct {
+            ID string `json:"id"`
+        } `json:"data"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+        return fmt.Errorf("failed to decode models response: %w", err)
+    }
+    if len(result.Data) == 0 {
+        return nil
+    }
+    for _, m := range result.Data {
+        if m.ID == ts.model {
+            return nil
+        }
+    }
+    return fmt.Errorf("translation model %q not offered by provider", ts.model)
+}
+
 func (ts *TranslationService) IsPurelyEnglish(text string) bool {
     punctuationRegex := regexp.MustCompile(`[.,!?'"()\-\s\d]+`)
     cleanText := punctuationRegex.ReplaceAllString(text, "")
@@ -75,7 +123,7 @@ func (ts *TranslationService) NeedsTranslation(text string) bool {
 }
 
 func (ts *TranslationService) TranslateToEnglish(ctx context.Context, text string) (string, error) {
-    ts.logger.Info("Starting translation", "text", text)
+    ts.logger.Debug("Starting translation", ctx, "text_length", len([]rune(text)))
 
     systemPrompt := "Translate the following Persian medical text to clear and precise English. Provide only the direct translation without any explanations, comments, or additional information."
     userPrompt := text
@@ -131,12 +179,15 @@ func (ts *TranslationService) TranslateToEnglish(ctx context.Context, text strin
     }
 
     if len(result.Choices) == 0 || strings.TrimSpace(result.Choices[0].Message.Content) == "" {
-        ts.logger.Warn("Translation API returned empty result", "original", text)
+        ts.logger.Warn("Translation API returned empty result", ctx, "original", text)
         return "", fmt.Errorf("translation returned empty result")
     }
 
     translation := strings.TrimSpace(result.Choices[0].Message.Content)
-    ts.logger.Info("Translation completed successfully", "original", text, "translated", translation)
+    ts.logger.Info("Translation completed successfully", ctx,
+        "original_length", len([]rune(text)),
+        "translated_length", len([]rune(translation)))
+    ts.logger.Debug("Translation content", ctx, "original", text, "translated", translation)
 
     return translation, nil
 }
@@ -144,13 +195,16 @@ func (ts *TranslationService) TranslateToEnglish(ctx context.Context, text strin
 // Add this NEW method to your existing translation_service.go (keep all existing methods unchanged)
 
 // UPDATED NEW METHOD - Process ALL queries (Persian + English) with context awareness
+// summary is the chat's running summary, if any; it lets the rewriter resolve references
+// to facts from turns that are no longer in the recent history.
 func (ts *TranslationService) TranslateWithMedicalContext(
     ctx context.Context,
     currentQuery string,
     conversationHistory []domain.Message,
+    summary string,
 ) (embeddingQuery string, llmQuery string, err error) {
-    ts.logger.Info("Starting context-aware processing", 
-        "current_query", currentQuery,
+    ts.logger.Debug("Starting context-aware processing", ctx,
+        "query_length", len([]rune(currentQuery)),
         "history_length", len(conversationHistory))
 
     // Step 1: Translate if needed (Persian → English)
@@ -166,6 +220,9 @@ func (ts *TranslationService) TranslateWithMedicalContext(
 
     // Step 2: Prepare conversation context (last few exchanges - max 3 pairs)
     contextMessages := ts.prepareConversationContext(conversationHistory, 3)
+    if summary != "" {
+        contextMessages = strings.TrimSpace("Case summary: " + summary + "\n" + contextMessages)
+    }
     
     // Step 3: ALWAYS generate focused embedding query (for Persian AND English queries)
     if len(contextMessages) > 0 {
@@ -182,11 +239,13 @@ func (ts *TranslationService) TranslateWithMedicalContext(
     // Step 4: LLM query is the translated current query (context added separately)
     llmQuery = translatedCurrent
 
-    ts.logger.Info("Context-aware processing completed",
-        "original", currentQuery,
-        "translated", translatedCurrent,
-        "embedding_query", embeddingQuery,
+    ts.logger.Info("Context-aware processing completed", ctx,
+        "was_translated", translatedCurrent != currentQuery,
         "embedding_length", len(embeddingQuery))
+    ts.logger.Debug("Context-aware processing content", ctx,
+        "original", currentQuery,
+        "llm_query", translatedCurrent,
+        "embedding_query", embeddingQuery)
 
     return embeddingQuery, llmQuery, nil
 }
@@ -12,20 +12,31 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/iyunix/go-internist/internal/domain"
+	"github.com/iyunix/go-internist/internal/logging"
 	"github.com/iyunix/go-internist/internal/repository/user"
 )
 
 // NOTE: The duplicate 'Logger' interface has been removed from this file.
 // It should be defined in another file in this package, like types.go.
 
+var (
+	ErrAccountSuspended      = errors.New("account suspended")
+	ErrAccountLocked         = errors.New("account temporarily locked")
+	ErrPasswordResetRequired = errors.New("password reset required")
+)
+
+// impersonationScope marks tokens that let an admin view the app as another user. They
+// are only accepted from the impersonation cookie, never as a login.
+const impersonationScope = "impersonation"
+
 type AuthService struct {
 	userRepo     user.UserRepository
 	jwtSecretKey string
 	adminPhone   string
-	logger       Logger
+	logger       logging.Logger
 }
 
-func NewAuthService(userRepo user.UserRepository, jwtSecretKey, adminPhone string, logger Logger) *AuthService {
+func NewAuthService(userRepo user.UserRepository, jwtSecretKey, adminPhone string, logger logging.Logger) *AuthService {
 	return &AuthService{
 		userRepo:     userRepo,
 		jwtSecretKey: jwtSecretKey,
@@ -60,6 +71,11 @@ func (s *AuthService) Login(ctx context.Context, identifier, password string) (*
 		return nil, "", errors.New("invalid credentials")
 	}
 
+	if userEntity.IsAccountLocked() {
+		s.logger.Warn("login attempt on locked account", "identifier", identifier, "user_id", userEntity.ID)
+		return nil, "", ErrAccountLocked
+	}
+
 	if err := bcrypt.CompareHashAndPassword([]byte(userEntity.Password), []byte(password)); err != nil {
 		s.logger.Warn("login failed - invalid password", "identifier", identifier, "user_id", userEntity.ID)
 		return nil, "", errors.New("invalid credentials")
@@ -70,6 +86,17 @@ func (s *AuthService) Login(ctx context.Context, identifier, password string) (*
 		return nil, "", errors.New("account not verified")
 	}
 
+	// Checked after the password so these states are only revealed to the account owner
+	if userEntity.IsSuspended() {
+		s.logger.Warn("login attempt by suspended user", "identifier", identifier, "user_id", userEntity.ID)
+		return nil, "", ErrAccountSuspended
+	}
+
+	if userEntity.PasswordResetRequired {
+		s.logger.Info("login blocked until password reset", "identifier", identifier, "user_id", userEntity.ID)
+		return nil, "", ErrPasswordResetRequired
+	}
+
 	token, err := s.generateJWTToken(userEntity)
 	if err != nil {
 		s.logger.Error("JWT token generation failed", "error", err, "user_id", userEntity.ID)
@@ -143,8 +170,62 @@ func (s *AuthService) validateRegistrationInput(username, phone, passwordHash st
 
 // Validate JWT token
 func (s *AuthService) ValidateJWTToken(tokenString string) (uint, error) {
+	userID, _, err := s.ParseJWTToken(tokenString)
+	return userID, err
+}
+
+// ParseJWTToken validates a login token and returns its user and issue time, so callers
+// can reject tokens issued before the user's sessions were revoked
+func (s *AuthService) ParseJWTToken(tokenString string) (uint, time.Time, error) {
+	claims, err := s.parseClaims(tokenString)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if scope, _ := claims["scope"].(string); scope != "" {
+		return 0, time.Time{}, errors.New("invalid token: not a login token")
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, time.Time{}, errors.New("invalid token claims: missing user_id")
+	}
+	return uint(userID), claimTime(claims, "iat"), nil
+}
+
+// GenerateImpersonationToken issues a short-lived token that lets adminID view the app
+// as userID
+func (s *AuthService) GenerateImpersonationToken(adminID, userID uint, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id":         userID,
+		"impersonator_id": adminID,
+		"scope":           impersonationScope,
+		"exp":             now.Add(ttl).Unix(),
+		"iat":             now.Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.jwtSecretKey))
+}
+
+// ValidateImpersonationToken returns the admin and the user they are viewing as
+func (s *AuthService) ValidateImpersonationToken(tokenString string) (adminID, userID uint, err error) {
+	claims, err := s.parseClaims(tokenString)
+	if err != nil {
+		return 0, 0, err
+	}
+	if scope, _ := claims["scope"].(string); scope != impersonationScope {
+		return 0, 0, errors.New("invalid token: not an impersonation token")
+	}
+	admin, ok1 := claims["impersonator_id"].(float64)
+	user, ok2 := claims["user_id"].(float64)
+	if !ok1 || !ok2 {
+		return 0, 0, errors.New("invalid token claims: missing user_id or impersonator_id")
+	}
+	return uint(admin), uint(user), nil
+}
+
+func (s *AuthService) parseClaims(tokenString string) (jwt.MapClaims, error) {
 	if tokenString == "" {
-		return 0, errors.New("empty token")
+		return nil, errors.New("empty token")
 	}
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -153,16 +234,20 @@ func (s *AuthService) ValidateJWTToken(tokenString string) (uint, error) {
 		return []byte(s.jwtSecretKey), nil
 	})
 	if err != nil {
-		return 0, fmt.Errorf("invalid token: %w", err)
+		return nil, fmt.Errorf("invalid token: %w", err)
 	}
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID, ok := claims["user_id"].(float64)
-		if !ok {
-			return 0, errors.New("invalid token claims: missing user_id")
-		}
-		return uint(userID), nil
+		return claims, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// claimTime reads a NumericDate claim; a missing claim is the zero time
+func claimTime(claims jwt.MapClaims, name string) time.Time {
+	if v, ok := claims[name].(float64); ok {
+		return time.Unix(int64(v), 0)
 	}
-	return 0, errors.New("invalid token")
+	return time.Time{}
 }
 
 // Generate JWT token
@@ -7,19 +7,23 @@ import (
     "fmt"
 
     "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/logging"
     "github.com/iyunix/go-internist/internal/repository/user"
 )
 
 // BalanceService handles user credit and balance management
 type BalanceService struct {
     userRepo user.UserRepository
-    logger   Logger
+    notifier Notifier
+    logger   logging.Logger
 }
 
-// NewBalanceService creates a new balance service
-func NewBalanceService(userRepo user.UserRepository, logger Logger) *BalanceService {
+// NewBalanceService creates a new balance service. The notifier sends the low balance
+// SMS; nil disables it.
+func NewBalanceService(userRepo user.UserRepository, notifier Notifier, logger logging.Logger) *BalanceService {
     return &BalanceService{
         userRepo: userRepo,
+        notifier: notifier,
         logger:   logger,
     }
 }
@@ -137,6 +141,7 @@ func (s *BalanceService) DeductCredits(ctx context.Context, userID uint, amount
         "new_balance", user.CharacterBalance,
         "remaining_percentage", calculateUsagePercentage(user.CharacterBalance, user.TotalCharacterBalance))
 
+    notifyLowBalance(ctx, s.notifier, s.logger, user, oldBalance)
     return nil
 }
 
@@ -5,6 +5,7 @@ import (
     "fmt"
     "time"
 
+    "github.com/iyunix/go-internist/internal/logging"
     "github.com/iyunix/go-internist/internal/repository/user"
 )
 
@@ -16,11 +17,11 @@ const (
 // LockoutService handles account security and brute force protection
 type LockoutService struct {
     userRepo user.UserRepository
-    logger   Logger
+    logger   logging.Logger
 }
 
 // NewLockoutService creates a new lockout service
-func NewLockoutService(userRepo user.UserRepository, logger Logger) *LockoutService {
+func NewLockoutService(userRepo user.UserRepository, logger logging.Logger) *LockoutService {
     return &LockoutService{
         userRepo: userRepo,
         logger:   logger,
@@ -0,0 +1,37 @@
+// File: internal/services/user_services/notifications.go
+package user_services
+
+import (
+    "context"
+    "strconv"
+
+    "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/logging"
+    "github.com/iyunix/go-internist/internal/services/sms"
+)
+
+// lowBalancePercent is the share of the plan's characters below which the user is told
+// their balance is running low
+const lowBalancePercent = 10
+
+// Notifier sends account notifications by SMS, skipping users who opted out
+// (services.SMSService)
+type Notifier interface {
+    Notify(ctx context.Context, user *domain.User, template string, params map[string]string) error
+}
+
+// notifyLowBalance tells the user their balance is running low when a deduction takes it
+// below the threshold. Only crossing the threshold notifies, so each refill warns once.
+func notifyLowBalance(ctx context.Context, notifier Notifier, logger logging.Logger, user *domain.User, oldBalance int) {
+    if notifier == nil {
+        return
+    }
+    threshold := user.TotalCharacterBalance * lowBalancePercent / 100
+    if oldBalance < threshold || user.CharacterBalance >= threshold {
+        return
+    }
+    params := map[string]string{"balance": strconv.Itoa(user.CharacterBalance)}
+    if err := notifier.Notify(ctx, user, sms.TemplateLowBalance, params); err != nil {
+        logger.Warn("failed to queue low balance notification", "error", err, "user_id", user.ID)
+    }
+}
@@ -6,6 +6,7 @@ import (
     "fmt"
 
     "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/logging"
     "github.com/iyunix/go-internist/internal/repository/user"
 )
 
@@ -14,15 +15,18 @@ type UserService struct {
     userRepo     user.UserRepository
     jwtSecretKey string
     adminPhone   string
-    logger       Logger
+    notifier     Notifier
+    logger       logging.Logger
 }
 
-// NewUserService creates a new user service
-func NewUserService(userRepo user.UserRepository, jwtSecretKey, adminPhone string, logger Logger) *UserService {
+// NewUserService creates a new user service. The notifier sends the low balance SMS
+// after question charges; nil disables it.
+func NewUserService(userRepo user.UserRepository, jwtSecretKey, adminPhone string, notifier Notifier, logger logging.Logger) *UserService {
     return &UserService{
         userRepo:     userRepo,
         jwtSecretKey: jwtSecretKey,
         adminPhone:   adminPhone,
+        notifier:     notifier,
         logger:       logger,
     }
 }
@@ -340,6 +344,60 @@ func (s *UserService) GetCharacterBalance(ctx context.Context, userID uint) (int
     return user.CharacterBalance, nil
 }
 
+// GetPreferredLanguage returns the user's response language preference
+func (s *UserService) GetPreferredLanguage(ctx context.Context, userID uint) (string, error) {
+    user, err := s.userRepo.FindByID(ctx, userID)
+    if err != nil {
+        return "", fmt.Errorf("failed to find user: %w", err)
+    }
+    if user.PreferredLanguage == "" {
+        return domain.LanguageAuto, nil
+    }
+    return user.PreferredLanguage, nil
+}
+
+// SetPreferredLanguage changes the language assistant answers are written in
+func (s *UserService) SetPreferredLanguage(ctx context.Context, userID uint, lang string) error {
+    if userID == 0 {
+        return errors.New("user ID must be provided")
+    }
+    if !domain.IsSupportedLanguage(lang) {
+        return fmt.Errorf("unsupported language %q", lang)
+    }
+
+    if err := s.userRepo.UpdatePreferredLanguage(ctx, userID, lang); err != nil {
+        s.logger.Error("failed to update preferred language", "error", err, "user_id", userID)
+        return fmt.Errorf("failed to update preferred language: %w", err)
+    }
+
+    s.logger.Info("preferred language updated", "user_id", userID, "language", lang)
+    return nil
+}
+
+// GetSMSNotifications reports whether the user receives account notifications by SMS
+func (s *UserService) GetSMSNotifications(ctx context.Context, userID uint) (bool, error) {
+    user, err := s.userRepo.FindByID(ctx, userID)
+    if err != nil {
+        return false, fmt.Errorf("failed to find user: %w", err)
+    }
+    return user.SMSNotifications, nil
+}
+
+// SetSMSNotifications opts the user in to or out of low balance and plan notifications
+func (s *UserService) SetSMSNotifications(ctx context.Context, userID uint, enabled bool) error {
+    if userID == 0 {
+        return errors.New("user ID must be provided")
+    }
+
+    if err := s.userRepo.UpdateSMSNotifications(ctx, userID, enabled); err != nil {
+        s.logger.Error("failed to update SMS notifications", "error", err, "user_id", userID)
+        return fmt.Errorf("failed to update SMS notifications: %w", err)
+    }
+
+    s.logger.Info("SMS notifications updated", "user_id", userID, "enabled", enabled)
+    return nil
+}
+
 // FIXED: CanUserAskQuestion - Updated signature to match chat handler expectations
 func (s *UserService) CanUserAskQuestion(ctx context.Context, userID uint, questionLength int) (bool, int, error) {
     if userID == 0 {
@@ -430,6 +488,7 @@ func (s *UserService) DeductCharactersForQuestion(ctx context.Context, userID ui
         "old_balance", oldBalance,
         "new_balance", user.CharacterBalance)
 
+    notifyLowBalance(ctx, s.notifier, s.logger, user, oldBalance)
     return chargeAmount, nil
 }
 
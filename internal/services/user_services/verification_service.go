@@ -3,47 +3,106 @@ package user_services
 
 import (
     "context"
+    "crypto/hmac"
     "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
     "errors"
     "fmt"
     "math/big"
+    "sync"
     "time"
 
     "github.com/iyunix/go-internist/internal/domain"
+    "github.com/iyunix/go-internist/internal/logging"
     "github.com/iyunix/go-internist/internal/repository/user"
     "github.com/iyunix/go-internist/internal/repository/verification"
     "github.com/iyunix/go-internist/internal/services"
     "golang.org/x/crypto/bcrypt"
 )
 
-// VerificationService handles SMS verification workflows using VerificationCode table
+var (
+    ErrCodeCooldown    = errors.New("please wait before requesting another code")
+    ErrCodeDailyLimit  = errors.New("too many codes requested for this number today, please try again tomorrow")
+    ErrCodeSendBlocked = errors.New("verification codes cannot be sent right now, please try again later")
+)
+
+// VerificationConfig holds the code and send limits
+type VerificationConfig struct {
+    CodeSecret         []byte        // HMAC key for stored codes
+    CodeTTL            time.Duration
+    ResendCooldown     time.Duration // per phone, across code types
+    DailyCap           int           // codes per phone in any 24 hours
+    PumpingWindow      time.Duration // window for the two checks below
+    MaxPhonesPerIP     int           // other numbers one client IP may ask codes for
+    MaxPhonesPerPrefix int           // other numbers in one range that may get codes
+    CleanupInterval    time.Duration
+    SendLogRetention   time.Duration
+}
+
+// DefaultVerificationConfig returns the limits used unless configured otherwise. The
+// code secret has no default and must be set.
+func DefaultVerificationConfig() *VerificationConfig {
+    return &VerificationConfig{
+        CodeTTL:            10 * time.Minute,
+        ResendCooldown:     time.Minute,
+        DailyCap:           10,
+        PumpingWindow:      time.Hour,
+        MaxPhonesPerIP:     5,
+        MaxPhonesPerPrefix: 20,
+        CleanupInterval:    15 * time.Minute,
+        SendLogRetention:   48 * time.Hour,
+    }
+}
+
+// phonePrefixDrop is how many trailing digits are dropped to get a number's range
+const phonePrefixDrop = 4
+
+// VerificationService handles SMS verification workflows using VerificationCode table.
+// Codes are stored as salted HMACs, and every send passes the limits in checkSend.
 type VerificationService struct {
+    config           *VerificationConfig
     userRepo         user.UserRepository
     verificationRepo verification.VerificationRepository
     smsService       *services.SMSService
     authService      *AuthService
-    logger           services.Logger
+    logger           logging.Logger
+
+    mu      sync.Mutex
+    stop    chan struct{}
+    done    chan struct{}
+    started bool
+    stopped bool
 }
 
-// NewVerificationService creates a new verification service
+// NewVerificationService creates a new verification service. Call StartCleanup to
+// begin removing expired codes.
 func NewVerificationService(
+    config *VerificationConfig,
     userRepo user.UserRepository, 
     verificationRepo verification.VerificationRepository,
     smsService *services.SMSService, 
     authService *AuthService, 
-    logger services.Logger,
-) *VerificationService {
+    logger logging.Logger,
+) (*VerificationService, error) {
+    if len(config.CodeSecret) == 0 {
+        return nil, errors.New("verification code secret is required")
+    }
     return &VerificationService{
+        config:           config,
         userRepo:         userRepo,
         verificationRepo: verificationRepo,
         smsService:       smsService,
         authService:      authService,
         logger:           logger,
-    }
+        stop:             make(chan struct{}),
+        done:             make(chan struct{}),
+    }, nil
 }
 
-// SendVerificationCode generates and sends a verification code to the user
-func (s *VerificationService) SendVerificationCode(ctx context.Context, userID uint) error {
+// SendVerificationCode generates and sends a verification code to the user. clientIP
+// is the requester's address, used for SMS-pumping detection.
+func (s *VerificationService) SendVerificationCode(ctx context.Context, userID uint, clientIP string) error {
     if userID == 0 {
         s.logger.Warn("verification code send attempted with invalid user ID", "user_id", userID)
         return errors.New("user ID must be provided")
@@ -62,48 +121,9 @@ func (s *VerificationService) SendVerificationCode(ctx context.Context, userID u
         return errors.New("user is already verified")
     }
 
-    // Check for existing verification code and rate limiting
-    existingCode, err := s.verificationRepo.FindByPhoneAndType(ctx, user.PhoneNumber, domain.VerificationTypeSMS)
-    if err != nil {
-        s.logger.Error("failed to check existing verification code", "error", err)
-        return fmt.Errorf("failed to check existing verification code: %w", err)
-    }
-
-    if existingCode != nil && time.Since(existingCode.CreatedAt) < time.Minute {
-        s.logger.Warn("verification code rate limited", "user_id", userID)
-        return errors.New("please wait before requesting another code")
-    }
-
-    // Delete any existing verification codes
-    if err := s.verificationRepo.DeleteByPhone(ctx, user.PhoneNumber, domain.VerificationTypeSMS); err != nil {
-        s.logger.Warn("failed to delete existing verification codes", "error", err)
-    }
-
-    // Generate new verification code
-    code, err := s.generateVerificationCode()
-    if err != nil {
-        s.logger.Error("failed to generate verification code", "error", err)
-        return fmt.Errorf("failed to generate verification code: %w", err)
-    }
-
-    // Create verification record
-    verification := &domain.VerificationCode{
-        PhoneNumber: user.PhoneNumber,
-        Code:        code,
-        Type:        domain.VerificationTypeSMS,
-        ExpiresAt:   time.Now().Add(10 * time.Minute),
-        MaxAttempts: 3,
-    }
-
-    if err := s.verificationRepo.Create(ctx, verification); err != nil {
-        s.logger.Error("failed to save verification code", "error", err, "user_id", userID)
-        return fmt.Errorf("failed to save verification code: %w", err)
-    }
-
-    // Send SMS
-    if err := s.smsService.SendVerificationCode(ctx, user.PhoneNumber, code); err != nil {
-        s.logger.Error("SMS sending failed", "error", err, "user_id", userID)
-        return fmt.Errorf("failed to send SMS: %w", err)
+    if err := s.issueCode(ctx, user.PhoneNumber, domain.VerificationTypeSMS, clientIP); err != nil {
+        s.logger.Warn("verification code not sent", "error", err, "user_id", userID)
+        return err
     }
 
     s.logger.Info("verification code sent successfully", "user_id", userID)
@@ -144,13 +164,12 @@ func (s *VerificationService) VerifyCode(ctx context.Context, userID uint, code
     }
 
     if !verification.CanAttempt() {
+        s.exhaustCode(ctx, verification)
         return errors.New("maximum verification attempts exceeded")
     }
 
     // Verify code
-    if verification.Code != code {
-        verification.IncrementAttempt()
-        s.verificationRepo.Update(ctx, verification)
+    if !s.checkCode(ctx, verification, code) {
         return errors.New("invalid verification code")
     }
 
@@ -174,67 +193,73 @@ func (s *VerificationService) VerifyCode(ctx context.Context, userID uint, code
 }
 
 // ResendVerificationCode sends a new verification code
-func (s *VerificationService) ResendVerificationCode(ctx context.Context, userID uint) error {
+func (s *VerificationService) ResendVerificationCode(ctx context.Context, userID uint, clientIP string) error {
     if userID == 0 {
         return errors.New("user ID must be provided")
     }
 
     s.logger.Info("resending verification code", "user_id", userID)
-    
-    user, err := s.userRepo.FindByID(ctx, userID)
-    if err != nil {
-        return fmt.Errorf("failed to find user: %w", err)
+    return s.SendVerificationCode(ctx, userID, clientIP)
+}
+
+// SendRegistrationCode sends a verification code to a phone number that has no
+// account yet; the registration completes once VerifyRegistrationCode accepts it
+func (s *VerificationService) SendRegistrationCode(ctx context.Context, phone, clientIP string) error {
+    if phone == "" {
+        return errors.New("phone number must be provided")
+    }
+    if err := s.issueCode(ctx, phone, domain.VerificationTypeSMS, clientIP); err != nil {
+        s.logger.Warn("registration code not sent", "error", err, "phone", logging.MaskPhone(phone))
+        return err
+    }
+    s.logger.Info("registration code sent successfully", "phone", logging.MaskPhone(phone))
+    return nil
+}
+
+// VerifyRegistrationCode checks a code sent by SendRegistrationCode and uses it up
+func (s *VerificationService) VerifyRegistrationCode(ctx context.Context, phone, code string) error {
+    if phone == "" || len(code) != 6 {
+        return errors.New("invalid input for verification")
     }
 
-    // Check rate limiting
-    existingCode, err := s.verificationRepo.FindByPhoneAndType(ctx, user.PhoneNumber, domain.VerificationTypeSMS)
-    if err == nil && existingCode != nil && time.Since(existingCode.CreatedAt) < 2*time.Minute {
-        return errors.New("please wait before requesting another code")
+    verification, err := s.verificationRepo.FindByPhoneAndType(ctx, phone, domain.VerificationTypeSMS)
+    if err != nil {
+        s.logger.Error("failed to find verification code", "error", err)
+        return fmt.Errorf("failed to find verification code: %w", err)
+    }
+    if verification == nil {
+        return errors.New("verification expired or not found")
+    }
+    if !verification.CanAttempt() {
+        s.exhaustCode(ctx, verification)
+        return errors.New("maximum verification attempts exceeded")
+    }
+    if !verification.IsValid() {
+        return errors.New("verification expired or not found")
+    }
+    if !s.checkCode(ctx, verification, code) {
+        return errors.New("invalid verification code")
     }
 
-    return s.SendVerificationCode(ctx, userID)
+    verification.UseCode()
+    s.verificationRepo.Update(ctx, verification)
+    s.verificationRepo.DeleteByPhone(ctx, phone, domain.VerificationTypeSMS)
+    return nil
 }
 
 // SendPasswordResetCode finds a user by phone, generates a reset code, and sends it
-func (s *VerificationService) SendPasswordResetCode(ctx context.Context, phone string) error {
-    s.logger.Info("password reset requested", "phone", phone)
+func (s *VerificationService) SendPasswordResetCode(ctx context.Context, phone, clientIP string) error {
+    s.logger.Info("password reset requested", "phone", logging.MaskPhone(phone))
     
     user, err := s.userRepo.FindByPhoneNumber(ctx, phone)
     if err != nil {
         // Security: Do not reveal if user exists
-        s.logger.Warn("password reset requested for non-existent phone number", "phone", phone, "error", err)
+        s.logger.Warn("password reset requested for non-existent phone number", "phone", logging.MaskPhone(phone), "error", err)
         return nil // Return success to avoid user enumeration
     }
 
-    // Delete any existing password reset codes
-    if err := s.verificationRepo.DeleteByPhone(ctx, phone, domain.VerificationTypePassword); err != nil {
-        s.logger.Warn("failed to delete existing password reset codes", "error", err)
-    }
-
-    // Generate reset code
-    code, err := s.generateVerificationCode()
-    if err != nil {
-        s.logger.Error("failed to generate password reset code", "error", err)
-        return err
-    }
-
-    // Create password reset verification record
-    verification := &domain.VerificationCode{
-        PhoneNumber: phone,
-        Code:        code,
-        Type:        domain.VerificationTypePassword,
-        ExpiresAt:   time.Now().Add(10 * time.Minute),
-        MaxAttempts: 3,
-    }
-
-    if err := s.verificationRepo.Create(ctx, verification); err != nil {
-        s.logger.Error("failed to save password reset code", "error", err, "user_id", user.ID)
-        return err
-    }
-
-    // Send SMS
-    if err := s.smsService.SendVerificationCode(ctx, user.PhoneNumber, code); err != nil {
-        s.logger.Error("failed to send password reset SMS", "error", err, "user_id", user.ID)
+    if err := s.issueCode(ctx, phone, domain.VerificationTypePassword, clientIP); err != nil {
+        s.logger.Warn("password reset code not sent", "error", err, "user_id", user.ID)
         return err
     }
 
@@ -270,12 +295,11 @@ func (s *VerificationService) VerifyPasswordResetCode(ctx context.Context, userI
     }
 
     if !verification.CanAttempt() {
+        s.exhaustCode(ctx, verification)
         return errors.New("maximum password reset attempts exceeded")
     }
 
-    if verification.Code != code {
-        verification.IncrementAttempt()
-        s.verificationRepo.Update(ctx, verification)
+    if !s.checkCode(ctx, verification, code) {
         return errors.New("invalid password reset code")
     }
 
@@ -284,24 +308,25 @@ func (s *VerificationService) VerifyPasswordResetCode(ctx context.Context, userI
 
 // VerifyAndResetPassword validates the reset code and updates the user's password
 func (s *VerificationService) VerifyAndResetPassword(ctx context.Context, phone, code, newPassword string) error {
-    s.logger.Info("attempting to reset password", "phone", phone)
+    s.logger.Info("attempting to reset password", "phone", logging.MaskPhone(phone))
     
     user, err := s.userRepo.FindByPhoneNumber(ctx, phone)
     if err != nil {
-        s.logger.Warn("password reset attempt for non-existent phone", "phone", phone, "error", err)
+        s.logger.Warn("password reset attempt for non-existent phone", "phone", logging.MaskPhone(phone), "error", err)
         return errors.New("invalid code or phone number")
     }
 
     // Find and verify password reset code
     verification, err := s.verificationRepo.FindByPhoneAndType(ctx, phone, domain.VerificationTypePassword)
     if err != nil || verification == nil {
-        s.logger.Warn("no password reset code found", "phone", phone)
+        s.logger.Warn("no password reset code found", "phone", logging.MaskPhone(phone))
         return errors.New("invalid code or phone number")
     }
 
-    if verification.Code != code {
-        s.logger.Warn("invalid password reset code provided", "user_id", user.ID)
-        return errors.New("invalid code or phone number")
+    if !verification.CanAttempt() {
+        s.exhaustCode(ctx, verification)
+        s.logger.Warn("password reset code has no attempts left", "user_id", user.ID)
+        return errors.New("maximum password reset attempts exceeded")
     }
 
     if !verification.IsValid() {
@@ -309,6 +334,11 @@ func (s *VerificationService) VerifyAndResetPassword(ctx context.Context, phone,
         return errors.New("reset code has expired")
     }
 
+    if !s.checkCode(ctx, verification, code) {
+        s.logger.Warn("invalid password reset code provided", "user_id", user.ID)
+        return errors.New("invalid code or phone number")
+    }
+
     // Hash the new password securely
     hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
     if err != nil {
@@ -317,7 +347,7 @@ func (s *VerificationService) VerifyAndResetPassword(ctx context.Context, phone,
     }
 
     user.Password = string(hashedPassword)
-
+    user.PasswordResetRequired = false
 
     // --- Add this block to verify user if not already ---
     if !user.IsVerified {
@@ -342,6 +372,243 @@ func (s *VerificationService) VerifyAndResetPassword(ctx context.Context, phone,
     return nil
 }
 
+// issueCode replaces any pending code of the type with a fresh one and queues it by
+// SMS, if the send limits allow
+func (s *VerificationService) issueCode(ctx context.Context, phone string, codeType domain.VerificationCodeType, clientIP string) error {
+    if err := s.checkSend(ctx, phone, codeType, clientIP); err != nil {
+        return err
+    }
+
+    // Delete any existing codes of this type
+    if err := s.verificationRepo.DeleteByPhone(ctx, phone, codeType); err != nil {
+        s.logger.Warn("failed to delete existing verification codes", "error", err)
+    }
+
+    code, err := s.generateVerificationCode()
+    if err != nil {
+        s.logger.Error("failed to generate verification code", "error", err)
+        return fmt.Errorf("failed to generate verification code: %w", err)
+    }
+    salt, err := generateSalt()
+    if err != nil {
+        s.logger.Error("failed to generate verification code salt", "error", err)
+        return fmt.Errorf("failed to generate verification code: %w", err)
+    }
+
+    verification := &domain.VerificationCode{
+        PhoneNumber: phone,
+        CodeHash:    s.hashCode(salt, phone, codeType, code),
+        CodeSalt:    salt,
+        Type:        codeType,
+        ExpiresAt:   time.Now().Add(s.config.CodeTTL),
+        MaxAttempts: 3,
+    }
+    if err := s.verificationRepo.Create(ctx, verification); err != nil {
+        s.logger.Error("failed to save verification code", "error", err, "phone", logging.MaskPhone(phone))
+        return fmt.Errorf("failed to save verification code: %w", err)
+    }
+
+    if codeType == domain.VerificationTypePassword {
+        err = s.smsService.SendPasswordResetCode(ctx, phone, code)
+    } else {
+        err = s.smsService.SendVerificationCode(ctx, phone, code)
+    }
+    if err != nil {
+        s.logger.Error("SMS sending failed", "error", err, "phone", logging.MaskPhone(phone))
+        return fmt.Errorf("failed to send SMS: %w", err)
+    }
+    return nil
+}
+
+// checkSend enforces the per-phone resend cooldown and daily cap, and refuses sends
+// that look like SMS pumping: one client asking codes for many different numbers, or
+// many numbers in one range getting codes. Every request is logged, refused or not.
+func (s *VerificationService) checkSend(ctx context.Context, phone string, codeType domain.VerificationCodeType, clientIP string) error {
+    now := time.Now()
+    send := &domain.VerificationSend{
+        PhoneNumber: phone,
+        Type:        codeType,
+        ClientIP:    clientIP,
+        Prefix:      phonePrefix(phone),
+    }
+
+    // The limits are read and the send recorded under a per-phone lock, so parallel
+    // requests for one number cannot all pass the cooldown before any is recorded
+    var blocked string
+    err := s.verificationRepo.WithPhoneLock(ctx, phone, func(repo verification.VerificationRepository) error {
+        var err error
+        blocked, err = s.blockReason(ctx, repo, send, now)
+        if err != nil {
+            s.logger.Error("failed to check verification send limits", "error", err, "phone", logging.MaskPhone(phone))
+            return fmt.Errorf("failed to check send limits: %w", err)
+        }
+        send.Blocked = blocked
+        if err := repo.RecordSend(ctx, send); err != nil {
+            s.logger.Error("failed to record verification send", "error", err, "phone", logging.MaskPhone(phone))
+            return fmt.Errorf("failed to record send: %w", err)
+        }
+        return nil
+    })
+    if err != nil {
+        return err
+    }
+
+    switch blocked {
+    case "":
+        return nil
+    case domain.VerificationBlockCooldown:
+        return ErrCodeCooldown
+    case domain.VerificationBlockDailyCap:
+        return ErrCodeDailyLimit
+    default:
+        s.logger.Warn("verification code blocked as possible SMS pumping",
+            "reason", blocked,
+            "phone", logging.MaskPhone(phone),
+            "prefix", send.Prefix,
+            "client_ip", clientIP)
+        return ErrCodeSendBlocked
+    }
+}
+
+// blockReason returns why the send must be refused, or "" if it may go out
+func (s *VerificationService) blockReason(ctx context.Context, repo verification.VerificationRepository, send *domain.VerificationSend, now time.Time) (string, error) {
+    last, err := repo.LastSendAt(ctx, send.PhoneNumber)
+    if err != nil {
+        return "", err
+    }
+    if last != nil && now.Sub(*last) < s.config.ResendCooldown {
+        return domain.VerificationBlockCooldown, nil
+    }
+
+    sent, err := repo.CountSends(ctx, send.PhoneNumber, now.Add(-24*time.Hour))
+    if err != nil {
+        return "", err
+    }
+    if s.config.DailyCap > 0 && sent >= int64(s.config.DailyCap) {
+        return domain.VerificationBlockDailyCap, nil
+    }
+
+    since := now.Add(-s.config.PumpingWindow)
+    if send.ClientIP != "" && s.config.MaxPhonesPerIP > 0 {
+        phones, err := repo.CountPhonesByIP(ctx, send.ClientIP, send.PhoneNumber, since)
+        if err != nil {
+            return "", err
+        }
+        if phones >= int64(s.config.MaxPhonesPerIP) {
+            return domain.VerificationBlockIPPumping, nil
+        }
+    }
+    if send.Prefix != "" && s.config.MaxPhonesPerPrefix > 0 {
+        phones, err := repo.CountPhonesByPrefix(ctx, send.Prefix, send.PhoneNumber, since)
+        if err != nil {
+            return "", err
+        }
+        if phones >= int64(s.config.MaxPhonesPerPrefix) {
+            return domain.VerificationBlockPrefix, nil
+        }
+    }
+    return "", nil
+}
+
+// checkCode compares the code with the stored HMAC in constant time, counting a
+// failed attempt on mismatch and using the code up once no attempts are left
+func (s *VerificationService) checkCode(ctx context.Context, verification *domain.VerificationCode, code string) bool {
+    want, err := hex.DecodeString(verification.CodeHash)
+    got, _ := hex.DecodeString(s.hashCode(verification.CodeSalt, verification.PhoneNumber, verification.Type, code))
+    if err == nil && hmac.Equal(got, want) {
+        return true
+    }
+    verification.IncrementAttempt()
+    if !verification.CanAttempt() {
+        verification.UseCode()
+    }
+    s.verificationRepo.Update(ctx, verification)
+    return false
+}
+
+// exhaustCode marks a code with no attempts left as used, so only a new code can
+// complete the verification
+func (s *VerificationService) exhaustCode(ctx context.Context, verification *domain.VerificationCode) {
+    if verification.IsUsed {
+        return
+    }
+    verification.UseCode()
+    if err := s.verificationRepo.Update(ctx, verification); err != nil {
+        s.logger.Error("failed to mark exhausted verification code as used", "error", err)
+    }
+}
+
+// hashCode binds the code to its phone number and type, so a stored hash cannot be
+// replayed for another number or purpose
+func (s *VerificationService) hashCode(salt, phone string, codeType domain.VerificationCodeType, code string) string {
+    mac := hmac.New(sha256.New, s.config.CodeSecret)
+    for _, part := range []string{salt, phone, string(codeType), code} {
+        mac.Write([]byte(part))
+        mac.Write([]byte{0})
+    }
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+// StartCleanup starts the background job that deletes expired codes and prunes the
+// send log
+func (s *VerificationService) StartCleanup() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.started || s.stopped {
+        return
+    }
+    s.started = true
+    go s.cleanupLoop()
+}
+
+// Shutdown stops the cleanup job
+func (s *VerificationService) Shutdown(ctx context.Context) error {
+    s.mu.Lock()
+    started := s.started && !s.stopped
+    if started {
+        close(s.stop)
+    }
+    s.stopped = true
+    s.mu.Unlock()
+    if !started {
+        return nil
+    }
+
+    select {
+    case <-s.done:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+func (s *VerificationService) cleanupLoop() {
+    defer close(s.done)
+    ticker := time.NewTicker(s.config.CleanupInterval)
+    defer ticker.Stop()
+
+    s.cleanup()
+    for {
+        select {
+        case <-s.stop:
+            return
+        case <-ticker.C:
+            s.cleanup()
+        }
+    }
+}
+
+func (s *VerificationService) cleanup() {
+    ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+    defer cancel()
+    if err := s.verificationRepo.DeleteExpired(ctx); err != nil {
+        s.logger.Warn("failed to delete expired verification codes", "error", err)
+    }
+    if err := s.verificationRepo.DeleteSendsBefore(ctx, time.Now().Add(-s.config.SendLogRetention)); err != nil {
+        s.logger.Warn("failed to prune verification send log", "error", err)
+    }
+}
+
 // generateVerificationCode creates a secure 6-digit verification code
 func (s *VerificationService) generateVerificationCode() (string, error) {
     // Use crypto/rand for secure random generation
@@ -352,3 +619,20 @@ func (s *VerificationService) generateVerificationCode() (string, error) {
     }
     return fmt.Sprintf("%06d", n.Int64()), nil
 }
+
+// generateSalt returns a random per-code salt
+func generateSalt() (string, error) {
+    salt := make([]byte, 16)
+    if _, err := rand.Read(salt); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(salt), nil
+}
+
+// phonePrefix returns the number's range, e.g. 0912345 for 09123456789
+func phonePrefix(phone string) string {
+    if len(phone) <= phonePrefixDrop+3 {
+        return ""
+    }
+    return phone[:len(phone)-phonePrefixDrop]
+}
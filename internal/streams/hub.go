@@ -0,0 +1,234 @@
+// File: internal/streams/hub.go
+package streams
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config controls how long finished streams stay replayable.
+type Config struct {
+	Retention       time.Duration // keep finished streams this long for reconnects
+	MaxEvents       int           // hard cap on buffered events per stream, closing events included
+	CleanupInterval time.Duration
+}
+
+// DefaultConfig returns settings suited to mobile reconnects.
+func DefaultConfig() *Config {
+	return &Config{
+		Retention:       5 * time.Minute,
+		MaxEvents:       20000,
+		CleanupInterval: time.Minute,
+	}
+}
+
+// Event is one SSE frame. Name is empty for the default "message" event.
+type Event struct {
+	Seq  uint64
+	Name string
+	Data []byte
+}
+
+// Stream buffers the events of one generation so that clients can replay
+// them after a dropped connection.
+type Stream struct {
+	ID     string
+	UserID uint
+	ChatID uint
+
+	mu         sync.Mutex
+	events     []Event
+	nextSeq    uint64
+	done       bool
+	truncated  bool
+	finishedAt time.Time
+	notify     chan struct{}
+	maxEvents  int
+}
+
+// EventID renders the SSE id for an event of this stream.
+func (s *Stream) EventID(seq uint64) string {
+	return s.ID + "-" + strconv.FormatUint(seq, 10)
+}
+
+// closingHeadroom is the part of MaxEvents that progress events may not use, so the
+// events that end a stream (final sources, complete, error, cancelled, done) still
+// fit after a long answer has filled the buffer with deltas.
+const closingHeadroom = 16
+
+// TruncatedEvent is published once, in place of the first dropped progress event, so a
+// client knows the deltas it holds are incomplete. The publisher then sends the whole
+// answer in a "final" event when it finishes.
+const TruncatedEvent = "truncated"
+
+// isProgressEvent reports whether an event is an answer delta or a status update,
+// the events a stream produces many of
+func isProgressEvent(name string) bool {
+	return name == "" || name == "status"
+}
+
+// Publish appends an event and wakes all subscribers. Events published after
+// Close are dropped, as is any event beyond MaxEvents. Progress events are dropped
+// once the buffer is within closingHeadroom of MaxEvents; the first one dropped is
+// replaced by a TruncatedEvent.
+func (s *Stream) Publish(name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+	if isProgressEvent(name) && len(s.events) >= s.maxEvents-closingHeadroom {
+		if s.truncated {
+			return
+		}
+		s.truncated = true
+		name, data = TruncatedEvent, []byte(`{"type":"truncated"}`)
+	}
+	if len(s.events) >= s.maxEvents {
+		return
+	}
+	s.nextSeq++
+	s.events = append(s.events, Event{Seq: s.nextSeq, Name: name, Data: data})
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// Truncated reports whether progress events were dropped, so the finished answer has
+// to be sent in full.
+func (s *Stream) Truncated() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.truncated
+}
+
+// Close marks the stream finished; it remains replayable until retention expires.
+func (s *Stream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+	s.done = true
+	s.finishedAt = time.Now()
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// Since returns events with Seq > after, whether the stream is finished, and a
+// channel that is closed on the next Publish or Close.
+func (s *Stream) Since(after uint64) ([]Event, bool, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Seq values are contiguous starting at 1, so the index is Seq-1
+	start := int(after)
+	if start > len(s.events) {
+		start = len(s.events)
+	}
+	out := make([]Event, len(s.events)-start)
+	copy(out, s.events[start:])
+	return out, s.done, s.notify
+}
+
+// Hub tracks live and recently finished streams in memory.
+type Hub struct {
+	config  *Config
+	mu      sync.RWMutex
+	streams map[string]*Stream
+	stopCh  chan struct{}
+}
+
+// NewHub creates a hub and starts its cleanup loop.
+func NewHub(config *Config) *Hub {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	h := &Hub{
+		config:  config,
+		streams: make(map[string]*Stream),
+		stopCh:  make(chan struct{}),
+	}
+	go h.cleanupLoop()
+	return h
+}
+
+// Create registers a new stream for the given user and chat.
+func (h *Hub) Create(userID, chatID uint) *Stream {
+	s := &Stream{
+		ID:        newStreamID(),
+		UserID:    userID,
+		ChatID:    chatID,
+		notify:    make(chan struct{}),
+		maxEvents: h.config.MaxEvents,
+	}
+	h.mu.Lock()
+	h.streams[s.ID] = s
+	h.mu.Unlock()
+	return s
+}
+
+// Get returns a stream by ID if it is still retained.
+func (h *Hub) Get(id string) (*Stream, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	s, ok := h.streams[id]
+	return s, ok
+}
+
+// Close stops the cleanup loop.
+func (h *Hub) Close() {
+	close(h.stopCh)
+}
+
+func (h *Hub) cleanupLoop() {
+	ticker := time.NewTicker(h.config.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.cleanup()
+		case <-h.stopCh:
+			return
+		}
+	}
+}
+
+func (h *Hub) cleanup() {
+	cutoff := time.Now().Add(-h.config.Retention)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, s := range h.streams {
+		s.mu.Lock()
+		expired := s.done && s.finishedAt.Before(cutoff)
+		s.mu.Unlock()
+		if expired {
+			delete(h.streams, id)
+		}
+	}
+}
+
+// ParseEventID splits a Last-Event-ID value into stream ID and sequence.
+func ParseEventID(value string) (string, uint64, error) {
+	idx := strings.LastIndex(value, "-")
+	if idx <= 0 || idx == len(value)-1 {
+		return "", 0, fmt.Errorf("malformed event id %q", value)
+	}
+	seq, err := strconv.ParseUint(value[idx+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed event id %q: %w", value, err)
+	}
+	return value[:idx], seq, nil
+}
+
+func newStreamID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
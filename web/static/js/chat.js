@@ -128,9 +128,9 @@ function createMessageHTML(msg) {
             <div class="message-content rounded-lg rounded-tl-none bg-gray-100 p-3 text-base text-[#1e293b]">
               <div class="prose prose-lg">${sanitizeHTML(msg.content)}</div>
             </div>
-            <button onclick="exportMessageAsPDF(this.parentElement.querySelector('.message-content').innerHTML)"
+            <button onclick="exportChatAsPDF()"
                     class="absolute top-1 right-1 opacity-0 group-hover:opacity-100 transition-opacity bg-white p-1 rounded-full shadow-sm hover:bg-gray-200"
-                    title="Export as PDF">
+                    title="Export conversation as PDF">
               <span class="material-symbols-outlined text-base text-gray-600">picture_as_pdf</span>
             </button>
           </div>
@@ -222,11 +222,11 @@ async function sendMessage(prompt) {
 // MODIFIED: This function now ONLY creates the chat and updates the state. It does NOT reload the page.
 async function startNewChat(prompt) {
   try {
-    const title = prompt.length > 50 ? prompt.slice(0, 50) + "..." : prompt;
+    // No title: the server names the chat after the first answer
     const resp = await fetch("/api/chats", {
       method: "POST",
       headers: { "Content-Type": "application/json" },
-      body: JSON.stringify({ title }),
+      body: JSON.stringify({}),
     });
     if (!resp.ok) throw new Error("Server failed to create chat");
     const newChat = await resp.json();
@@ -316,7 +316,25 @@ async function streamAssistantResponse(chatId, prompt) {
   
   if (currentEventSource) currentEventSource.close();
   
-  currentEventSource = new EventSource(`/api/chats/${chatId}/stream?q=${encodeURIComponent(prompt)}`);
+  // Queue the job first: generation runs server-side even if this tab closes,
+  // and the prompt travels in the request body rather than the URL.
+  let job;
+  try {
+    const resp = await fetch(`/api/chats/${chatId}/ask`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ prompt }),
+    });
+    if (!resp.ok) throw new Error((await resp.text()) || `HTTP ${resp.status}`);
+    job = await resp.json();
+  } catch (err) {
+    console.error(err);
+    if (assistantContent()) assistantContent().innerHTML = '<p class="text-red-500">Sorry, an error occurred.</p>';
+    enableInput(true);
+    return;
+  }
+
+  currentEventSource = new EventSource(job.streamUrl);
   
   currentEventSource.addEventListener("status", e => {
     const data = JSON.parse(e.data);
@@ -342,39 +360,55 @@ async function streamAssistantResponse(chatId, prompt) {
     }
   };
 
+  // Sent on reconnect when the server no longer buffers the stream: full saved answer
+  currentEventSource.addEventListener("final", e => {
+    const data = JSON.parse(e.data);
+    const contentDiv = assistantContent();
+    fullContent = data.content || "";
+    firstChunk = false;
+    if (contentDiv) {
+      contentDiv.innerHTML = sanitizeHTML(fullContent);
+      messageContainer.scrollTop = messageContainer.scrollHeight;
+    }
+  });
+
+  // The answer outgrew the server's stream buffer: later deltas are dropped and the
+  // whole answer follows in a "final" event once it is finished
+  currentEventSource.addEventListener("truncated", () => {
+    const contentDiv = assistantContent();
+    if (contentDiv) {
+      contentDiv.innerHTML = sanitizeHTML(fullContent) + '<p class="text-gray-500">…</p>';
+    }
+  });
+
+  // Carries the generated title once the chat's first answer is saved
+  currentEventSource.addEventListener("complete", e => {
+    const data = JSON.parse(e.data);
+    if (data.chatTitle) insertOrUpdateChatInSidebar({ id: chatId, title: data.chatTitle });
+  });
+
   currentEventSource.addEventListener("done", () => {
     if (currentEventSource) currentEventSource.close();
     enableInput(true);
   });
 
-  currentEventSource.onerror = () => {
+  currentEventSource.onerror = e => {
+    // Dropped connection: the browser reconnects with Last-Event-ID and the
+    // server replays missed frames without charging again.
+    if (!e.data && currentEventSource && currentEventSource.readyState === EventSource.CONNECTING) {
+      return;
+    }
     if (currentEventSource) currentEventSource.close();
     if (assistantContent()) assistantContent().innerHTML = '<p class="text-red-500">Sorry, an error occurred.</p>';
     enableInput(true);
   };
 }
 
-// ===== PDF Export (unchanged) =====
-async function exportMessageAsPDF(messageHTML) {
-  try {
-    const resp = await fetch("/static/pdf_template.html");
-    if (!resp.ok) throw new Error("PDF template not found");
-    const template = await resp.text();
-    const win = window.open("", "_blank", "height=800,width=800");
-    if (!win) return alert("Please allow popups for PDF export");
-    win.document.write(template);
-    win.document.close();
-    win.onload = () => {
-      const dateEl = win.document.getElementById("generation-date");
-      const contentEl = win.document.getElementById("ai-content-container");
-      if (dateEl) dateEl.textContent = new Date().toLocaleString();
-      if (contentEl) contentEl.innerHTML = DOMPurify.sanitize(messageHTML);
-      setTimeout(() => { win.print(); win.close(); }, 250);
-    };
-  } catch (err) {
-    console.error(err);
-    alert("Could not generate PDF.");
-  }
+// ===== Export =====
+// The server renders the whole conversation (with sources) as a PDF download
+function exportChatAsPDF() {
+  if (!activeChatID) return;
+  window.location.href = `/api/chats/${activeChatID}/export?format=pdf`;
 }
 
 // ===== Initial Load =====